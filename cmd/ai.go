@@ -3,13 +3,13 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
+	"io"
 	"strings"
 	"time"
 	"pltf/pkg/ai"
 )
 
-func maybeAICritique(run tfRunSummary) string {
+func maybeAICritique(w io.Writer, run tfRunSummary) string {
 	if run.Plan == nil || strings.ToLower(run.Action) != "plan" {
 		return ""
 	}
@@ -29,24 +29,16 @@ func maybeAICritique(run tfRunSummary) string {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	summary := fmt.Sprintf("adds: %d, changes: %d, destroys: %d", run.Plan.Added, run.Plan.Changed, run.Plan.Destroyed)
+	summary := "plan: " + run.Plan.Counts.String()
 	var b strings.Builder
-	if len(run.Plan.Adds) > 0 {
-		b.WriteString("\nadds:\n")
-		for _, a := range run.Plan.Adds {
-			b.WriteString("- " + a + "\n")
+	// Changes are grouped by pltf module so the review can refer to spec modules.
+	for _, m := range run.Plan.Modules {
+		fmt.Fprintf(&b, "\nmodule %s: %s\n", run.Plan.moduleLabel(m.ID), m.Counts)
+		for _, rc := range m.Resources {
+			b.WriteString("- " + describeChange(rc) + "\n")
 		}
-	}
-	if len(run.Plan.Changes) > 0 {
-		b.WriteString("\nchanges:\n")
-		for _, a := range run.Plan.Changes {
-			b.WriteString("- " + a + "\n")
-		}
-	}
-	if len(run.Plan.Deletes) > 0 {
-		b.WriteString("\ndestroys:\n")
-		for _, a := range run.Plan.Deletes {
-			b.WriteString("- " + a + "\n")
+		for _, rc := range m.Drift {
+			fmt.Fprintf(&b, "- drift: %s changed outside Terraform\n", rc.Address)
 		}
 	}
 	if strings.TrimSpace(run.Plan.Text) != "" {
@@ -56,7 +48,7 @@ func maybeAICritique(run tfRunSummary) string {
 
 	critique, err := provider.Critique(ctx, summary, b.String())
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "warn: AI critique failed: %v\n", err)
+		fmt.Fprintf(w, "warn: AI critique failed: %v\n", err)
 		return ""
 	}
 	return critique
@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"pltf/pkg/config"
+)
+
+// stackGroup is an Environment and the Services whose metadata.ref resolves to it.
+type stackGroup struct {
+	env      specStack
+	services []specStack
+}
+
+// findStackGroup loads the Environment spec file together with the specs under specsDir (the
+// directory of file by default) and returns the Environment with every Service that references it.
+// Only errors in those specs fail; errors in the other specs of the directory are reported to errOut
+// as warnings.
+func findStackGroup(errOut io.Writer, file, specsDir string) (stackGroup, error) {
+	var g stackGroup
+	if info, err := os.Stat(file); err == nil && info.IsDir() {
+		return g, fmt.Errorf("--all needs -f to name an Environment spec file, got directory %s", file)
+	}
+	specsDir = defaultString(specsDir, filepath.Dir(file))
+	set, ds, err := config.LoadSpecSet(file, specsDir)
+	if err != nil {
+		return g, err
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return g, err
+	}
+	var envs []config.SpecEntry
+	for _, e := range set.Environments() {
+		if abs, _ := filepath.Abs(e.File); abs == absFile {
+			envs = append(envs, e)
+		}
+	}
+	if len(envs) != 1 {
+		config.RenderDiagnosticsText(errOut, ds)
+		if n := len(ds.Errors()); n > 0 && len(envs) == 0 {
+			return g, fmt.Errorf("spec set %s has %d error(s)", file, n)
+		}
+		return g, fmt.Errorf("--all needs -f to name a file holding one Environment spec; %s holds %d", file, len(envs))
+	}
+
+	group := append(config.Diagnostics{}, envs[0].Diagnostics...)
+	g.env = newSpecStack(envs[0])
+	for _, e := range set.Services() {
+		if e.ServiceEnv != nil && e.ServiceEnv == envs[0].Environment {
+			g.services = append(g.services, newSpecStack(e))
+			group = append(group, e.Diagnostics...)
+		}
+	}
+	others := unrelatedDiagnostics(ds, group)
+	if n := len(others.Errors()); n > 0 {
+		fmt.Fprintf(errOut, "warn: %d error(s) in specs that do not belong to Environment %q; they are not run:\n", n, g.env.name())
+		config.RenderDiagnosticsText(errOut, asWarnings(others.Errors()))
+	}
+	if len(group) > 0 {
+		group.Sort()
+		config.RenderDiagnosticsText(errOut, group)
+	}
+	if n := len(group.Errors()); n > 0 {
+		return g, fmt.Errorf("environment %q and its services have %d error(s)", g.env.name(), n)
+	}
+	return g, nil
+}
+
+// unrelatedDiagnostics returns the diagnostics of ds that are not in group.
+func unrelatedDiagnostics(ds, group config.Diagnostics) config.Diagnostics {
+	seen := map[config.Diagnostic]int{}
+	for _, d := range group {
+		seen[d]++
+	}
+	var out config.Diagnostics
+	for _, d := range ds {
+		if seen[d] > 0 {
+			seen[d]--
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// asWarnings returns a copy of ds with every diagnostic reported as a warning.
+func asWarnings(ds config.Diagnostics) config.Diagnostics {
+	out := make(config.Diagnostics, len(ds))
+	for i, d := range ds {
+		d.Severity = config.SeverityWarning
+		out[i] = d
+	}
+	return out
+}
+
+// stackRunner runs a Terraform action against one stack of a group. log receives the stack's
+// output when it runs next to others; nil means the terminal.
+type stackRunner struct {
+	// prepare renders the stack; stacks are prepared one at a time, in order.
+	prepare func(s specStack, env string) error
+	run     func(s specStack, env string, log io.Writer) error
+}
+
+// stackResult is the outcome of one stack of a group run.
+type stackResult struct {
+	stack   specStack
+	env     string
+	status  string // succeeded, failed or skipped
+	reason  string
+	elapsed time.Duration
+}
+
+// runStackGroup runs action against the Environment and its Services. For plan and apply the
+// Environment goes first and the Services follow once it succeeded; destroy runs in reverse, tearing
+// the Environment down only once every Service is gone. Up to jobs Services run at a time, with their
+// output buffered and printed whole when each one finishes.
+func runStackGroup(out io.Writer, action string, g stackGroup, env string, jobs int, r stackRunner) ([]stackResult, error) {
+	envName, ok, err := stackEnv(g.env, env)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("environment %q is not defined in %s", envName, g.env.label)
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	envResult := stackResult{stack: g.env, env: envName}
+	results := make([]stackResult, len(g.services))
+	var pending []int
+	for i, s := range g.services {
+		results[i] = stackResult{stack: s, env: envName}
+		if !s.hasEnv(envName) {
+			results[i].status, results[i].reason = "skipped", fmt.Sprintf("no environment %q", envName)
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	runEnv := func() {
+		fmt.Fprintf(out, "==> %s %s %q (env=%s) from %s\n", titleCase(action), g.env.kind, g.env.name(), envName, g.env.label)
+		envResult = runGroupStack(r, envResult, nil)
+	}
+	runServices := func() {
+		for _, i := range pending {
+			if err := r.prepare(results[i].stack, envName); err != nil {
+				results[i].status, results[i].reason = "failed", err.Error()
+			}
+		}
+		var (
+			wg  sync.WaitGroup
+			mu  sync.Mutex
+			sem = make(chan struct{}, jobs)
+		)
+		for _, i := range pending {
+			if results[i].status != "" {
+				continue
+			}
+			s := results[i].stack
+			header := fmt.Sprintf("==> %s %s %q (env=%s) from %s\n", titleCase(action), s.kind, s.name(), envName, s.label)
+			if jobs == 1 {
+				fmt.Fprint(out, header)
+				results[i] = runGroupStack(r, results[i], nil)
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				var buf bytes.Buffer
+				res := runGroupStack(r, results[i], &buf)
+				mu.Lock()
+				defer mu.Unlock()
+				results[i] = res
+				fmt.Fprint(out, header)
+				out.Write(buf.Bytes())
+			}(i)
+		}
+		wg.Wait()
+	}
+	skipServices := func(reason string) {
+		for _, i := range pending {
+			if results[i].status == "" {
+				results[i].status, results[i].reason = "skipped", reason
+			}
+		}
+	}
+
+	if action == "destroy" {
+		runServices()
+		failed := 0
+		for _, res := range results {
+			if res.status == "failed" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			envResult.status, envResult.reason = "skipped", fmt.Sprintf("%d service(s) failed to destroy", failed)
+		} else if err := r.prepare(g.env, envName); err != nil {
+			envResult.status, envResult.reason = "failed", err.Error()
+		} else {
+			runEnv()
+		}
+		return append(results, envResult), nil
+	}
+
+	if err := r.prepare(g.env, envName); err != nil {
+		envResult.status, envResult.reason = "failed", err.Error()
+	} else {
+		runEnv()
+	}
+	if envResult.status == "failed" {
+		skipServices(fmt.Sprintf("Environment %q failed", g.env.name()))
+	} else {
+		runServices()
+	}
+	return append([]stackResult{envResult}, results...), nil
+}
+
+func runGroupStack(r stackRunner, res stackResult, log io.Writer) stackResult {
+	start := time.Now()
+	err := r.run(res.stack, res.env, log)
+	res.elapsed = time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		res.status, res.reason = "failed", err.Error()
+		return res
+	}
+	res.status = "succeeded"
+	return res
+}
+
+// reportStackResults prints a table of the group run and fails when any stack failed.
+func reportStackResults(out io.Writer, action string, results []stackResult) error {
+	counts := map[string]int{}
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STACK\tKIND\tENV\tSTATUS\tDURATION")
+	for _, res := range results {
+		counts[res.status]++
+		elapsed := "-"
+		if res.status != "skipped" {
+			elapsed = res.elapsed.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", res.stack.name(), res.stack.kind, res.env, res.status, elapsed)
+	}
+	tw.Flush()
+	fmt.Fprintf(out, "%s: %d succeeded, %d failed, %d skipped\n", titleCase(action), counts["succeeded"], counts["failed"], counts["skipped"])
+
+	var failed []string
+	for _, res := range results {
+		if res.status != "succeeded" && res.reason != "" {
+			fmt.Fprintf(out, "  %s %s: %s\n", res.stack.label, res.status, res.reason)
+		}
+		if res.status == "failed" {
+			failed = append(failed, res.stack.label)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed for %d stack(s): %s", action, len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// runTfAll generates and runs action against the Environment spec file and every Service that
+// references it. outRoot replaces the .pltf root of the standard layout.
+func runTfAll(out io.Writer, action, file, specsDir, env, modulesRoot, outRoot string, vars []string, jobs int, opts tfExecOpts) error {
+	if strings.TrimSpace(opts.planFile) != "" {
+		return fmt.Errorf("--plan-file cannot be used with --all; each stack writes its own plan")
+	}
+	if opts.rover {
+		return fmt.Errorf("--rover cannot be used with --all; it serves one plan and blocks until stopped")
+	}
+	if jobs > 1 && (action == "apply" || action == "destroy") && !opts.autoApprove {
+		return fmt.Errorf("--all with --jobs > 1 needs --auto-approve (or --jobs 1); Terraform cannot prompt for several stacks at once")
+	}
+	g, err := findStackGroup(os.Stderr, defaultString(file, "env.yaml"), specsDir)
+	if err != nil {
+		return err
+	}
+	cliVars, err := parseVarFlags(vars)
+	if err != nil {
+		return err
+	}
+	embeddedRoot, customRoot, err := resolveModuleRoots(modulesRoot)
+	if err != nil {
+		return err
+	}
+
+	r := stackRunner{
+		prepare: func(s specStack, env string) error {
+			_, err := s.generate(embeddedRoot, customRoot, env, filepath.Clean(s.outDir(outRoot, env)), cliVars)
+			return err
+		},
+		run: func(s specStack, env string, log io.Writer) error {
+			ctx := s.stackContext(env, filepath.Clean(s.outDir(outRoot, env)))
+			ctx.log = log
+			return runTfInStack(action, s.label, ctx, "", opts)
+		},
+	}
+	fmt.Fprintf(out, "Found Environment %q and %d service(s) referencing it\n", g.env.name(), len(g.services))
+	results, err := runStackGroup(out, action, g, env, jobs, r)
+	if err != nil {
+		return err
+	}
+	return reportStackResults(out, action, results)
+}
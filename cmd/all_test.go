@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeStackGroupSpecs(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	env := func(name string) string {
+		return `apiVersion: platform.io/v1
+kind: Environment
+metadata:
+  name: ` + name + `
+  org: acme
+  provider: aws
+environments:
+  dev:
+    account: "111111111111"
+    region: us-east-1
+  prod:
+    account: "222222222222"
+    region: us-west-2
+modules:
+  - id: base
+    type: aws_base
+`
+	}
+	svc := func(name, ref, envs string) string {
+		return `apiVersion: platform.io/v1
+kind: Service
+metadata:
+  name: ` + name + `
+  ref: ` + ref + `
+  envRef:
+` + envs + `modules:
+  - id: bucket
+    type: aws_s3
+    inputs:
+      bucket_name: ` + name + `-data
+`
+	}
+	files := map[string]string{
+		"env.yaml":       env("shared"),
+		"other.yaml":     env("other"),
+		"services.yaml":  svc("api", "shared", "    dev: {}\n    prod: {}\n") + "---\n" + svc("worker", "shared", "    dev: {}\n") + "---\n" + svc("billing", "shared", "    dev: {}\n"),
+		"elsewhere.yaml": svc("ledger", "other", "    dev: {}\n"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// fakeStackRunner records the order stacks run in and how many ran at once.
+type fakeStackRunner struct {
+	mu      sync.Mutex
+	order   []string
+	running int
+	peak    int
+	fail    map[string]bool
+}
+
+func (f *fakeStackRunner) runner() stackRunner {
+	return stackRunner{
+		prepare: func(s specStack, env string) error { return nil },
+		run: func(s specStack, env string, log io.Writer) error {
+			f.mu.Lock()
+			f.order = append(f.order, s.name())
+			f.running++
+			if f.running > f.peak {
+				f.peak = f.running
+			}
+			f.mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			f.mu.Lock()
+			f.running--
+			f.mu.Unlock()
+			if f.fail[s.name()] {
+				return fmt.Errorf("terraform failed for %s", s.name())
+			}
+			return nil
+		},
+	}
+}
+
+func TestFindStackGroupCollectsReferencingServices(t *testing.T) {
+	resetProfileCache()
+	dir := writeStackGroupSpecs(t)
+
+	g, err := findStackGroup(io.Discard, filepath.Join(dir, "env.yaml"), "")
+	if err != nil {
+		t.Fatalf("findStackGroup: %v", err)
+	}
+	var names []string
+	for _, s := range g.services {
+		names = append(names, s.name())
+	}
+	if g.env.name() != "shared" || strings.Join(names, ",") != "api,worker,billing" {
+		t.Fatalf("unexpected group: env=%s services=%v", g.env.name(), names)
+	}
+
+	if _, err := findStackGroup(io.Discard, dir, ""); err == nil {
+		t.Fatalf("expected a directory to be rejected")
+	}
+}
+
+func TestFindStackGroupOnlyFailsOnItsOwnSpecs(t *testing.T) {
+	resetProfileCache()
+	dir := writeStackGroupSpecs(t)
+	broken := func(name, ref string) string {
+		return "apiVersion: platform.io/v1\nkind: Service\nmetadata:\n  name: " + name + "\n  ref: " + ref + "\n  envRef:\n    dev: {}\nmodules:\n  - id: bucket\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte(broken("ledger2", "other")), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var errOut bytes.Buffer
+	g, err := findStackGroup(&errOut, filepath.Join(dir, "env.yaml"), "")
+	if err != nil {
+		t.Fatalf("expected errors in another Environment's service not to fail, got %v", err)
+	}
+	if len(g.services) != 3 || !strings.Contains(errOut.String(), "warn: 1 error(s) in specs that do not belong to Environment \"shared\"") || strings.Contains(errOut.String(), "error:") {
+		t.Fatalf("expected the unrelated error as a warning, got:\n%s", errOut.String())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte(broken("ledger2", "shared")), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := findStackGroup(io.Discard, filepath.Join(dir, "env.yaml"), ""); err == nil || !strings.Contains(err.Error(), `environment "shared" and its services have 1 error(s)`) {
+		t.Fatalf("expected an error in a referencing service to fail, got %v", err)
+	}
+}
+
+func TestRunStackGroupOrdersAndBoundsStacks(t *testing.T) {
+	resetProfileCache()
+	dir := writeStackGroupSpecs(t)
+	g, err := findStackGroup(io.Discard, filepath.Join(dir, "env.yaml"), "")
+	if err != nil {
+		t.Fatalf("findStackGroup: %v", err)
+	}
+
+	f := &fakeStackRunner{}
+	var buf bytes.Buffer
+	results, err := runStackGroup(&buf, "apply", g, "dev", 2, f.runner())
+	if err != nil {
+		t.Fatalf("runStackGroup: %v", err)
+	}
+	if f.order[0] != "shared" || len(f.order) != 4 {
+		t.Fatalf("expected the Environment to apply first, got %v", f.order)
+	}
+	if f.peak != 2 {
+		t.Fatalf("expected 2 services to run at once, got %d", f.peak)
+	}
+	if err := reportStackResults(&buf, "apply", results); err != nil {
+		t.Fatalf("reportStackResults: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Apply: 4 succeeded, 0 failed, 0 skipped") {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+
+	// A failed Environment skips its Services; worker and billing have no prod environment.
+	f = &fakeStackRunner{fail: map[string]bool{"shared": true}}
+	buf.Reset()
+	results, err = runStackGroup(&buf, "plan", g, "prod", 2, f.runner())
+	if err != nil {
+		t.Fatalf("runStackGroup: %v", err)
+	}
+	if len(f.order) != 1 {
+		t.Fatalf("expected only the Environment to run, got %v", f.order)
+	}
+	err = reportStackResults(&buf, "plan", results)
+	if err == nil || !strings.Contains(err.Error(), "plan failed for 1 stack(s)") {
+		t.Fatalf("expected the failed Environment to be reported, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "Plan: 0 succeeded, 1 failed, 3 skipped") ||
+		!strings.Contains(buf.String(), `skipped: Environment "shared" failed`) ||
+		!strings.Contains(buf.String(), `skipped: no environment "prod"`) {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+
+	// Destroy tears the Services down first and keeps the Environment when one of them fails.
+	f = &fakeStackRunner{fail: map[string]bool{"worker": true}}
+	buf.Reset()
+	results, err = runStackGroup(&buf, "destroy", g, "dev", 1, f.runner())
+	if err != nil {
+		t.Fatalf("runStackGroup: %v", err)
+	}
+	if strings.Join(f.order, ",") != "api,worker,billing" || f.peak != 1 {
+		t.Fatalf("expected services to be destroyed one at a time, got %v (peak %d)", f.order, f.peak)
+	}
+	if last := results[len(results)-1]; last.stack.name() != "shared" || last.status != "skipped" {
+		t.Fatalf("expected the Environment to be skipped, got %+v", last)
+	}
+}
+
+func TestRunTfAllRequiresAutoApproveForParallelApply(t *testing.T) {
+	err := runTfAll(io.Discard, "apply", "env.yaml", "", "dev", "", "", nil, 4, tfExecOpts{})
+	if err == nil || !strings.Contains(err.Error(), "--auto-approve") {
+		t.Fatalf("expected parallel apply without --auto-approve to fail, got %v", err)
+	}
+	err = runTfAll(io.Discard, "plan", "env.yaml", "", "dev", "", "", nil, 4, tfExecOpts{planFile: "plan.tfplan"})
+	if err == nil || !strings.Contains(err.Error(), "--plan-file") {
+		t.Fatalf("expected --plan-file to be rejected, got %v", err)
+	}
+	err = runTfAll(io.Discard, "plan", "env.yaml", "", "dev", "", "", nil, 1, tfExecOpts{rover: true})
+	if err == nil || !strings.Contains(err.Error(), "--rover") {
+		t.Fatalf("expected --rover to be rejected, got %v", err)
+	}
+}
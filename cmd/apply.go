@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,6 +17,9 @@ import (
 	"github.com/spf13/cobra"
 
 	"pltf/pkg/config"
+	"pltf/pkg/generate"
+	"pltf/pkg/policy"
+	"pltf/pkg/secrets"
 	rover "rover"
 )
 
@@ -33,6 +37,13 @@ var (
 	applyInput       bool
 	applyRefresh     bool
 	applyAutoApprove bool
+	applyAll         bool
+	applySpecs       string
+	applyJobs        int
+	applyPlanBundle  string
+	applyUnsigned    bool
+	applyAllowDel    []string
+	applyPolicies    string
 
 	destroyFile        string
 	destroyEnv         string
@@ -47,6 +58,11 @@ var (
 	destroyInput       bool
 	destroyRefresh     bool
 	destroyAutoApprove bool
+	destroyAll         bool
+	destroySpecs       string
+	destroyJobs        int
+	destroyAllowDel    []string
+	destroyPolicies    string
 
 	planFile       string
 	planEnv        string
@@ -65,6 +81,13 @@ var (
 	planRover      bool
 	planScan       bool
 	planCost       bool
+	planAll        bool
+	planSpecs      string
+	planJobs       int
+	planBundle     string
+	planUnsigned   bool
+	planAllowDel   []string
+	planPolicies   string
 
 	outputFile       string
 	outputEnv        string
@@ -100,23 +123,39 @@ var applyCmd = &cobra.Command{
 	Long: `Render Terraform from an Environment or Service spec, ensure the backend bucket,
 then run 'terraform apply'. Supports Terraform-style flags like targets, lock timeout,
 parallelism, refresh control, and color toggles. Defaults to embedded modules and the
-standard output layout unless overridden.`,
+standard output layout unless overridden. With --all, the Environment of -f is applied first,
+then every Service under --specs whose metadata.ref points at it, up to --jobs at a time;
+-o replaces the .pltf root and a report of every stack is printed at the end.`,
 	Example: `  pltf terraform apply -f env.yaml -e prod
-  pltf terraform apply -f service.yaml -e dev -m ./modules -o ./.pltf/service/payments/dev --target=module.eks`,
+  pltf terraform apply -f service.yaml -e dev -m ./modules -o ./.pltf/service/payments/dev --target=module.eks
+  pltf terraform apply -f env.yaml -e prod --all --specs ./services --jobs 8 --auto-approve`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runTfWithAction("apply", applyFile, applyEnv, applyModulesDir, applyOut, applyVars, "", tfExecOpts{
-			targets:      applyTargets,
-			parallelism:  applyParallel,
-			lock:         applyLock,
-			lockTimeout:  applyLockTime,
-			noColor:      applyNoColor,
-			input:        applyInput,
-			refresh:      &applyRefresh,
-			jsonOutput:   false,
-			planFile:     "",
-			detailedExit: false,
-			autoApprove:  applyAutoApprove,
-		})
+		opts := tfExecOpts{
+			targets:       applyTargets,
+			parallelism:   applyParallel,
+			lock:          applyLock,
+			lockTimeout:   applyLockTime,
+			noColor:       applyNoColor,
+			input:         applyInput,
+			refresh:       &applyRefresh,
+			jsonOutput:    false,
+			planFile:      "",
+			detailedExit:  false,
+			autoApprove:   applyAutoApprove,
+			allowDestroy:  applyAllowDel,
+			policies:      applyPolicies,
+			allowUnsigned: applyUnsigned,
+		}
+		if err := planBundleSupported(applyPlanBundle, applyAll, applyFile); err != nil {
+			return err
+		}
+		if applyPlanBundle != "" {
+			return runApplyBundle(applyFile, applyEnv, applyModulesDir, applyOut, applyVars, applyPlanBundle, opts)
+		}
+		if applyAll {
+			return runTfAll(os.Stdout, "apply", applyFile, applySpecs, applyEnv, applyModulesDir, applyOut, applyVars, applyJobs, opts)
+		}
+		return runTfWithAction("apply", applyFile, applyEnv, applyModulesDir, applyOut, applyVars, "", opts)
 	},
 }
 
@@ -138,22 +177,31 @@ var destroyCmd = &cobra.Command{
 	Use:   "destroy",
 	Args:  cobra.NoArgs,
 	Short: "Generate (if needed) and destroy Terraform for a spec",
-	Long: `Render Terraform if missing, then run 'terraform destroy'. Mirrors apply
-defaults (modules, output layout) and exposes Terraform knobs for targets, locking,
-refresh behavior, and color.`,
+	Long: `Render Terraform if missing, then plan the destroy and apply it. Like apply, the plan is
+checked first: protected resources need --allow-destroy and Rego policies must not deny it.
+Mirrors apply defaults (modules, output layout) and exposes Terraform knobs for targets, locking,
+refresh behavior, and color. With --all, every Service referencing the Environment of -f is
+destroyed first and the Environment last, only once all of them succeeded.`,
 	Example: `  pltf terraform destroy -f env.yaml -e prod
-  pltf terraform destroy -f service.yaml -e dev --target=module.app-bucket`,
+  pltf terraform destroy -f service.yaml -e dev --target=module.app-bucket
+  pltf terraform destroy -f env.yaml -e dev --all --auto-approve`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runTfWithAction("destroy", destroyFile, destroyEnv, destroyModulesDir, destroyOut, destroyVars, "", tfExecOpts{
-			targets:     destroyTargets,
-			parallelism: destroyParallel,
-			lock:        destroyLock,
-			lockTimeout: destroyLockTime,
-			noColor:     destroyNoColor,
-			input:       destroyInput,
-			refresh:     &destroyRefresh,
-			autoApprove: destroyAutoApprove,
-		})
+		opts := tfExecOpts{
+			targets:      destroyTargets,
+			parallelism:  destroyParallel,
+			lock:         destroyLock,
+			lockTimeout:  destroyLockTime,
+			noColor:      destroyNoColor,
+			input:        destroyInput,
+			refresh:      &destroyRefresh,
+			autoApprove:  destroyAutoApprove,
+			allowDestroy: destroyAllowDel,
+			policies:     destroyPolicies,
+		}
+		if destroyAll {
+			return runTfAll(os.Stdout, "destroy", destroyFile, destroySpecs, destroyEnv, destroyModulesDir, destroyOut, destroyVars, destroyJobs, opts)
+		}
+		return runTfWithAction("destroy", destroyFile, destroyEnv, destroyModulesDir, destroyOut, destroyVars, "", opts)
 	},
 }
 
@@ -163,27 +211,53 @@ var planCmd = &cobra.Command{
 	Short: "Generate (if needed) and run terraform plan for a spec",
 	Long: `Render Terraform and run 'terraform plan'. Supports detailed exit codes,
 plan file output, targets, locking, refresh toggles, and parallelism. Ideal for CI or
-local dry runs with the same generation defaults as apply.`,
+local dry runs with the same generation defaults as apply. With a directory or a
+multi-document YAML file, every spec of the set is generated and planned in turn
+(Environments first); -o replaces the .pltf root and failures are summarised at the end.
+With --all, the Environment of -f and every Service referencing it are planned, the
+Services up to --jobs at a time.`,
 	Example: `  pltf terraform plan -f env.yaml -e prod
   pltf terraform plan -f service.yaml -e dev --detailed-exitcode --plan-file=/tmp/plan.tfplan
   pltf terraform plan -f env.yaml -e prod --rover   # renders plan.json and opens rover (https://github.com/yindia/rover)
   pltf terraform plan -f env.yaml -e prod --scan    # run tfsec against generated TF
-  pltf terraform plan -f env.yaml -e prod --cost    # run infracost breakdown (if infracost binary present)`,
+  pltf terraform plan -f env.yaml -e prod --cost    # run infracost breakdown (if infracost binary present)
+  pltf terraform plan -f env.yaml -e prod --policies ./policies  # check Rego policies
+  pltf terraform plan -f ./specs -e dev             # plan every spec in the directory
+  pltf terraform plan -f env.yaml -e dev --all      # plan the environment and its services`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runTfWithAction("plan", planFile, planEnv, planModulesDir, planOut, planVars, "", tfExecOpts{
-			targets:      planTargets,
-			parallelism:  planParallel,
-			lock:         planLock,
-			lockTimeout:  planLockTime,
-			noColor:      planNoColor,
-			input:        planInput,
-			refresh:      &planRefresh,
-			planFile:     planOutFile,
-			detailedExit: planDetailed,
-			rover:        planRover,
-			scan:         planScan,
-			cost:         planCost,
-		})
+		opts := tfExecOpts{
+			targets:       planTargets,
+			parallelism:   planParallel,
+			lock:          planLock,
+			lockTimeout:   planLockTime,
+			noColor:       planNoColor,
+			input:         planInput,
+			refresh:       &planRefresh,
+			planFile:      planOutFile,
+			detailedExit:  planDetailed,
+			rover:         planRover,
+			scan:          planScan,
+			cost:          planCost,
+			planBundle:    planBundle,
+			allowDestroy:  planAllowDel,
+			policies:      planPolicies,
+			allowUnsigned: planUnsigned,
+		}
+		if err := planBundleSupported(planBundle, planAll, planFile); err != nil {
+			return err
+		}
+		if planBundle != "" {
+			if _, err := bundleKey(planUnsigned); err != nil {
+				return err
+			}
+		}
+		if planAll {
+			return runTfAll(os.Stdout, "plan", planFile, planSpecs, planEnv, planModulesDir, planOut, planVars, planJobs, opts)
+		}
+		if config.IsSpecSet(defaultString(planFile, "env.yaml")) {
+			return planSpecSet(os.Stdout, planFile, planEnv, planModulesDir, planOut, planVars, opts)
+		}
+		return runTfWithAction("plan", planFile, planEnv, planModulesDir, planOut, planVars, "", opts)
 	},
 }
 
@@ -236,13 +310,77 @@ type tfExecOpts struct {
 	rover        bool
 	scan         bool
 	cost         bool
+	// planBundle is where plan packs the saved plan into a bundle; savedPlan is a plan file
+	// that apply applies instead of planning afresh.
+	planBundle string
+	savedPlan  string
+	// allowUnsigned lets plan bundles be written and applied without PLTF_PLAN_SIGNING_KEY.
+	allowUnsigned bool
+	// allowDestroy names the modules whose protected resources may be deleted or replaced.
+	allowDestroy []string
+	// policies is the directory of Rego policies checked against plan and apply.
+	policies string
 }
 
 type stackContext struct {
 	kind   string
 	env    string
 	envCfg *config.EnvironmentConfig
+	svcCfg *config.ServiceConfig
 	outDir string
+	// secrets in scope for env and the spec directory their relative paths resolve against
+	secrets map[string]config.SecretRef
+	specDir string
+	// approval gates that apply to env
+	approvals []config.ApprovalRequirement
+	// log receives the stack's Terraform output instead of the terminal when stacks run
+	// concurrently; nil means stdout/stderr
+	log io.Writer
+}
+
+func (c stackContext) stdout() io.Writer {
+	if c.log != nil {
+		return c.log
+	}
+	return os.Stdout
+}
+
+func (c stackContext) stderr() io.Writer {
+	if c.log != nil {
+		return c.log
+	}
+	return os.Stderr
+}
+
+// run runs a command in the stack's output directory with extra env entries.
+func (c stackContext) run(env []string, name string, args ...string) error {
+	_, err := c.runExit(env, name, args...)
+	return err
+}
+
+func (c stackContext) runExit(env []string, name string, args ...string) (int, error) {
+	return runCmdIO(c.outDir, env, c.stdout(), c.stderr(), name, args...)
+}
+
+// initArgs are the arguments of terraform init. Stacks rendered with tfvars have a partial backend
+// completed from the environment's backend config file; -reconfigure lets the same directory switch
+// between environments.
+func (c stackContext) initArgs() []string {
+	args := []string{"init"}
+	if name := generate.BackendConfigFile(c.env); fileExists(filepath.Join(c.outDir, name)) {
+		args = append(args, "-reconfigure", "-backend-config="+name)
+	}
+	return args
+}
+
+// withValues adds the environment's values file of stacks rendered with tfvars to a plan or destroy
+// invocation when the stack was rendered for another environment. The rendered environment's
+// values are in an auto-loaded file and need no flag.
+func (c stackContext) withValues(args []string) []string {
+	if name := generate.TfvarsFile(c.env); fileExists(filepath.Join(c.outDir, name)) {
+		args = append(args, "-var-file="+name)
+	}
+	return args
 }
 
 func prepareStackContext(file, env, out string) (stackContext, error) {
@@ -265,6 +403,8 @@ func prepareStackContext(file, env, out string) (stackContext, error) {
 			return ctx, err
 		}
 		ctx.envCfg = envCfg
+		ctx.secrets = secrets.Refs(envCfg, nil, env)
+		ctx.approvals = config.ApprovalRequirements(envCfg, nil, env)
 		if out == "" {
 			ctx.outDir = filepath.Join(".pltf", envCfg.Metadata.Name, "env", env)
 		} else {
@@ -279,7 +419,9 @@ func prepareStackContext(file, env, out string) (stackContext, error) {
 		if err != nil {
 			return ctx, err
 		}
-		ctx.envCfg = envCfg
+		ctx.envCfg, ctx.svcCfg = envCfg, svcCfg
+		ctx.secrets = secrets.Refs(envCfg, svcCfg, env)
+		ctx.approvals = config.ApprovalRequirements(envCfg, svcCfg, env)
 		if out == "" {
 			ctx.outDir = filepath.Join(".pltf", envCfg.Metadata.Name, svcCfg.Metadata.Name, "env", env)
 		} else {
@@ -291,6 +433,9 @@ func prepareStackContext(file, env, out string) (stackContext, error) {
 
 	ctx.env = env
 	ctx.outDir, _ = filepath.Abs(filepath.Clean(ctx.outDir))
+	if absFile, err := filepath.Abs(defaultString(file, "env.yaml")); err == nil {
+		ctx.specDir = filepath.Dir(absFile)
+	}
 	return ctx, nil
 }
 
@@ -304,6 +449,27 @@ func runTfWithAction(action, file, env, modules, out string, vars []string, lock
 	if err != nil {
 		return err
 	}
+	return runTfInStack(action, file, ctx, lockID, opts)
+}
+
+// runTfInStack runs a terraform action against an already generated stack. spec names the spec
+// the stack was rendered from, for run summaries and PR comments.
+func runTfInStack(action, spec string, ctx stackContext, lockID string, opts tfExecOpts) error {
+	// Approval gates are checked before anything touches the backend.
+	var approvals []approvalStatus
+	if action == "plan" || action == "apply" || action == "destroy" {
+		var err error
+		approvals, err = checkApprovalGates(action, ctx)
+		if err != nil {
+			if action == "apply" {
+				blocked := tfRunSummary{Action: action, Status: "blocked", Spec: spec, Env: ctx.env, OutDir: ctx.outDir, Err: err.Error(), Approvals: approvals}
+				if cerr := maybeUpsertPRComment(ctx.stderr(), blocked); cerr != nil {
+					fmt.Fprintf(ctx.stderr(), "warn: failed to update PR comment: %v\n", cerr)
+				}
+			}
+			return err
+		}
+	}
 
 	// Optional security scan happens before init/plan to fail fast.
 	var scanSum *tfsecSummary
@@ -325,10 +491,19 @@ func runTfWithAction(action, file, env, modules, out string, vars []string, lock
 		}
 	}
 
-	if err := runCmd(ctx.outDir, "terraform", "init"); err != nil {
+	if err := ctx.run(nil, "terraform", ctx.initArgs()...); err != nil {
 		return fmt.Errorf("terraform init failed: %w", err)
 	}
 
+	// Secrets resolved outside Terraform (env, file, sops) reach it as TF_VAR_<name>.
+	var secretEnv []string
+	if action == "apply" || action == "destroy" || action == "plan" {
+		secretEnv, err = secrets.Environment(context.Background(), ctx.secrets, ctx.specDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve secrets: %w", err)
+		}
+	}
+
 	common := func(args []string) []string {
 		args = appendTfCommonArgs(args, opts)
 		return args
@@ -343,22 +518,56 @@ func runTfWithAction(action, file, env, modules, out string, vars []string, lock
 	planArg := opts.planFile
 	tempPlan := false
 	var costSum *costSummary
+	var protected []protectionViolation
+	var policyResults []policy.Result
 	switch action {
-	case "apply":
-		args := []string{"apply"}
-		if opts.autoApprove {
-			args = append(args, "-auto-approve")
+	case "apply", "destroy":
+		// Apply and destroy always go through a saved plan, so protected resources and policies are
+		// checked against exactly what terraform will do.
+		saved := opts.savedPlan
+		if saved == "" {
+			planArg := ".pltf-" + action + ".tfplan"
+			saved = filepath.Join(ctx.outDir, planArg)
+			defer os.Remove(saved)
+			args := []string{"plan"}
+			if action == "destroy" {
+				args = append(args, "-destroy")
+			}
+			args = append(args, "-out="+planArg)
+			if err := ctx.run(secretEnv, "terraform", ctx.withValues(common(args))...); err != nil {
+				runErr = fmt.Errorf("terraform plan failed: %w", err)
+				break
+			}
 		}
-		if err := runCmd(ctx.outDir, "terraform", common(args)...); err != nil {
-			runErr = fmt.Errorf("terraform apply failed: %w", err)
+		sum, err := collectPlanSummary(ctx.outDir, saved)
+		if err != nil {
+			runErr = fmt.Errorf("failed to read plan for protected resources: %w", err)
+			break
 		}
-	case "destroy":
-		args := []string{"destroy"}
-		if opts.autoApprove {
-			args = append(args, "-auto-approve")
+		planSum = sum
+		if opts.savedPlan == "" {
+			printPlanModules(ctx.stdout(), sum)
 		}
-		if err := runCmd(ctx.outDir, "terraform", common(args)...); err != nil {
-			runErr = fmt.Errorf("terraform destroy failed: %w", err)
+		var blocked bool
+		if protected, policyResults, blocked, err = guardPlan(ctx, opts, sum); err != nil {
+			runErr = err
+			if blocked {
+				runStatus = "blocked"
+			}
+			break
+		}
+		if opts.savedPlan == "" {
+			if err := approveCheckedPlan(ctx, action, opts); err != nil {
+				runErr = err
+				break
+			}
+		}
+		// Planning options cannot be combined with a saved plan.
+		applyOpts := opts
+		applyOpts.targets, applyOpts.refresh = nil, nil
+		args := append(appendTfCommonArgs([]string{"apply"}, applyOpts), saved)
+		if err := ctx.run(secretEnv, "terraform", args...); err != nil {
+			runErr = fmt.Errorf("terraform %s failed: %w", action, err)
 		}
 	case "plan":
 		args := []string{"plan"}
@@ -378,8 +587,15 @@ func runTfWithAction(action, file, env, modules, out string, vars []string, lock
 			}
 		}
 		args = append(args, "-out="+planArg)
-		planArgs = append(planArgs, common(args)...)
-		planExit, runErr = runCmdExit(ctx.outDir, "terraform", planArgs...)
+		var sourceHash string
+		if opts.planBundle != "" {
+			// Hashed before terraform writes anything else next to the generated files.
+			if sourceHash, err = generate.TreeHash(ctx.outDir); err != nil {
+				return fmt.Errorf("hash generated Terraform: %w", err)
+			}
+		}
+		planArgs = append(planArgs, ctx.withValues(common(args))...)
+		planExit, runErr = ctx.runExit(secretEnv, "terraform", planArgs...)
 		if runErr != nil && !(opts.detailedExit && planExit == 2) {
 			runErr = fmt.Errorf("terraform plan failed: %w", runErr)
 		}
@@ -394,30 +610,34 @@ func runTfWithAction(action, file, env, modules, out string, vars []string, lock
 			}
 		}
 		planJSONPath := ""
-		if tempPlan || strings.TrimSpace(planPathOnDisk) != "" {
-			planJSONPath = strings.TrimSuffix(planPathOnDisk, filepath.Ext(planPathOnDisk)) + ".json"
-			out, err := runCmdOutput(ctx.outDir, "terraform", "show", "-json", planPathOnDisk)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "warn: terraform show -json failed: %v\n", err)
-			} else if err := os.WriteFile(planJSONPath, []byte(out), 0o644); err != nil {
-				fmt.Fprintf(os.Stderr, "warn: write plan json failed: %v\n", err)
-			}
-		}
 		if sum, err := collectPlanSummary(ctx.outDir, planPathOnDisk); err == nil {
 			planSum = sum
+			printPlanModules(ctx.stdout(), sum)
+			for _, w := range sum.Warnings {
+				fmt.Fprintf(ctx.stderr(), "warn: %s\n", w)
+			}
+			protected = protectionViolations(sum.Model, opts.allowDestroy)
+			printProtectionViolations(ctx.stdout(), protected)
+			if err := blockedByProtection(protected); err != nil {
+				fmt.Fprintf(ctx.stderr(), "warn: apply will be blocked: %v\n", err)
+			}
 			planSum.RawPlanArgs = planArgs
-			if planJSONPath != "" {
+			planJSONPath = strings.TrimSuffix(planPathOnDisk, filepath.Ext(planPathOnDisk)) + ".json"
+			if err := os.WriteFile(planJSONPath, sum.json, 0o644); err != nil {
+				fmt.Fprintf(ctx.stderr(), "warn: write plan json failed: %v\n", err)
+				planJSONPath = ""
+			} else {
 				planSum.PlanJSON = planJSONPath
 			}
 		} else {
-			fmt.Fprintf(os.Stderr, "warn: failed to collect plan summary: %v\n", err)
+			fmt.Fprintf(ctx.stderr(), "warn: failed to collect plan summary: %v\n", err)
 		}
 		if opts.rover && planJSONPath != "" {
 			tfPath := "terraform"
 			if p, err := exec.LookPath("terraform"); err == nil {
 				tfPath = p
 			} else {
-				fmt.Fprintf(os.Stderr, "warn: terraform not found in PATH for rover (defaulting to %q): %v\n", tfPath, err)
+				fmt.Fprintf(ctx.stderr(), "warn: terraform not found in PATH for rover (defaulting to %q): %v\n", tfPath, err)
 			}
 			r, err := rover.New(rover.Config{
 				WorkingDir:   ctx.outDir,
@@ -428,22 +648,38 @@ func runTfWithAction(action, file, env, modules, out string, vars []string, lock
 				// WorkspaceName, TFCOrgName, TFCWorkspaceName, ShowSensitive, GenImage, TFCNewRun.
 			})
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "warn: rover init failed: %v\n", err)
+				fmt.Fprintf(ctx.stderr(), "warn: rover init failed: %v\n", err)
 			} else {
 				if err := r.GenerateAssets(); err != nil {
-					fmt.Fprintf(os.Stderr, "warn: rover asset generation failed: %v\n", err)
+					fmt.Fprintf(ctx.stderr(), "warn: rover asset generation failed: %v\n", err)
 				} else if err := r.StartServer("0.0.0.0:9000"); err != nil {
-					fmt.Fprintf(os.Stderr, "warn: rover server failed: %v\n", err)
+					fmt.Fprintf(ctx.stderr(), "warn: rover server failed: %v\n", err)
 				}
 			}
 		}
 		if opts.cost && planJSONPath != "" {
 			if sum, err := runInfracost(planJSONPath, ctx.outDir); err != nil {
-				fmt.Fprintf(os.Stderr, "warn: infracost run failed: %v\n", err)
+				fmt.Fprintf(ctx.stderr(), "warn: infracost run failed: %v\n", err)
 			} else {
 				costSum = sum
 			}
 		}
+		if planSum != nil {
+			var blocked bool
+			if policyResults, blocked, err = checkPolicies(ctx, opts, planSum); err != nil {
+				runErr = err
+				if blocked {
+					runStatus = "blocked"
+				}
+			}
+		}
+		if opts.planBundle != "" && runErr == nil {
+			if err := writePlanBundle(ctx, spec, opts.planBundle, planPathOnDisk, sourceHash, opts.allowUnsigned); err != nil {
+				runErr = fmt.Errorf("write plan bundle: %w", err)
+			} else {
+				fmt.Fprintf(ctx.stdout(), "Wrote plan bundle %s\n", opts.planBundle)
+			}
+		}
 		if tempPlan {
 			_ = os.Remove(planPathOnDisk)
 			_ = os.Remove(planJSONPath)
@@ -456,43 +692,53 @@ func runTfWithAction(action, file, env, modules, out string, vars []string, lock
 		if opts.jsonOutput {
 			args = append(args, "-json")
 		}
-		if err := runCmd(ctx.outDir, "terraform", common(args)...); err != nil {
+		if err := ctx.run(nil, "terraform", common(args)...); err != nil {
 			runErr = fmt.Errorf("terraform output failed: %w", err)
 		}
 	case "force-unlock":
 		args := []string{"force-unlock", "-force", lockID}
-		if err := runCmd(ctx.outDir, "terraform", common(args)...); err != nil {
+		if err := ctx.run(nil, "terraform", common(args)...); err != nil {
 			runErr = fmt.Errorf("terraform force-unlock failed: %w", err)
 		}
 	}
 
 	if action == "plan" || action == "apply" {
 		status := tfRunSummary{
-			Action: action,
-			Spec:   file,
-			Env:    env,
-			OutDir: ctx.outDir,
-			Plan:   planSum,
-			Scan:   scanSum,
-			Cost:   costSum,
+			Action:    action,
+			Spec:      spec,
+			Env:       ctx.env,
+			OutDir:    ctx.outDir,
+			Plan:      planSum,
+			Scan:      scanSum,
+			Cost:      costSum,
+			Approvals: approvals,
+			Protected: protected,
+			Policy:    policyResults,
 		}
+		status.Status = runStatusText(runErr, runStatus)
 		if runErr != nil {
-			status.Status = "failed"
 			status.Err = runErr.Error()
-		} else {
-			status.Status = runStatus
 		}
 		if status.Plan != nil {
-			status.AI = maybeAICritique(status)
+			status.AI = maybeAICritique(ctx.stderr(), status)
 		}
-		if err := maybeUpsertPRComment(status); err != nil {
-			fmt.Fprintf(os.Stderr, "warn: failed to update PR comment: %v\n", err)
+		if err := maybeUpsertPRComment(ctx.stderr(), status); err != nil {
+			fmt.Fprintf(ctx.stderr(), "warn: failed to update PR comment: %v\n", err)
 		}
 	}
 
 	return runErr
 }
 
+// runStatusText is the status reported for a run: failed when it errored, unless a guard blocked it,
+// and otherwise runStatus.
+func runStatusText(runErr error, runStatus string) string {
+	if runErr != nil && runStatus != "blocked" {
+		return "failed"
+	}
+	return runStatus
+}
+
 type tfsecFinding struct {
 	Severity    string
 	Rule        string
@@ -778,6 +1024,13 @@ func init() {
 	applyCmd.Flags().BoolVarP(&applyInput, "input", "i", false, "Ask for input if necessary (default false)")
 	applyCmd.Flags().BoolVarP(&applyRefresh, "refresh", "r", true, "Update state prior to actions")
 	applyCmd.Flags().BoolVar(&applyAutoApprove, "auto-approve", false, "Pass -auto-approve to terraform apply")
+	applyCmd.Flags().BoolVar(&applyAll, "all", false, "Apply the Environment and every Service whose metadata.ref points at it")
+	applyCmd.Flags().StringVar(&applySpecs, "specs", "", "Directory searched for Services with --all (defaults to the directory of --file)")
+	applyCmd.Flags().IntVar(&applyJobs, "jobs", 4, "Services run at the same time with --all")
+	applyCmd.Flags().StringArrayVar(&applyAllowDel, "allow-destroy", nil, "Module id whose protected resources may be deleted or replaced (repeatable)")
+	applyCmd.Flags().StringVar(&applyPolicies, "policies", "", "Directory of Rego policies checked against the plan before apply; defaults to profile policies_dir")
+	applyCmd.Flags().StringVar(&applyPlanBundle, "plan-bundle", "", "Apply the plan in a bundle written by plan --plan-bundle, after checking its PLTF_PLAN_SIGNING_KEY signature and that it still matches the spec and generated Terraform")
+	applyCmd.Flags().BoolVar(&applyUnsigned, "allow-unsigned", false, "Accept an unsigned plan bundle when PLTF_PLAN_SIGNING_KEY is not set")
 
 	destroyCmd.Flags().StringVarP(&destroyFile, "file", "f", "env.yaml", "Path to the Environment or Service YAML file")
 	destroyCmd.Flags().StringVarP(&destroyEnv, "env", "e", "", "Environment key to render (dev, prod, etc.)")
@@ -791,9 +1044,14 @@ func init() {
 	destroyCmd.Flags().BoolVarP(&destroyNoColor, "no-color", "C", false, "Disable color output")
 	destroyCmd.Flags().BoolVarP(&destroyInput, "input", "i", false, "Ask for input if necessary (default false)")
 	destroyCmd.Flags().BoolVarP(&destroyRefresh, "refresh", "r", true, "Update state prior to actions")
-	destroyCmd.Flags().BoolVar(&destroyAutoApprove, "auto-approve", false, "Pass -auto-approve to terraform destroy")
+	destroyCmd.Flags().BoolVar(&destroyAutoApprove, "auto-approve", false, "Destroy without asking for approval")
+	destroyCmd.Flags().BoolVar(&destroyAll, "all", false, "Destroy every Service whose metadata.ref points at the Environment, then the Environment")
+	destroyCmd.Flags().StringVar(&destroySpecs, "specs", "", "Directory searched for Services with --all (defaults to the directory of --file)")
+	destroyCmd.Flags().IntVar(&destroyJobs, "jobs", 4, "Services run at the same time with --all")
+	destroyCmd.Flags().StringArrayVar(&destroyAllowDel, "allow-destroy", nil, "Module id whose protected resources may be destroyed (repeatable)")
+	destroyCmd.Flags().StringVar(&destroyPolicies, "policies", "", "Directory of Rego policies checked against the destroy plan; defaults to profile policies_dir")
 
-	planCmd.Flags().StringVarP(&planFile, "file", "f", "env.yaml", "Path to the Environment or Service YAML file")
+	planCmd.Flags().StringVarP(&planFile, "file", "f", "env.yaml", "Path to the Environment or Service YAML file, a multi-document YAML file, or a directory of specs")
 	planCmd.Flags().StringVarP(&planEnv, "env", "e", "", "Environment key to render (dev, prod, etc.)")
 	planCmd.Flags().StringVarP(&planModulesDir, "modules", "m", "", "Override modules root; defaults to embedded modules")
 	planCmd.Flags().StringVarP(&planOut, "out", "o", "", "Output directory for generated Terraform")
@@ -810,6 +1068,13 @@ func init() {
 	planCmd.Flags().BoolVar(&planRover, "rover", false, "Run rover (https://github.com/yindia/rover) against the generated plan.json (requires rover binary in PATH)")
 	planCmd.Flags().BoolVar(&planScan, "scan", false, "Run tfsec security scan against the generated Terraform")
 	planCmd.Flags().BoolVar(&planCost, "cost", false, "Run infracost breakdown against the plan (requires infracost binary in PATH and INFRACOST_API_KEY)")
+	planCmd.Flags().BoolVar(&planAll, "all", false, "Plan the Environment and every Service whose metadata.ref points at it")
+	planCmd.Flags().StringVar(&planSpecs, "specs", "", "Directory searched for Services with --all (defaults to the directory of --file)")
+	planCmd.Flags().IntVar(&planJobs, "jobs", 4, "Services run at the same time with --all")
+	planCmd.Flags().StringArrayVar(&planAllowDel, "allow-destroy", nil, "Module id whose protected resources may be deleted or replaced; plan reports the rest as blocking apply (repeatable)")
+	planCmd.Flags().StringVar(&planPolicies, "policies", "", "Directory of Rego policies checked against the spec, module inputs and plan; defaults to profile policies_dir")
+	planCmd.Flags().StringVar(&planBundle, "plan-bundle", "", "Write the saved plan with hashes of the spec and generated Terraform to a bundle (tar.gz) for apply --plan-bundle, signed with PLTF_PLAN_SIGNING_KEY")
+	planCmd.Flags().BoolVar(&planUnsigned, "allow-unsigned", false, "Write an unsigned plan bundle when PLTF_PLAN_SIGNING_KEY is not set")
 
 	outputCmd.Flags().StringVarP(&outputFile, "file", "f", "env.yaml", "Path to the Environment or Service YAML file")
 	outputCmd.Flags().StringVarP(&outputEnv, "env", "e", "", "Environment key to render (dev, prod, etc.)")
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"pltf/pkg/config"
+	"pltf/pkg/git"
+)
+
+// newCommenter is swapped in tests to avoid calling a git provider.
+var newCommenter = git.NewCommenter
+
+// approvalStatus is the state of one approval gate for a run.
+type approvalStatus struct {
+	Label    string
+	Required int
+	// Approved lists the approving reviewers that count toward the gate.
+	Approved []string
+}
+
+func (a approvalStatus) satisfied() bool { return len(a.Approved) >= a.Required }
+
+func (a approvalStatus) String() string {
+	s := fmt.Sprintf("%s: %d of %d approval(s)", a.Label, min(len(a.Approved), a.Required), a.Required)
+	if len(a.Approved) > 0 {
+		s += " (" + strings.Join(a.Approved, ", ") + ")"
+	}
+	return s
+}
+
+// evaluateApprovals matches the latest approving review of each reviewer against the gates, ignoring
+// approvals of an earlier commit. Teams are resolved through teams, which may be nil when no gate
+// names a team.
+func evaluateApprovals(gates []config.ApprovalRequirement, summary git.ReviewSummary, teams git.TeamResolver) ([]approvalStatus, error) {
+	var approvers []string
+	for _, r := range summary.Reviews {
+		if strings.EqualFold(r.Status, "APPROVED") && !r.Outdated {
+			approvers = append(approvers, r.Name)
+		}
+	}
+	sort.Strings(approvers)
+
+	out := make([]approvalStatus, 0, len(gates))
+	for _, gate := range gates {
+		st := approvalStatus{Label: gate.Label(), Required: gate.Required()}
+		name := gate.Approver()
+		var members map[string]bool
+		if gate.IsTeam() {
+			if teams == nil {
+				return nil, fmt.Errorf("approval gate %s names a team, but the git provider cannot list team members", st.Label)
+			}
+			logins, err := teams.TeamMembers(name)
+			if err != nil {
+				return nil, fmt.Errorf("approval gate %s: list team members: %w", st.Label, err)
+			}
+			members = map[string]bool{}
+			for _, login := range logins {
+				members[strings.ToLower(login)] = true
+			}
+		}
+		for _, login := range approvers {
+			switch {
+			case members != nil && !members[strings.ToLower(login)]:
+				continue
+			case members == nil && name != "" && !strings.EqualFold(name, login):
+				continue
+			}
+			st.Approved = append(st.Approved, login)
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// fetchApprovals reads the pull request review state and evaluates gates against it.
+func fetchApprovals(gates []config.ApprovalRequirement) ([]approvalStatus, error) {
+	commenter, err := newCommenter("")
+	if err != nil {
+		return nil, fmt.Errorf("pull request review state is unavailable: %w", err)
+	}
+	summary, err := commenter.GetReviewSummary()
+	if err != nil {
+		return nil, fmt.Errorf("pull request review state is unavailable: %w", err)
+	}
+	teams, _ := commenter.(git.TeamResolver)
+	return evaluateApprovals(gates, summary, teams)
+}
+
+func outstandingApprovals(statuses []approvalStatus) []approvalStatus {
+	var out []approvalStatus
+	for _, st := range statuses {
+		if !st.satisfied() {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// checkApprovalGates returns the gate states for a run. apply and destroy fail closed: they return
+// an error when review state cannot be read or any gate is outstanding; plan only reports.
+func checkApprovalGates(action string, ctx stackContext) ([]approvalStatus, error) {
+	if len(ctx.approvals) == 0 {
+		return nil, nil
+	}
+	statuses, err := fetchApprovals(ctx.approvals)
+	if action == "plan" {
+		if err != nil {
+			fmt.Fprintf(ctx.stderr(), "warn: approval gates not evaluated: %v\n", err)
+		}
+		return statuses, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("approval gates for env %s: %w", ctx.env, err)
+	}
+	if pending := outstandingApprovals(statuses); len(pending) > 0 {
+		lines := make([]string, 0, len(pending))
+		for _, st := range pending {
+			lines = append(lines, st.String())
+		}
+		return statuses, fmt.Errorf("terraform %s for env %s is waiting for approval:\n  %s", action, ctx.env, strings.Join(lines, "\n  "))
+	}
+	return statuses, nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"pltf/pkg/config"
+	"pltf/pkg/git"
+)
+
+type fakeCommenter struct {
+	summary  git.ReviewSummary
+	teams    map[string][]string
+	comments []git.PRComment
+}
+
+func (f *fakeCommenter) UpsertPRComment(c git.PRComment) error {
+	f.comments = append(f.comments, c)
+	return nil
+}
+
+func (f *fakeCommenter) GetReviewSummary() (git.ReviewSummary, error) { return f.summary, nil }
+
+func (f *fakeCommenter) TeamMembers(team string) ([]string, error) { return f.teams[team], nil }
+
+func TestEvaluateApprovalsMatchesUsersTeamsAndCounts(t *testing.T) {
+	fake := &fakeCommenter{
+		summary: git.ReviewSummary{Reviews: []git.ReviewStatus{
+			{Name: "alice", Status: "APPROVED"},
+			{Name: "Bob", Status: "APPROVED"},
+			{Name: "carol", Status: "CHANGES_REQUESTED"},
+		}},
+		teams: map[string][]string{"acme/platform": {"bob", "carol"}},
+	}
+	gates := []config.ApprovalRequirement{
+		{Name: "@alice"},
+		{Name: "acme/platform", Alias: "platform team", Count: 2},
+		{Count: 2},
+	}
+	got, err := evaluateApprovals(gates, fake.summary, fake)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got[0].satisfied() || got[1].satisfied() || !got[2].satisfied() {
+		t.Fatalf("unexpected gate states: %+v", got)
+	}
+	if want := "platform team: 1 of 2 approval(s) (Bob)"; got[1].String() != want {
+		t.Fatalf("got %q, want %q", got[1].String(), want)
+	}
+
+	if _, err := evaluateApprovals(gates, fake.summary, nil); err == nil {
+		t.Fatalf("team gates need a provider that can list members")
+	}
+}
+
+func TestEvaluateApprovalsIgnoresOutdatedApprovals(t *testing.T) {
+	summary := git.ReviewSummary{Reviews: []git.ReviewStatus{{Name: "alice", Status: "APPROVED", Outdated: true}}}
+	got, err := evaluateApprovals([]config.ApprovalRequirement{{Name: "alice"}}, summary, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got[0].satisfied() {
+		t.Fatalf("an approval of an earlier commit must not satisfy the gate: %+v", got)
+	}
+}
+
+func TestApplyRefusesUntilApprovalGatesAreSatisfied(t *testing.T) {
+	fake := &fakeCommenter{summary: git.ReviewSummary{Reviews: []git.ReviewStatus{{Name: "bob", Status: "APPROVED"}}}}
+	prev := newCommenter
+	newCommenter = func(string) (git.Commenter, error) { return fake, nil }
+	defer func() { newCommenter = prev }()
+
+	envCfg := &config.EnvironmentConfig{Metadata: config.EnvironmentMetadata{Approve: []config.ApprovalRequirement{
+		{Name: "alice", Environments: []string{"prod"}},
+		{Name: "bob"},
+	}}}
+	ctx := stackContext{kind: "Environment", env: "prod", envCfg: envCfg, outDir: t.TempDir(),
+		approvals: config.ApprovalRequirements(envCfg, nil, "prod")}
+
+	err := runTfInStack("apply", "env.yaml", ctx, "", tfExecOpts{})
+	if err == nil || !strings.Contains(err.Error(), "waiting for approval") || !strings.Contains(err.Error(), "alice: 0 of 1") {
+		t.Fatalf("expected apply to be blocked on alice, got %v", err)
+	}
+	if len(fake.comments) != 1 || !strings.Contains(fake.comments[0].Body, "⏳ alice: 0 of 1 approval(s)") ||
+		!strings.Contains(fake.comments[0].Body, "✅ bob: 1 of 1 approval(s) (bob)") {
+		t.Fatalf("expected outstanding approvals in the PR comment, got %+v", fake.comments)
+	}
+
+	if gates := config.ApprovalRequirements(envCfg, nil, "dev"); len(gates) != 1 {
+		t.Fatalf("prod-only gates should not apply to dev, got %+v", gates)
+	}
+}
@@ -1,73 +1,318 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"pltf/pkg/config"
 	"pltf/pkg/generate"
+	"pltf/pkg/secrets"
 )
 
-func autoValidate(file, env string) error {
-	return autoValidateWithOutput(os.Stdout, file, env)
+func autoValidate(file, env, modules, format string, sources bool) error {
+	return autoValidateWithOutput(os.Stdout, file, env, modules, format, sources)
 }
 
-func autoValidateWithOutput(out io.Writer, file, env string) error {
-	var (
-		envCfg *config.EnvironmentConfig
-		svcCfg *config.ServiceConfig
-		// envName string
-	)
-
+func autoValidateWithOutput(out io.Writer, file, env, modules, format string, sources bool) error {
 	kind, err := config.DetectKind(file)
 	if err != nil {
 		return err
 	}
 
+	var (
+		ds      config.Diagnostics
+		summary string
+		idx     *config.SourceIndex
+	)
 	switch kind {
 	case "Environment":
-		cfg, err := config.LoadEnvironmentConfig(file)
+		envCfg, diags, err := config.LoadEnvironmentConfigDiagnostics(file)
 		if err != nil {
 			return err
 		}
-		_, err = selectEnvName(kind, env, cfg, nil)
-		if err != nil {
-			return err
+		ds = diags
+		if !ds.HasErrors() {
+			envName, err := selectEnvName(kind, env, envCfg, nil)
+			if err != nil {
+				return err
+			}
+			modDiags, err := stackModuleDiagnostics(envCfg, nil, sortedKeys(envCfg.Environments), modules)
+			if err != nil {
+				return err
+			}
+			ds = append(ds, modDiags...)
+			ds = append(ds, secretDiagnostics(envCfg, nil)...)
+			if !ds.HasErrors() {
+				graphDiags, err := graphDiagnostics(file, envCfg, nil, envName, modules)
+				if err != nil {
+					return err
+				}
+				ds = append(ds, graphDiags...)
+			}
+			idx = envCfg.Sources
+			summary = fmt.Sprintf("Environment %q is valid (provider=%s, org=%s)\n",
+				envCfg.Metadata.Name,
+				envCfg.Metadata.Provider,
+				envCfg.Metadata.Org,
+			)
 		}
-		envCfg = cfg
-		fmt.Fprintf(out, "Environment %q is valid (provider=%s, org=%s)\n",
-			envCfg.Metadata.Name,
-			envCfg.Metadata.Provider,
-			envCfg.Metadata.Org,
-		)
 
 	case "Service":
-		svc, envConfig, err := config.LoadService(file)
+		svcCfg, envCfg, diags, err := config.LoadServiceDiagnostics(file)
 		if err != nil {
 			return err
 		}
-		_, err = selectEnvName(kind, env, envConfig, svc)
-		if err != nil {
-			return err
+		ds = diags
+		if !ds.HasErrors() {
+			envName, err := selectEnvName(kind, env, envCfg, svcCfg)
+			if err != nil {
+				return err
+			}
+			modDiags, err := stackModuleDiagnostics(envCfg, svcCfg, sortedKeys(svcCfg.Metadata.EnvRef), modules)
+			if err != nil {
+				return err
+			}
+			ds = append(ds, modDiags...)
+			ds = append(ds, secretDiagnostics(envCfg, svcCfg)...)
+			if !ds.HasErrors() {
+				graphDiags, err := graphDiagnostics(file, envCfg, svcCfg, envName, modules)
+				if err != nil {
+					return err
+				}
+				ds = append(ds, graphDiags...)
+			}
+			idx = svcCfg.Sources
+			summary = fmt.Sprintf("Service %q is valid and uses Environment %q (provider=%s)\n",
+				svcCfg.Metadata.Name,
+				envCfg.Metadata.Name,
+				envCfg.Metadata.Provider,
+			)
 		}
-		svcCfg = svc
-		envCfg = envConfig
-		fmt.Fprintf(out, "Service %q is valid and uses Environment %q (provider=%s)\n",
-			svcCfg.Metadata.Name,
-			envCfg.Metadata.Name,
-			envCfg.Metadata.Provider,
-		)
 
 	default:
 		return fmt.Errorf("unknown or missing kind in %s (expected Environment or Service)", file)
 	}
 
-	// Run lint suggestions alongside validation.
+	ds.Sort()
+	if err := reportDiagnostics(out, ds, format, summary); err != nil {
+		return err
+	}
+	if f := strings.ToLower(strings.TrimSpace(format)); sources && (f == "" || f == "text") {
+		printValueSources(out, idx)
+	}
 	return nil
 }
 
+// printValueSources lists every final spec value with the file and line that supplied it,
+// which shows how extends/imports were resolved.
+func printValueSources(out io.Writer, idx *config.SourceIndex) {
+	values := idx.Values()
+	if len(values) == 0 {
+		return
+	}
+	width := 0
+	for _, v := range values {
+		width = max(width, len(v.Path))
+	}
+	fmt.Fprintln(out, "Value sources:")
+	for _, v := range values {
+		fmt.Fprintf(out, "  %-*s  %s:%d\n", width, v.Path, v.File, v.Line)
+	}
+}
+
+// reportDiagnostics renders diagnostics in the requested format and fails when any are errors.
+// The summary line is only printed in text mode and only when validation succeeded.
+func reportDiagnostics(out io.Writer, ds config.Diagnostics, format, summary string) error {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "text":
+		if len(ds) > 0 {
+			config.RenderDiagnosticsText(out, ds)
+		}
+		if !ds.HasErrors() {
+			fmt.Fprint(out, summary)
+		}
+	case "json":
+		if err := config.RenderDiagnosticsJSON(out, ds); err != nil {
+			return err
+		}
+	case "sarif":
+		if err := config.RenderDiagnosticsSARIF(out, ds); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported output format %q (use text|json|sarif)", format)
+	}
+	if n := len(ds.Errors()); n > 0 {
+		return fmt.Errorf("validation failed with %d error(s)", n)
+	}
+	return nil
+}
+
+// stackModuleDiagnostics checks module input contracts and capabilities for each environment key in
+// keys, with that environment's overrides applied, so a module an override disables or re-configures
+// is checked the way generate renders it. Findings are positioned in the spec that declares them and
+// findings shared by several environments are reported once. svcCfg is nil for Environment specs.
+func stackModuleDiagnostics(envCfg *config.EnvironmentConfig, svcCfg *config.ServiceConfig, keys []string, modulesRoot string) (config.Diagnostics, error) {
+	var ds config.Diagnostics
+	seen := map[config.Diagnostic]struct{}{}
+	for _, key := range keys {
+		if _, ok := envCfg.Environments[key]; !ok {
+			continue
+		}
+		envMods, err := envCfg.ModulesFor(key)
+		if err != nil {
+			// Invalid overrides are reported by spec validation itself.
+			continue
+		}
+		var kds config.Diagnostics
+		if svcCfg == nil {
+			kds, err = moduleDiagnostics(envMods, nil, modulesRoot)
+			if err != nil {
+				return nil, err
+			}
+			kds = kds.MapPaths(func(p string) string { return envCfg.SpecPathFor(key, p) }).Locate(envCfg.Sources)
+		} else {
+			kds, err = moduleDiagnostics(svcCfg.Modules, envMods, modulesRoot)
+			if err != nil {
+				return nil, err
+			}
+			kds = kds.Locate(svcCfg.Sources)
+		}
+		for _, d := range kds {
+			if _, dup := seen[d]; dup {
+				continue
+			}
+			seen[d] = struct{}{}
+			ds = append(ds, d)
+		}
+	}
+	return ds, nil
+}
+
+// moduleDiagnostics checks mods against the module.yaml contracts of their types and reports
+// capabilities they accept that nothing in the stack provides. parent is the Environment's modules
+// when mods belong to a Service. Paths are modules[i] of mods.
+func moduleDiagnostics(mods, parent []config.Module, modulesRoot string) (config.Diagnostics, error) {
+	var ds config.Diagnostics
+	err := checkModuleContracts(mods, modulesRoot)
+	var inErr *config.InputValidationError
+	switch {
+	case errors.As(err, &inErr):
+		ds = inErr.Diagnostics()
+	case err != nil:
+		return nil, err
+	}
+	capDiags, err := capabilityDiagnostics(mods, parent, modulesRoot)
+	if err != nil {
+		return nil, err
+	}
+	return append(ds, capDiags...), nil
+}
+
+// secretDiagnostics checks every secret reference against its resolver, positioned at the secret
+// that declares it. svcCfg is nil for Environment specs.
+func secretDiagnostics(envCfg *config.EnvironmentConfig, svcCfg *config.ServiceConfig) config.Diagnostics {
+	var ds config.Diagnostics
+	check := func(path string, refs map[string]config.SecretRef) {
+		for _, name := range sortedKeys(refs) {
+			if err := secrets.Validate(map[string]config.SecretRef{name: refs[name]}, envCfg.Metadata.Provider); err != nil {
+				ds.Errorf(path+"."+name, "%v", err)
+			}
+		}
+	}
+	if svcCfg == nil {
+		for _, key := range sortedKeys(envCfg.Environments) {
+			check("environments."+key+".secrets", envCfg.Environments[key].Secrets)
+		}
+		return ds.Locate(envCfg.Sources)
+	}
+	for _, key := range sortedKeys(svcCfg.Metadata.EnvRef) {
+		check("metadata.envRef."+key+".secrets", svcCfg.Metadata.EnvRef[key].Secrets)
+	}
+	return ds.Locate(svcCfg.Sources)
+}
+
+// checkModuleContracts validates module inputs in a spec against the module.yaml of each module type.
+func checkModuleContracts(mods []config.Module, modulesRoot string) error {
+	metas, err := loadModuleMetas(mods, modulesRoot)
+	if err != nil {
+		return err
+	}
+	return config.ValidateModuleInputs(mods, metas, "")
+}
+
+// capabilityDiagnostics reports capabilities that modules accept but nothing in the stack provides.
+// parent is the Environment's modules when mods belong to a Service.
+func capabilityDiagnostics(mods, parent []config.Module, modulesRoot string) (config.Diagnostics, error) {
+	metas, err := loadModuleMetas(append(append([]config.Module{}, mods...), parent...), modulesRoot)
+	if err != nil {
+		return nil, err
+	}
+	return config.DiagnoseCapabilities(mods, parent, metas), nil
+}
+
+// graphDiagnostics resolves the module dependency graph of the stack for env, as generate would,
+// and reports cycles and references the stack cannot reach. A stack that does not resolve yet,
+// for example because a required input is left to --var, gets a warning that the graph was not
+// checked.
+func graphDiagnostics(file string, envCfg *config.EnvironmentConfig, svcCfg *config.ServiceConfig, env, modulesRoot string) (config.Diagnostics, error) {
+	embeddedRoot, customRoot, err := resolveModuleRoots(modulesRoot)
+	if err != nil {
+		return nil, err
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	specDir := filepath.Dir(absFile)
+
+	var ds config.Diagnostics
+	idx := envCfg.Sources
+	if svcCfg != nil {
+		idx = svcCfg.Sources
+		ds, err = generate.DiagnoseServiceGraph(svcCfg, envCfg, embeddedRoot, customRoot, env, specDir, nil)
+	} else {
+		ds, err = generate.DiagnoseEnvironmentGraph(envCfg, embeddedRoot, customRoot, env, specDir, nil)
+	}
+	if err != nil {
+		ds = nil
+		ds.Warnf("modules", "module dependency graph not checked: %v", err)
+	}
+	return ds.Locate(idx), nil
+}
+
+// loadModuleMetas reads the module.yaml of every module type used by mods, keyed by type. The
+// custom root takes precedence over the embedded modules.
+func loadModuleMetas(mods []config.Module, modulesRoot string) (map[string]*config.ModuleMetadata, error) {
+	embeddedRoot, customRoot, err := resolveModuleRoots(modulesRoot)
+	if err != nil {
+		return nil, err
+	}
+	var roots []string
+	if customRoot != "" {
+		roots = append(roots, customRoot)
+	}
+	roots = append(roots, embeddedRoot)
+
+	moduleTypes := make([]string, 0, len(mods))
+	for _, m := range mods {
+		moduleTypes = append(moduleTypes, m.Type)
+	}
+	records, err := config.ScanModuleRoots(roots, moduleTypes)
+	if err != nil {
+		return nil, err
+	}
+	metas := make(map[string]*config.ModuleMetadata, len(records))
+	for t, rec := range records {
+		metas[t] = rec.Meta
+	}
+	return metas, nil
+}
+
 func autoValidateWithScan(out io.Writer, file, env, modules string) error {
 	absFile, err := filepath.Abs(file)
 	if err != nil {
@@ -106,7 +351,7 @@ func autoValidateWithScan(out io.Writer, file, env, modules string) error {
 			envCfg.Metadata.Provider,
 			envCfg.Metadata.Org,
 		)
-		if err := generate.GenerateEnvironmentTF(envCfg, embeddedRoot, customRoot, envName, outDir, specDir, nil); err != nil {
+		if _, err := generate.GenerateEnvironmentTF(envCfg, embeddedRoot, customRoot, envName, outDir, specDir, nil); err != nil {
 			return err
 		}
 
@@ -124,7 +369,7 @@ func autoValidateWithScan(out io.Writer, file, env, modules string) error {
 			envCfg.Metadata.Name,
 			envCfg.Metadata.Provider,
 		)
-		if err := generate.GenerateServiceTF(svcCfg, envCfg, embeddedRoot, customRoot, envName, outDir, specDir, nil); err != nil {
+		if _, err := generate.GenerateServiceTF(svcCfg, envCfg, embeddedRoot, customRoot, envName, outDir, specDir, nil); err != nil {
 			return err
 		}
 
@@ -175,11 +420,13 @@ func autoGenerate(file, env, modulesRoot, out string, vars []string) error {
 		}
 		out = filepath.Clean(out)
 
-		if err := generate.GenerateEnvironmentTF(envCfg, embeddedRoot, customRoot, envName, out, specDir, cliVars); err != nil {
+		changes, err := generate.GenerateEnvironmentTF(envCfg, embeddedRoot, customRoot, envName, out, specDir, cliVars)
+		if err != nil {
 			return err
 		}
-		fmt.Printf("Generated Environment Terraform for %q (env=%s) into %s\n",
-			envCfg.Metadata.Name, envName, out)
+		fmt.Printf("Generated Environment Terraform for %q (env=%s) into %s (%s)\n",
+			envCfg.Metadata.Name, envName, out, changes)
+		printChanges(os.Stdout, changes)
 		return nil
 
 	case "Service":
@@ -196,11 +443,13 @@ func autoGenerate(file, env, modulesRoot, out string, vars []string) error {
 		}
 		out = filepath.Clean(out)
 
-		if err := generate.GenerateServiceTF(svcCfg, envCfg, embeddedRoot, customRoot, envName, out, specDir, cliVars); err != nil {
+		changes, err := generate.GenerateServiceTF(svcCfg, envCfg, embeddedRoot, customRoot, envName, out, specDir, cliVars)
+		if err != nil {
 			return err
 		}
-		fmt.Printf("Generated Service Terraform for %q (env=%s) into %s\n",
-			svcCfg.Metadata.Name, envName, out)
+		fmt.Printf("Generated Service Terraform for %q (env=%s) into %s (%s)\n",
+			svcCfg.Metadata.Name, envName, out, changes)
+		printChanges(os.Stdout, changes)
 		return nil
 
 	default:
@@ -208,6 +457,70 @@ func autoGenerate(file, env, modulesRoot, out string, vars []string) error {
 	}
 }
 
+// autoCheck compares the output directory of a single spec with a fresh rendering and fails with a
+// unified diff when they differ.
+func autoCheck(w io.Writer, file, env, modulesRoot, out string, vars []string) error {
+	kind, err := config.DetectKind(file)
+	if err != nil {
+		return err
+	}
+	cliVars, err := parseVarFlags(vars)
+	if err != nil {
+		return err
+	}
+	embeddedRoot, customRoot, err := resolveModuleRoots(modulesRoot)
+	if err != nil {
+		return err
+	}
+
+	s := specStack{label: file, kind: kind, file: file}
+	switch kind {
+	case "Environment":
+		if s.envCfg, err = config.LoadEnvironmentConfig(file); err != nil {
+			return err
+		}
+	case "Service":
+		if s.svcCfg, s.envCfg, err = config.LoadService(file); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported kind %q", kind)
+	}
+	envName, err := selectEnvName(kind, env, s.envCfg, s.svcCfg)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		out = s.outDir("", envName)
+	}
+	out = filepath.Clean(out)
+
+	drift, err := s.check(embeddedRoot, customRoot, envName, out, cliVars)
+	if err != nil {
+		return err
+	}
+	if reportDrift(w, s, envName, out, drift) {
+		return fmt.Errorf("generated Terraform in %s is out of date; run pltf generate", out)
+	}
+	return nil
+}
+
+// printChanges lists the files a generate run added (+), updated (~) or removed (-).
+func printChanges(w io.Writer, c generate.Changes) {
+	for _, f := range c.Added {
+		fmt.Fprintf(w, "  + %s\n", f)
+	}
+	for _, f := range c.Updated {
+		fmt.Fprintf(w, "  ~ %s\n", f)
+	}
+	for _, f := range c.Removed {
+		fmt.Fprintf(w, "  - %s\n", f)
+	}
+	for _, msg := range c.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", msg)
+	}
+}
+
 // autoGenerateQuiet renders Terraform without printing status messages. Used by graph command to keep DOT output clean.
 func autoGenerateQuiet(file, env, modulesRoot, out string, vars []string) error {
 	absFile, err := filepath.Abs(file)
@@ -246,7 +559,7 @@ func autoGenerateQuiet(file, env, modulesRoot, out string, vars []string) error
 		}
 		out = filepath.Clean(out)
 
-		if err := generate.GenerateEnvironmentTF(envCfg, embeddedRoot, customRoot, envName, out, specDir, cliVars); err != nil {
+		if _, err := generate.GenerateEnvironmentTF(envCfg, embeddedRoot, customRoot, envName, out, specDir, cliVars); err != nil {
 			return err
 		}
 		return nil
@@ -265,7 +578,7 @@ func autoGenerateQuiet(file, env, modulesRoot, out string, vars []string) error
 		}
 		out = filepath.Clean(out)
 
-		if err := generate.GenerateServiceTF(svcCfg, envCfg, embeddedRoot, customRoot, envName, out, specDir, cliVars); err != nil {
+		if _, err := generate.GenerateServiceTF(svcCfg, envCfg, embeddedRoot, customRoot, envName, out, specDir, cliVars); err != nil {
 			return err
 		}
 		return nil
@@ -36,7 +36,7 @@ func TestAutoValidateRequiresEnvSelection(t *testing.T) {
 	_ = os.Unsetenv("PLTF_DEFAULT_ENV")
 
 	envCfg := config.EnvironmentConfig{
-		APIVersion: "v1",
+		APIVersion: "platform.io/v1",
 		Kind:       "Environment",
 		Metadata: config.EnvironmentMetadata{
 			Name:     "example",
@@ -57,7 +57,7 @@ func TestAutoValidateRequiresEnvSelection(t *testing.T) {
 	writeYAML(t, envPath, envCfg)
 
 	var buf bytes.Buffer
-	err := autoValidateWithOutput(&buf, envPath, "")
+	err := autoValidateWithOutput(&buf, envPath, "", "", "text", false)
 	if err == nil || !strings.Contains(err.Error(), "--env is required") {
 		t.Fatalf("expected env selection error, got %v (output=%s)", err, buf.String())
 	}
@@ -69,7 +69,7 @@ func TestAutoValidateEmitsLintSuggestions(t *testing.T) {
 	_ = os.Unsetenv("PLTF_DEFAULT_ENV")
 
 	envCfg := config.EnvironmentConfig{
-		APIVersion: "v1",
+		APIVersion: "platform.io/v1",
 		Kind:       "Environment",
 		Metadata: config.EnvironmentMetadata{
 			Name:     "demo",
@@ -93,7 +93,7 @@ func TestAutoValidateEmitsLintSuggestions(t *testing.T) {
 	writeYAML(t, envPath, envCfg)
 
 	var buf bytes.Buffer
-	if err := autoValidateWithOutput(&buf, envPath, "dev"); err != nil {
+	if err := autoValidateWithOutput(&buf, envPath, "dev", "", "text", false); err != nil {
 		t.Fatalf("autoValidateWithOutput returned error: %v", err)
 	}
 
@@ -102,3 +102,211 @@ func TestAutoValidateEmitsLintSuggestions(t *testing.T) {
 		t.Fatalf("expected validation output, got: %s", out)
 	}
 }
+
+func TestSpecSetValidatesAndGeneratesEveryStack(t *testing.T) {
+	resetProfileCache()
+	_ = os.Unsetenv("PLTF_DEFAULT_ENV")
+
+	dir := t.TempDir()
+	env := `apiVersion: platform.io/v1
+kind: Environment
+metadata:
+  name: shared
+  org: acme
+  provider: aws
+  labels:
+    team: platform
+environments:
+  dev:
+    account: "111111111111"
+    region: us-east-1
+  prod:
+    account: "222222222222"
+    region: us-west-2
+modules:
+  - id: base
+    type: aws_base
+`
+	svc := func(name, envs string) string {
+		return `apiVersion: platform.io/v1
+kind: Service
+metadata:
+  name: ` + name + `
+  ref: shared
+  envRef:
+` + envs + `modules:
+  - id: bucket
+    type: aws_s3
+    inputs:
+      bucket_name: ` + name + `-data
+`
+	}
+	if err := os.WriteFile(filepath.Join(dir, "env.yaml"), []byte(env), 0o644); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+	services := svc("api", "    dev: {}\n    prod: {}\n") + "---\n" + svc("worker", "    dev: {}\n")
+	if err := os.WriteFile(filepath.Join(dir, "services.yaml"), []byte(services), 0o644); err != nil {
+		t.Fatalf("write services: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := validateSpecSet(&buf, dir, "prod", "", "text"); err != nil {
+		t.Fatalf("validateSpecSet returned error: %v (output=%s)", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "Validated 3 spec(s)") {
+		t.Fatalf("expected set summary, got: %s", buf.String())
+	}
+
+	buf.Reset()
+	outRoot := filepath.Join(dir, "out")
+	if err := generateSpecSet(&buf, dir, "prod", "", outRoot, nil); err != nil {
+		t.Fatalf("generateSpecSet returned error: %v", err)
+	}
+	for _, stack := range []string{"shared/env/prod", "shared/api/env/prod"} {
+		if _, err := os.Stat(filepath.Join(outRoot, stack, "versions.tf")); err != nil {
+			t.Fatalf("expected %s to be generated: %v", stack, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outRoot, "shared/worker")); !os.IsNotExist(err) {
+		t.Fatalf("worker has no prod environment and should be skipped")
+	}
+	if !strings.Contains(buf.String(), `Skipped Service "worker"`) {
+		t.Fatalf("expected skipped stack to be reported, got: %s", buf.String())
+	}
+}
+
+func TestAutoValidateReportsDependencyCycles(t *testing.T) {
+	t.Parallel()
+	resetProfileCache()
+
+	envCfg := config.EnvironmentConfig{
+		APIVersion: "platform.io/v1",
+		Kind:       "Environment",
+		Metadata: config.EnvironmentMetadata{
+			Name:     "demo",
+			Org:      "acme",
+			Provider: "aws",
+			Labels:   map[string]string{"team": "platform"},
+		},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{
+			{ID: "net", Type: "aws_base"},
+			{ID: "edge", Type: "aws_base"},
+		},
+	}
+
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "env.yaml")
+	writeYAML(t, envPath, envCfg)
+
+	var buf bytes.Buffer
+	err := autoValidateWithOutput(&buf, envPath, "dev", "", "text", false)
+	if err == nil {
+		t.Fatalf("expected validation to fail, got output: %s", buf.String())
+	}
+	out := buf.String()
+	if !strings.Contains(out, `module dependency cycle: "edge" -> "net" (input private_subnet_ids) -> "edge" (input private_subnet_ids)`) ||
+		!strings.Contains(out, "(modules[1].inputs.private_subnet_ids)") {
+		t.Fatalf("expected a located cycle diagnostic, got: %s", out)
+	}
+}
+
+func TestAutoValidateChecksModulesPerEnvironment(t *testing.T) {
+	t.Parallel()
+	resetProfileCache()
+
+	modules := t.TempDir()
+	for _, meta := range []config.ModuleMetadata{
+		{
+			Name: "store", Type: "store", Provider: "aws", Version: "1.0.0",
+			Capabilities: config.Capabilities{Provides: []string{"store.arn"}},
+			Outputs:      []config.OutputSpec{{Name: "store_arn", Type: "string", Capability: "store.arn"}},
+		},
+		{
+			Name: "client", Type: "client", Provider: "aws", Version: "1.0.0",
+			Capabilities: config.Capabilities{Accepts: []string{"store.arn"}},
+			Inputs: []config.InputSpec{
+				{Name: "target_arn", Type: "string", Required: true, Capability: "store.arn"},
+				{Name: "replicas", Type: "number"},
+			},
+		},
+	} {
+		if err := os.MkdirAll(filepath.Join(modules, meta.Type), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeYAML(t, filepath.Join(modules, meta.Type, "module.yaml"), meta)
+	}
+
+	off := false
+	envCfg := config.EnvironmentConfig{
+		APIVersion: "platform.io/v1",
+		Kind:       "Environment",
+		Metadata: config.EnvironmentMetadata{
+			Name:     "demo",
+			Org:      "acme",
+			Provider: "aws",
+			Labels:   map[string]string{"team": "platform"},
+		},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+			"prod": {Account: "222222222222", Region: "us-west-2", Modules: map[string]config.ModuleOverride{
+				"kv":  {Enabled: &off},
+				"app": {Inputs: map[string]interface{}{"replicas": "many"}},
+			}},
+		},
+		Modules: []config.Module{
+			{ID: "kv", Type: "store"},
+			{ID: "app", Type: "client", Inputs: map[string]interface{}{"replicas": 2}},
+		},
+	}
+
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "env.yaml")
+	writeYAML(t, envPath, envCfg)
+
+	var buf bytes.Buffer
+	err := autoValidateWithOutput(&buf, envPath, "dev", modules, "text", false)
+	if err == nil {
+		t.Fatalf("expected the prod override to fail validation, got output: %s", buf.String())
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`(environments.prod.modules.app.inputs.replicas)`,
+		`(modules[1].inputs.target_arn)`,
+		`accepts capability "store.arn", but no module in the stack provides it`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got: %s", want, out)
+		}
+	}
+	if strings.Count(out, `accepts capability "store.arn"`) != 1 {
+		t.Fatalf("expected the capability error only for prod, got: %s", out)
+	}
+}
+
+func TestCheckValidateFlagsRejectsIgnoredCombinations(t *testing.T) {
+	cases := []struct {
+		specSet, scan, sources bool
+		format                 string
+		want                   string
+	}{
+		{specSet: true, scan: true, format: "text", want: "--scan"},
+		{specSet: true, sources: true, format: "text", want: "--sources"},
+		{scan: true, sources: true, format: "text", want: "--sources"},
+		{scan: true, format: "sarif", want: "--output sarif"},
+	}
+	for _, tc := range cases {
+		err := checkValidateFlags(tc.specSet, tc.scan, tc.sources, tc.format)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%+v: expected error mentioning %q, got %v", tc, tc.want, err)
+		}
+	}
+	if err := checkValidateFlags(false, true, false, "text"); err != nil {
+		t.Fatalf("scan with text output: %v", err)
+	}
+	if err := checkValidateFlags(true, false, false, "json"); err != nil {
+		t.Fatalf("spec set with json output: %v", err)
+	}
+}
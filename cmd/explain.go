@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pltf/pkg/generate"
+)
+
+var (
+	explainFile string
+	explainEnv  string
+	explainOut  string
+)
+
+// explainCmd reads the source map written by generate and reports where module inputs come from.
+var explainCmd = &cobra.Command{
+	Use:   "explain <module>[.<input>]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Show where a generated module input comes from",
+	Long: `Read the pltf-sourcemap.json that generate writes next to the Terraform and report where
+the value of a module input comes from: the module's inputs, an environment override, links,
+wire, a platform field, a capability or output auto-wired from another module, a variable, or
+the module.yaml default, with the spec file and line that supplied it.
+
+With only a module id, every input the module sets is listed. Run generate first; explain reads
+the output directory and does not render anything.`,
+	Example: `  pltf explain eks.vpc_id -f env.yaml -e dev
+  pltf explain postgres -f service.yaml -e prod`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		explainFile = cleanOptionalPath(defaultString(explainFile, "env.yaml"))
+		explainEnv = strings.TrimSpace(explainEnv)
+		explainOut = cleanOptionalPath(explainOut)
+		return ensureSpecPath(explainFile)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExplain(os.Stdout, explainFile, explainEnv, explainOut, args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+
+	explainCmd.Flags().StringVarP(&explainFile, "file", "f", "env.yaml", "Path to the Environment or Service YAML file")
+	explainCmd.Flags().StringVarP(&explainEnv, "env", "e", "", "Environment key the output was generated for (dev, prod, etc.)")
+	explainCmd.Flags().StringVarP(&explainOut, "out", "o", "", "Output directory generate wrote to (defaults based on kind: .pltf/<env_name>/env/<env> or .pltf/<env_name>/<service>/env/<env>)")
+}
+
+func runExplain(w io.Writer, file, env, out, target string) error {
+	ctx, err := prepareStackContext(file, env, out)
+	if err != nil {
+		return err
+	}
+	sm, err := generate.ReadSourceMap(ctx.outDir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no %s in %s; run pltf generate -f %s -e %s first", generate.SourceMapFile, ctx.outDir, file, ctx.env)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Module ids may contain dots, so a whole-id match wins over module.input.
+	if ms, ok := sm.Modules[target]; ok {
+		printModuleSources(w, target, ms)
+		return nil
+	}
+	i := strings.LastIndex(target, ".")
+	if i <= 0 {
+		return fmt.Errorf("module %q not found in %s; modules: %s", target, ctx.outDir, strings.Join(sortedKeys(sm.Modules), ", "))
+	}
+	modID, input := target[:i], target[i+1:]
+	ms, ok := sm.Modules[modID]
+	if !ok {
+		return fmt.Errorf("module %q not found in %s; modules: %s", modID, ctx.outDir, strings.Join(sortedKeys(sm.Modules), ", "))
+	}
+	src, ok := ms.Inputs[input]
+	if !ok {
+		return fmt.Errorf("module %q does not set input %q; inputs: %s", modID, input, strings.Join(sortedKeys(ms.Inputs), ", "))
+	}
+
+	fmt.Fprintf(w, "%s.%s = %s\n", modID, input, src.Value)
+	fmt.Fprintf(w, "  source: %s\n", describeSource(src))
+	if src.Spec != nil {
+		fmt.Fprintf(w, "  spec:   %s\n", src.Spec)
+	}
+	fmt.Fprintf(w, "  file:   %s\n", ms.File)
+	return nil
+}
+
+func printModuleSources(w io.Writer, modID string, ms generate.ModuleSources) {
+	fmt.Fprintf(w, "module %q (%s) in %s", modID, ms.Type, ms.File)
+	if ms.Spec != nil {
+		fmt.Fprintf(w, ", from %s", ms.Spec)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INPUT\tSOURCE\tSPEC")
+	for _, name := range sortedKeys(ms.Inputs) {
+		src := ms.Inputs[name]
+		spec := "-"
+		if src.Spec != nil {
+			spec = src.Spec.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, describeSource(src), spec)
+	}
+	tw.Flush()
+}
+
+func describeSource(src generate.InputSource) string {
+	if src.Detail == "" {
+		return src.Source
+	}
+	return fmt.Sprintf("%s (%s)", src.Source, src.Detail)
+}
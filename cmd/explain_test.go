@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pltf/pkg/config"
+)
+
+func TestExplainReportsInputSources(t *testing.T) {
+	resetProfileCache()
+
+	envCfg := config.EnvironmentConfig{
+		APIVersion: "platform.io/v1",
+		Kind:       "Environment",
+		Metadata:   config.EnvironmentMetadata{Name: "demo", Org: "acme", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{
+			{ID: "base", Type: "aws_base"},
+			{ID: "eks", Type: "aws_eks", Inputs: map[string]interface{}{"cluster_name": "eks", "enable_metrics": true}},
+		},
+	}
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "env.yaml")
+	writeYAML(t, envPath, envCfg)
+	out := filepath.Join(dir, "out")
+
+	var buf bytes.Buffer
+	if err := runExplain(&buf, envPath, "dev", out, "eks.vpc_id"); err == nil || !strings.Contains(err.Error(), "run pltf generate") {
+		t.Fatalf("expected explain to ask for generate first, got %v", err)
+	}
+	if err := autoGenerateQuiet(envPath, "dev", "", out, nil); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if err := runExplain(&buf, envPath, "dev", out, "eks.vpc_id"); err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	got := buf.String()
+	if !strings.Contains(got, "eks.vpc_id = module.base.vpc_id") ||
+		!strings.Contains(got, `source: auto-wire (output vpc_id of module "base")`) ||
+		!strings.Contains(got, "spec:   env.yaml:") {
+		t.Fatalf("unexpected explain output:\n%s", got)
+	}
+
+	buf.Reset()
+	if err := runExplain(&buf, envPath, "dev", out, "eks"); err != nil {
+		t.Fatalf("explain module: %v", err)
+	}
+	if !strings.Contains(buf.String(), "cluster_name") || !strings.Contains(buf.String(), "(modules[1].inputs.cluster_name)") {
+		t.Fatalf("expected the module's inputs to be listed, got:\n%s", buf.String())
+	}
+
+	if err := runExplain(&buf, envPath, "dev", out, "eks.nope"); err == nil || !strings.Contains(err.Error(), `does not set input "nope"`) {
+		t.Fatalf("expected an unknown input error, got %v", err)
+	}
+}
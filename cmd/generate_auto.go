@@ -1,8 +1,12 @@
 package cmd
 
 import (
-	"github.com/spf13/cobra"
+	"os"
 	"strings"
+
+	"github.com/spf13/cobra"
+
+	"pltf/pkg/config"
 )
 
 var (
@@ -11,6 +15,7 @@ var (
 	autoGenOut        string
 	autoGenModulesDir string
 	autoGenVars       []string
+	autoGenCheck      bool
 )
 
 // generateCmd auto-detects whether the file is an Environment or Service spec and generates accordingly.
@@ -20,9 +25,19 @@ var generateCmd = &cobra.Command{
 	Short: "Generate Terraform from an Environment or Service spec (auto-detects kind)",
 	Long: `Read a YAML spec, detect Environment vs Service, and render Terraform with the proper
 remote state, providers, locals, secrets, and module wiring. Uses embedded modules by
-default; can override modules root and output directory.`,
+default; can override modules root and output directory.
+
+-f also accepts a directory of specs or a multi-document YAML file. Each Environment and
+Service in the set is rendered into the standard layout, with -o replacing the .pltf root.
+With -e, specs that do not define that environment are skipped.
+
+--check renders into a temporary directory instead and compares it with the existing output,
+printing a unified diff and exiting non-zero when they differ. Use it in CI to catch hand-edited
+or stale generated Terraform.`,
 	Example: `  pltf generate -f env.yaml -e dev
-  pltf generate -f service.yaml -e prod -m ./modules -o .pltf/my-env/my-svc/env/prod`,
+  pltf generate -f service.yaml -e prod -m ./modules -o .pltf/my-env/my-svc/env/prod
+  pltf generate -f ./specs -e dev
+  pltf generate -f env.yaml -e prod --check`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		autoGenFile = defaultString(autoGenFile, "env.yaml")
 		autoGenFile = cleanOptionalPath(autoGenFile)
@@ -30,12 +45,21 @@ default; can override modules root and output directory.`,
 		autoGenModulesDir = cleanOptionalPath(autoGenModulesDir)
 		autoGenOut = cleanOptionalPath(autoGenOut)
 
-		if err := ensureFile(autoGenFile, "spec file"); err != nil {
+		if err := ensureSpecPath(autoGenFile); err != nil {
 			return err
 		}
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if autoGenCheck {
+			if config.IsSpecSet(autoGenFile) {
+				return checkSpecSet(os.Stdout, autoGenFile, autoGenEnv, autoGenModulesDir, autoGenOut, autoGenVars)
+			}
+			return autoCheck(os.Stdout, autoGenFile, autoGenEnv, autoGenModulesDir, autoGenOut, autoGenVars)
+		}
+		if config.IsSpecSet(autoGenFile) {
+			return generateSpecSet(os.Stdout, autoGenFile, autoGenEnv, autoGenModulesDir, autoGenOut, autoGenVars)
+		}
 		return autoGenerate(autoGenFile, autoGenEnv, autoGenModulesDir, autoGenOut, autoGenVars)
 	},
 }
@@ -43,9 +67,10 @@ default; can override modules root and output directory.`,
 func init() {
 	rootCmd.AddCommand(generateCmd)
 
-	generateCmd.Flags().StringVarP(&autoGenFile, "file", "f", "env.yaml", "Path to the Environment or Service YAML file")
+	generateCmd.Flags().StringVarP(&autoGenFile, "file", "f", "env.yaml", "Path to the Environment or Service YAML file, a multi-document YAML file, or a directory of specs")
 	generateCmd.Flags().StringVarP(&autoGenEnv, "env", "e", "", "Environment key to render (dev, prod, etc.); required for both env and service specs")
 	generateCmd.Flags().StringVarP(&autoGenModulesDir, "modules", "m", "", "Root directory containing module type folders with module.yaml metadata; defaults to embedded modules bundle")
 	generateCmd.Flags().StringVarP(&autoGenOut, "out", "o", "", "Output directory for generated Terraform (defaults based on kind: .pltf/<env_name>/env/<env> or .pltf/<env_name>/<service>/env/<env>)")
 	generateCmd.Flags().StringArrayVarP(&autoGenVars, "var", "v", nil, "Override variable as key=value; merges over vars and supports bool/int/JSON/list parsing. Can be repeated for multiple overrides.")
+	generateCmd.Flags().BoolVar(&autoGenCheck, "check", false, "Do not write anything; compare the output directory with a fresh rendering and exit non-zero with a unified diff if it is out of date")
 }
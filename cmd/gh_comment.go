@@ -3,13 +3,21 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"path/filepath"
 	"strings"
 
 	"pltf/pkg/git"
+	"pltf/pkg/plan"
+	"pltf/pkg/policy"
 )
 
-const prCommentMarker = "<!-- pltf:terraform-run -->"
+// prCommentMarkerFor identifies the PR comment of one stack, so the stacks of an --all run each keep
+// their own comment instead of overwriting one another's.
+func prCommentMarkerFor(run tfRunSummary) string {
+	stack := strings.ReplaceAll(filepath.ToSlash(run.Spec), "--", "-")
+	return fmt.Sprintf("<!-- pltf:terraform-run spec=%s env=%s -->", stack, run.Env)
+}
 
 type tfRunSummary struct {
 	Action string
@@ -22,24 +30,31 @@ type tfRunSummary struct {
 	AI     string
 	Scan   *tfsecSummary
 	Cost   *costSummary
+	// Approvals holds the state of the spec's approval gates for Env.
+	Approvals []approvalStatus
+	// Protected are the protected resources the plan deletes or replaces.
+	Protected []protectionViolation
+	// Policy holds the violations reported by the Rego policies.
+	Policy []policy.Result
 }
 
-func maybeUpsertPRComment(run tfRunSummary) error {
+// maybeUpsertPRComment creates or updates the stack's PR comment; notes go to w.
+func maybeUpsertPRComment(w io.Writer, run tfRunSummary) error {
 	body := buildPRCommentBody(run)
-	commenter, err := git.NewCommenter("")
+	commenter, err := newCommenter("")
 	if err != nil {
 		if errors.Is(err, git.ErrNoProvider) {
-			fmt.Fprintln(os.Stderr, "info: skipping PR comment, missing git provider credentials")
+			fmt.Fprintln(w, "info: skipping PR comment, missing git provider credentials")
 			return nil
 		}
 		if errors.Is(err, git.ErrProviderNotImplemented) {
-			fmt.Fprintf(os.Stderr, "info: skipping PR comment, provider not implemented: %v\n", err)
+			fmt.Fprintf(w, "info: skipping PR comment, provider not implemented: %v\n", err)
 			return nil
 		}
 		return err
 	}
 
-	if err := commenter.UpsertPRComment(git.PRComment{Body: body, Marker: prCommentMarker}); err != nil {
+	if err := commenter.UpsertPRComment(git.PRComment{Body: body, Marker: prCommentMarkerFor(run)}); err != nil {
 		if errors.Is(err, git.ErrNoPRNumber) {
 			return nil
 		}
@@ -59,16 +74,25 @@ func buildPRCommentBody(run tfRunSummary) string {
 	}
 
 	var sb strings.Builder
-	sb.WriteString(prCommentMarker)
+	sb.WriteString(prCommentMarkerFor(run))
 	sb.WriteString("\n\n")
 	sb.WriteString("### Terrateam Plan Output\n\n")
 	sb.WriteString(fmt.Sprintf("**%s** %s\n\n", run.Spec, statusEmoji+" "+titleCase(statusText)))
 
 	if run.Plan != nil {
-		sb.WriteString(fmt.Sprintf("Plan: %d to add, %d to change, %d to destroy\n\n",
-			run.Plan.Added, run.Plan.Changed, run.Plan.Destroyed))
+		sb.WriteString(fmt.Sprintf("Plan: %s\n\n", run.Plan.Counts))
+		writeModuleChanges(&sb, run.Plan)
+		for _, w := range run.Plan.Warnings {
+			sb.WriteString(fmt.Sprintf("> ⚠️ %s\n", w))
+		}
+		if len(run.Plan.Warnings) > 0 {
+			sb.WriteString("\n")
+		}
 	}
 
+	writeProtectedResources(&sb, run.Protected)
+	writePolicyResults(&sb, run.Policy)
+
 	sb.WriteString("<details><summary>Expand for plan output details</summary>\n\n")
 	sb.WriteString("```\n")
 	if run.Plan != nil && strings.TrimSpace(run.Plan.Text) != "" {
@@ -81,8 +105,7 @@ func buildPRCommentBody(run tfRunSummary) string {
 	}
 	sb.WriteString("```\n")
 	if run.Plan != nil {
-		sb.WriteString(fmt.Sprintf("\nPlan: %d to add, %d to change, %d to destroy\n",
-			run.Plan.Added, run.Plan.Changed, run.Plan.Destroyed))
+		sb.WriteString(fmt.Sprintf("\nPlan: %s\n", run.Plan.Counts))
 	}
 	if strings.TrimSpace(run.Err) != "" {
 		sb.WriteString(fmt.Sprintf("\nError: %s\n", truncateForComment(run.Err)))
@@ -116,9 +139,23 @@ func buildPRCommentBody(run tfRunSummary) string {
 		}
 	}
 
-	if strings.TrimSpace(run.AI) != "" || run.Scan != nil {
+	if strings.TrimSpace(run.AI) != "" || run.Scan != nil || len(run.Approvals) > 0 {
 		sb.WriteString("\n---\n\n")
 		sb.WriteString("**Approval Requirements**\n\n")
+		if len(run.Approvals) > 0 {
+			for _, st := range run.Approvals {
+				mark := "⏳"
+				if st.satisfied() {
+					mark = "✅"
+				}
+				sb.WriteString(fmt.Sprintf("- %s %s\n", mark, st))
+			}
+			if pending := outstandingApprovals(run.Approvals); len(pending) > 0 {
+				sb.WriteString(fmt.Sprintf("\n%d approval gate(s) outstanding; apply is blocked until they are satisfied.\n\n", len(pending)))
+			} else {
+				sb.WriteString("\nAll approval gates are satisfied.\n\n")
+			}
+		}
 		if strings.TrimSpace(run.AI) != "" {
 			sb.WriteString("AI risk review:\n")
 			sb.WriteString(run.AI)
@@ -156,6 +193,81 @@ func buildPRCommentBody(run tfRunSummary) string {
 	return sb.String()
 }
 
+// writeModuleChanges adds a table of changes per pltf module, then lists the replaced and destroyed
+// resources and drift, which are what reviewers need to notice.
+func writeModuleChanges(sb *strings.Builder, sum *planSummary) {
+	if len(sum.Modules) == 0 {
+		return
+	}
+	sb.WriteString("| Module | Add | Change | Replace | Destroy | Drift |\n")
+	sb.WriteString("| --- | ---: | ---: | ---: | ---: | ---: |\n")
+	for _, m := range sum.Modules {
+		sb.WriteString(fmt.Sprintf("| `%s` | %d | %d | %d | %d | %d |\n",
+			sum.moduleLabel(m.ID), m.Counts.Add, m.Counts.Change, m.Counts.Replace, m.Counts.Destroy, len(m.Drift)))
+	}
+	sb.WriteString("\n")
+
+	var notes []string
+	for _, m := range sum.Modules {
+		for _, rc := range m.Resources {
+			if rc.Action == plan.ActionReplace || rc.Action == plan.ActionDelete {
+				notes = append(notes, fmt.Sprintf("- `%s`: %s", sum.moduleLabel(m.ID), describeChange(rc)))
+			}
+		}
+		for _, rc := range m.Drift {
+			notes = append(notes, fmt.Sprintf("- `%s`: %s changed outside Terraform (%s)", sum.moduleLabel(m.ID), rc.Address, rc.Action))
+		}
+	}
+	if len(notes) > 0 {
+		sb.WriteString(strings.Join(notes, "\n"))
+		sb.WriteString("\n\n")
+	}
+}
+
+// writeProtectedResources lists the protected resources the plan deletes or replaces, and whether
+// --allow-destroy lets apply go ahead.
+func writeProtectedResources(sb *strings.Builder, vs []protectionViolation) {
+	if len(vs) == 0 {
+		return
+	}
+	sb.WriteString("**Protected resources**\n\n")
+	for _, v := range vs {
+		state := fmt.Sprintf("🛑 blocked; apply needs `--allow-destroy=%s`", v.Module)
+		if v.Allowed {
+			state = fmt.Sprintf("⚠️ allowed by `--allow-destroy=%s`", v.Module)
+		}
+		line := fmt.Sprintf("- `%s`: %s %s — %s", v.Module, v.Address, v.Action, state)
+		if v.Why != "" {
+			line += fmt.Sprintf(" (%s)", v.Why)
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n")
+}
+
+// writePolicyResults adds a table of the policy violations, denials first.
+func writePolicyResults(sb *strings.Builder, results []policy.Result) {
+	if len(results) == 0 {
+		return
+	}
+	denied, warned := policyCounts(results)
+	sb.WriteString(fmt.Sprintf("**Policy checks**: %d denied, %d warnings\n\n", denied, warned))
+	sb.WriteString("| Result | Severity | Policy | Where | Message |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, r := range results {
+		icon := "⚠️ warn"
+		if r.Enforcement == policy.Deny {
+			icon = "🛑 deny"
+		}
+		where := strings.TrimSuffix(strings.TrimPrefix(policyLocation(r), " ("), ")")
+		if where != "" {
+			where = "`" + where + "`"
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | `%s` | %s | %s |\n", icon, r.Severity, r.Policy, where, strings.ReplaceAll(r.Message, "|", "\\|")))
+	}
+	sb.WriteString("\n")
+}
+
 func truncateForComment(s string) string {
 	const max = 4000
 	if len(s) <= max {
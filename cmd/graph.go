@@ -50,7 +50,7 @@ func runTerraformGraph(file, env, modules, out string, vars []string, outFile st
 		}
 	}
 
-	initCmd := exec.Command("terraform", "init")
+	initCmd := exec.Command("terraform", ctx.initArgs()...)
 	initCmd.Dir = ctx.outDir
 	initCmd.Stdout = io.Discard
 	initCmd.Stderr = os.Stderr
@@ -104,8 +104,11 @@ func buildSpecGraphFromFile(file, env string) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		_ = envName
-		return buildSpecGraph(envCfg.Modules), nil
+		envMods, err := envCfg.ModulesFor(envName)
+		if err != nil {
+			return "", err
+		}
+		return buildSpecGraph(envMods), nil
 	case "Service":
 		svcCfg, envCfg, err := config.LoadService(file)
 		if err != nil {
@@ -115,8 +118,11 @@ func buildSpecGraphFromFile(file, env string) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		_ = envName
-		mods := append([]config.Module{}, envCfg.Modules...)
+		envMods, err := envCfg.ModulesFor(envName)
+		if err != nil {
+			return "", err
+		}
+		mods := append([]config.Module{}, envMods...)
 		mods = append(mods, svcCfg.Modules...)
 		return buildSpecGraph(mods), nil
 	default:
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"pltf/pkg/config"
+)
+
+var (
+	migrateFiles  []string
+	migrateCheck  bool
+	migrateDryRun bool
+)
+
+// migrateCmd rewrites specs written for an older apiVersion to the current one.
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Args:  cobra.NoArgs,
+	Short: "Upgrade Environment/Service specs to the current apiVersion",
+	Long: `Rewrite specs written for an older apiVersion so they match the current model.
+Files already at the current apiVersion are left untouched. When only apiVersion changes, just
+that value is replaced; other upgrades re-encode the YAML node tree, keeping comments and key
+order. Older specs still load without migrating (with a deprecation warning); migrate makes the
+upgrade permanent. Use --check in CI to fail when any file still needs migrating.`,
+	Example: `  pltf migrate -f env.yaml -f service.yaml
+  pltf migrate -f env.yaml --dry-run
+  pltf migrate -f env.yaml --check`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(migrateFiles) == 0 {
+			migrateFiles = []string{"env.yaml"}
+		}
+		for i, f := range migrateFiles {
+			migrateFiles[i] = cleanOptionalPath(f)
+			if err := ensureFile(migrateFiles[i], "spec file"); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMigrate(os.Stdout, migrateFiles, migrateCheck, migrateDryRun)
+	},
+}
+
+func runMigrate(out io.Writer, files []string, check, dryRun bool) error {
+	pending := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		migrated, notes, err := config.MigrateSpec(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if len(notes) == 0 {
+			fmt.Fprintf(out, "%s: already at %s\n", file, config.CurrentAPIVersion)
+			continue
+		}
+		pending++
+		fmt.Fprintf(out, "%s:\n", file)
+		for _, n := range notes {
+			fmt.Fprintf(out, "  - %s\n", n)
+		}
+		switch {
+		case check:
+			continue
+		case dryRun:
+			fmt.Fprintf(out, "---\n%s", migrated)
+		default:
+			if err := writeFileAtomic(file, migrated); err != nil {
+				return err
+			}
+		}
+	}
+	if check && pending > 0 {
+		return fmt.Errorf("%d file(s) need migration to %s", pending, config.CurrentAPIVersion)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path via a temp file in the same directory, keeping its permissions.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().StringSliceVarP(&migrateFiles, "file", "f", nil, "Spec file to migrate (repeatable); defaults to env.yaml")
+	migrateCmd.Flags().BoolVar(&migrateCheck, "check", false, "Report files that need migration and exit non-zero without writing")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Print the migrated YAML instead of writing it")
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"pltf/pkg/bundle"
+	"pltf/pkg/config"
+	"pltf/pkg/generate"
+)
+
+// stackName is metadata.name of the spec the stack was rendered from.
+func (c stackContext) stackName() string {
+	if c.svcCfg != nil {
+		return c.svcCfg.Metadata.Name
+	}
+	if c.envCfg != nil {
+		return c.envCfg.Metadata.Name
+	}
+	return ""
+}
+
+// specHash hashes the loaded spec, after extends/imports, so that edits which do not change the
+// generated Terraform (approvals, secrets) still invalidate a plan bundle.
+func specHash(ctx stackContext) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	if err := enc.Encode(ctx.envCfg); err != nil {
+		return "", err
+	}
+	if ctx.svcCfg != nil {
+		if err := enc.Encode(ctx.svcCfg); err != nil {
+			return "", err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return bundle.HashBytes(buf.Bytes()), nil
+}
+
+// bundleKey returns the plan bundle signing key. Without one, bundles are only written and applied
+// with --allow-unsigned.
+func bundleKey(allowUnsigned bool) ([]byte, error) {
+	key := bundle.Key()
+	if len(key) == 0 && !allowUnsigned {
+		return nil, fmt.Errorf("%s is not set; plan bundles are signed with it (pass --allow-unsigned to use unsigned bundles)", bundle.KeyEnv)
+	}
+	return key, nil
+}
+
+// writePlanBundle packs the saved plan of the stack into a bundle at path. sourceHash is the hash
+// of the generated Terraform the plan was made from.
+func writePlanBundle(ctx stackContext, spec, path, planFile, sourceHash string, allowUnsigned bool) error {
+	key, err := bundleKey(allowUnsigned)
+	if err != nil {
+		return err
+	}
+	sh, err := specHash(ctx)
+	if err != nil {
+		return err
+	}
+	m := bundle.Manifest{
+		PltfVersion: cliVersion(),
+		Kind:        ctx.kind,
+		Name:        ctx.stackName(),
+		Env:         ctx.env,
+		Spec:        spec,
+		SpecHash:    sh,
+		SourceHash:  sourceHash,
+		CreatedAt:   time.Now().UTC(),
+	}
+	return bundle.Write(path, m, planFile, key)
+}
+
+// verifyPlanBundle checks that a bundle was planned from the stack as it is now generated: same
+// spec, same environment and the same generated Terraform.
+func verifyPlanBundle(ctx stackContext, m bundle.Manifest, allowUnsigned bool) error {
+	key, err := bundleKey(allowUnsigned)
+	if err != nil {
+		return err
+	}
+	if err := m.Verify(key, allowUnsigned); err != nil {
+		return err
+	}
+	if m.Kind != ctx.kind || m.Name != ctx.stackName() {
+		return fmt.Errorf("plan bundle is for %s %q, not %s %q", m.Kind, m.Name, ctx.kind, ctx.stackName())
+	}
+	if m.Env != ctx.env {
+		return fmt.Errorf("plan bundle is for environment %q, not %q", m.Env, ctx.env)
+	}
+	sh, err := specHash(ctx)
+	if err != nil {
+		return err
+	}
+	if sh != m.SpecHash {
+		return fmt.Errorf("spec %s changed since the plan was made; plan again", m.Spec)
+	}
+	source, err := generate.TreeHash(ctx.outDir)
+	if err != nil {
+		return err
+	}
+	if source != m.SourceHash {
+		return fmt.Errorf("generated Terraform in %s differs from the one planned; plan again", ctx.outDir)
+	}
+	return nil
+}
+
+// runApplyBundle regenerates the stack, verifies the bundle against it and applies exactly the
+// plan the bundle holds.
+func runApplyBundle(file, env, modules, out string, vars []string, path string, opts tfExecOpts) error {
+	if len(opts.targets) > 0 {
+		return fmt.Errorf("--target cannot be used with --plan-bundle; targets are fixed when the plan is made")
+	}
+	if _, err := bundleKey(opts.allowUnsigned); err != nil {
+		return err
+	}
+	if err := autoGenerate(file, env, modules, out, vars); err != nil {
+		return err
+	}
+	ctx, err := prepareStackContext(file, env, out)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "pltf-plan-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	m, planPath, err := bundle.Open(path, dir)
+	if err != nil {
+		return err
+	}
+	if err := verifyPlanBundle(ctx, m, opts.allowUnsigned); err != nil {
+		return fmt.Errorf("plan bundle %s: %w", path, err)
+	}
+	if v := cliVersion(); m.PltfVersion != v {
+		fmt.Fprintf(os.Stderr, "warn: plan bundle was made with pltf %s; this is %s\n", m.PltfVersion, v)
+	}
+	signed := "unsigned"
+	if m.Signed() {
+		signed = "signature verified"
+	} else {
+		fmt.Fprintf(os.Stderr, "warn: plan bundle %s is not signed; anyone who could write it could have replaced the plan\n", path)
+	}
+	fmt.Printf("Applying plan bundle %s (%s, planned %s)\n", path, signed, m.CreatedAt.Format(time.RFC3339))
+
+	opts.savedPlan = planPath
+	return runTfInStack("apply", file, ctx, "", opts)
+}
+
+// planBundleSupported rejects --plan-bundle where a single stack is not being planned.
+func planBundleSupported(bundlePath string, all bool, file string) error {
+	if strings.TrimSpace(bundlePath) == "" {
+		return nil
+	}
+	if all {
+		return fmt.Errorf("--plan-bundle cannot be used with --all; bundles hold the plan of one stack")
+	}
+	if config.IsSpecSet(defaultString(file, "env.yaml")) {
+		return fmt.Errorf("--plan-bundle cannot be used with a spec set; bundles hold the plan of one stack")
+	}
+	return nil
+}
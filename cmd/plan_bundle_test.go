@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pltf/pkg/bundle"
+	"pltf/pkg/config"
+	"pltf/pkg/generate"
+)
+
+func TestPlanBundleVerifiesSpecAndGeneratedTerraform(t *testing.T) {
+	resetProfileCache()
+	t.Setenv(bundle.KeyEnv, "test-key")
+
+	envCfg := config.EnvironmentConfig{
+		APIVersion: "platform.io/v1",
+		Kind:       "Environment",
+		Metadata:   config.EnvironmentMetadata{Name: "demo", Org: "acme", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev":  {Account: "111111111111", Region: "us-east-1"},
+			"prod": {Account: "222222222222", Region: "us-west-2"},
+		},
+		Modules: []config.Module{{ID: "base", Type: "aws_base"}},
+	}
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "env.yaml")
+	writeYAML(t, envPath, envCfg)
+	out := filepath.Join(dir, "out")
+	if err := autoGenerateQuiet(envPath, "dev", "", out, nil); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	ctx, err := prepareStackContext(envPath, "dev", out)
+	if err != nil {
+		t.Fatalf("prepareStackContext: %v", err)
+	}
+
+	planFile := filepath.Join(dir, "saved.tfplan")
+	if err := os.WriteFile(planFile, []byte("plan"), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	source, err := generate.TreeHash(out)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	bundlePath := filepath.Join(dir, "plan.tar.gz")
+	if err := writePlanBundle(ctx, envPath, bundlePath, planFile, source, false); err != nil {
+		t.Fatalf("writePlanBundle: %v", err)
+	}
+	m, _, err := bundle.Open(bundlePath, t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := verifyPlanBundle(ctx, m, false); err != nil {
+		t.Fatalf("expected the bundle to match the stack: %v", err)
+	}
+
+	prodCtx := ctx
+	prodCtx.env = "prod"
+	if err := verifyPlanBundle(prodCtx, m, false); err == nil || !strings.Contains(err.Error(), `environment "dev"`) {
+		t.Fatalf("expected an environment mismatch, got %v", err)
+	}
+
+	// Editing generated Terraform by hand invalidates the bundle.
+	if err := os.WriteFile(filepath.Join(out, "extra.tf"), []byte("# edit\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := verifyPlanBundle(ctx, m, false); err == nil || !strings.Contains(err.Error(), "differs from the one planned") {
+		t.Fatalf("expected a generated Terraform mismatch, got %v", err)
+	}
+
+	// A spec change is reported before the generated Terraform is compared.
+	envCfg.Metadata.Labels = map[string]string{"team": "platform"}
+	writeYAML(t, envPath, envCfg)
+	ctx, err = prepareStackContext(envPath, "dev", out)
+	if err != nil {
+		t.Fatalf("prepareStackContext: %v", err)
+	}
+	if err := verifyPlanBundle(ctx, m, false); err == nil || !strings.Contains(err.Error(), "changed since the plan was made") {
+		t.Fatalf("expected a spec mismatch, got %v", err)
+	}
+
+	if err := planBundleSupported(bundlePath, true, envPath); err == nil {
+		t.Fatalf("expected --plan-bundle to be rejected with --all")
+	}
+
+	// Without a signing key, bundles are only written and applied with --allow-unsigned.
+	t.Setenv(bundle.KeyEnv, "")
+	if source, err = generate.TreeHash(out); err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if err := writePlanBundle(ctx, envPath, bundlePath, planFile, source, false); err == nil || !strings.Contains(err.Error(), "--allow-unsigned") {
+		t.Fatalf("expected an unsigned bundle to be refused, got %v", err)
+	}
+	if err := verifyPlanBundle(ctx, m, true); err == nil || !strings.Contains(err.Error(), "is signed") {
+		t.Fatalf("expected a signed bundle to need the key, got %v", err)
+	}
+	if err := writePlanBundle(ctx, envPath, bundlePath, planFile, source, true); err != nil {
+		t.Fatalf("writePlanBundle --allow-unsigned: %v", err)
+	}
+	if m, _, err = bundle.Open(bundlePath, t.TempDir()); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := verifyPlanBundle(ctx, m, false); err == nil || !strings.Contains(err.Error(), "--allow-unsigned") {
+		t.Fatalf("expected an unsigned bundle to be refused on apply, got %v", err)
+	}
+	if err := verifyPlanBundle(ctx, m, true); err != nil {
+		t.Fatalf("expected --allow-unsigned to accept the bundle: %v", err)
+	}
+}
@@ -1,34 +1,35 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"pltf/pkg/generate"
+	"pltf/pkg/plan"
 )
 
 type planSummary struct {
-	Added       int
-	Changed     int
-	Destroyed   int
-	Adds        []string
-	Changes     []string
-	Deletes     []string
+	Counts plan.Counts
+	// Modules are the changes grouped by pltf module id, and ModuleTypes the module type of each id
+	// from the source map.
+	Modules     []plan.ModuleChanges
+	ModuleTypes map[string]string
+	Outputs     []plan.OutputChange
+	Model       *plan.Plan
 	Text        string
 	RawPlanArgs []string
 	PlanJSON    string
+	// Warnings flag protected resources the plan destroys because their module left the spec.
+	Warnings []string
+	// json is the output of terraform show -json the summary was built from.
+	json []byte
 }
 
-type tfPlanJSON struct {
-	ResourceChanges []struct {
-		Address string `json:"address"`
-		Change  struct {
-			Actions []string `json:"actions"`
-		} `json:"change"`
-	} `json:"resource_changes"`
-}
-
+// collectPlanSummary reads the saved plan once as JSON, for the model, and once as text, for the
+// PR comment.
 func collectPlanSummary(outDir, planFile string) (*planSummary, error) {
 	if strings.TrimSpace(planFile) == "" {
 		return nil, nil
@@ -43,43 +44,167 @@ func collectPlanSummary(outDir, planFile string) (*planSummary, error) {
 	if _, err := os.Stat(planPath); err != nil {
 		return nil, err
 	}
-	sum := &planSummary{}
-
 	out, err := runCmdOutput(outDir, "terraform", "show", "-json", planPath)
-	if err == nil {
-		var plan tfPlanJSON
-		if err := json.Unmarshal([]byte(out), &plan); err == nil {
-			for _, rc := range plan.ResourceChanges {
-				actions := map[string]bool{}
-				for _, a := range rc.Change.Actions {
-					actions[a] = true
-				}
-				switch {
-				case actions["create"] && actions["delete"]:
-					sum.Changed++
-					sum.Changes = append(sum.Changes, rc.Address)
-				case actions["update"]:
-					sum.Changed++
-					sum.Changes = append(sum.Changes, rc.Address)
-				case actions["create"]:
-					sum.Added++
-					sum.Adds = append(sum.Adds, rc.Address)
-				case actions["delete"]:
-					sum.Destroyed++
-					sum.Deletes = append(sum.Deletes, rc.Address)
-				}
-			}
-		} else {
-			fmt.Fprintf(os.Stderr, "warn: unable to parse plan JSON: %v\n", err)
-		}
-	} else {
-		fmt.Fprintf(os.Stderr, "warn: terraform show -json failed: %v\n", err)
+	if err != nil {
+		return nil, fmt.Errorf("terraform show -json failed: %w", err)
+	}
+	sum, err := newPlanSummary([]byte(out), outDir)
+	if err != nil {
+		return nil, err
 	}
-
 	if text, err := runCmdOutput(outDir, "terraform", "show", "-no-color", planPath); err == nil {
 		// Keep full plan output; GitHub comment limit is large enough for typical plans.
 		sum.Text = strings.TrimSpace(text)
 	}
+	return sum, nil
+}
 
+// newPlanSummary builds the summary of the plan JSON data for the stack generated into outDir.
+func newPlanSummary(data []byte, outDir string) (*planSummary, error) {
+	model, err := plan.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	sum := &planSummary{
+		Counts:      model.Counts(),
+		Modules:     model.ByModule(),
+		ModuleTypes: map[string]string{},
+		Outputs:     model.Outputs,
+		Model:       model,
+		json:        data,
+	}
+	if sm, err := generate.ReadSourceMap(outDir); err == nil {
+		for id, ms := range sm.Modules {
+			sum.ModuleTypes[id] = ms.Type
+		}
+		sum.Warnings = removedModuleWarnings(model, sm)
+	}
 	return sum, nil
 }
+
+// moduleLabel names a module group: its id and type, or "(root)" for resources outside modules.
+func (s *planSummary) moduleLabel(id string) string {
+	if id == "" {
+		return "(root)"
+	}
+	if t := s.ModuleTypes[id]; t != "" {
+		return fmt.Sprintf("%s (%s)", id, t)
+	}
+	return id
+}
+
+// describeChange is one line for a changed resource: the action, the address, and why it is replaced
+// or which attributes change, with sensitive ones marked.
+func describeChange(rc plan.ResourceChange) string {
+	line := fmt.Sprintf("%s %s", rc.Action.Symbol(), rc.Address)
+	if rc.PreviousAddress != "" && rc.PreviousAddress != rc.Address {
+		line += " (moved from " + rc.PreviousAddress + ")"
+	}
+	switch rc.Action {
+	case plan.ActionReplace, plan.ActionDelete:
+		if why := rc.Why(); why != "" {
+			line += " (" + why + ")"
+		}
+	case plan.ActionUpdate:
+		var attrs []string
+		for _, a := range rc.ChangedAttributes() {
+			if rc.Sensitive(a) {
+				a += " (sensitive)"
+			}
+			attrs = append(attrs, a)
+		}
+		if len(attrs) > 0 {
+			line += ": " + strings.Join(attrs, ", ")
+		}
+	}
+	return line
+}
+
+// printPlanModules prints the changes of the plan per pltf module.
+func printPlanModules(w io.Writer, sum *planSummary) {
+	fmt.Fprintf(w, "\nPlan: %s\n", sum.Counts)
+	if len(sum.Modules) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Changes by module:")
+	for _, m := range sum.Modules {
+		if len(m.Resources) > 0 {
+			fmt.Fprintf(w, "  %s: %s\n", sum.moduleLabel(m.ID), m.Counts)
+		} else {
+			fmt.Fprintf(w, "  %s: no changes\n", sum.moduleLabel(m.ID))
+		}
+		for _, rc := range m.Resources {
+			fmt.Fprintf(w, "    %s\n", describeChange(rc))
+		}
+		for _, rc := range m.Drift {
+			fmt.Fprintf(w, "    drift: %s changed outside Terraform (%s)\n", rc.Address, rc.Action)
+		}
+	}
+}
+
+// removedModuleWarnings reports modules that are no longer in the spec whose protected resources
+// the plan destroys, which is what renaming a module without previousIds looks like. What a module
+// protects comes from its module.yaml or spec protect, as recorded in the pltf_protected output of
+// the state. Modules the plan only creates are suggested as the new id.
+func removedModuleWarnings(p *plan.Plan, sm *generate.SourceMap) []string {
+	protected := planProtection(p)
+	destroyed := map[string][]plan.ResourceChange{}
+	creates := map[string]map[string]bool{}
+	onlyCreates := map[string]bool{}
+	for _, rc := range p.Resources {
+		id := rc.Module
+		if id == "" {
+			continue
+		}
+		if _, inSpec := sm.Modules[id]; !inSpec {
+			if rc.Action != plan.ActionDelete {
+				continue
+			}
+			for _, pattern := range protected[id] {
+				if protectMatches(pattern, rc) {
+					destroyed[id] = append(destroyed[id], rc)
+					break
+				}
+			}
+			continue
+		}
+		if _, seen := onlyCreates[id]; !seen {
+			onlyCreates[id] = true
+		}
+		if rc.Action != plan.ActionCreate && rc.Action != plan.ActionRead && rc.Action != plan.ActionNoOp {
+			onlyCreates[id] = false
+		}
+		if creates[id] == nil {
+			creates[id] = map[string]bool{}
+		}
+		creates[id][rc.Type] = true
+	}
+
+	var warnings []string
+	for _, id := range sortedKeys(destroyed) {
+		addrs := make([]string, len(destroyed[id]))
+		for i, rc := range destroyed[id] {
+			addrs[i] = rc.Address
+		}
+		msg := fmt.Sprintf("module %q is no longer in the spec and the plan destroys %s", id, strings.Join(addrs, ", "))
+		var candidates []string
+		for _, newID := range sortedKeys(onlyCreates) {
+			if !onlyCreates[newID] {
+				continue
+			}
+			for _, rc := range destroyed[id] {
+				if creates[newID][rc.Type] {
+					candidates = append(candidates, fmt.Sprintf("%q", newID))
+					break
+				}
+			}
+		}
+		if len(candidates) > 0 {
+			msg += fmt.Sprintf("; if it was renamed to %s, add previousIds: [%s] to that module so its state is moved instead", strings.Join(candidates, " or "), id)
+		} else {
+			msg += fmt.Sprintf("; if it was renamed, add previousIds: [%s] to the renamed module so its state is moved instead", id)
+		}
+		warnings = append(warnings, msg)
+	}
+	return warnings
+}
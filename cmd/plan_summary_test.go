@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pltf/pkg/generate"
+	"pltf/pkg/plan"
+)
+
+func TestRemovedModuleWarningsSuggestPreviousIDs(t *testing.T) {
+	change := func(addr, module, typ string, action plan.Action) plan.ResourceChange {
+		return plan.ResourceChange{Address: addr, ModuleAddress: "module." + module, Module: module, Type: typ, Action: action}
+	}
+	changes := []plan.ResourceChange{
+		change(`module.postgres.aws_rds_cluster.this`, "postgres", "aws_rds_cluster", plan.ActionDelete),
+		change(`module.postgres.aws_security_group.db`, "postgres", "aws_security_group", plan.ActionDelete),
+		change(`module.logs.aws_cloudwatch_log_group.this`, "logs", "aws_cloudwatch_log_group", plan.ActionDelete),
+		change(`module.db.aws_rds_cluster.this`, "db", "aws_rds_cluster", plan.ActionCreate),
+		change(`module.app.aws_rds_cluster.this`, "app", "aws_rds_cluster", plan.ActionUpdate),
+	}
+	sm := &generate.SourceMap{Modules: map[string]generate.ModuleSources{"db": {}, "app": {}}}
+	// The state still records what the removed module protected.
+	outputs := []plan.OutputChange{{
+		Name:   generate.ProtectedOutput,
+		Action: plan.ActionUpdate,
+		Before: map[string]interface{}{"postgres": []interface{}{"aws_rds_cluster"}, "logs": []interface{}{}},
+		After:  map[string]interface{}{"db": []interface{}{"aws_rds_cluster"}},
+	}}
+
+	warnings := removedModuleWarnings(&plan.Plan{Resources: changes, Outputs: outputs}, sm)
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning for the protected module, got %v", warnings)
+	}
+	if !strings.Contains(warnings[0], `module "postgres" is no longer in the spec and the plan destroys module.postgres.aws_rds_cluster.this;`) ||
+		!strings.Contains(warnings[0], `renamed to "db", add previousIds: [postgres]`) {
+		t.Fatalf("unexpected warning: %s", warnings[0])
+	}
+}
+
+func TestPlanSummaryGroupsChangesByModule(t *testing.T) {
+	outDir := t.TempDir()
+	sm := `{"kind":"Environment","name":"demo","env":"dev","modules":{"db":{"type":"aws_postgres","file":"db.tf","inputs":{}}}}`
+	if err := os.WriteFile(filepath.Join(outDir, generate.SourceMapFile), []byte(sm), 0o644); err != nil {
+		t.Fatalf("write source map: %v", err)
+	}
+	data := `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "module.db.aws_rds_cluster.this", "module_address": "module.db", "type": "aws_rds_cluster",
+     "change": {"actions": ["delete", "create"], "replace_paths": [["engine"]]}, "action_reason": "replace_because_cannot_update"},
+    {"address": "module.db.aws_db_parameter_group.this", "module_address": "module.db", "type": "aws_db_parameter_group",
+     "change": {"actions": ["update"], "before": {"family": "a", "password": "x"}, "after": {"family": "b", "password": "y"},
+                "after_sensitive": {"password": true}}},
+    {"address": "aws_s3_bucket.logs", "type": "aws_s3_bucket", "change": {"actions": ["create"]}}
+  ]
+}`
+	sum, err := newPlanSummary([]byte(data), outDir)
+	if err != nil {
+		t.Fatalf("newPlanSummary: %v", err)
+	}
+	if got := sum.Counts.String(); got != "1 to add, 1 to change, 1 to replace, 0 to destroy" {
+		t.Fatalf("unexpected counts: %s", got)
+	}
+
+	var buf bytes.Buffer
+	printPlanModules(&buf, sum)
+	out := buf.String()
+	for _, want := range []string{
+		"(root): 1 to add, 0 to change, 0 to replace, 0 to destroy",
+		"db (aws_postgres): 0 to add, 1 to change, 1 to replace, 0 to destroy",
+		"-/+ module.db.aws_rds_cluster.this (cannot update in place: engine)",
+		"~ module.db.aws_db_parameter_group.this: family, password (sensitive)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	body := buildPRCommentBody(tfRunSummary{Action: "plan", Status: "succeeded", Spec: "env.yaml", Plan: sum})
+	if !strings.Contains(body, "| `db (aws_postgres)` | 0 | 1 | 1 | 0 | 0 |") ||
+		!strings.Contains(body, "- `db (aws_postgres)`: -/+ module.db.aws_rds_cluster.this (cannot update in place: engine)") {
+		t.Fatalf("expected per-module changes in the PR comment:\n%s", body)
+	}
+}
+
+func TestPRCommentMarkerIsPerStack(t *testing.T) {
+	api := tfRunSummary{Spec: "specs/services.yaml#1", Env: "dev"}
+	worker := tfRunSummary{Spec: "specs/services.yaml#2", Env: "dev"}
+	prod := tfRunSummary{Spec: "specs/services.yaml#1", Env: "prod"}
+	if prCommentMarkerFor(api) == prCommentMarkerFor(worker) || prCommentMarkerFor(api) == prCommentMarkerFor(prod) {
+		t.Fatalf("expected distinct markers, got %q, %q and %q", prCommentMarkerFor(api), prCommentMarkerFor(worker), prCommentMarkerFor(prod))
+	}
+	if marker := prCommentMarkerFor(tfRunSummary{Spec: "a--b.yaml", Env: "dev"}); strings.Count(marker, "--") != 2 {
+		t.Fatalf("expected -- only to open and close the HTML comment, got %q", marker)
+	}
+	if body := buildPRCommentBody(api); !strings.HasPrefix(body, prCommentMarkerFor(api)) {
+		t.Fatalf("expected the body to start with its marker:\n%s", body)
+	}
+}
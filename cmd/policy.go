@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"pltf/pkg/generate"
+	"pltf/pkg/policy"
+)
+
+// resolvePoliciesDir returns the policy directory from --policies, or else the profile's
+// policies_dir. Empty means policies are not evaluated.
+func resolvePoliciesDir(flag string) string {
+	if strings.TrimSpace(flag) != "" {
+		return flag
+	}
+	if prof := loadProfile(); prof != nil {
+		return strings.TrimSpace(prof.PoliciesDir)
+	}
+	return ""
+}
+
+// policyInput gathers what policies see for the stack: the loaded spec, the module inputs from
+// the source map generate wrote and, when sum is set, the plan.
+func policyInput(ctx stackContext, sum *planSummary) (policy.Input, error) {
+	in := policy.Input{Kind: ctx.kind, Name: ctx.stackName(), Env: ctx.env}
+	var err error
+	if ctx.envCfg != nil {
+		if in.Environment, err = policy.SpecValue(ctx.envCfg); err != nil {
+			return in, err
+		}
+	}
+	if ctx.svcCfg != nil {
+		if in.Service, err = policy.SpecValue(ctx.svcCfg); err != nil {
+			return in, err
+		}
+	}
+	sm, err := generate.ReadSourceMap(ctx.outDir)
+	if err != nil {
+		return in, fmt.Errorf("read source map (run generate first): %w", err)
+	}
+	in.Modules = policy.ModulesFromSourceMap(sm)
+	if sum != nil {
+		in.Resources = policy.ResourcesFromPlan(sum.Model)
+		in.Plan = sum.json
+	}
+	return in, nil
+}
+
+// evaluatePolicies runs the policies of dir against the stack and prints the results.
+func evaluatePolicies(ctx stackContext, dir string, sum *planSummary) ([]policy.Result, error) {
+	engine, err := policy.Load(context.Background(), dir)
+	if err != nil {
+		return nil, err
+	}
+	in, err := policyInput(ctx, sum)
+	if err != nil {
+		return nil, err
+	}
+	results, err := engine.Evaluate(context.Background(), in)
+	if err != nil {
+		return nil, err
+	}
+	printPolicyResults(ctx.stdout(), len(engine.Policies), results)
+	return results, nil
+}
+
+// checkPolicies evaluates the policies of --policies (or the profile) against the stack when a
+// directory is configured. blocked is set when a policy denies it.
+func checkPolicies(ctx stackContext, opts tfExecOpts, sum *planSummary) (results []policy.Result, blocked bool, err error) {
+	dir := resolvePoliciesDir(opts.policies)
+	if dir == "" {
+		return nil, false, nil
+	}
+	if results, err = evaluatePolicies(ctx, dir, sum); err != nil {
+		return nil, false, fmt.Errorf("policy check failed: %w", err)
+	}
+	if err := policyDenied(results); err != nil {
+		return results, true, err
+	}
+	return results, false, nil
+}
+
+// policyCounts returns the number of denials and warnings in results.
+func policyCounts(results []policy.Result) (denied, warned int) {
+	denied = len(policy.Denied(results))
+	return denied, len(results) - denied
+}
+
+// printPolicyResults reports the violations, denials first.
+func printPolicyResults(w io.Writer, evaluated int, results []policy.Result) {
+	denied, warned := policyCounts(results)
+	fmt.Fprintf(w, "\nPolicy checks: %d policies, %d denied, %d warnings\n", evaluated, denied, warned)
+	for _, r := range results {
+		fmt.Fprintf(w, "  %-4s [%s] %s%s: %s\n", strings.ToUpper(string(r.Enforcement)), r.Severity, r.Policy, policyLocation(r), r.Message)
+	}
+}
+
+// policyLocation is the module or resource a result points at, in parentheses.
+func policyLocation(r policy.Result) string {
+	var parts []string
+	if r.Module != "" {
+		parts = append(parts, r.Module)
+	}
+	if r.Resource != "" {
+		parts = append(parts, r.Resource)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// policyDenied returns an error when a policy denies the stack.
+func policyDenied(results []policy.Result) error {
+	denied := policy.Denied(results)
+	if len(denied) == 0 {
+		return nil
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, r := range denied {
+		if !seen[r.Policy] {
+			seen[r.Policy] = true
+			names = append(names, r.Policy)
+		}
+	}
+	return fmt.Errorf("%d policy violation(s) denied by %s", len(denied), strings.Join(names, ", "))
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pltf/pkg/config"
+)
+
+const bucketPolicy = `# METADATA
+# title: Buckets block public access
+# custom:
+#   severity: critical
+#   enforcement:
+#     prod: deny
+package pltf.public_s3
+
+violation contains {"msg": sprintf("bucket %s allows public access", [id]), "module": id} if {
+	some id, m in input.modules
+	m.type == "aws_s3"
+	m.inputs.block_public == false
+}
+
+violation contains {"msg": "bucket is deleted", "resource": r.address} if {
+	some r in input.resources
+	r.type == "aws_s3_bucket"
+	r.action == "delete"
+}
+`
+
+func TestEvaluatePoliciesOverSpecInputsAndPlan(t *testing.T) {
+	resetProfileCache()
+	envCfg := config.EnvironmentConfig{
+		APIVersion: "platform.io/v1",
+		Kind:       "Environment",
+		Metadata:   config.EnvironmentMetadata{Name: "demo", Org: "acme", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev":  {Account: "111111111111", Region: "us-east-1"},
+			"prod": {Account: "222222222222", Region: "us-west-2"},
+		},
+		Modules: []config.Module{
+			{ID: "base", Type: "aws_base"},
+			{ID: "assets", Type: "aws_s3", Inputs: map[string]interface{}{"bucket_name": "assets", "block_public": false}},
+		},
+	}
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "env.yaml")
+	writeYAML(t, envPath, envCfg)
+	policies := filepath.Join(dir, "policies")
+	if err := os.MkdirAll(policies, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(policies, "public_s3.rego"), []byte(bucketPolicy), 0o644); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+
+	for env, wantDeny := range map[string]bool{"dev": false, "prod": true} {
+		out := filepath.Join(dir, "out-"+env)
+		if err := autoGenerateQuiet(envPath, env, "", out, nil); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		ctx, err := prepareStackContext(envPath, env, out)
+		if err != nil {
+			t.Fatalf("prepareStackContext: %v", err)
+		}
+		var buf bytes.Buffer
+		ctx.log = &buf
+		sum, err := newPlanSummary([]byte(`{"resource_changes": [{"address": "module.assets.aws_s3_bucket.this", "module_address": "module.assets",
+			"type": "aws_s3_bucket", "name": "this", "change": {"actions": ["delete"]}}]}`), out)
+		if err != nil {
+			t.Fatalf("newPlanSummary: %v", err)
+		}
+		results, err := evaluatePolicies(ctx, policies, sum)
+		if err != nil {
+			t.Fatalf("evaluatePolicies: %v", err)
+		}
+		if len(results) != 2 || results[0].Module != "assets" || results[1].Resource != "module.assets.aws_s3_bucket.this" {
+			t.Fatalf("%s: unexpected results %+v", env, results)
+		}
+		if denied := policyDenied(results) != nil; denied != wantDeny {
+			t.Fatalf("%s: expected denied=%v, got %v", env, wantDeny, denied)
+		}
+		if !strings.Contains(buf.String(), "Policy checks: 1 policies") {
+			t.Fatalf("%s: expected a policy report, got:\n%s", env, buf.String())
+		}
+		_, blocked, err := checkPolicies(ctx, tfExecOpts{policies: policies}, sum)
+		if blocked != wantDeny || (err != nil) != wantDeny {
+			t.Fatalf("%s: expected blocked=%v, got blocked=%v err=%v", env, wantDeny, blocked, err)
+		}
+		want := "succeeded"
+		if wantDeny {
+			want = "blocked"
+		}
+		runStatus := "succeeded"
+		if blocked {
+			runStatus = "blocked"
+		}
+		if got := runStatusText(err, runStatus); got != want {
+			t.Fatalf("%s: expected plan status %q, got %q", env, want, got)
+		}
+		if env == "prod" {
+			body := buildPRCommentBody(tfRunSummary{Action: "plan", Status: "blocked", Spec: "env.yaml", Policy: results})
+			if !strings.Contains(body, "**Policy checks**: 2 denied, 0 warnings") || !strings.Contains(body, "| 🛑 deny | critical | `public_s3` | `assets` | bucket assets allows public access |") {
+				t.Fatalf("unexpected PR comment:\n%s", body)
+			}
+		}
+	}
+	if got := runStatusText(fmt.Errorf("terraform plan failed"), "changes"); got != "failed" {
+		t.Fatalf("expected other errors to fail the run, got %q", got)
+	}
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"pltf/pkg/generate"
+	"pltf/pkg/plan"
+	"pltf/pkg/policy"
+)
+
+// protectionViolation is a protected resource the plan deletes or replaces.
+type protectionViolation struct {
+	Module  string
+	Address string
+	Action  plan.Action
+	Why     string
+	// Allowed is set when --allow-destroy names the module.
+	Allowed bool
+}
+
+// planProtection returns the protected resources of each module id from the pltf_protected output
+// of the plan. Both the value in the state and the planned value count, so removing protect from a
+// spec only lifts the guard once that change has been applied.
+func planProtection(p *plan.Plan) map[string][]string {
+	protected := map[string][]string{}
+	add := func(v interface{}) {
+		mods, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for id, list := range mods {
+			items, _ := list.([]interface{})
+			for _, it := range items {
+				if s, ok := it.(string); ok {
+					protected[id] = append(protected[id], s)
+				}
+			}
+		}
+	}
+	for _, o := range p.Outputs {
+		if o.Name == generate.ProtectedOutput {
+			add(o.Before)
+			add(o.After)
+		}
+	}
+	return protected
+}
+
+// protectMatches reports whether a protect pattern covers the resource: "*", its type, or its
+// type and name with any count or for_each key stripped.
+func protectMatches(pattern string, rc plan.ResourceChange) bool {
+	if pattern == "*" || pattern == rc.Type {
+		return true
+	}
+	name := rc.Name
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	return pattern == rc.Type+"."+name
+}
+
+// protectionViolations lists the protected resources the plan deletes or replaces, marking those of
+// modules named in allow.
+func protectionViolations(p *plan.Plan, allow []string) []protectionViolation {
+	if p == nil {
+		return nil
+	}
+	protected := planProtection(p)
+	allowed := map[string]bool{}
+	for _, a := range allow {
+		for _, id := range strings.Split(a, ",") {
+			allowed[strings.TrimSpace(id)] = true
+		}
+	}
+	var out []protectionViolation
+	for _, rc := range p.Resources {
+		if rc.Mode == "data" || (rc.Action != plan.ActionDelete && rc.Action != plan.ActionReplace) {
+			continue
+		}
+		for _, pattern := range protected[rc.Module] {
+			if !protectMatches(pattern, rc) {
+				continue
+			}
+			out = append(out, protectionViolation{
+				Module:  rc.Module,
+				Address: rc.Address,
+				Action:  rc.Action,
+				Why:     rc.Why(),
+				Allowed: allowed[rc.Module] || allowed["*"],
+			})
+			break
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out
+}
+
+// blockedByProtection returns an error naming the modules whose protected resources the plan
+// deletes or replaces without --allow-destroy.
+func blockedByProtection(vs []protectionViolation) error {
+	var mods []string
+	seen := map[string]bool{}
+	for _, v := range vs {
+		if v.Allowed || seen[v.Module] {
+			continue
+		}
+		seen[v.Module] = true
+		mods = append(mods, v.Module)
+	}
+	if len(mods) == 0 {
+		return nil
+	}
+	flags := make([]string, len(mods))
+	for i, m := range mods {
+		flags[i] = "--allow-destroy=" + m
+	}
+	return fmt.Errorf("plan deletes or replaces protected resources of module(s) %s; review the plan and re-run with %s to proceed", strings.Join(mods, ", "), strings.Join(flags, " "))
+}
+
+// printProtectionViolations reports the protected resources a plan deletes or replaces.
+func printProtectionViolations(w io.Writer, vs []protectionViolation) {
+	if len(vs) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nProtected resources:")
+	for _, v := range vs {
+		state := "BLOCKED"
+		if v.Allowed {
+			state = "allowed"
+		}
+		line := fmt.Sprintf("  %s %s (%s) %s", v.Action.Symbol(), v.Address, v.Action, state)
+		if v.Why != "" {
+			line += ": " + v.Why
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// guardPlan runs the checks a saved plan passes before apply or destroy applies it: protected
+// resources first, then policies. blocked is set when one of them stops the run.
+func guardPlan(ctx stackContext, opts tfExecOpts, sum *planSummary) (protected []protectionViolation, results []policy.Result, blocked bool, err error) {
+	protected = protectionViolations(sum.Model, opts.allowDestroy)
+	printProtectionViolations(ctx.stdout(), protected)
+	if err := blockedByProtection(protected); err != nil {
+		return protected, nil, true, err
+	}
+	results, blocked, err = checkPolicies(ctx, opts, sum)
+	return protected, results, blocked, err
+}
+
+// confirmApply asks on stdin before a checked plan of action is applied, as terraform would.
+func confirmApply(w io.Writer, in io.Reader, action string) error {
+	fmt.Fprint(w, "\nDo you want to perform these actions?\n  Only 'yes' will be accepted to approve.\n\n  Enter a value: ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("read approval: %w", err)
+	}
+	if strings.TrimSpace(line) != "yes" {
+		return fmt.Errorf("%s cancelled", action)
+	}
+	return nil
+}
+
+// approveCheckedPlan returns nil when the checked plan may be applied: with --auto-approve, or
+// once the user answers yes when --input allows prompting.
+func approveCheckedPlan(ctx stackContext, action string, opts tfExecOpts) error {
+	if opts.autoApprove {
+		return nil
+	}
+	if !opts.input {
+		return fmt.Errorf("%s needs approval: pass --auto-approve, or --input to be prompted", action)
+	}
+	return confirmApply(ctx.stdout(), os.Stdin, action)
+}
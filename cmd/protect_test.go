@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"pltf/pkg/plan"
+)
+
+const protectedPlan = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "module.db.aws_rds_cluster.this", "module_address": "module.db", "type": "aws_rds_cluster", "name": "this",
+     "change": {"actions": ["delete", "create"], "replace_paths": [["engine_version"]]}, "action_reason": "replace_because_cannot_update"},
+    {"address": "module.db.aws_rds_cluster_instance.this[0]", "module_address": "module.db", "type": "aws_rds_cluster_instance", "name": "this",
+     "index": 0, "change": {"actions": ["delete"]}},
+    {"address": "module.old.aws_s3_bucket.this", "module_address": "module.old", "type": "aws_s3_bucket", "name": "this",
+     "change": {"actions": ["delete"]}, "action_reason": "delete_because_no_module"},
+    {"address": "module.app.aws_iam_role.this", "module_address": "module.app", "type": "aws_iam_role", "name": "this",
+     "change": {"actions": ["delete"]}}
+  ],
+  "output_changes": {
+    "pltf_protected": {"actions": ["update"],
+      "before": {"db": ["aws_rds_cluster"], "old": ["aws_s3_bucket.this"]},
+      "after": {"db": ["aws_rds_cluster"]}}
+  }
+}`
+
+func TestProtectionViolationsBlockApply(t *testing.T) {
+	p, err := plan.Parse([]byte(protectedPlan))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	vs := protectionViolations(p, nil)
+	if len(vs) != 2 || vs[0].Address != "module.db.aws_rds_cluster.this" || vs[1].Module != "old" {
+		t.Fatalf("unexpected violations: %+v", vs)
+	}
+	if vs[0].Action != plan.ActionReplace || !strings.Contains(vs[0].Why, "engine_version") {
+		t.Fatalf("expected the replacement reason, got %+v", vs[0])
+	}
+	err = blockedByProtection(vs)
+	if err == nil || !strings.Contains(err.Error(), "--allow-destroy=db --allow-destroy=old") {
+		t.Fatalf("expected apply to be blocked for db and old, got %v", err)
+	}
+
+	vs = protectionViolations(p, []string{"db"})
+	err = blockedByProtection(vs)
+	if err == nil || strings.Contains(err.Error(), "=db") || !vs[0].Allowed {
+		t.Fatalf("expected only old to block, got %v", err)
+	}
+	if err := blockedByProtection(protectionViolations(p, []string{"db,old"})); err != nil {
+		t.Fatalf("expected --allow-destroy to lift the guard, got %v", err)
+	}
+
+	body := buildPRCommentBody(tfRunSummary{Action: "apply", Status: "blocked", Spec: "env.yaml", Protected: vs})
+	for _, want := range []string{"**Protected resources**", "allowed by `--allow-destroy=db`", "blocked; apply needs `--allow-destroy=old`"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in PR comment:\n%s", want, body)
+		}
+	}
+}
+
+func TestProtectedModuleBlocksDestroy(t *testing.T) {
+	resetProfileCache()
+	destroyPlan := `{
+  "resource_changes": [
+    {"address": "module.db.aws_rds_cluster.this", "module_address": "module.db", "type": "aws_rds_cluster", "name": "this",
+     "change": {"actions": ["delete"]}},
+    {"address": "module.app.aws_iam_role.this", "module_address": "module.app", "type": "aws_iam_role", "name": "this",
+     "change": {"actions": ["delete"]}}
+  ],
+  "output_changes": {
+    "pltf_protected": {"actions": ["delete"], "before": {"db": ["aws_rds_cluster"]}, "after": null}
+  }
+}`
+	sum, err := newPlanSummary([]byte(destroyPlan), t.TempDir())
+	if err != nil {
+		t.Fatalf("newPlanSummary: %v", err)
+	}
+	var buf bytes.Buffer
+	ctx := stackContext{env: "prod", log: &buf}
+
+	protected, _, blocked, err := guardPlan(ctx, tfExecOpts{}, sum)
+	if !blocked || err == nil || !strings.Contains(err.Error(), "--allow-destroy=db") || len(protected) != 1 {
+		t.Fatalf("expected destroy to be blocked by db, got blocked=%t err=%v %+v", blocked, err, protected)
+	}
+	if !strings.Contains(buf.String(), "module.db.aws_rds_cluster.this (delete) BLOCKED") {
+		t.Fatalf("expected the blocked resource to be reported, got:\n%s", buf.String())
+	}
+
+	if _, _, blocked, err := guardPlan(ctx, tfExecOpts{allowDestroy: []string{"db"}}, sum); blocked || err != nil {
+		t.Fatalf("expected --allow-destroy=db to let destroy proceed, got blocked=%t err=%v", blocked, err)
+	}
+	if err := approveCheckedPlan(ctx, "destroy", tfExecOpts{}); err == nil || !strings.Contains(err.Error(), "destroy needs approval") {
+		t.Fatalf("expected destroy to need approval, got %v", err)
+	}
+}
@@ -3,8 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"pltf/pkg/config"
 )
 
 // Global flags (if you want them later)
@@ -63,6 +66,10 @@ func init() {
 	defaultTelemetry := false
 	if p := loadProfile(); p != nil {
 		defaultTelemetry = p.Telemetry
+		// Spec imports written as modules://<path> resolve against the profile modules root.
+		if strings.TrimSpace(p.ModulesRoot) != "" {
+			config.ImportRoots = append(config.ImportRoots, p.ModulesRoot)
+		}
 	}
 	rootCmd.PersistentFlags().BoolVarP(
 		&flagVerbose,
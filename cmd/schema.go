@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"pltf/pkg/config"
+	"pltf/pkg/schema"
+)
+
+var (
+	schemaCatalog bool
+	schemaMods    string
+	schemaOut     string
+)
+
+// schemaCmd prints JSON Schema for the spec kinds and module.yaml.
+var schemaCmd = &cobra.Command{
+	Use:       "schema <environment|service|module>",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"environment", "service", "module"},
+	Short:     "Print JSON Schema for Environment, Service or module.yaml",
+	Long: `Emit a JSON Schema (draft-07) for the platform.io/v1 Environment and Service kinds or for
+module.yaml. With --catalog, modules[].type is limited to the module catalog and modules[].inputs
+is validated against the module.yaml of the selected type (custom root first, then embedded modules).
+Point yaml-language-server (VS Code YAML extension) at the output for completion and inline errors.`,
+	Example: `  pltf schema environment --catalog > .pltf/environment.schema.json
+  pltf schema service --catalog -m ./modules --out .pltf/service.schema.json
+  pltf schema module > .pltf/module.schema.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := io.Writer(os.Stdout)
+		if strings.TrimSpace(schemaOut) != "" {
+			f, err := os.Create(schemaOut)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", schemaOut, err)
+			}
+			defer f.Close()
+			out = f
+		}
+		return writeSchema(out, args[0], schemaCatalog, schemaMods)
+	},
+}
+
+func writeSchema(out io.Writer, kind string, catalog bool, modulesRoot string) error {
+	var metas map[string]*config.ModuleMetadata
+	if catalog {
+		var err error
+		if metas, err = loadModuleCatalog(modulesRoot); err != nil {
+			return err
+		}
+	}
+
+	var doc schema.Schema
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "environment", "env":
+		doc = schema.Environment(metas)
+	case "service", "svc":
+		doc = schema.Service(metas)
+	case "module":
+		doc = schema.ModuleMetadata()
+	default:
+		return fmt.Errorf("unknown schema %q (use environment|service|module)", kind)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// loadModuleCatalog merges the embedded module catalog with the custom modules root; custom wins.
+func loadModuleCatalog(modulesRoot string) (map[string]*config.ModuleMetadata, error) {
+	embeddedRoot, customRoot, err := resolveModuleRoots(modulesRoot)
+	if err != nil {
+		return nil, err
+	}
+	metas, err := scanModules(embeddedRoot)
+	if err != nil {
+		return nil, err
+	}
+	if customRoot != "" {
+		custom, err := scanModules(customRoot)
+		if err != nil {
+			return nil, err
+		}
+		for t, m := range custom {
+			metas[t] = m
+		}
+	}
+	return metas, nil
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+
+	schemaCmd.Flags().BoolVar(&schemaCatalog, "catalog", false, "Type modules[].inputs per module type from the module catalog")
+	schemaCmd.Flags().StringVarP(&schemaMods, "modules", "m", "", "Custom modules root added to the catalog; defaults to profile modules_root")
+	schemaCmd.Flags().StringVar(&schemaOut, "out", "", "Write the schema to a file instead of stdout")
+}
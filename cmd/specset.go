@@ -0,0 +1,356 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pltf/pkg/config"
+	"pltf/pkg/generate"
+	"pltf/pkg/secrets"
+)
+
+// specStack is one Environment or Service spec of a set, rendered as its own Terraform stack.
+type specStack struct {
+	label  string
+	kind   string
+	file   string
+	envCfg *config.EnvironmentConfig
+	svcCfg *config.ServiceConfig
+}
+
+func (s specStack) name() string {
+	if s.svcCfg != nil {
+		return s.svcCfg.Metadata.Name
+	}
+	return s.envCfg.Metadata.Name
+}
+
+// hasEnv reports whether the stack defines the environment key env.
+func (s specStack) hasEnv(env string) bool {
+	if _, ok := s.envCfg.Environments[env]; !ok {
+		return false
+	}
+	if s.svcCfg != nil {
+		_, ok := s.svcCfg.Metadata.EnvRef[env]
+		return ok
+	}
+	return true
+}
+
+// outDir returns the standard output layout for the stack under root (".pltf" by default).
+func (s specStack) outDir(root, env string) string {
+	root = defaultString(root, ".pltf")
+	if s.svcCfg != nil {
+		return filepath.Join(root, s.envCfg.Metadata.Name, s.svcCfg.Metadata.Name, "env", env)
+	}
+	return filepath.Join(root, s.envCfg.Metadata.Name, "env", env)
+}
+
+func (s specStack) generate(embeddedRoot, customRoot, env, out string, cliVars map[string]string) (generate.Changes, error) {
+	absFile, err := filepath.Abs(s.file)
+	if err != nil {
+		return generate.Changes{}, err
+	}
+	specDir := filepath.Dir(absFile)
+	if s.svcCfg != nil {
+		return generate.GenerateServiceTF(s.svcCfg, s.envCfg, embeddedRoot, customRoot, env, out, specDir, cliVars)
+	}
+	return generate.GenerateEnvironmentTF(s.envCfg, embeddedRoot, customRoot, env, out, specDir, cliVars)
+}
+
+// stackContext returns the context for running Terraform in the stack's output directory dir.
+func (s specStack) stackContext(env, dir string) stackContext {
+	absDir, _ := filepath.Abs(dir)
+	absFile, _ := filepath.Abs(s.file)
+	return stackContext{
+		kind:      s.kind,
+		env:       env,
+		envCfg:    s.envCfg,
+		svcCfg:    s.svcCfg,
+		outDir:    absDir,
+		secrets:   secrets.Refs(s.envCfg, s.svcCfg, env),
+		specDir:   filepath.Dir(absFile),
+		approvals: config.ApprovalRequirements(s.envCfg, s.svcCfg, env),
+	}
+}
+
+// check renders the stack into a temporary directory and compares it with out.
+func (s specStack) check(embeddedRoot, customRoot, env, out string, cliVars map[string]string) (generate.Drift, error) {
+	absFile, err := filepath.Abs(s.file)
+	if err != nil {
+		return generate.Drift{}, err
+	}
+	specDir := filepath.Dir(absFile)
+	if s.svcCfg != nil {
+		return generate.CheckServiceTF(s.svcCfg, s.envCfg, embeddedRoot, customRoot, env, out, specDir, cliVars)
+	}
+	return generate.CheckEnvironmentTF(s.envCfg, embeddedRoot, customRoot, env, out, specDir, cliVars)
+}
+
+// reportDrift prints whether the stack's output directory matches its spec, with a unified diff
+// when it does not, and reports whether it drifted.
+func reportDrift(out io.Writer, s specStack, env, dir string, drift generate.Drift) bool {
+	if drift.Empty() {
+		fmt.Fprintf(out, "Up to date: %s Terraform for %q (env=%s) in %s\n", s.kind, s.name(), env, dir)
+		return false
+	}
+	fmt.Fprintf(out, "Out of date: %s Terraform for %q (env=%s) in %s (%s)\n", s.kind, s.name(), env, dir, drift.Changes)
+	fmt.Fprint(out, drift.Diff)
+	return true
+}
+
+// ensureSpecPath accepts a spec file or a directory of specs.
+func ensureSpecPath(path string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return nil
+	}
+	return ensureFile(path, "spec file")
+}
+
+// loadSpecStacks loads a spec set and returns a stack per spec, Environments first. Loading fails
+// when any spec in the set has errors; the diagnostics are printed to errOut first.
+func loadSpecStacks(errOut io.Writer, paths ...string) ([]specStack, error) {
+	set, ds, err := config.LoadSpecSet(paths...)
+	if err != nil {
+		return nil, err
+	}
+	if len(ds) > 0 {
+		config.RenderDiagnosticsText(errOut, ds)
+	}
+	if n := len(ds.Errors()); n > 0 {
+		return nil, fmt.Errorf("spec set %s has %d error(s)", strings.Join(paths, ", "), n)
+	}
+	stacks := make([]specStack, 0, len(set.Entries))
+	for _, e := range set.Entries {
+		stacks = append(stacks, newSpecStack(e))
+	}
+	if len(stacks) == 0 {
+		return nil, fmt.Errorf("no Environment or Service specs found in %s", strings.Join(paths, ", "))
+	}
+	return stacks, nil
+}
+
+// newSpecStack returns the stack of one spec set entry; a Service is paired with its Environment.
+func newSpecStack(e config.SpecEntry) specStack {
+	s := specStack{label: e.Label(), kind: e.Kind, file: e.File, envCfg: e.Environment}
+	if e.Service != nil {
+		s.envCfg, s.svcCfg = e.ServiceEnv, e.Service
+	}
+	return s
+}
+
+// stackEnv picks the environment key a stack is rendered for. With an explicit env, stacks that do
+// not define it are skipped (ok=false) so one invocation can cover services deployed to different
+// environments; without one the single-spec defaults apply.
+func stackEnv(s specStack, env string) (string, bool, error) {
+	if env = strings.TrimSpace(env); env != "" {
+		return env, s.hasEnv(env), nil
+	}
+	name, err := selectEnvName(s.kind, "", s.envCfg, s.svcCfg)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", s.label, err)
+	}
+	return name, true, nil
+}
+
+// validateSpecSet validates every spec of a set, including module input contracts, and reports all
+// findings together.
+func validateSpecSet(out io.Writer, path, env, modules, format string) error {
+	set, ds, err := config.LoadSpecSet(path)
+	if err != nil {
+		return err
+	}
+	envs, svcs := 0, 0
+	for _, e := range set.Entries {
+		var (
+			modDiags config.Diagnostics
+			err      error
+		)
+		switch {
+		case e.Service != nil && e.ServiceEnv != nil:
+			svcs++
+			modDiags, err = stackModuleDiagnostics(e.ServiceEnv, e.Service, sortedKeys(e.Service.Metadata.EnvRef), modules)
+			ds = append(ds, secretDiagnostics(e.ServiceEnv, e.Service)...)
+		case e.Service != nil:
+			svcs++
+			modDiags, err = moduleDiagnostics(e.Service.Modules, nil, modules)
+			modDiags = modDiags.Locate(e.Service.Sources)
+		default:
+			envs++
+			modDiags, err = stackModuleDiagnostics(e.Environment, nil, sortedKeys(e.Environment.Environments), modules)
+			ds = append(ds, secretDiagnostics(e.Environment, nil)...)
+		}
+		if err != nil {
+			return err
+		}
+		ds = append(ds, modDiags...)
+	}
+	if env = strings.TrimSpace(env); env != "" {
+		found := false
+		for _, e := range set.Entries {
+			if e.Environment != nil {
+				if _, ok := e.Environment.Environments[env]; ok {
+					found = true
+				}
+			}
+			if e.Service != nil {
+				if _, ok := e.Service.Metadata.EnvRef[env]; ok {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("environment %q is not defined by any spec in %s", env, path)
+		}
+		// Module dependency graphs depend on the environment, so they are only checked with -e.
+		if !ds.HasErrors() {
+			for _, e := range set.Entries {
+				s := newSpecStack(e)
+				if !s.hasEnv(env) {
+					continue
+				}
+				graphDiags, err := graphDiagnostics(s.file, s.envCfg, s.svcCfg, env, modules)
+				if err != nil {
+					return err
+				}
+				ds = append(ds, graphDiags...)
+			}
+		}
+	}
+
+	ds.Sort()
+	summary := fmt.Sprintf("Validated %d spec(s) in %s (%d environment(s), %d service(s))\n", len(set.Entries), path, envs, svcs)
+	return reportDiagnostics(out, ds, format, summary)
+}
+
+// generateSpecSet renders every spec of a set into the standard layout under outRoot.
+func generateSpecSet(out io.Writer, path, env, modulesRoot, outRoot string, vars []string) error {
+	stacks, err := loadSpecStacks(os.Stderr, path)
+	if err != nil {
+		return err
+	}
+	cliVars, err := parseVarFlags(vars)
+	if err != nil {
+		return err
+	}
+	embeddedRoot, customRoot, err := resolveModuleRoots(modulesRoot)
+	if err != nil {
+		return err
+	}
+
+	generated := 0
+	for _, s := range stacks {
+		envName, ok, err := stackEnv(s, env)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintf(out, "Skipped %s %q (%s): no environment %q\n", s.kind, s.name(), s.label, envName)
+			continue
+		}
+		dir := filepath.Clean(s.outDir(outRoot, envName))
+		changes, err := s.generate(embeddedRoot, customRoot, envName, dir, cliVars)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.label, err)
+		}
+		fmt.Fprintf(out, "Generated %s Terraform for %q (env=%s) into %s (%s)\n", s.kind, s.name(), envName, dir, changes)
+		printChanges(out, changes)
+		generated++
+	}
+	fmt.Fprintf(out, "Generated %d of %d spec(s) from %s\n", generated, len(stacks), path)
+	return nil
+}
+
+// checkSpecSet compares every spec of a set with its generated output under outRoot and fails when
+// any stack is out of date.
+func checkSpecSet(out io.Writer, path, env, modulesRoot, outRoot string, vars []string) error {
+	stacks, err := loadSpecStacks(os.Stderr, path)
+	if err != nil {
+		return err
+	}
+	cliVars, err := parseVarFlags(vars)
+	if err != nil {
+		return err
+	}
+	embeddedRoot, customRoot, err := resolveModuleRoots(modulesRoot)
+	if err != nil {
+		return err
+	}
+
+	checked, drifted := 0, 0
+	for _, s := range stacks {
+		envName, ok, err := stackEnv(s, env)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		dir := filepath.Clean(s.outDir(outRoot, envName))
+		drift, err := s.check(embeddedRoot, customRoot, envName, dir, cliVars)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.label, err)
+		}
+		checked++
+		if reportDrift(out, s, envName, dir, drift) {
+			drifted++
+		}
+	}
+	if drifted > 0 {
+		return fmt.Errorf("generated Terraform is out of date for %d of %d spec(s) in %s; run pltf generate", drifted, checked, path)
+	}
+	return nil
+}
+
+// planSpecSet generates and plans every spec of a set in order (Environments before the Services
+// that depend on them). A failed stack does not stop the others; the failures are summarised.
+func planSpecSet(out io.Writer, path, env, modulesRoot, outRoot string, vars []string, opts tfExecOpts) error {
+	if strings.TrimSpace(opts.planFile) != "" {
+		return fmt.Errorf("--plan-file cannot be used with a spec set; each stack writes its own plan")
+	}
+	stacks, err := loadSpecStacks(os.Stderr, path)
+	if err != nil {
+		return err
+	}
+	cliVars, err := parseVarFlags(vars)
+	if err != nil {
+		return err
+	}
+	embeddedRoot, customRoot, err := resolveModuleRoots(modulesRoot)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	planned := 0
+	for _, s := range stacks {
+		envName, ok, err := stackEnv(s, env)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintf(out, "Skipped %s %q (%s): no environment %q\n", s.kind, s.name(), s.label, envName)
+			continue
+		}
+		fmt.Fprintf(out, "==> Planning %s %q (env=%s) from %s\n", s.kind, s.name(), envName, s.label)
+		dir := filepath.Clean(s.outDir(outRoot, envName))
+		if _, err := s.generate(embeddedRoot, customRoot, envName, dir, cliVars); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", s.label, err))
+			continue
+		}
+		ctx := s.stackContext(envName, dir)
+		if err := runTfInStack("plan", s.label, ctx, "", opts); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", s.label, err))
+			continue
+		}
+		planned++
+	}
+	fmt.Fprintf(out, "Planned %d stack(s) from %s; %d failed\n", planned, path, len(failed))
+	if len(failed) > 0 {
+		return fmt.Errorf("%d stack(s) failed:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	return nil
+}
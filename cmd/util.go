@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -68,6 +69,12 @@ func ensureFile(path, description string) error {
 	return nil
 }
 
+// fileExists reports whether path is a regular file.
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
 func ensureDir(path, description string) error {
 	info, err := os.Stat(path)
 	if err != nil {
@@ -165,18 +172,33 @@ func resolveModuleRoots(userPath string) (embedded string, custom string, err er
 }
 
 func runCmd(dir, name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Dir = dir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return runCmdEnv(dir, nil, name, args...)
+}
+
+// runCmdEnv runs a command with extra KEY=VALUE entries appended to the current environment.
+func runCmdEnv(dir string, env []string, name string, args ...string) error {
+	_, err := runCmdIO(dir, env, os.Stdout, os.Stderr, name, args...)
+	return err
 }
 
 func runCmdExit(dir, name string, args ...string) (int, error) {
+	return runCmdExitEnv(dir, nil, name, args...)
+}
+
+func runCmdExitEnv(dir string, env []string, name string, args ...string) (int, error) {
+	return runCmdIO(dir, env, os.Stdout, os.Stderr, name, args...)
+}
+
+// runCmdIO runs a command with extra env entries, writing its output to stdout and stderr, and
+// returns its exit code.
+func runCmdIO(dir string, env []string, stdout, stderr io.Writer, name string, args ...string) (int, error) {
 	cmd := exec.Command(name, args...)
 	cmd.Dir = dir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	err := cmd.Run()
 	if err == nil {
 		return 0, nil
@@ -367,6 +389,7 @@ type profileConfig struct {
 	DefaultEnv  string `yaml:"default_env"`
 	DefaultOut  string `yaml:"default_out"`
 	Telemetry   bool   `yaml:"telemetry"`
+	PoliciesDir string `yaml:"policies_dir"`
 }
 
 func loadProfile() *profileConfig {
@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"pltf/pkg/config"
 )
 
 var (
@@ -12,6 +15,8 @@ var (
 	autoValEnv  string
 	autoValScan bool
 	autoValMods string
+	autoValOut  string
+	autoValSrc  bool
 )
 
 // validateCmd auto-detects Environment vs Service and validates accordingly.
@@ -21,28 +26,69 @@ var validateCmd = &cobra.Command{
 	Short: "Validate an Environment or Service spec (auto-detects kind)",
 	Long: `Parse a YAML spec, detect Environment vs Service, and run structural validation.
 Optionally assert that a specific environment key exists in both the environment file
-and the service envRef (for services). Lint suggestions are run alongside validation.`,
+and the service envRef (for services). Module inputs are checked against each module's
+module.yaml contract (types and unknown names). Lint suggestions are run alongside validation.
+Every error and warning is reported with its file, line, column and spec path; use
+--output json or --output sarif for editors and CI annotators. Specs that use extends/imports
+are composed first; --sources lists the file each final value came from. --scan reports in
+text only, so it cannot be combined with --sources or a non-text --output.
+
+-f also accepts a directory of specs or a multi-document YAML file ("---" separated). Every
+Environment and Service in the set is validated in one pass, and a Service's metadata.ref
+resolves to an Environment of the set by path or by metadata.name. Spec sets do not support
+--scan or --sources.`,
 	Example: `  pltf validate -f env.yaml
-  pltf validate -f service.yaml -e dev`,
+  pltf validate -f service.yaml -e dev
+  pltf validate -f service.yaml -e dev -o sarif > pltf.sarif
+  pltf validate -f envs/prod.yaml --sources
+  pltf validate -f ./specs -o json`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		autoValFile = defaultString(autoValFile, "env.yaml")
 		autoValFile = cleanOptionalPath(autoValFile)
 		autoValEnv = strings.TrimSpace(autoValEnv)
-		return ensureFile(autoValFile, "spec file")
+		return ensureSpecPath(autoValFile)
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		specSet := config.IsSpecSet(autoValFile)
+		if err := checkValidateFlags(specSet, autoValScan, autoValSrc, autoValOut); err != nil {
+			return err
+		}
+		if specSet {
+			return validateSpecSet(os.Stdout, autoValFile, autoValEnv, autoValMods, autoValOut)
+		}
 		if autoValScan {
 			return autoValidateWithScan(os.Stdout, autoValFile, autoValEnv, autoValMods)
 		}
-		return autoValidate(autoValFile, autoValEnv)
+		return autoValidate(autoValFile, autoValEnv, autoValMods, autoValOut, autoValSrc)
 	},
 }
 
+// checkValidateFlags rejects flag combinations a validate path would otherwise silently ignore.
+func checkValidateFlags(specSet, scan, sources bool, format string) error {
+	textOutput := func() bool {
+		f := strings.ToLower(strings.TrimSpace(format))
+		return f == "" || f == "text"
+	}
+	switch {
+	case specSet && scan:
+		return fmt.Errorf("--scan is not supported for spec sets; scan each stack with pltf terraform plan --scan")
+	case specSet && sources:
+		return fmt.Errorf("--sources is not supported for spec sets; pass a single spec file")
+	case scan && sources:
+		return fmt.Errorf("--sources cannot be combined with --scan")
+	case scan && !textOutput():
+		return fmt.Errorf("--output %s cannot be combined with --scan; scan results are text only", format)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(validateCmd)
 
-	validateCmd.Flags().StringVarP(&autoValFile, "file", "f", "env.yaml", "Path to the Environment or Service YAML file")
+	validateCmd.Flags().StringVarP(&autoValFile, "file", "f", "env.yaml", "Path to the Environment or Service YAML file, a multi-document YAML file, or a directory of specs")
 	validateCmd.Flags().StringVarP(&autoValEnv, "env", "e", "", "Environment key to assert exists (dev, prod, etc.)")
 	validateCmd.Flags().BoolVar(&autoValScan, "scan", false, "Run tfsec security scan against generated Terraform")
-	validateCmd.Flags().StringVarP(&autoValMods, "modules", "m", "", "Override modules root; defaults to embedded modules")
+	validateCmd.Flags().StringVarP(&autoValOut, "output", "o", "text", "Output format: text|json|sarif")
+	validateCmd.Flags().BoolVar(&autoValSrc, "sources", false, "List the file and line each final value came from (after extends/imports)")
+	validateCmd.Flags().StringVarP(&autoValMods, "modules", "m", "", "Override modules root used for input contract checks and scans; defaults to embedded modules")
 }
@@ -0,0 +1,205 @@
+// Package bundle reads and writes plan bundles: a saved Terraform plan packed with a manifest that
+// pins what it was planned from (the generated Terraform, the spec, the pltf version and the
+// environment key), signed with HMAC-SHA256 so apply can tell the bundle was produced by a trusted
+// plan run. Unsigned bundles are only accepted when the caller allows them explicitly.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FormatVersion is the manifest format written by this package.
+const FormatVersion = 1
+
+// KeyEnv names the environment variable holding the signing key.
+const KeyEnv = "PLTF_PLAN_SIGNING_KEY"
+
+const (
+	manifestName = "manifest.json"
+	planName     = "plan.tfplan"
+)
+
+// Manifest describes the plan held by a bundle.
+type Manifest struct {
+	Format      int       `json:"format"`
+	PltfVersion string    `json:"pltf_version"`
+	Kind        string    `json:"kind"`
+	Name        string    `json:"name"`
+	Env         string    `json:"env"`
+	Spec        string    `json:"spec"`
+	SpecHash    string    `json:"spec_hash"`
+	SourceHash  string    `json:"source_hash"` // of the generated Terraform
+	PlanHash    string    `json:"plan_hash"`   // of plan.tfplan
+	CreatedAt   time.Time `json:"created_at"`
+	// Signature is the hex HMAC-SHA256 of the manifest without it; empty for unsigned bundles.
+	Signature string `json:"signature,omitempty"`
+}
+
+// Signed reports whether the manifest carries a signature.
+func (m Manifest) Signed() bool {
+	return m.Signature != ""
+}
+
+func (m Manifest) sign(key []byte) (string, error) {
+	m.Signature = ""
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Verify checks the signature against key. A signed manifest needs a key, and with a key an unsigned
+// manifest is rejected so that a signing setup cannot be bypassed by dropping the signature. Without
+// a key an unsigned manifest passes only when allowUnsigned is set.
+func (m Manifest) Verify(key []byte, allowUnsigned bool) error {
+	switch {
+	case len(key) == 0 && m.Signed():
+		return fmt.Errorf("plan bundle is signed; set %s to verify it", KeyEnv)
+	case len(key) == 0 && !allowUnsigned:
+		return fmt.Errorf("plan bundle is not signed and %s is not set", KeyEnv)
+	case len(key) == 0:
+		return nil
+	case !m.Signed():
+		return fmt.Errorf("plan bundle is not signed but %s is set", KeyEnv)
+	}
+	want, err := m.sign(key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(want), []byte(m.Signature)) {
+		return fmt.Errorf("plan bundle signature does not match; it was modified or signed with another key")
+	}
+	return nil
+}
+
+// Key returns the signing key from KeyEnv, or nil when it is unset.
+func Key() []byte {
+	if k := os.Getenv(KeyEnv); k != "" {
+		return []byte(k)
+	}
+	return nil
+}
+
+// Write packs planFile with m into a gzipped tarball at path. PlanHash and Format are filled in, and
+// the manifest is signed when key is set.
+func Write(path string, m Manifest, planFile string, key []byte) error {
+	plan, err := os.ReadFile(planFile)
+	if err != nil {
+		return fmt.Errorf("read plan: %w", err)
+	}
+	m.Format = FormatVersion
+	m.PlanHash = HashBytes(plan)
+	m.Signature = ""
+	if len(key) > 0 {
+		if m.Signature, err = m.sign(key); err != nil {
+			return err
+		}
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{{manifestName, append(manifest, '\n')}, {planName, plan}} {
+		hdr := &tar.Header{Name: entry.name, Mode: 0o644, Size: int64(len(entry.data)), ModTime: m.CreatedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Open unpacks the bundle at path, writing its plan into dir, and checks the plan against the
+// manifest's PlanHash. It returns the manifest and the path of the extracted plan. Signatures are
+// checked separately with Manifest.Verify.
+func Open(path, dir string) (Manifest, string, error) {
+	var m Manifest
+	f, err := os.Open(path)
+	if err != nil {
+		return m, "", err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return m, "", fmt.Errorf("read plan bundle %s: %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+	var manifest, plan []byte
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return m, "", fmt.Errorf("read plan bundle %s: %w", path, err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return m, "", fmt.Errorf("read plan bundle %s: %w", path, err)
+		}
+		switch hdr.Name {
+		case manifestName:
+			manifest = data
+		case planName:
+			plan = data
+		}
+	}
+	if manifest == nil || plan == nil {
+		return m, "", fmt.Errorf("plan bundle %s is missing %s or %s", path, manifestName, planName)
+	}
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return m, "", fmt.Errorf("parse %s in %s: %w", manifestName, path, err)
+	}
+	if m.Format != FormatVersion {
+		return m, "", fmt.Errorf("plan bundle %s has format %d; this pltf reads format %d", path, m.Format, FormatVersion)
+	}
+	if got := HashBytes(plan); got != m.PlanHash {
+		return m, "", fmt.Errorf("plan in %s does not match its manifest (sha256 %s, want %s)", path, got, m.PlanHash)
+	}
+	planPath := filepath.Join(dir, planName)
+	if err := os.WriteFile(planPath, plan, 0o600); err != nil {
+		return m, "", err
+	}
+	return m, planPath, nil
+}
+
+// HashBytes returns the hex sha256 of data.
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
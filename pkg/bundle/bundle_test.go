@@ -0,0 +1,76 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestBundle(t *testing.T, key []byte) (string, []byte) {
+	t.Helper()
+	dir := t.TempDir()
+	plan := []byte("not really a terraform plan")
+	planFile := filepath.Join(dir, "in.tfplan")
+	if err := os.WriteFile(planFile, plan, 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	path := filepath.Join(dir, "bundle", "plan.tar.gz")
+	m := Manifest{PltfVersion: "1.2.3", Kind: "Environment", Name: "demo", Env: "dev", SpecHash: "spec", SourceHash: "source", CreatedAt: time.Unix(0, 0).UTC()}
+	if err := Write(path, m, planFile, key); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return path, plan
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	key := []byte("secret")
+	path, plan := writeTestBundle(t, key)
+
+	m, planPath, err := Open(path, t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := os.ReadFile(planPath)
+	if err != nil || string(got) != string(plan) {
+		t.Fatalf("extracted plan = %q, %v", got, err)
+	}
+	if m.Name != "demo" || m.Env != "dev" || m.PlanHash != HashBytes(plan) || !m.Signed() {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+	if err := m.Verify(key, false); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := m.Verify([]byte("other"), false); err == nil || !strings.Contains(err.Error(), "signature does not match") {
+		t.Fatalf("expected a wrong key to fail, got %v", err)
+	}
+	if err := m.Verify(nil, true); err == nil || !strings.Contains(err.Error(), KeyEnv) {
+		t.Fatalf("expected a signed bundle to need a key, got %v", err)
+	}
+
+	m.Env = "prod"
+	if err := m.Verify(key, false); err == nil {
+		t.Fatalf("expected an edited manifest to fail verification")
+	}
+}
+
+func TestUnsignedBundle(t *testing.T) {
+	path, _ := writeTestBundle(t, nil)
+	m, _, err := Open(path, t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if m.Signed() {
+		t.Fatalf("expected an unsigned bundle")
+	}
+	if err := m.Verify(nil, false); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Fatalf("expected an unsigned bundle to need allowUnsigned, got %v", err)
+	}
+	if err := m.Verify(nil, true); err != nil {
+		t.Fatalf("unsigned bundle allowed without a key: %v", err)
+	}
+	if err := m.Verify([]byte("secret"), true); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Fatalf("expected an unsigned bundle to be rejected when a key is set, got %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentAPIVersion is the apiVersion of the in-memory model. Documents written for an older
+// registered version are upgraded to it when loaded and can be rewritten with pltf migrate.
+const CurrentAPIVersion = "platform.io/v1"
+
+// Migration upgrades a spec document from one apiVersion to the next. Apply edits the document's
+// root mapping in place (so comments and key order survive) and returns one note per change.
+type Migration struct {
+	From  string
+	To    string
+	Apply func(kind string, root *yaml.Node) ([]string, error)
+}
+
+// migrations is the upgrade chain, oldest first. Each breaking spec change adds a new
+// CurrentAPIVersion and a Migration from the previous one. platform.io/v1 is the first versioned
+// model, so the chain is empty.
+var migrations []Migration
+
+// KnownAPIVersions lists every apiVersion that can be loaded, oldest first.
+func KnownAPIVersions() []string {
+	var out []string
+	for _, m := range migrations {
+		out = append(out, m.From)
+	}
+	return append(out, CurrentAPIVersion)
+}
+
+// MigrateDocument upgrades a parsed spec (document or root mapping node) in place to
+// CurrentAPIVersion. It returns the apiVersion the document started at and notes for every
+// change. Documents without apiVersion (e.g. import fragments) are left untouched.
+func MigrateDocument(doc *yaml.Node) (string, []string, error) {
+	from, notes, _, err := migrateDocument(doc)
+	return from, notes, err
+}
+
+// migrateDocument is MigrateDocument that also reports whether a migration step edited the document
+// beyond its apiVersion value.
+func migrateDocument(doc *yaml.Node) (string, []string, bool, error) {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	versionNode := mappingValue(root, "apiVersion")
+	if versionNode == nil {
+		return "", nil, false, nil
+	}
+	from := strings.TrimSpace(versionNode.Value)
+	if from == "" {
+		return "", nil, false, nil
+	}
+	kind := ""
+	if k := mappingValue(root, "kind"); k != nil {
+		kind = k.Value
+	}
+
+	var (
+		notes  []string
+		edited bool
+	)
+	for version := from; version != CurrentAPIVersion; {
+		m, ok := migrationFrom(version)
+		if !ok {
+			return from, nil, false, fmt.Errorf("unsupported apiVersion %q (known: %s)", version, strings.Join(KnownAPIVersions(), ", "))
+		}
+		if m.Apply != nil {
+			stepNotes, err := m.Apply(kind, root)
+			if err != nil {
+				return from, nil, false, fmt.Errorf("migrate %s -> %s: %w", m.From, m.To, err)
+			}
+			notes = append(notes, stepNotes...)
+			edited = edited || len(stepNotes) > 0
+		}
+		versionNode.Value = m.To
+		notes = append(notes, fmt.Sprintf("apiVersion: %s -> %s", m.From, m.To))
+		version = m.To
+	}
+	return from, notes, edited, nil
+}
+
+func migrationFrom(version string) (Migration, bool) {
+	for _, m := range migrations {
+		if m.From == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// MigrateSpec upgrades every document in a YAML spec file to CurrentAPIVersion. When no document
+// needs changes the original bytes are returned unchanged with no notes. When the only change is
+// the apiVersion value, that value is replaced in the original bytes so the rest of the file,
+// including blank lines and indentation, stays as written. Otherwise the edited yaml.Node tree is
+// re-encoded, which keeps comments and key order.
+func MigrateSpec(data []byte) ([]byte, []string, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var (
+		docs     []*yaml.Node
+		notes    []string
+		edits    []versionEdit
+		reencode bool
+	)
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, nil, fmt.Errorf("failed to parse yaml: %w", err)
+		}
+		before := documentVersion(&doc)
+		_, docNotes, edited, err := migrateDocument(&doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(docNotes) > 0 {
+			edits = append(edits, versionEdit{before: before, value: documentVersion(&doc).Value})
+		}
+		notes = append(notes, docNotes...)
+		reencode = reencode || edited
+		docs = append(docs, &doc)
+	}
+	if len(notes) == 0 {
+		return data, nil, nil
+	}
+	if !reencode {
+		if out, ok := spliceVersions(data, edits); ok {
+			return out, notes, nil
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode migrated yaml: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode migrated yaml: %w", err)
+	}
+	return buf.Bytes(), notes, nil
+}
+
+// versionEdit replaces the apiVersion scalar of one document.
+type versionEdit struct {
+	before yaml.Node // the apiVersion value node as parsed, with its position
+	value  string
+}
+
+// documentVersion returns a copy of the apiVersion value node of a document, or a zero node.
+func documentVersion(doc *yaml.Node) yaml.Node {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if n := mappingValue(root, "apiVersion"); n != nil {
+		return *n
+	}
+	return yaml.Node{}
+}
+
+// spliceVersions rewrites each apiVersion value in place in data, keeping its quoting. It reports
+// false when a value is not found where the parser placed it, such as a multi-line scalar.
+func spliceVersions(data []byte, edits []versionEdit) ([]byte, bool) {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for _, e := range edits {
+		n := e.before
+		if n.Line < 1 || n.Line > len(lines) || n.Column < 1 {
+			return nil, false
+		}
+		line := string(lines[n.Line-1])
+		start := len(string([]rune(line)[:min(n.Column-1, len([]rune(line)))]))
+		var quote string
+		switch n.Style {
+		case 0:
+		case yaml.DoubleQuotedStyle:
+			quote = `"`
+		case yaml.SingleQuotedStyle:
+			quote = "'"
+		default:
+			return nil, false
+		}
+		old := quote + n.Value + quote
+		if !strings.HasPrefix(line[start:], old) {
+			return nil, false
+		}
+		lines[n.Line-1] = []byte(line[:start] + quote + e.value + quote + line[start+len(old):])
+	}
+	return bytes.Join(lines, nil), true
+}
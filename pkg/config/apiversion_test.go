@@ -0,0 +1,147 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// withMigration registers a temporary upgrade in front of the real chain.
+func withMigration(t *testing.T, m Migration) {
+	t.Helper()
+	saved := migrations
+	migrations = append([]Migration{m}, migrations...)
+	t.Cleanup(func() { migrations = saved })
+}
+
+func TestMigrateSpecPreservesCommentsAndOrder(t *testing.T) {
+	withMigration(t, Migration{
+		From: "platform.io/v0",
+		To:   CurrentAPIVersion,
+		Apply: func(kind string, root *yaml.Node) ([]string, error) {
+			meta := mappingValue(root, "metadata")
+			for i := 0; i+1 < len(meta.Content); i += 2 {
+				if meta.Content[i].Value == "organisation" {
+					meta.Content[i].Value = "org"
+					return []string{"metadata.organisation renamed to metadata.org"}, nil
+				}
+			}
+			return nil, nil
+		},
+	})
+
+	src := `# shared platform env
+apiVersion: platform.io/v0
+kind: Environment
+metadata:
+  name: demo # display name
+  organisation: acme
+  provider: aws
+`
+	out, notes, err := MigrateSpec([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `# shared platform env
+apiVersion: platform.io/v1
+kind: Environment
+metadata:
+  name: demo # display name
+  org: acme
+  provider: aws
+`
+	if string(out) != want {
+		t.Fatalf("unexpected migration output:\n%s", out)
+	}
+	if len(notes) != 2 || notes[0] != "metadata.organisation renamed to metadata.org" {
+		t.Fatalf("unexpected notes %v", notes)
+	}
+
+	again, notes, err := MigrateSpec(out)
+	if err != nil || len(notes) != 0 || string(again) != string(out) {
+		t.Fatalf("migrating a current spec should be a no-op, got notes=%v err=%v", notes, err)
+	}
+
+	if _, _, err := MigrateSpec([]byte("apiVersion: platform.io/v9\nkind: Environment\n")); err == nil ||
+		!strings.Contains(err.Error(), `unsupported apiVersion "platform.io/v9"`) {
+		t.Fatalf("expected unsupported apiVersion error, got %v", err)
+	}
+}
+
+func TestLoadEnvironmentConfigUpgradesOlderAPIVersion(t *testing.T) {
+	withMigration(t, Migration{From: "platform.io/v0", To: CurrentAPIVersion})
+	dir := t.TempDir()
+	path := filepath.Join(dir, "env.yaml")
+	writeSpec(t, path, `apiVersion: platform.io/v0
+kind: Environment
+metadata:
+  name: demo
+  org: acme
+  provider: aws
+  labels:
+    team: platform
+environments:
+  dev:
+    account: "111111111111"
+    region: us-east-1
+modules:
+  - id: base
+    type: aws_base
+`)
+
+	cfg, ds, err := LoadEnvironmentConfigDiagnostics(path)
+	if err != nil || ds.HasErrors() {
+		t.Fatalf("unexpected failure: err=%v diags=%+v", err, ds)
+	}
+	if cfg.APIVersion != CurrentAPIVersion {
+		t.Fatalf("apiVersion = %q, want %q", cfg.APIVersion, CurrentAPIVersion)
+	}
+	if len(ds) != 1 || ds[0].Severity != SeverityWarning || ds[0].Line != 1 || !strings.Contains(ds[0].Message, "pltf migrate") {
+		t.Fatalf("expected deprecation warning on line 1, got %+v", ds)
+	}
+
+	writeSpec(t, path, "apiVersion: example.com/v2\nkind: Environment\n")
+	_, ds, _ = LoadEnvironmentConfigDiagnostics(path)
+	if len(ds) != 1 || !strings.Contains(ds[0].Message, "known: platform.io/v0, platform.io/v1") {
+		t.Fatalf("expected unsupported apiVersion diagnostic, got %+v", ds)
+	}
+}
+
+func TestMigrateSpecKeepsLayout(t *testing.T) {
+	current := `# shared platform env
+apiVersion: platform.io/v1
+kind: Environment
+
+metadata:
+    name: demo   # display name
+    org: acme
+    provider: aws
+
+modules:
+- id: base
+  type: aws_base
+---
+apiVersion: "platform.io/v1"
+kind: Service
+`
+	out, notes, err := MigrateSpec([]byte(current))
+	if err != nil || len(notes) != 0 || string(out) != current {
+		t.Fatalf("a current spec should round-trip byte for byte, got notes=%v err=%v:\n%s", notes, err, out)
+	}
+
+	// A migration that only renames apiVersion leaves everything else in the file where it was.
+	withMigration(t, Migration{From: "platform.io/v0", To: CurrentAPIVersion})
+	old := strings.ReplaceAll(current, "platform.io/v1", "platform.io/v0")
+	out, notes, err = MigrateSpec([]byte(old))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != current {
+		t.Fatalf("expected only apiVersion to change, got:\n%s", out)
+	}
+	if len(notes) != 2 || notes[0] != "apiVersion: platform.io/v0 -> platform.io/v1" {
+		t.Fatalf("unexpected notes %v", notes)
+	}
+}
@@ -1,8 +1,97 @@
 package config
 
-// ApprovalRequirement defines an approval gate for a spec.
+import (
+	"fmt"
+	"strings"
+)
+
+// ApprovalRequirement defines an approval gate for a spec. `pltf terraform apply` and `destroy`
+// refuse to run until the pull request reviews satisfy every gate that applies to the environment.
 type ApprovalRequirement struct {
-	Name  string `yaml:"name"`
+	// Name is who must approve: a user login ("alice"), a team ("acme/platform"), or empty / "*" for
+	// any reviewer. A leading "@" is ignored.
+	Name string `yaml:"name"`
+	// Alias is shown instead of Name in PR comments and errors.
 	Alias string `yaml:"alias,omitempty"`
-	Count int    `yaml:"count,omitempty"`
+	// Count is the number of distinct approvals required; defaults to 1.
+	Count int `yaml:"count,omitempty"`
+	// Environments limits the gate to these environment keys; empty applies it everywhere.
+	Environments []string `yaml:"environments,omitempty"`
+}
+
+// Approver returns Name without a leading "@"; "*" is normalised to "".
+func (a ApprovalRequirement) Approver() string {
+	name := strings.TrimPrefix(strings.TrimSpace(a.Name), "@")
+	if name == "*" {
+		return ""
+	}
+	return name
+}
+
+// IsTeam reports whether the gate names a team ("org/team").
+func (a ApprovalRequirement) IsTeam() bool {
+	return strings.Contains(a.Approver(), "/")
+}
+
+// Required returns the number of approvals the gate needs.
+func (a ApprovalRequirement) Required() int {
+	if a.Count <= 0 {
+		return 1
+	}
+	return a.Count
+}
+
+// Label is the gate's display name.
+func (a ApprovalRequirement) Label() string {
+	if s := strings.TrimSpace(a.Alias); s != "" {
+		return s
+	}
+	if s := a.Approver(); s != "" {
+		return s
+	}
+	return "any reviewer"
+}
+
+// AppliesTo reports whether the gate covers environment key env.
+func (a ApprovalRequirement) AppliesTo(env string) bool {
+	return len(a.Environments) == 0 || containsString(a.Environments, env)
+}
+
+// ApprovalRequirements returns the gates for envKey: the Environment's followed by the Service's.
+func ApprovalRequirements(env *EnvironmentConfig, svc *ServiceConfig, envKey string) []ApprovalRequirement {
+	var out []ApprovalRequirement
+	var all []ApprovalRequirement
+	if env != nil {
+		all = append(all, env.Metadata.Approve...)
+	}
+	if svc != nil {
+		all = append(all, svc.Metadata.Approve...)
+	}
+	for _, a := range all {
+		if a.AppliesTo(envKey) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// diagnoseApprovals checks gates under path; envs lists the environment keys the spec defines.
+func diagnoseApprovals(path string, gates []ApprovalRequirement, envs map[string]bool, ds *Diagnostics) {
+	for i, a := range gates {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		if a.Count < 0 {
+			ds.Errorf(p+".count", "approval count must not be negative, got %d", a.Count)
+		}
+		if name := a.Approver(); strings.Count(name, "/") > 1 || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+			ds.Errorf(p+".name", "approval name %q must be a user login or an org/team", a.Name)
+		}
+		if !a.IsTeam() && a.Approver() != "" && a.Required() > 1 {
+			ds.Errorf(p+".count", "approval gate for user %s cannot require %d approvals", a.Approver(), a.Required())
+		}
+		for _, env := range a.Environments {
+			if envs != nil && !envs[env] {
+				ds.Errorf(p+".environments", "approval gate references unknown environment %q", env)
+			}
+		}
+	}
 }
@@ -0,0 +1,112 @@
+package config
+
+import "fmt"
+
+// OutputsWithCapability returns the outputs tagged with capability, in declaration order.
+func (m *ModuleMetadata) OutputsWithCapability(capability string) []string {
+	var out []string
+	for _, o := range m.Outputs {
+		if o.Capability == capability {
+			out = append(out, o.Name)
+		}
+	}
+	return out
+}
+
+// AcceptedCapabilities returns capabilities.accepts together with the capabilities its inputs are
+// tagged with, sorted.
+func (m *ModuleMetadata) AcceptedCapabilities() []string {
+	seen := map[string]struct{}{}
+	for _, c := range m.Capabilities.Accepts {
+		seen[c] = struct{}{}
+	}
+	for _, in := range m.Inputs {
+		if in.Capability != "" {
+			seen[in.Capability] = struct{}{}
+		}
+	}
+	return sortedMapKeys(seen)
+}
+
+// DiagnoseCapabilities reports capabilities that modules accept but no other module in the stack
+// provides through a tagged output, the only outputs auto-wiring reads. parent holds the
+// Environment's modules when mods belong to a Service, since their outputs reach the service
+// through remote state. An input tagged with such a capability that is required and has no value,
+// wire or same-named output is an error; anything else is a warning. It also warns about module
+// contracts auto-wiring cannot honour: a provided capability with no tagged output, or a tagged
+// input missing from accepts. metas is keyed by module type; modules of unknown types are skipped.
+func DiagnoseCapabilities(mods, parent []Module, metas map[string]*ModuleMetadata) Diagnostics {
+	providers := map[string][]string{}
+	outputs := map[string][]string{}
+	for _, m := range append(append([]Module{}, mods...), parent...) {
+		meta := metas[m.Type]
+		if meta == nil {
+			continue
+		}
+		for _, o := range meta.Outputs {
+			outputs[o.Name] = append(outputs[o.Name], m.ID)
+			if o.Capability != "" {
+				providers[o.Capability] = append(providers[o.Capability], m.ID)
+			}
+		}
+	}
+	others := func(ids []string, self string) bool {
+		for _, id := range ids {
+			if id != self {
+				return true
+			}
+		}
+		return false
+	}
+
+	var ds Diagnostics
+	for i, m := range mods {
+		meta := metas[m.Type]
+		if meta == nil {
+			continue
+		}
+		for _, c := range meta.Capabilities.Provides {
+			if len(meta.OutputsWithCapability(c)) == 0 {
+				ds.Warnf(fmt.Sprintf("modules[%d].type", i),
+					"module type %s provides capability %q, but no output is tagged with it, so nothing is wired from it",
+					m.Type, c)
+			}
+		}
+		for _, in := range meta.Inputs {
+			if in.Capability != "" && !containsString(meta.Capabilities.Accepts, in.Capability) {
+				ds.Warnf(fmt.Sprintf("modules[%d].type", i),
+					"module type %s input %q declares capability %q, but it is not listed in capabilities.accepts",
+					m.Type, in.Name, in.Capability)
+			}
+		}
+		for _, c := range meta.AcceptedCapabilities() {
+			if others(providers[c], m.ID) {
+				continue
+			}
+			unset := false
+			for _, in := range meta.Inputs {
+				if in.Capability != c || !in.Required || in.Default != nil {
+					continue
+				}
+				if _, ok := m.Inputs[in.Name]; ok {
+					continue
+				}
+				if _, ok := m.Wire[in.Name]; ok {
+					continue
+				}
+				if others(outputs[in.Name], m.ID) {
+					continue
+				}
+				unset = true
+				ds.Errorf(fmt.Sprintf("modules[%d].inputs.%s", i, in.Name),
+					"module %q input %q accepts capability %q, but no module in the stack provides it and the input has no value",
+					m.ID, in.Name, c)
+			}
+			if !unset {
+				ds.Warnf(fmt.Sprintf("modules[%d].type", i),
+					"module %q (type=%s) accepts capability %q, but no module in the stack provides it", m.ID, m.Type, c)
+			}
+		}
+	}
+	return ds
+}
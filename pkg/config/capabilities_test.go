@@ -0,0 +1,80 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagnoseCapabilities(t *testing.T) {
+	metas := map[string]*ModuleMetadata{
+		"store": {
+			Capabilities: Capabilities{Provides: []string{"store.arn"}},
+			Outputs:      []OutputSpec{{Name: "store_arn", Type: "string", Capability: "store.arn"}},
+		},
+		"client": {
+			Capabilities: Capabilities{Accepts: []string{"store.arn", "queue.url"}},
+			Inputs: []InputSpec{
+				{Name: "target_arn", Type: "string", Required: true, Capability: "store.arn"},
+				{Name: "queue_url", Type: "string", Required: true, Capability: "queue.url"},
+			},
+		},
+	}
+
+	ds := DiagnoseCapabilities([]Module{{ID: "client", Type: "client"}}, nil, metas)
+	if n := len(ds.Errors()); n != 2 {
+		t.Fatalf("expected 2 errors for unprovided required inputs, got %d: %v", n, ds)
+	}
+	if ds[0].Path != "modules[0].inputs.queue_url" || !strings.Contains(ds[0].Message, `accepts capability "queue.url"`) {
+		t.Fatalf("unexpected first diagnostic: %v", ds[0])
+	}
+
+	// A provider in the parent Environment satisfies store.arn; an explicit value downgrades
+	// queue.url to a warning.
+	mods := []Module{{ID: "client", Type: "client", Inputs: map[string]interface{}{"queue_url": "https://q"}}}
+	ds = DiagnoseCapabilities(mods, []Module{{ID: "kv", Type: "store"}}, metas)
+	if ds.HasErrors() || len(ds) != 1 {
+		t.Fatalf("expected a single warning, got %v", ds)
+	}
+	if ds[0].Severity != SeverityWarning || ds[0].Path != "modules[0].type" || !strings.Contains(ds[0].Message, `"queue.url"`) {
+		t.Fatalf("unexpected warning: %v", ds[0])
+	}
+}
+
+func TestDiagnoseCapabilitiesWarnsAboutUnwirableContracts(t *testing.T) {
+	metas := map[string]*ModuleMetadata{
+		"store": {
+			Name: "store", Type: "store", Provider: "aws", Version: "1.0.0",
+			Capabilities: Capabilities{Provides: []string{"store.arn"}},
+			Outputs:      []OutputSpec{{Name: "store_arn", Type: "string"}},
+		},
+		"client": {
+			Name: "client", Type: "client", Provider: "aws", Version: "1.0.0",
+			Inputs: []InputSpec{{Name: "target_arn", Type: "string", Capability: "store.arn"}},
+		},
+	}
+	// Both contracts still load; they only affect wiring.
+	for _, meta := range metas {
+		if err := meta.Validate(); err != nil {
+			t.Fatalf("Validate error: %v", err)
+		}
+	}
+
+	ds := DiagnoseCapabilities([]Module{{ID: "kv", Type: "store"}, {ID: "client", Type: "client"}}, nil, metas)
+	if ds.HasErrors() {
+		t.Fatalf("expected warnings only, got %v", ds)
+	}
+	var provides, accepts bool
+	for _, d := range ds {
+		provides = provides || strings.Contains(d.Message, "no output is tagged with it")
+		accepts = accepts || strings.Contains(d.Message, "not listed in capabilities.accepts")
+	}
+	if !provides || !accepts {
+		t.Fatalf("expected provides and accepts warnings, got %v", ds)
+	}
+
+	metas["store"].Outputs[0].Capability = "store.arn"
+	metas["client"].Capabilities.Accepts = []string{"store.arn"}
+	if ds := DiagnoseCapabilities([]Module{{ID: "kv", Type: "store"}, {ID: "client", Type: "client"}}, nil, metas); len(ds) != 0 {
+		t.Fatalf("expected no diagnostics, got %v", ds)
+	}
+}
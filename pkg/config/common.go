@@ -8,11 +8,12 @@ import (
 
 // SecretRef describes where to resolve a secret value.
 type SecretRef struct {
-	// Source is retained for backward compatibility but defaults to env and is otherwise ignored.
+	// Source selects the resolver (env, file, sops, ssm, secretsmanager, vault); defaults to env.
 	Source string `yaml:"source,omitempty"`
 	// Key is the logical name of the secret; defaults to the map key if omitted.
 	Key string `yaml:"key,omitempty"`
-	// Path is optional and can be used by future resolvers (e.g., SSM/Secrets Manager).
+	// Path locates the secret for the resolver: an env var, file, SSM parameter, secret id or
+	// vault <mount>/<name>. "#field" selects one field of a structured secret.
 	Path string `yaml:"path,omitempty"`
 }
 
@@ -33,6 +34,24 @@ type Module struct {
 	Source string                 `yaml:"source,omitempty"` // "custom" to force custom root
 	Inputs map[string]interface{} `yaml:"inputs,omitempty"`
 	Links  AccessLinks            `yaml:"links,omitempty"`
+	// Wire maps an input to the module output that feeds it, as <module>.<output> (or
+	// parent.<output> in a Service), for outputs whose names differ from the input.
+	Wire map[string]string `yaml:"wire,omitempty"`
+
+	// ForEach (a list or map) or Count stamps out one instance per entry; inputs may use
+	// ${each.key}, ${each.value} and ${each.value.<field>}.
+	ForEach interface{} `yaml:"forEach,omitempty"`
+	Count   *int        `yaml:"count,omitempty"`
+	// Expand renders one module block per instance (<id>_<key>) instead of a single block with
+	// Terraform for_each/count.
+	Expand bool `yaml:"expand,omitempty"`
+
+	// PreviousIDs lists ids the module had before it was renamed; each becomes a Terraform moved
+	// block so its resources keep their state instead of being destroyed and recreated.
+	PreviousIDs []string `yaml:"previousIds,omitempty"`
+
+	// Protect overrides the resources module.yaml protects from being deleted or replaced.
+	Protect *Protection `yaml:"protect,omitempty"`
 }
 
 // AccessLinks maps access level → list of target module IDs.
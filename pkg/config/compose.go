@@ -0,0 +1,391 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportRoots lists directories searched for imports written as "modules://<path>".
+// Directories from PLTF_IMPORT_PATH (os.PathListSeparator separated) are searched after these.
+var ImportRoots []string
+
+const moduleRootImportPrefix = "modules://"
+
+// specComposer resolves the extends/imports chain of a spec into a single YAML tree.
+// Every node remembers the file it was read from so diagnostics and provenance point at
+// the file that supplied the final value.
+type specComposer struct {
+	kind  string
+	files map[*yaml.Node]string
+	stack []string
+	ds    Diagnostics
+
+	// refs declared by the top-level file, kept for the loaded config.
+	extends string
+	imports []string
+}
+
+// composeSpec resolves the extends/imports chain of document index of path (data holds the whole
+// file, doc its parsed document node) and deep-merges it in order: the extends base first, then
+// each import, then the document itself. Mappings merge key by key, lists of objects with an id
+// merge by id, and any other value is replaced by the later file. The returned root is nil when
+// any file in the chain fails to parse or resolve.
+func composeSpec(path string, data []byte, index int, doc *yaml.Node, kind string) (*yaml.Node, *SourceIndex, Diagnostics) {
+	c := &specComposer{kind: kind, files: map[*yaml.Node]string{}}
+	c.stack = append(c.stack, absPath(path))
+	root := c.compose(path, data, index, doc, false)
+	if root == nil || c.ds.HasErrors() {
+		return nil, nil, c.ds
+	}
+	idx := &SourceIndex{
+		File:      path,
+		positions: map[string]Position{},
+		files:     c.files,
+		extends:   c.extends,
+		imports:   c.imports,
+	}
+	idx.walk(root, "")
+	return root, idx, c.ds
+}
+
+// parseDocuments splits a YAML stream into its document nodes.
+func parseDocuments(data []byte) ([]*yaml.Node, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, err
+		}
+		docs = append(docs, &doc)
+	}
+}
+
+// decodeDocumentStrict decodes document index of data into target with unknown fields rejected,
+// so errors keep the line numbers of the original file.
+func decodeDocumentStrict(data []byte, index int, target interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	for i := 0; i < index; i++ {
+		var skip yaml.Node
+		if err := dec.Decode(&skip); err != nil {
+			return err
+		}
+	}
+	return dec.Decode(target)
+}
+
+func (c *specComposer) compose(path string, data []byte, index int, doc *yaml.Node, imported bool) *yaml.Node {
+	// Upgrade older apiVersions first so fields renamed or removed since are not reported as unknown.
+	from, notes, err := MigrateDocument(doc)
+	if err != nil {
+		c.errorAt(path, versionNodeOf(doc), "%v", err)
+		return nil
+	}
+	migrated := len(notes) > 0
+	if migrated {
+		c.warnAt(path, versionNodeOf(doc), "apiVersion %q is deprecated; loaded as %q (run: pltf migrate -f %s)", from, CurrentAPIVersion, path)
+	}
+
+	// Check each document on its own so unknown fields are reported against the file that has them.
+	strictErr := error(nil)
+	if migrated {
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			c.errorAt(path, doc, "failed to re-encode migrated spec: %v", err)
+			return nil
+		}
+		strictErr = decodeDocumentStrict(out, 0, c.newTarget())
+	} else {
+		strictErr = decodeDocumentStrict(data, index, c.newTarget())
+	}
+	if strictErr != nil && !errors.Is(strictErr, io.EOF) {
+		c.ds = append(c.ds, yamlErrorDiagnostics(path, fmt.Errorf("failed to parse yaml %s: %w", path, strictErr))...)
+		return nil
+	}
+
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		c.errorAt(path, root, "%s must be a YAML mapping", path)
+		return nil
+	}
+	c.record(root, path)
+
+	if kindNode := mappingValue(root, "kind"); imported && kindNode != nil && kindNode.Value != c.kind {
+		c.errorAt(path, kindNode, "imported file %s is kind %q, expected %q", path, kindNode.Value, c.kind)
+		return nil
+	}
+
+	refs, ok := c.takeRefs(path, root)
+	if !ok {
+		return nil
+	}
+	if !imported {
+		for _, ref := range refs {
+			if ref.field == "extends" {
+				c.extends = ref.value
+			} else {
+				c.imports = append(c.imports, ref.value)
+			}
+		}
+	}
+
+	var base *yaml.Node
+	for _, ref := range refs {
+		parent := c.load(path, ref)
+		if parent == nil {
+			return nil
+		}
+		base = c.merge(base, parent)
+	}
+	return c.merge(base, root)
+}
+
+type specRef struct {
+	value string
+	field string
+	node  *yaml.Node
+}
+
+// takeRefs removes extends/imports from root and returns them in merge order.
+func (c *specComposer) takeRefs(path string, root *yaml.Node) ([]specRef, bool) {
+	var refs []specRef
+	ok := true
+	kept := root.Content[:0:0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "extends":
+			if val.Kind != yaml.ScalarNode || strings.TrimSpace(val.Value) == "" {
+				c.errorAt(path, val, "extends must be a single file path")
+				ok = false
+				continue
+			}
+			// extends is the base of the chain, so it always merges before imports.
+			refs = append([]specRef{{value: val.Value, field: "extends", node: val}}, refs...)
+		case "imports":
+			if val.Kind != yaml.SequenceNode {
+				c.errorAt(path, val, "imports must be a list of file paths")
+				ok = false
+				continue
+			}
+			for j, item := range val.Content {
+				if item.Kind != yaml.ScalarNode || strings.TrimSpace(item.Value) == "" {
+					c.errorAt(path, item, "imports[%d] must be a file path", j)
+					ok = false
+					continue
+				}
+				refs = append(refs, specRef{value: item.Value, field: fmt.Sprintf("imports[%d]", j), node: item})
+			}
+		default:
+			kept = append(kept, key, val)
+		}
+	}
+	root.Content = kept
+	return refs, ok
+}
+
+func (c *specComposer) load(from string, ref specRef) *yaml.Node {
+	target, err := resolveImport(filepath.Dir(from), ref.value)
+	if err != nil {
+		c.errorAt(from, ref.node, "%s: %v", ref.field, err)
+		return nil
+	}
+	abs := absPath(target)
+	for i, p := range c.stack {
+		if p == abs {
+			chain := append(append([]string{}, c.stack[i:]...), abs)
+			c.errorAt(from, ref.node, "import cycle: %s", strings.Join(chain, " -> "))
+			return nil
+		}
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		c.errorAt(from, ref.node, "%s: failed to read %s: %v", ref.field, target, err)
+		return nil
+	}
+	docs, err := parseDocuments(data)
+	if err != nil {
+		c.ds = append(c.ds, yamlErrorDiagnostics(target, err)...)
+		return nil
+	}
+	if len(docs) != 1 {
+		c.errorAt(from, ref.node, "%s: %s must contain exactly one YAML document, found %d", ref.field, target, len(docs))
+		return nil
+	}
+	c.stack = append(c.stack, abs)
+	defer func() { c.stack = c.stack[:len(c.stack)-1] }()
+	return c.compose(target, data, 0, docs[0], true)
+}
+
+// resolveImport maps an import reference to a file. Relative paths resolve against the importing
+// file's directory; "modules://<path>" searches ImportRoots and PLTF_IMPORT_PATH in order.
+func resolveImport(dir, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if !strings.HasPrefix(ref, moduleRootImportPrefix) {
+		if filepath.IsAbs(ref) {
+			return ref, nil
+		}
+		return filepath.Join(dir, ref), nil
+	}
+	rel := filepath.FromSlash(strings.TrimPrefix(ref, moduleRootImportPrefix))
+	roots := append([]string{}, ImportRoots...)
+	if env := strings.TrimSpace(os.Getenv("PLTF_IMPORT_PATH")); env != "" {
+		roots = append(roots, filepath.SplitList(env)...)
+	}
+	if len(roots) == 0 {
+		return "", fmt.Errorf("%s needs a modules root (set modules_root in the profile or PLTF_IMPORT_PATH)", ref)
+	}
+	for _, root := range roots {
+		candidate := filepath.Join(root, rel)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in %s", ref, strings.Join(roots, ", "))
+}
+
+// merge overlays over onto base and returns the result. Nodes taken from over keep their file.
+func (c *specComposer) merge(base, over *yaml.Node) *yaml.Node {
+	if base == nil {
+		return over
+	}
+	switch {
+	case base.Kind == yaml.MappingNode && over.Kind == yaml.MappingNode:
+		out := c.clone(over)
+		out.Content = append([]*yaml.Node{}, base.Content...)
+		fromBase := make([]bool, len(out.Content)/2)
+		for i := range fromBase {
+			fromBase[i] = true
+		}
+		for i := 0; i+1 < len(over.Content); i += 2 {
+			key, val := over.Content[i], over.Content[i+1]
+			replaced := false
+			for j := range fromBase {
+				if fromBase[j] && out.Content[2*j].Value == key.Value {
+					out.Content[2*j] = key
+					out.Content[2*j+1] = c.merge(out.Content[2*j+1], val)
+					fromBase[j] = false
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				out.Content = append(out.Content, key, val)
+			}
+		}
+		return out
+	case base.Kind == yaml.SequenceNode && over.Kind == yaml.SequenceNode && keyedByID(base) && keyedByID(over):
+		out := c.clone(over)
+		out.Content = append([]*yaml.Node{}, base.Content...)
+		for _, item := range over.Content {
+			id := mappingValue(item, "id").Value
+			replaced := false
+			for j, existing := range out.Content {
+				if mappingValue(existing, "id").Value == id {
+					out.Content[j] = c.merge(existing, item)
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				out.Content = append(out.Content, item)
+			}
+		}
+		return out
+	}
+	return over
+}
+
+func (c *specComposer) clone(n *yaml.Node) *yaml.Node {
+	cp := *n
+	c.files[&cp] = c.files[n]
+	return &cp
+}
+
+func (c *specComposer) record(n *yaml.Node, file string) {
+	c.files[n] = file
+	for _, child := range n.Content {
+		c.record(child, file)
+	}
+}
+
+func (c *specComposer) errorAt(file string, n *yaml.Node, format string, args ...interface{}) {
+	c.ds = append(c.ds, Diagnostic{
+		Severity: SeverityError,
+		File:     file,
+		Line:     n.Line,
+		Column:   n.Column,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *specComposer) warnAt(file string, n *yaml.Node, format string, args ...interface{}) {
+	c.ds = append(c.ds, Diagnostic{
+		Severity: SeverityWarning,
+		File:     file,
+		Line:     n.Line,
+		Column:   n.Column,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// versionNodeOf returns the apiVersion value node of a document, or the document itself.
+func versionNodeOf(doc *yaml.Node) *yaml.Node {
+	if len(doc.Content) > 0 {
+		if n := mappingValue(doc.Content[0], "apiVersion"); n != nil {
+			return n
+		}
+	}
+	return doc
+}
+
+func (c *specComposer) newTarget() interface{} {
+	if c.kind == "Service" {
+		return &ServiceConfig{}
+	}
+	return &EnvironmentConfig{}
+}
+
+// keyedByID reports whether every item of a sequence is a mapping with a scalar id.
+func keyedByID(n *yaml.Node) bool {
+	for _, item := range n.Content {
+		id := mappingValue(item, "id")
+		if id == nil || id.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSpec(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLoadEnvironmentConfigComposesExtendsAndImports(t *testing.T) {
+	dir := t.TempDir()
+	roots := filepath.Join(dir, "catalog")
+	ImportRoots = []string{roots}
+	t.Cleanup(func() { ImportRoots = nil })
+
+	writeSpec(t, filepath.Join(dir, "base.yaml"), `apiVersion: platform.io/v1
+kind: Environment
+metadata:
+  name: base
+  org: acme
+  provider: aws
+  labels:
+    team: platform
+environments:
+  dev:
+    account: "111111111111"
+    region: us-east-1
+modules:
+  - id: base
+    type: aws_base
+  - id: dns
+    type: aws_dns
+    inputs:
+      domain: example.com
+`)
+	writeSpec(t, filepath.Join(roots, "fragments", "eks.yaml"), `modules:
+  - id: eks
+    type: aws_eks
+    inputs:
+      max_nodes: 5
+`)
+	prod := filepath.Join(dir, "prod.yaml")
+	writeSpec(t, prod, `extends: base.yaml
+imports:
+  - modules://fragments/eks.yaml
+metadata:
+  name: prod
+environments:
+  prod:
+    account: "222222222222"
+    region: us-west-2
+modules:
+  - id: eks
+    inputs:
+      max_nodes: 20
+`)
+
+	cfg, err := LoadEnvironmentConfig(prod)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Metadata.Name != "prod" || cfg.Metadata.Org != "acme" || cfg.Kind != "Environment" {
+		t.Fatalf("metadata not merged: %+v", cfg.Metadata)
+	}
+	if len(cfg.Environments) != 2 {
+		t.Fatalf("expected dev and prod environments, got %v", cfg.Environments)
+	}
+	var ids []string
+	for _, m := range cfg.Modules {
+		ids = append(ids, m.ID)
+	}
+	if strings.Join(ids, ",") != "base,dns,eks" {
+		t.Fatalf("unexpected module order %v", ids)
+	}
+	eks := cfg.Modules[2]
+	if eks.Type != "aws_eks" || eks.Inputs["max_nodes"] != 20 {
+		t.Fatalf("eks module not merged by id: %+v", eks)
+	}
+	if cfg.Extends != "base.yaml" || len(cfg.Imports) != 1 {
+		t.Fatalf("expected top-level refs to be kept, got %q %v", cfg.Extends, cfg.Imports)
+	}
+
+	origins := map[string]string{}
+	for _, v := range cfg.Sources.Values() {
+		origins[v.Path] = filepath.Base(v.File)
+	}
+	for path, want := range map[string]string{
+		"metadata.name":               "prod.yaml",
+		"metadata.org":                "base.yaml",
+		"modules[1].inputs.domain":    "base.yaml",
+		"modules[2].type":             "eks.yaml",
+		"modules[2].inputs.max_nodes": "prod.yaml",
+		"environments.prod.account":   "prod.yaml",
+		"environments.dev.region":     "base.yaml",
+	} {
+		if origins[path] != want {
+			t.Fatalf("%s came from %q, want %q", path, origins[path], want)
+		}
+	}
+}
+
+func TestLoadEnvironmentConfigImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	writeSpec(t, a, "apiVersion: platform.io/v1\nkind: Environment\nimports:\n  - b.yaml\n")
+	writeSpec(t, filepath.Join(dir, "b.yaml"), "imports:\n  - a.yaml\n")
+
+	_, ds, err := LoadEnvironmentConfigDiagnostics(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ds) != 1 || !strings.Contains(ds[0].Message, "import cycle") || filepath.Base(ds[0].File) != "b.yaml" || ds[0].Line != 2 {
+		t.Fatalf("expected cycle diagnostic in b.yaml, got %+v", ds)
+	}
+
+	writeSpec(t, filepath.Join(dir, "b.yaml"), "kind: Service\n")
+	if _, err := LoadEnvironmentConfig(a); err == nil || !strings.Contains(err.Error(), `is kind "Service"`) {
+		t.Fatalf("expected kind mismatch error, got %v", err)
+	}
+
+	writeSpec(t, filepath.Join(dir, "b.yaml"), "metadata:\n  nmae: typo\n")
+	_, ds, _ = LoadEnvironmentConfigDiagnostics(a)
+	if len(ds) != 1 || filepath.Base(ds[0].File) != "b.yaml" || ds[0].Line != 2 {
+		t.Fatalf("expected unknown field reported in b.yaml, got %+v", ds)
+	}
+}
@@ -0,0 +1,422 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity classifies a diagnostic.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a single validation finding tied to a spec location.
+// Path uses dotted keys and [index] for sequences, e.g. modules[3].inputs.bucket_name.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	File     string   `json:"file,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	loc := d.File
+	if d.Line > 0 {
+		loc = fmt.Sprintf("%s:%d:%d", loc, d.Line, d.Column)
+	}
+	if loc != "" {
+		return fmt.Sprintf("%s: %s", loc, d.Message)
+	}
+	return d.Message
+}
+
+// Diagnostics is an ordered collection of findings.
+type Diagnostics []Diagnostic
+
+// Errorf records an error at path.
+func (ds *Diagnostics) Errorf(path, format string, args ...interface{}) {
+	*ds = append(*ds, Diagnostic{Severity: SeverityError, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Warnf records a warning at path.
+func (ds *Diagnostics) Warnf(path, format string, args ...interface{}) {
+	*ds = append(*ds, Diagnostic{Severity: SeverityWarning, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// HasErrors reports whether any diagnostic is an error.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns only the error diagnostics.
+func (ds Diagnostics) Errors() Diagnostics {
+	var out Diagnostics
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Err returns a *DiagnosticsError when ds contains errors, or nil otherwise.
+func (ds Diagnostics) Err() error {
+	if !ds.HasErrors() {
+		return nil
+	}
+	return &DiagnosticsError{Diagnostics: ds}
+}
+
+// Sort orders diagnostics by file, line, column and path so output is stable.
+func (ds Diagnostics) Sort() {
+	sort.SliceStable(ds, func(i, j int) bool {
+		a, b := ds[i], ds[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Path < b.Path
+	})
+}
+
+// DiagnosticsError carries every diagnostic from a failed validation. Warnings are kept so
+// callers can render them, but only errors appear in Error().
+type DiagnosticsError struct {
+	Diagnostics Diagnostics
+}
+
+func (e *DiagnosticsError) Error() string {
+	errs := e.Diagnostics.Errors()
+	lines := make([]string, 0, len(errs))
+	for _, d := range errs {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Position is a 1-based line/column location in a YAML source. File is set when the value
+// came from a file other than the one the index was built for (extends/imports).
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// SourceIndex maps diagnostic paths to positions in a parsed YAML document.
+type SourceIndex struct {
+	File      string
+	positions map[string]Position
+	values    []string
+	files     map[*yaml.Node]string
+	// extends/imports declared by File itself; they are stripped from the composed tree.
+	extends string
+	imports []string
+}
+
+// NewSourceIndex parses data and records the position of every mapping key and sequence item.
+// Parse failures yield an empty index so callers can still report diagnostics without positions.
+func NewSourceIndex(file string, data []byte) *SourceIndex {
+	idx := &SourceIndex{File: file, positions: map[string]Position{}}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return idx
+	}
+	idx.walk(&root, "")
+	return idx
+}
+
+func (idx *SourceIndex) walk(n *yaml.Node, path string) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		idx.positions[path] = idx.position(n)
+		for _, c := range n.Content {
+			idx.walk(c, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			p := joinInputPath(path, key.Value)
+			// First occurrence wins so duplicate keys point at their original definition.
+			if _, exists := idx.positions[p]; !exists {
+				idx.positions[p] = idx.position(key)
+				if val.Kind == yaml.ScalarNode || len(val.Content) == 0 {
+					idx.values = append(idx.values, p)
+				}
+			}
+			idx.walk(val, p)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			idx.positions[p] = idx.position(c)
+			if c.Kind == yaml.ScalarNode {
+				idx.values = append(idx.values, p)
+			}
+			idx.walk(c, p)
+		}
+	}
+}
+
+func (idx *SourceIndex) position(n *yaml.Node) Position {
+	file := idx.File
+	if f, ok := idx.files[n]; ok {
+		file = f
+	}
+	return Position{File: file, Line: n.Line, Column: n.Column}
+}
+
+// ValueSource records which file supplied a final value in a composed spec.
+type ValueSource struct {
+	Path string
+	Position
+}
+
+// Values lists every leaf value of the spec with the file and line it came from, in document order.
+func (idx *SourceIndex) Values() []ValueSource {
+	if idx == nil {
+		return nil
+	}
+	out := make([]ValueSource, 0, len(idx.values))
+	for _, p := range idx.values {
+		out = append(out, ValueSource{Path: p, Position: idx.positions[p]})
+	}
+	return out
+}
+
+// Lookup returns the position of path, falling back to the closest ancestor that exists.
+func (idx *SourceIndex) Lookup(path string) (Position, bool) {
+	if idx == nil {
+		return Position{}, false
+	}
+	for p := path; ; p = parentPath(p) {
+		if pos, ok := idx.positions[p]; ok && p != "" {
+			return pos, true
+		}
+		if p == "" {
+			return Position{}, false
+		}
+	}
+}
+
+func parentPath(p string) string {
+	if strings.HasSuffix(p, "]") {
+		if i := strings.LastIndex(p, "["); i >= 0 {
+			return p[:i]
+		}
+	}
+	if i := strings.LastIndex(p, "."); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// Locate fills in File, Line and Column for diagnostics that do not have them yet. For composed
+// specs the file is the one that supplied the value. Diagnostics that already belong to another
+// file are left untouched.
+func (ds Diagnostics) Locate(idx *SourceIndex) Diagnostics {
+	out := make(Diagnostics, len(ds))
+	for i, d := range ds {
+		if d.Line == 0 && (d.File == "" || d.File == idx.File) {
+			if pos, ok := idx.Lookup(d.Path); ok {
+				d.File, d.Line, d.Column = pos.File, pos.Line, pos.Column
+			}
+		}
+		if d.File == "" {
+			d.File = idx.File
+		}
+		out[i] = d
+	}
+	return out
+}
+
+// MapPaths returns a copy of ds with every path rewritten by f, for diagnostics computed over a
+// derived module list whose paths must point back at the spec.
+func (ds Diagnostics) MapPaths(f func(string) string) Diagnostics {
+	out := make(Diagnostics, len(ds))
+	for i, d := range ds {
+		d.Path = f(d.Path)
+		out[i] = d
+	}
+	return out
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+): (.*)`)
+
+// yamlErrorDiagnostics converts yaml.v3 parse/decode errors into diagnostics with line numbers.
+func yamlErrorDiagnostics(file string, err error) Diagnostics {
+	var msgs []string
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		msgs = te.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+	var ds Diagnostics
+	for _, m := range msgs {
+		d := Diagnostic{Severity: SeverityError, File: file, Message: m}
+		if sm := yamlLinePattern.FindStringSubmatch(m); sm != nil {
+			d.Line, _ = strconv.Atoi(sm[1])
+			d.Column = 1
+			d.Message = sm[2]
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// RenderDiagnosticsText writes human-readable diagnostics with a source excerpt for each location.
+func RenderDiagnosticsText(w io.Writer, ds Diagnostics) {
+	sources := map[string][]string{}
+	for _, d := range ds {
+		fmt.Fprintf(w, "%s: %s\n", d.Severity, d.Message)
+		if d.File == "" {
+			continue
+		}
+		loc := d.File
+		if d.Line > 0 {
+			loc = fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.Column)
+		}
+		if d.Path != "" {
+			loc += " (" + d.Path + ")"
+		}
+		fmt.Fprintf(w, "  --> %s\n", loc)
+		if d.Line <= 0 {
+			continue
+		}
+		lines, ok := sources[d.File]
+		if !ok {
+			if data, err := os.ReadFile(d.File); err == nil {
+				lines = strings.Split(string(data), "\n")
+			}
+			sources[d.File] = lines
+		}
+		if d.Line > len(lines) {
+			continue
+		}
+		gutter := len(strconv.Itoa(d.Line))
+		fmt.Fprintf(w, "  %*s |\n", gutter, "")
+		fmt.Fprintf(w, "  %d | %s\n", d.Line, lines[d.Line-1])
+		col := d.Column
+		if col < 1 {
+			col = 1
+		}
+		fmt.Fprintf(w, "  %*s | %s^\n", gutter, "", strings.Repeat(" ", col-1))
+	}
+	errs := len(ds.Errors())
+	fmt.Fprintf(w, "%d error(s), %d warning(s)\n", errs, len(ds)-errs)
+}
+
+// RenderDiagnosticsJSON writes diagnostics as a JSON array.
+func RenderDiagnosticsJSON(w io.Writer, ds Diagnostics) error {
+	if ds == nil {
+		ds = Diagnostics{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ds)
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name           string `json:"name"`
+		InformationURI string `json:"informationUri"`
+	} `json:"driver"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// RenderDiagnosticsSARIF writes diagnostics as a SARIF 2.1.0 log for CI annotators.
+func RenderDiagnosticsSARIF(w io.Writer, ds Diagnostics) error {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "pltf"
+	run.Tool.Driver.InformationURI = "https://github.com/yindia/pltf"
+	for _, d := range ds {
+		res := sarifResult{
+			RuleID:  "pltf/validate",
+			Level:   string(d.Severity),
+			Message: sarifMessage{Text: d.Message},
+		}
+		if d.Path != "" {
+			res.Message.Text = d.Path + ": " + d.Message
+		}
+		if d.File != "" {
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = strings.ReplaceAll(d.File, "\\", "/")
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+			res.Locations = append(res.Locations, loc)
+		}
+		run.Results = append(run.Results, res)
+	}
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
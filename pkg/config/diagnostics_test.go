@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadEnvironmentConfigDiagnosticsCollectsPositions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "env.yaml")
+	spec := `apiVersion: platform.io/v1
+kind: Environment
+metadata:
+  name: demo
+  provider: aws
+environments:
+  dev:
+    region: us-east-1
+modules:
+  - id: base
+    type: aws_base
+  - id: base
+    type: aws_dns
+    links:
+      read: missing
+`
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatalf("write spec: %v", err)
+	}
+
+	_, ds, err := LoadEnvironmentConfigDiagnostics(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]int{
+		"metadata.org":             3,
+		"environments.dev.account": 7,
+		"modules[1].id":            12,
+		"modules[1].links.read":    15,
+	}
+	for _, d := range ds.Errors() {
+		line, ok := want[d.Path]
+		if !ok {
+			t.Fatalf("unexpected diagnostic %+v", d)
+		}
+		if d.Line != line || d.File != path {
+			t.Fatalf("diagnostic %s at %s:%d, want line %d", d.Path, d.File, d.Line, line)
+		}
+		delete(want, d.Path)
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing diagnostics for %v", want)
+	}
+
+	for _, d := range ds {
+		if d.Severity == SeverityWarning {
+			t.Fatalf("unexpected warning %+v", d)
+		}
+	}
+
+	if _, err := LoadEnvironmentConfig(path); err == nil || !strings.Contains(err.Error(), path+":3:1: metadata.org is required") {
+		t.Fatalf("expected positioned error from LoadEnvironmentConfig, got %v", err)
+	}
+}
+
+func TestDiagnosticsStrictDecodeAndRendering(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "env.yaml")
+	spec := "apiVersion: platform.io/v1\nkind: Environment\nmetadata:\n  name: demo\n  nmae: typo\n"
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatalf("write spec: %v", err)
+	}
+
+	_, ds, err := LoadEnvironmentConfigDiagnostics(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ds) != 1 || ds[0].Line != 5 || !strings.Contains(ds[0].Message, "field nmae not found") {
+		t.Fatalf("expected unknown field diagnostic on line 5, got %+v", ds)
+	}
+
+	var text bytes.Buffer
+	RenderDiagnosticsText(&text, ds)
+	if !strings.Contains(text.String(), "5 |   nmae: typo") {
+		t.Fatalf("expected source excerpt, got:\n%s", text.String())
+	}
+
+	var sarif bytes.Buffer
+	if err := RenderDiagnosticsSARIF(&sarif, ds); err != nil {
+		t.Fatalf("render sarif: %v", err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+		t.Fatalf("invalid sarif json: %v", err)
+	}
+	res := log.Runs[0].Results[0]
+	if res.Level != "error" || res.Locations[0].PhysicalLocation.Region.StartLine != 5 {
+		t.Fatalf("unexpected sarif result: %+v", res)
+	}
+}
@@ -9,6 +9,18 @@ type EnvironmentConfig struct {
 	Backend      Backend                     `yaml:"backend"`
 	Environments map[string]EnvironmentEntry `yaml:"environments"` // dev, prod, ...
 	Modules      []Module                    `yaml:"modules"`
+	// Variables declares types and constraints for variables set in environments.<env>.variables.
+	Variables map[string]VariableSpec `yaml:"variables,omitempty"`
+	// DefaultProviders names the module that auto-wires an output when several modules provide it.
+	DefaultProviders map[string]string `yaml:"defaultProviders,omitempty"`
+	// Render selects rendering options, such as variables as tfvars instead of locals.
+	Render Render `yaml:"render,omitempty"`
+
+	// Extends names a base spec and Imports lists fragments merged before this file.
+	Extends string   `yaml:"extends,omitempty"`
+	Imports []string `yaml:"imports,omitempty"`
+	// Sources records which file supplied each value after extends/imports are resolved.
+	Sources *SourceIndex `yaml:"-"`
 }
 
 type EnvironmentMetadata struct {
@@ -16,12 +28,20 @@ type EnvironmentMetadata struct {
 	Org      string                 `yaml:"org"`
 	Provider string                 `yaml:"provider"` // "aws", etc.
 	Labels   map[string]string      `yaml:"labels"`
-	// Approve    []ApprovalRequirement `yaml:"approve,omitempty"`
+	Approve  []ApprovalRequirement  `yaml:"approve,omitempty"`
 }
 
 type EnvironmentEntry struct {
-	Account   string               `yaml:"account"`             // "111111111111"
-	Region    string               `yaml:"region"`              // provider region per environment
-	Variables map[string]string    `yaml:"variables,omitempty"` // cluster_name, base_domain, ...
-	Secrets   map[string]SecretRef `yaml:"secrets,omitempty"`
+	Account   string                    `yaml:"account"`             // "111111111111"
+	Region    string                    `yaml:"region"`              // provider region per environment
+	Variables VariableValues            `yaml:"variables,omitempty"` // cluster_name, base_domain, ...
+	Secrets   map[string]SecretRef      `yaml:"secrets,omitempty"`
+	Modules   map[string]ModuleOverride `yaml:"modules,omitempty"` // per-environment overrides keyed by module id
+}
+
+// ModuleOverride enables/disables a shared module or replaces some of its inputs in one environment.
+type ModuleOverride struct {
+	Enabled *bool                  `yaml:"enabled,omitempty"` // nil keeps the module enabled
+	Inputs  map[string]interface{} `yaml:"inputs,omitempty"`  // replaces the module's inputs key by key
+	Protect *Protection            `yaml:"protect,omitempty"` // replaces the module's protect
 }
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// templateBracesPattern matches the ${{ expr }} spelling, which is equivalent to ${ expr }.
+var templateBracesPattern = regexp.MustCompile(`\$\{\{([^}]+)\}\}`)
+
+// expressionRoots are the names an input expression may start a reference with. var.X reads a
+// variable, parent.X an output of the parent Environment, module.X.Y a module output, and
+// each/count the current instance of a module with forEach or count.
+var expressionRoots = map[string]struct{}{
+	"var":    {},
+	"parent": {},
+	"module": {},
+	"each":   {},
+	"count":  {},
+}
+
+// placeholderNames are the intrinsic ${name} placeholders replaced with plain values before an
+// input is rendered.
+var placeholderNames = map[string]struct{}{
+	"env_name":    {},
+	"layer_name":  {},
+	"parent_name": {},
+	"account_id":  {},
+	"project_id":  {},
+	"region":      {},
+}
+
+// IsPlaceholder reports whether name is an intrinsic placeholder such as env_name or region.
+func IsPlaceholder(name string) bool {
+	_, ok := placeholderNames[name]
+	return ok
+}
+
+// ParseInputTemplate parses an input string holding ${...} interpolations as an HCL template.
+// ${{ expr }} is accepted as a spelling of ${ expr }. The returned source is what the expression's
+// ranges point into.
+func ParseInputTemplate(s string) (hclsyntax.Expression, []byte, error) {
+	src := []byte(templateBracesPattern.ReplaceAllString(s, `${$1}`))
+	expr, diags := hclsyntax.ParseTemplate(src, "input", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, nil, fmt.Errorf("invalid expression %q: %s", s, diags[0].Summary)
+	}
+	return expr, src, nil
+}
+
+// InputTraversals returns the references an expression makes, in source order, leaving out the
+// symbols that for expressions declare.
+func InputTraversals(expr hclsyntax.Expression) []*hclsyntax.ScopeTraversalExpr {
+	w := &traversalWalker{}
+	hclsyntax.Walk(expr, w)
+	sort.SliceStable(w.found, func(i, j int) bool {
+		return w.found[i].SrcRange.Start.Byte < w.found[j].SrcRange.Start.Byte
+	})
+	return w.found
+}
+
+type traversalWalker struct {
+	scopes []map[string]struct{}
+	found  []*hclsyntax.ScopeTraversalExpr
+}
+
+func (w *traversalWalker) Enter(node hclsyntax.Node) hcl.Diagnostics {
+	switch n := node.(type) {
+	case *hclsyntax.ForExpr:
+		scope := map[string]struct{}{n.ValVar: {}}
+		if n.KeyVar != "" {
+			scope[n.KeyVar] = struct{}{}
+		}
+		w.scopes = append(w.scopes, scope)
+	case *hclsyntax.ScopeTraversalExpr:
+		root := n.Traversal.RootName()
+		for _, scope := range w.scopes {
+			if _, ok := scope[root]; ok {
+				return nil
+			}
+		}
+		w.found = append(w.found, n)
+	}
+	return nil
+}
+
+func (w *traversalWalker) Exit(node hclsyntax.Node) hcl.Diagnostics {
+	if _, ok := node.(*hclsyntax.ForExpr); ok {
+		w.scopes = w.scopes[:len(w.scopes)-1]
+	}
+	return nil
+}
+
+// checkInputExpression parses one input string and checks the roots of its references. fanOut
+// names what the module fans out with ("forEach", "count" or "") to allow each/count.
+func checkInputExpression(s, fanOut string) error {
+	if !strings.Contains(s, "${") {
+		return nil
+	}
+	expr, _, err := ParseInputTemplate(s)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(s, "%{") {
+		return fmt.Errorf("template directives (%%{...}) are not supported in %q; use a conditional or for expression inside ${...}", s)
+	}
+	for _, t := range InputTraversals(expr) {
+		root := t.Traversal.RootName()
+		if len(t.Traversal) == 1 && IsPlaceholder(root) {
+			continue
+		}
+		if _, ok := expressionRoots[root]; !ok {
+			return fmt.Errorf("unknown reference %q in %q; references start with var., parent., module., each. or count.", root, s)
+		}
+		if len(t.Traversal) < 2 {
+			return fmt.Errorf("reference %q in %q needs an attribute, such as %s.<name>", root, s, root)
+		}
+		switch {
+		case root == "each" && fanOut == "":
+			return fmt.Errorf("each. in %q is only available in modules with forEach or count", s)
+		case root == "count" && fanOut != "count":
+			return fmt.Errorf("count. in %q is only available in modules with count", s)
+		}
+	}
+	return nil
+}
+
+// diagnoseExpressions checks the ${...} expressions in module inputs.
+func diagnoseExpressions(mods []Module, context string, ds *Diagnostics) {
+	for i, m := range mods {
+		fanOut := ""
+		switch {
+		case m.Count != nil:
+			fanOut = "count"
+		case m.ForEach != nil:
+			fanOut = "forEach"
+		}
+		for _, key := range sortedMapKeys(m.Inputs) {
+			walkInputStrings(m.Inputs[key], func(s string) {
+				if err := checkInputExpression(s, fanOut); err != nil {
+					ds.Errorf(fmt.Sprintf("modules[%d].inputs.%s", i, key), "module %q input %q: %v%s", m.ID, key, err, contextSuffix(context))
+				}
+			})
+		}
+	}
+}
+
+// walkInputStrings calls fn for every string in an input value.
+func walkInputStrings(v interface{}, fn func(string)) {
+	switch val := v.(type) {
+	case string:
+		fn(val)
+	case []interface{}:
+		for _, item := range val {
+			walkInputStrings(item, fn)
+		}
+	case []string:
+		for _, item := range val {
+			fn(item)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedMapKeys(val) {
+			walkInputStrings(val[k], fn)
+		}
+	}
+}
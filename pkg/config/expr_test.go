@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckInputExpression(t *testing.T) {
+	ok := map[string]string{
+		`plain text`:                              "",
+		`${join(",", var.subnets)}`:               "",
+		`${var.env == "prod" ? 3 : 1}`:            "",
+		`${{ parent.domain }}`:                    "",
+		`${[for s in var.subnets : upper(s)]}`:    "",
+		`${ { for k, v in var.tags : k => v } }`:  "",
+		`${module.queues["orders"].queue_arn}-x`:  "",
+		`${env_name}-${layer_name}`:               "",
+		`${each.key}`:                             "forEach",
+		`${each.value}-${count.index}`:            "count",
+		`literal $${not_a_reference} is escaped`:  "",
+		`${length(var.zones) > 1 ? "ha" : "one"}`: "",
+	}
+	for s, fanOut := range ok {
+		if err := checkInputExpression(s, fanOut); err != nil {
+			t.Fatalf("checkInputExpression(%q) unexpected error: %v", s, err)
+		}
+	}
+
+	bad := map[string]string{
+		`${local.x}`:                      `unknown reference "local"`,
+		`${terraform.workspace}`:          `unknown reference "terraform"`,
+		`${upper(name)}`:                  `unknown reference "name"`,
+		`${var}`:                          `needs an attribute`,
+		`${var.x ==}`:                     `invalid expression`,
+		`${each.key}`:                     `only available in modules with forEach or count`,
+		`%{ if true }x%{ endif }${var.x}`: `template directives`,
+	}
+	for s, want := range bad {
+		err := checkInputExpression(s, "")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("checkInputExpression(%q) = %v, want error containing %q", s, err, want)
+		}
+	}
+	if err := checkInputExpression(`${count.index}`, "forEach"); err == nil {
+		t.Fatalf("count.index must be rejected in a forEach module")
+	}
+}
+
+func TestDiagnoseModulesRejectsUnknownExpressionRoots(t *testing.T) {
+	var ds Diagnostics
+	diagnoseModules([]Module{{
+		ID:   "app",
+		Type: "aws_lambda",
+		Inputs: map[string]interface{}{
+			"name": "${var.prefix}-app",
+			"env":  map[string]interface{}{"URL": "${foo.bar}"},
+		},
+	}}, "", &ds)
+	errs := ds.Errors()
+	if len(errs) != 1 || errs[0].Path != "modules[0].inputs.env" || !strings.Contains(errs[0].Message, `unknown reference "foo"`) {
+		t.Fatalf("expected one unknown reference error on inputs.env, got %v", ds)
+	}
+}
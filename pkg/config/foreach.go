@@ -0,0 +1,287 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModuleInstance is one instance of a module fanned out with forEach or count.
+type ModuleInstance struct {
+	// Key identifies the instance: the list item, the map key, or the index for count.
+	Key string
+	// Value is each.value: the list item, the map value, or the index for count.
+	Value interface{}
+}
+
+var (
+	instanceKeyCleaner = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+	// eachRefPattern matches each.key, each.value and each.value.<field>... (and count.index) inside
+	// an interpolation.
+	eachRefPattern = regexp.MustCompile(`\$\{\s*(each\.key|each\.value((?:\.[A-Za-z0-9_]+)*)|count\.index)\s*\}`)
+)
+
+// FanOut reports whether the module declares forEach or count.
+func (m Module) FanOut() bool {
+	return m.ForEach != nil || m.Count != nil
+}
+
+// Instances returns the module's instances in order: list order for a forEach list, sorted keys
+// for a forEach map, 0..count-1 for count. Plain modules have no instances.
+func (m Module) Instances() ([]ModuleInstance, error) {
+	if m.ForEach != nil && m.Count != nil {
+		return nil, fmt.Errorf("module %q sets both forEach and count", m.ID)
+	}
+	if m.Count != nil {
+		if *m.Count < 0 {
+			return nil, fmt.Errorf("module %q count must not be negative, got %d", m.ID, *m.Count)
+		}
+		out := make([]ModuleInstance, 0, *m.Count)
+		for i := 0; i < *m.Count; i++ {
+			out = append(out, ModuleInstance{Key: strconv.Itoa(i), Value: i})
+		}
+		return out, nil
+	}
+
+	var out []ModuleInstance
+	switch v := normalizeYAMLValue(m.ForEach).(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		for _, item := range v {
+			switch item.(type) {
+			case string, int, int64, float64, bool:
+			default:
+				return nil, fmt.Errorf("module %q forEach list items must be scalars; use a map for structured values", m.ID)
+			}
+			out = append(out, ModuleInstance{Key: fmt.Sprint(item), Value: item})
+		}
+	case []string:
+		for _, item := range v {
+			out = append(out, ModuleInstance{Key: item, Value: item})
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, ModuleInstance{Key: k, Value: v[k]})
+		}
+	default:
+		return nil, fmt.Errorf("module %q forEach must be a list or a map", m.ID)
+	}
+
+	seen := map[string]struct{}{}
+	for _, inst := range out {
+		if strings.TrimSpace(inst.Key) == "" {
+			return nil, fmt.Errorf("module %q forEach has an empty key", m.ID)
+		}
+		if _, dup := seen[inst.Key]; dup {
+			return nil, fmt.Errorf("module %q forEach has duplicate key %q", m.ID, inst.Key)
+		}
+		seen[inst.Key] = struct{}{}
+	}
+	return out, nil
+}
+
+// InstanceID is the module id of an expanded instance: <id>_<key>, with characters Terraform does
+// not allow in module names replaced by "_".
+func (m Module) InstanceID(key string) string {
+	return m.ID + "_" + instanceKeyCleaner.ReplaceAllString(key, "_")
+}
+
+// InstanceRef is how links and references address an instance: <id>["key"] for forEach and
+// <id>[index] for count, matching Terraform's module instance addresses.
+func (m Module) InstanceRef(key string) string {
+	if m.Count != nil {
+		return m.ID + "[" + key + "]"
+	}
+	return m.ID + "[" + strconv.Quote(key) + "]"
+}
+
+// ExpandInstance returns a plain module for one instance: id <id>_<key>, each.key/each.value
+// (and count.index) substituted in inputs, and forEach/count/expand cleared.
+func (m Module) ExpandInstance(inst ModuleInstance) (Module, error) {
+	out := m
+	out.ID = m.InstanceID(inst.Key)
+	out.ForEach, out.Count, out.Expand = nil, nil, false
+	if m.Inputs != nil {
+		out.Inputs = make(map[string]interface{}, len(m.Inputs))
+		for k, v := range m.Inputs {
+			sub, err := substituteEach(v, inst)
+			if err != nil {
+				return Module{}, fmt.Errorf("module %q instance %q input %q: %w", m.ID, inst.Key, k, err)
+			}
+			out.Inputs[k] = sub
+		}
+	}
+	return out, nil
+}
+
+// ExpandModules replaces modules that fan out with expand: true by their instances and rewrites
+// links that target such a module to target every instance. Modules rendered with Terraform
+// for_each, and plain modules, are returned unchanged.
+func ExpandModules(mods []Module) ([]Module, error) {
+	groups := map[string][]string{}
+	var out []Module
+	for _, m := range mods {
+		if !m.FanOut() || !m.Expand {
+			out = append(out, m)
+			continue
+		}
+		insts, err := m.Instances()
+		if err != nil {
+			return nil, err
+		}
+		groups[m.ID] = []string{}
+		for _, inst := range insts {
+			im, err := m.ExpandInstance(inst)
+			if err != nil {
+				return nil, err
+			}
+			groups[m.ID] = append(groups[m.ID], im.ID)
+			out = append(out, im)
+		}
+	}
+	if len(groups) == 0 {
+		return out, nil
+	}
+	for i, m := range out {
+		if len(m.Links) == 0 {
+			continue
+		}
+		links := make(AccessLinks, len(m.Links))
+		for access, targets := range m.Links {
+			for _, t := range targets {
+				if ids, ok := groups[t]; ok {
+					links[access] = append(links[access], ids...)
+				} else {
+					links[access] = append(links[access], t)
+				}
+			}
+		}
+		out[i].Links = links
+	}
+	return out, nil
+}
+
+// substituteEach replaces each.key, each.value[.field...] and count.index. A string that is exactly
+// one reference takes the referenced value with its type; references inside a longer string are
+// formatted into it.
+func substituteEach(v interface{}, inst ModuleInstance) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		if sm := eachRefPattern.FindStringSubmatchIndex(val); sm != nil && sm[0] == 0 && sm[1] == len(val) {
+			return eachValue(val[sm[2]:sm[3]], inst)
+		}
+		var firstErr error
+		s := eachRefPattern.ReplaceAllStringFunc(val, func(match string) string {
+			ref := eachRefPattern.FindStringSubmatch(match)[1]
+			resolved, err := eachValue(ref, inst)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			return fmt.Sprint(resolved)
+		})
+		return s, firstErr
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			sub, err := substituteEach(item, inst)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = sub
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			sub, err := substituteEach(item, inst)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = sub
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func eachValue(ref string, inst ModuleInstance) (interface{}, error) {
+	switch {
+	case ref == "each.key":
+		return inst.Key, nil
+	case ref == "count.index":
+		return inst.Value, nil
+	}
+	value := normalizeYAMLValue(inst.Value)
+	path := strings.TrimPrefix(strings.TrimPrefix(ref, "each.value"), ".")
+	if path == "" {
+		return value, nil
+	}
+	for _, field := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("each.value has no field %q", field)
+		}
+		if value, ok = obj[field]; !ok {
+			return nil, fmt.Errorf("each.value has no field %q", field)
+		}
+	}
+	return value, nil
+}
+
+// diagnoseFanOut checks forEach/count and that links do not target a module rendered with
+// Terraform for_each (its instances share one module block, so they cannot get their own IAM).
+func diagnoseFanOut(mods []Module, context string, ds *Diagnostics) {
+	forEachGroups := map[string]struct{}{}
+	taken := map[string]struct{}{}
+	for _, m := range mods {
+		taken[m.ID] = struct{}{}
+	}
+	for i, m := range mods {
+		path := fmt.Sprintf("modules[%d]", i)
+		if !m.FanOut() {
+			if m.Expand {
+				ds.Errorf(path+".expand", "module %q sets expand without forEach or count%s", m.ID, contextSuffix(context))
+			}
+			continue
+		}
+		insts, err := m.Instances()
+		if err != nil {
+			ds.Errorf(path+".forEach", "%v%s", err, contextSuffix(context))
+			continue
+		}
+		if !m.Expand {
+			forEachGroups[m.ID] = struct{}{}
+			continue
+		}
+		for _, inst := range insts {
+			if _, err := m.ExpandInstance(inst); err != nil {
+				ds.Errorf(path+".inputs", "%v%s", err, contextSuffix(context))
+				break
+			}
+			id := m.InstanceID(inst.Key)
+			if _, clash := taken[id]; clash {
+				ds.Errorf(path+".forEach", "module %q instance %q expands to id %q, which is already in use%s", m.ID, inst.Key, id, contextSuffix(context))
+			}
+			taken[id] = struct{}{}
+		}
+	}
+	for i, m := range mods {
+		for _, access := range sortedMapKeys(m.Links) {
+			for _, t := range m.Links[access] {
+				if _, ok := forEachGroups[t]; ok {
+					ds.Errorf(fmt.Sprintf("modules[%d].links.%s", i, access),
+						"module %q links.%s targets %q, which fans out with Terraform for_each; set expand: true on %q to link to its instances%s",
+						m.ID, access, t, t, contextSuffix(context))
+				}
+			}
+		}
+	}
+}
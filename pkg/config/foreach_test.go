@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandModulesStampsOutInstancesAndLinks(t *testing.T) {
+	mods := []Module{
+		{ID: "role", Type: "aws_iam_role"},
+		{
+			ID:      "queues",
+			Type:    "aws_sqs",
+			ForEach: map[string]interface{}{"orders.v2": map[string]interface{}{"fifo": true}},
+			Inputs:  map[string]interface{}{"fifo": "${each.value.fifo}", "tag": "q-${each.key}"},
+			Expand:  true,
+		},
+		{ID: "api", Type: "aws_lambda", Links: AccessLinks{"write": {"queues"}}},
+	}
+	out, err := ExpandModules(mods)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q := out[1]
+	if q.ID != "queues_orders_v2" || q.FanOut() {
+		t.Fatalf("unexpected instance %+v", q)
+	}
+	if q.Inputs["fifo"] != true || q.Inputs["tag"] != "q-orders.v2" {
+		t.Fatalf("each.* should be substituted with types preserved, got %#v", q.Inputs)
+	}
+	if got := out[2].Links["write"]; !reflect.DeepEqual(got, []string{"queues_orders_v2"}) {
+		t.Fatalf("links to a group should target every instance, got %v", got)
+	}
+}
+
+func TestDiagnoseFanOut(t *testing.T) {
+	two := 2
+	var ds Diagnostics
+	diagnoseModules([]Module{
+		{ID: "role", Type: "aws_iam_role"},
+		{ID: "queues", Type: "aws_sqs", ForEach: []interface{}{"a", "a"}},
+		{ID: "topics", Type: "aws_sns", ForEach: []interface{}{"x"}, Count: &two},
+		{ID: "jobs", Type: "aws_sqs", ForEach: []interface{}{"x"}, Links: AccessLinks{"read": {"workers"}}},
+		{ID: "workers", Type: "aws_iam_role", Count: &two},
+		{ID: "plain", Type: "aws_s3", Expand: true},
+	}, "", &ds)
+	var msgs []string
+	for _, d := range ds.Errors() {
+		msgs = append(msgs, d.Message)
+	}
+	got := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		`duplicate key "a"`,
+		"sets both forEach and count",
+		`targets "workers", which fans out with Terraform for_each`,
+		"sets expand without forEach or count",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// InputProblem describes a single module input that violates its module.yaml contract.
+type InputProblem struct {
+	Index    int // position of the module in the list passed to ValidateModuleInputs
+	ModuleID string
+	Input    string
+	Message  string
+	// Wired marks problems with the module's wire: entry for Input rather than its inputs: value.
+	Wired bool
+}
+
+func (p InputProblem) String() string {
+	return fmt.Sprintf("module %q input %q: %s", p.ModuleID, p.Input, p.Message)
+}
+
+// Path returns the spec path of the offending input, e.g. modules[2].inputs.max_nodes.
+func (p InputProblem) Path() string {
+	if p.Wired {
+		return fmt.Sprintf("modules[%d].wire.%s", p.Index, p.Input)
+	}
+	return fmt.Sprintf("modules[%d].inputs.%s", p.Index, p.Input)
+}
+
+// InputValidationError aggregates every input contract violation found in a spec.
+type InputValidationError struct {
+	Context  string
+	Problems []InputProblem
+}
+
+func (e *InputValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		lines = append(lines, p.String())
+	}
+	return fmt.Sprintf("module inputs do not match module.yaml contracts%s:\n  - %s",
+		contextSuffix(e.Context), strings.Join(lines, "\n  - "))
+}
+
+// Diagnostics converts the problems into error diagnostics keyed by spec path.
+func (e *InputValidationError) Diagnostics() Diagnostics {
+	ds := make(Diagnostics, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		ds.Errorf(p.Path(), "%s", p.String())
+	}
+	return ds
+}
+
+// ValidateModuleInputs checks every module's YAML inputs against the module.yaml of its type.
+// Declared inputs must be compatible with the declared type, and undeclared inputs are rejected
+// with a "did you mean" suggestion. Modules whose metadata declares no inputs are treated as
+// open contracts. metas is keyed by module type; unknown types are skipped.
+func ValidateModuleInputs(mods []Module, metas map[string]*ModuleMetadata, context string) error {
+	var problems []InputProblem
+	for i, m := range mods {
+		meta, ok := metas[m.Type]
+		if !ok || meta == nil {
+			continue
+		}
+		for _, p := range checkModuleInputs(m, meta) {
+			p.Index = i
+			problems = append(problems, p)
+		}
+	}
+	problems = append(problems, checkWireOutputs(mods, metas)...)
+	if len(problems) == 0 {
+		return nil
+	}
+	return &InputValidationError{Context: context, Problems: problems}
+}
+
+func checkModuleInputs(m Module, meta *ModuleMetadata) []InputProblem {
+	declared := make(map[string]InputSpec, len(meta.Inputs))
+	names := make([]string, 0, len(meta.Inputs))
+	for _, in := range meta.Inputs {
+		declared[in.Name] = in
+		names = append(names, in.Name)
+	}
+
+	keys := make([]string, 0, len(m.Inputs))
+	for k := range m.Inputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var problems []InputProblem
+	for _, key := range sortedMapKeys(m.Wire) {
+		if _, ok := declared[key]; ok || len(meta.Inputs) == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("is wired but not declared by module type %q", meta.Type)
+		if s := suggestName(key, names); s != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", s)
+		}
+		problems = append(problems, InputProblem{ModuleID: m.ID, Input: key, Message: msg, Wired: true})
+	}
+	for _, key := range keys {
+		spec, ok := declared[key]
+		if !ok {
+			if len(meta.Inputs) == 0 {
+				continue
+			}
+			msg := fmt.Sprintf("is not declared by module type %q", meta.Type)
+			if s := suggestName(key, names); s != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+			problems = append(problems, InputProblem{ModuleID: m.ID, Input: key, Message: msg})
+			continue
+		}
+		ty, err := ParseInputType(spec.Type)
+		if err != nil {
+			// Unparseable declared types are treated as "any" so legacy module.yaml files keep working.
+			continue
+		}
+		if err := checkValueType(m.Inputs[key], ty, ""); err != nil {
+			problems = append(problems, InputProblem{ModuleID: m.ID, Input: key, Message: err.Error()})
+		}
+	}
+	return problems
+}
+
+// checkWireOutputs checks that every wire: entry names an output the source module declares.
+// Parent outputs and modules without declared outputs are not checked.
+func checkWireOutputs(mods []Module, metas map[string]*ModuleMetadata) []InputProblem {
+	types := make(map[string]string, len(mods))
+	for _, m := range mods {
+		types[m.ID] = m.Type
+	}
+	var problems []InputProblem
+	for i, m := range mods {
+		for _, key := range sortedMapKeys(m.Wire) {
+			src, err := ParseWireSource(m.Wire[key])
+			if err != nil || src.Parent() {
+				continue
+			}
+			meta := metas[types[src.Module]]
+			if meta == nil || len(meta.Outputs) == 0 {
+				continue
+			}
+			names := make([]string, 0, len(meta.Outputs))
+			found := false
+			for _, out := range meta.Outputs {
+				names = append(names, out.Name)
+				found = found || out.Name == src.Output
+			}
+			if found {
+				continue
+			}
+			msg := fmt.Sprintf("is wired from %s, but module type %q has no output %q", src, meta.Type, src.Output)
+			if s := suggestName(src.Output, names); s != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+			problems = append(problems, InputProblem{Index: i, ModuleID: m.ID, Input: key, Message: msg, Wired: true})
+		}
+	}
+	return problems
+}
+
+// ParseInputType converts a module.yaml type string (Terraform type constraint syntax) into a cty.Type.
+// Bare collection keywords (list, set, map, object, tuple) are accepted as collections of any.
+func ParseInputType(typ string) (cty.Type, error) {
+	typ = strings.TrimSpace(typ)
+	switch typ {
+	case "", "any", "object", "tuple":
+		return cty.DynamicPseudoType, nil
+	case "list":
+		return cty.List(cty.DynamicPseudoType), nil
+	case "set":
+		return cty.Set(cty.DynamicPseudoType), nil
+	case "map":
+		return cty.Map(cty.DynamicPseudoType), nil
+	}
+	expr, diags := hclsyntax.ParseExpression([]byte(typ), "module.yaml", hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilType, fmt.Errorf("invalid type %q: %s", typ, diags.Error())
+	}
+	ty, _, diags := typeexpr.TypeConstraintWithDefaults(expr)
+	if diags.HasErrors() {
+		return cty.NilType, fmt.Errorf("invalid type %q: %s", typ, diags.Error())
+	}
+	return ty, nil
+}
+
+// checkValueType reports whether a YAML-decoded value can be converted to ty the way Terraform would.
+// Strings that reference other values (module/var/parent refs, ${...} or {placeholder}) are not checked.
+func checkValueType(v interface{}, ty cty.Type, path string) error {
+	if v == nil || ty == cty.DynamicPseudoType {
+		return nil
+	}
+	if s, ok := v.(string); ok && isReferenceString(s) {
+		return nil
+	}
+
+	switch {
+	case ty == cty.String:
+		switch v.(type) {
+		case string, bool, int, int64, float64:
+			return nil
+		}
+		return typeMismatch(path, "string", v)
+	case ty == cty.Number:
+		switch val := v.(type) {
+		case int, int64, float64:
+			return nil
+		case string:
+			if _, ok := new(big.Float).SetString(strings.TrimSpace(val)); ok {
+				return nil
+			}
+		}
+		return typeMismatch(path, "number", v)
+	case ty == cty.Bool:
+		switch val := v.(type) {
+		case bool:
+			return nil
+		case string:
+			if _, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil {
+				return nil
+			}
+		}
+		return typeMismatch(path, "bool", v)
+	case ty.IsListType() || ty.IsSetType():
+		items, ok := asList(v)
+		if !ok {
+			return typeMismatch(path, ty.FriendlyName(), v)
+		}
+		for i, item := range items {
+			if err := checkValueType(item, ty.ElementType(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ty.IsTupleType():
+		items, ok := asList(v)
+		if !ok {
+			return typeMismatch(path, ty.FriendlyName(), v)
+		}
+		elems := ty.TupleElementTypes()
+		if len(items) != len(elems) {
+			return fmt.Errorf("%sexpected a tuple of %d elements, got %d", pathPrefix(path), len(elems), len(items))
+		}
+		for i, item := range items {
+			if err := checkValueType(item, elems[i], fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ty.IsMapType():
+		obj, ok := asMap(v)
+		if !ok {
+			return typeMismatch(path, ty.FriendlyName(), v)
+		}
+		for _, k := range sortedMapKeys(obj) {
+			if err := checkValueType(obj[k], ty.ElementType(), joinInputPath(path, k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ty.IsObjectType():
+		obj, ok := asMap(v)
+		if !ok {
+			return typeMismatch(path, "object", v)
+		}
+		attrs := ty.AttributeTypes()
+		for _, name := range sortedMapKeys(attrs) {
+			val, present := obj[name]
+			if !present {
+				if ty.AttributeOptional(name) {
+					continue
+				}
+				return fmt.Errorf("%smissing required attribute %q", pathPrefix(path), name)
+			}
+			if err := checkValueType(val, attrs[name], joinInputPath(path, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+func isReferenceString(s string) bool {
+	t := strings.TrimSpace(s)
+	return strings.Contains(t, "{") ||
+		strings.HasPrefix(t, "module.") ||
+		strings.HasPrefix(t, "var.") ||
+		strings.HasPrefix(t, "parent.")
+}
+
+func asList(v interface{}) ([]interface{}, bool) {
+	switch val := v.(type) {
+	case []interface{}:
+		return val, true
+	case []string:
+		out := make([]interface{}, len(val))
+		for i, s := range val {
+			out[i] = s
+		}
+		return out, true
+	case []map[string]interface{}:
+		out := make([]interface{}, len(val))
+		for i, m := range val {
+			out[i] = m
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return val, true
+	case map[string]string:
+		out := make(map[string]interface{}, len(val))
+		for k, s := range val {
+			out[k] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func sortedMapKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func typeMismatch(path, want string, v interface{}) error {
+	return fmt.Errorf("%sexpected %s, got %s", pathPrefix(path), want, describeValue(v))
+}
+
+func describeValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return fmt.Sprintf("string %q", val)
+	case bool:
+		return fmt.Sprintf("bool %t", val)
+	case int, int64, float64:
+		return fmt.Sprintf("number %v", val)
+	case []interface{}, []string, []map[string]interface{}:
+		return "list"
+	case map[string]interface{}, map[string]string:
+		return "map"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func pathPrefix(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + ": "
+}
+
+func joinInputPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// suggestName returns the closest candidate to name by edit distance, or "" if none is close enough.
+func suggestName(name string, candidates []string) string {
+	best := ""
+	bestDist := -1
+	for _, c := range candidates {
+		d := levenshtein(strings.ToLower(name), strings.ToLower(c))
+		if bestDist == -1 || d < bestDist || (d == bestDist && c < best) {
+			best, bestDist = c, d
+		}
+	}
+	limit := len(name) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	if bestDist < 0 || bestDist > limit {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateModuleInputsReportsAllProblems(t *testing.T) {
+	metas := map[string]*ModuleMetadata{
+		"aws_eks": {
+			Name: "aws_eks", Type: "aws_eks", Provider: "aws", Version: "1.0.0",
+			Inputs: []InputSpec{
+				{Name: "max_nodes", Type: "number"},
+				{Name: "enable_metrics", Type: "bool"},
+				{Name: "private_subnet_ids", Type: "list(string)"},
+				{Name: "node_launch_template", Type: "map(string)"},
+			},
+		},
+	}
+	mods := []Module{{
+		ID:   "eks",
+		Type: "aws_eks",
+		Inputs: map[string]interface{}{
+			"max_node":             5,
+			"enable_metrics":       "yes",
+			"private_subnet_ids":   []interface{}{"subnet-1", []interface{}{"nested"}},
+			"node_launch_template": map[string]interface{}{"ami": "${var.ami}"},
+		},
+	}}
+
+	err := ValidateModuleInputs(mods, metas, "environment")
+	var inErr *InputValidationError
+	if !errors.As(err, &inErr) {
+		t.Fatalf("expected InputValidationError, got %v", err)
+	}
+	if len(inErr.Problems) != 3 {
+		t.Fatalf("expected 3 problems, got %d: %v", len(inErr.Problems), err)
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`"enable_metrics": expected bool, got string "yes"`,
+		`"max_node": is not declared by module type "aws_eks" (did you mean "max_nodes"?)`,
+		`"private_subnet_ids": [1]: expected string, got list`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("expected %q in error, got:\n%s", want, msg)
+		}
+	}
+}
+
+func TestValidateModuleInputsAcceptsReferencesAndObjects(t *testing.T) {
+	metas := map[string]*ModuleMetadata{
+		"aws_iam_role": {
+			Name: "aws_iam_role", Type: "aws_iam_role", Provider: "aws", Version: "1.0.0",
+			Inputs: []InputSpec{
+				{Name: "kubernetes_trusts", Type: "list(object({\n  namespace = string\n  service_name = string\n}))"},
+				{Name: "max_session", Type: "number"},
+				{Name: "k8s_version", Type: "string"},
+				{Name: "legacy", Type: "map"},
+			},
+		},
+		"custom": {Name: "custom", Type: "custom", Provider: "aws", Version: "1.0.0"},
+	}
+	mods := []Module{
+		{ID: "role", Type: "aws_iam_role", Inputs: map[string]interface{}{
+			"kubernetes_trusts": []interface{}{
+				map[string]interface{}{"namespace": "*", "service_name": "*"},
+			},
+			"max_session": "module.base.session_length",
+			"k8s_version": 1.33,
+			"legacy":      map[string]interface{}{"anything": []interface{}{1, 2}},
+		}},
+		{ID: "free", Type: "custom", Inputs: map[string]interface{}{"whatever": true}},
+	}
+	if err := ValidateModuleInputs(mods, metas, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mods[0].Inputs["kubernetes_trusts"] = []interface{}{map[string]interface{}{"namespace": "*"}}
+	err := ValidateModuleInputs(mods, metas, "")
+	if err == nil || !strings.Contains(err.Error(), `missing required attribute "service_name"`) {
+		t.Fatalf("expected missing attribute error, got %v", err)
+	}
+}
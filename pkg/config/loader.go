@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-
 	"path/filepath"
 
 	"gopkg.in/yaml.v3"
@@ -49,65 +48,175 @@ func DetectKind(path string) (string, error) {
 
 // LoadEnvironmentConfig loads, parses, and validates an Environment YAML.
 func LoadEnvironmentConfig(path string) (*EnvironmentConfig, error) {
+	cfg, ds, err := LoadEnvironmentConfigDiagnostics(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ds.Err(); err != nil {
+		return nil, fmt.Errorf("environment validation failed for %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// LoadEnvironmentConfigDiagnostics loads an Environment YAML, resolving its extends/imports chain,
+// and returns every parse and validation finding as positioned diagnostics. The error is reserved
+// for I/O failures on path itself; the config is nil when the spec cannot be composed or decoded.
+func LoadEnvironmentConfigDiagnostics(path string) (*EnvironmentConfig, Diagnostics, error) {
+	data, doc, ds, err := readSingleDocument(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read environment file %s: %w", path, err)
+	}
+	if doc == nil {
+		return nil, ds, nil
+	}
+	cfg, ds := loadEnvironmentDocument(path, data, 0, doc)
+	return cfg, ds, nil
+}
+
+// readSingleDocument reads a spec file that must hold exactly one YAML document. Multi-document
+// files are loaded as a set with LoadSpecSet instead.
+func readSingleDocument(path string) ([]byte, *yaml.Node, Diagnostics, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read environment file %s: %w", path, err)
+		return nil, nil, nil, err
 	}
+	docs, err := parseDocuments(data)
+	if err != nil {
+		return nil, nil, yamlErrorDiagnostics(path, fmt.Errorf("failed to parse yaml %s: %w", path, err)), nil
+	}
+	switch len(docs) {
+	case 0:
+		return data, &yaml.Node{Kind: yaml.DocumentNode}, nil, nil
+	case 1:
+		return data, docs[0], nil, nil
+	}
+	var ds Diagnostics
+	ds = append(ds, Diagnostic{
+		Severity: SeverityError,
+		File:     path,
+		Line:     docs[1].Line,
+		Column:   docs[1].Column,
+		Message:  fmt.Sprintf("file %s contains multiple YAML documents; only one is supported here (load it as a spec set)", path),
+	})
+	return nil, nil, ds, nil
+}
 
-	var cfg EnvironmentConfig
-	if err := decodeYAMLStrict(data, &cfg, path); err != nil {
-		return nil, err
+// loadEnvironmentDocument composes and validates document index of an Environment spec file.
+func loadEnvironmentDocument(path string, data []byte, index int, doc *yaml.Node) (*EnvironmentConfig, Diagnostics) {
+	root, idx, composeDiags := composeSpec(path, data, index, doc, "Environment")
+	if root == nil {
+		return nil, composeDiags
 	}
 
-	if cfg.Kind != "Environment" {
-		return nil, fmt.Errorf("file %s is kind %q, expected 'Environment'", path, cfg.Kind)
+	var cfg EnvironmentConfig
+	if err := root.Decode(&cfg); err != nil {
+		return nil, yamlErrorDiagnostics(path, err)
 	}
+	cfg.Extends, cfg.Imports = idx.extends, idx.imports
+	cfg.Sources = idx
 
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("environment validation failed for %s: %w", path, err)
+	if cfg.Kind != "Environment" {
+		var ds Diagnostics
+		ds.Errorf("kind", "file %s is kind %q, expected 'Environment'", path, cfg.Kind)
+		return nil, ds.Locate(idx)
 	}
 
-	return &cfg, nil
+	ds := append(composeDiags, cfg.Diagnose().Locate(idx)...)
+	ds.Sort()
+	return &cfg, ds
 }
 
 // LoadService loads a Service config AND the referenced Environment
 // (following metadata.ref, resolving relative to the service file).
 // Validation errors from either file are surfaced with context.
 func LoadService(servicePath string) (*ServiceConfig, *EnvironmentConfig, error) {
-	svcData, err := os.ReadFile(servicePath)
+	svc, env, ds, err := LoadServiceDiagnostics(servicePath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read service file %s: %w", servicePath, err)
-	}
-
-	var svc ServiceConfig
-	if err := decodeYAMLStrict(svcData, &svc, servicePath); err != nil {
 		return nil, nil, err
 	}
-	if svc.Kind != "Service" {
-		return nil, nil, fmt.Errorf("file %s is kind %q, expected 'Service'", servicePath, svc.Kind)
+	if err := ds.Err(); err != nil {
+		return nil, nil, fmt.Errorf("service validation failed for %s: %w", servicePath, err)
 	}
+	return svc, env, nil
+}
 
-	if svc.Metadata.Ref == "" {
-		return nil, nil, fmt.Errorf("service %s metadata.ref is empty (no environment reference)", svc.Metadata.Name)
+// LoadServiceDiagnostics loads a Service and its referenced Environment, returning diagnostics from
+// both files. Each diagnostic carries the file it belongs to. The error is reserved for I/O failures
+// on the service file itself.
+func LoadServiceDiagnostics(servicePath string) (*ServiceConfig, *EnvironmentConfig, Diagnostics, error) {
+	data, doc, ds, err := readSingleDocument(servicePath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read service file %s: %w", servicePath, err)
 	}
+	if doc == nil {
+		return nil, nil, ds, nil
+	}
+	svc, env, ds := loadServiceDocument(servicePath, data, 0, doc, loadEnvironmentRef)
+	return svc, env, ds, nil
+}
+
+// envResolver loads the Environment a service's metadata.ref points at. envPath is already
+// resolved against the file that set metadata.ref.
+type envResolver func(ref, envPath string) (*EnvironmentConfig, Diagnostics, error)
+
+// loadEnvironmentRef resolves metadata.ref from disk.
+func loadEnvironmentRef(_, envPath string) (*EnvironmentConfig, Diagnostics, error) {
+	return LoadEnvironmentConfigDiagnostics(envPath)
+}
 
-	// Resolve env path relative to service file
-	envPath := svc.Metadata.Ref
-	if !filepath.IsAbs(envPath) {
-		envPath = filepath.Join(filepath.Dir(servicePath), envPath)
+// loadServiceDocument composes and validates document index of a Service spec file, loading the
+// referenced Environment through resolveEnv.
+func loadServiceDocument(servicePath string, data []byte, index int, doc *yaml.Node, resolveEnv envResolver) (*ServiceConfig, *EnvironmentConfig, Diagnostics) {
+	root, idx, composeDiags := composeSpec(servicePath, data, index, doc, "Service")
+	if root == nil {
+		return nil, nil, composeDiags
 	}
 
-	env, err := LoadEnvironmentConfig(envPath)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load environment for service %s: %w", svc.Metadata.Name, err)
+	var svc ServiceConfig
+	if err := root.Decode(&svc); err != nil {
+		return nil, nil, yamlErrorDiagnostics(servicePath, err)
 	}
+	svc.Extends, svc.Imports = idx.extends, idx.imports
+	svc.Sources = idx
 
-	// Now validate service WITH environment context
-	if err := svc.Validate(env); err != nil {
-		return nil, nil, fmt.Errorf("service validation failed for %s: %w", servicePath, err)
+	if svc.Kind != "Service" {
+		var ds Diagnostics
+		ds.Errorf("kind", "file %s is kind %q, expected 'Service'", servicePath, svc.Kind)
+		return nil, nil, ds.Locate(idx)
+	}
+
+	var (
+		ds  = composeDiags
+		env *EnvironmentConfig
+	)
+	if svc.Metadata.Ref != "" {
+		// Resolve env path relative to the file that set metadata.ref (the service or one of its imports)
+		envPath := svc.Metadata.Ref
+		if !filepath.IsAbs(envPath) {
+			refFile := servicePath
+			if pos, ok := idx.Lookup("metadata.ref"); ok && pos.File != "" {
+				refFile = pos.File
+			}
+			envPath = filepath.Join(filepath.Dir(refFile), envPath)
+		}
+
+		envCfg, envDiags, err := resolveEnv(svc.Metadata.Ref, envPath)
+		switch {
+		case err != nil:
+			ds.Errorf("metadata.ref", "failed to load environment for service %s: %v", svc.Metadata.Name, err)
+		case envDiags.HasErrors():
+			ds.Errorf("metadata.ref", "environment %s referenced by service %s is invalid", envPath, svc.Metadata.Name)
+		default:
+			env = envCfg
+		}
+		ds = append(ds, envDiags...)
 	}
 
-	return &svc, env, nil
+	// Now validate service WITH environment context
+	ds = append(ds, svc.Diagnose(env)...)
+	ds = ds.Locate(idx)
+	ds.Sort()
+	return &svc, env, ds
 }
 
 // LoadModuleMetadata reads module.yaml from a module directory.
@@ -14,6 +14,9 @@ type ModuleMetadata struct {
 	Capabilities Capabilities `yaml:"capabilities"` // what it provides/accepts
 	Inputs       []InputSpec  `yaml:"inputs,omitempty"`
 	Outputs      []OutputSpec `yaml:"outputs,omitempty"`
+	// Protect lists the resource types (or <type>.<name> addresses) that plan and apply refuse to
+	// delete or replace by default, such as the cluster of a database module.
+	Protect []string `yaml:"protect,omitempty"`
 }
 
 // Capabilities this module exposes.
@@ -70,6 +73,12 @@ func (m *ModuleMetadata) Validate() error {
 
 	}
 
+	for _, r := range m.Protect {
+		if !ValidProtectPattern(r) {
+			return fmt.Errorf("protect entry %q is not a resource type or <type>.<name>", r)
+		}
+	}
+
 	// ---------- Outputs ----------
 	outputNames := make(map[string]struct{})
 	for _, out := range m.Outputs {
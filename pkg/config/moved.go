@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// movedIDPattern is what Terraform accepts as a module name.
+var movedIDPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// diagnosePreviousIDs checks previousIds: each must be a valid module name that no module uses any
+// more and that only one module claims.
+func diagnosePreviousIDs(mods []Module, context string, ds *Diagnostics) {
+	current := make(map[string]struct{}, len(mods))
+	for _, m := range mods {
+		current[m.ID] = struct{}{}
+	}
+	claimed := map[string]string{}
+	for i, m := range mods {
+		for j, prev := range m.PreviousIDs {
+			path := fmt.Sprintf("modules[%d].previousIds[%d]", i, j)
+			switch owner, dup := claimed[prev]; {
+			case !movedIDPattern.MatchString(prev):
+				ds.Errorf(path, "module %q previousIds entry %q is not a valid module id%s", m.ID, prev, contextSuffix(context))
+			case prev == m.ID:
+				ds.Errorf(path, "module %q lists its own id in previousIds%s", m.ID, contextSuffix(context))
+			case dup:
+				ds.Errorf(path, "module %q previousIds entry %q is already claimed by module %q%s", m.ID, prev, owner, contextSuffix(context))
+			default:
+				if _, used := current[prev]; used {
+					ds.Errorf(path, "module %q previousIds entry %q is still the id of a module%s", m.ID, prev, contextSuffix(context))
+					continue
+				}
+				claimed[prev] = m.ID
+			}
+		}
+	}
+}
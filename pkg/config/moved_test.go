@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagnosePreviousIDs(t *testing.T) {
+	mods := []Module{
+		{ID: "db", Type: "aws_postgres", PreviousIDs: []string{"postgres", "db"}},
+		{ID: "cache", Type: "aws_redis", PreviousIDs: []string{"postgres", "bucket", "bad id"}},
+		{ID: "bucket", Type: "aws_s3"},
+	}
+	var ds Diagnostics
+	diagnosePreviousIDs(mods, "", &ds)
+	wants := map[string]string{
+		"modules[0].previousIds[1]": `module "db" lists its own id in previousIds`,
+		"modules[1].previousIds[0]": `"postgres" is already claimed by module "db"`,
+		"modules[1].previousIds[1]": `"bucket" is still the id of a module`,
+		"modules[1].previousIds[2]": `"bad id" is not a valid module id`,
+	}
+	if len(ds) != len(wants) {
+		t.Fatalf("expected %d diagnostics, got %v", len(wants), ds)
+	}
+	for _, d := range ds {
+		if want, ok := wants[d.Path]; !ok || !strings.Contains(d.Message, want) {
+			t.Fatalf("unexpected diagnostic at %s: %s", d.Path, d.Message)
+		}
+	}
+}
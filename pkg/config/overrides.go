@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Disabled reports whether the override turns its module off.
+func (o ModuleOverride) Disabled() bool {
+	return o.Enabled != nil && !*o.Enabled
+}
+
+// ModulesFor returns the shared modules as they apply to environment key envKey: modules disabled
+// for that environment are dropped and override inputs replace the module's inputs key by key.
+// The returned modules are copies; e.Modules is never modified.
+func (e *EnvironmentConfig) ModulesFor(envKey string) ([]Module, error) {
+	entry, ok := e.Environments[envKey]
+	if !ok {
+		return nil, fmt.Errorf("environment %q not found in %s", envKey, e.Metadata.Name)
+	}
+	mods, ds := resolveModuleOverrides(e.Modules, envKey, entry.Modules)
+	if err := ds.Err(); err != nil {
+		return nil, err
+	}
+	return mods, nil
+}
+
+// SpecPathFor maps a path into the modules ModulesFor(envKey) returns, such as modules[1].inputs.size,
+// back to the spec: the index becomes the module's index in e.Modules, and an input the environment
+// override replaced points at environments.<envKey>.modules.<id>.inputs.<name> instead.
+func (e *EnvironmentConfig) SpecPathFor(envKey, path string) string {
+	sm := moduleIndexPattern.FindStringSubmatch(path)
+	if sm == nil {
+		return path
+	}
+	want, _ := strconv.Atoi(sm[1])
+	rest := path[len(sm[0]):]
+	overrides := e.Environments[envKey].Modules
+	n := 0
+	for i, m := range e.Modules {
+		o, ok := overrides[m.ID]
+		if ok && o.Disabled() {
+			continue
+		}
+		if n < want {
+			n++
+			continue
+		}
+		if name, ok := strings.CutPrefix(rest, ".inputs."); ok {
+			if _, replaced := o.Inputs[strings.SplitN(name, ".", 2)[0]]; replaced {
+				return fmt.Sprintf("environments.%s.modules.%s%s", envKey, m.ID, rest)
+			}
+		}
+		return fmt.Sprintf("modules[%d]%s", i, rest)
+	}
+	return path
+}
+
+var moduleIndexPattern = regexp.MustCompile(`^modules\[(\d+)\]`)
+
+// resolveModuleOverrides applies per-environment overrides and reports overrides that name unknown
+// modules as well as enabled modules that still link to a disabled one.
+func resolveModuleOverrides(mods []Module, envKey string, overrides map[string]ModuleOverride) ([]Module, Diagnostics) {
+	var ds Diagnostics
+	known := make(map[string]struct{}, len(mods))
+	for _, m := range mods {
+		known[m.ID] = struct{}{}
+	}
+	for _, id := range sortedMapKeys(overrides) {
+		if _, ok := known[id]; !ok {
+			ds.Errorf(fmt.Sprintf("environments.%s.modules.%s", envKey, id),
+				"environments.%s.modules.%s overrides unknown module %q", envKey, id, id)
+		}
+	}
+
+	disabled := map[string]struct{}{}
+	out := make([]Module, 0, len(mods))
+	for _, m := range mods {
+		o, ok := overrides[m.ID]
+		if !ok {
+			out = append(out, m)
+			continue
+		}
+		if o.Disabled() {
+			disabled[m.ID] = struct{}{}
+			continue
+		}
+		if len(o.Inputs) > 0 {
+			inputs := make(map[string]interface{}, len(m.Inputs)+len(o.Inputs))
+			for k, v := range m.Inputs {
+				inputs[k] = v
+			}
+			for k, v := range o.Inputs {
+				inputs[k] = v
+			}
+			m.Inputs = inputs
+		}
+		if o.Protect != nil {
+			for j, r := range o.Protect.Resources {
+				if !ValidProtectPattern(r) {
+					ds.Errorf(fmt.Sprintf("environments.%s.modules.%s.protect[%d]", envKey, m.ID, j),
+						"module %q protect entry %q is not a resource type or <type>.<name>", m.ID, r)
+				}
+			}
+			p := *o.Protect
+			m.Protect = &p
+		}
+		out = append(out, m)
+	}
+
+	for _, m := range out {
+		for _, access := range sortedMapKeys(m.Links) {
+			for _, target := range m.Links[access] {
+				if _, off := disabled[target]; off {
+					ds.Errorf(fmt.Sprintf("environments.%s.modules.%s.enabled", envKey, target),
+						"module %q links.%s refers to module %q, which is disabled in environment %q", m.ID, access, target, envKey)
+				}
+			}
+		}
+		for _, k := range sortedMapKeys(m.Wire) {
+			if src, err := ParseWireSource(m.Wire[k]); err == nil && !src.Parent() {
+				if _, off := disabled[src.Module]; off {
+					ds.Errorf(fmt.Sprintf("environments.%s.modules.%s.enabled", envKey, src.Module),
+						"module %q wire.%s refers to module %q, which is disabled in environment %q", m.ID, k, src.Module, envKey)
+				}
+			}
+		}
+		for _, k := range sortedMapKeys(m.Inputs) {
+			if s, ok := m.Inputs[k].(string); ok {
+				if target := referencedModule(s); target != "" {
+					if _, off := disabled[target]; off {
+						ds.Errorf(fmt.Sprintf("environments.%s.modules.%s.enabled", envKey, target),
+							"module %q input %q references module %q, which is disabled in environment %q", m.ID, k, target, envKey)
+					}
+				}
+			}
+		}
+	}
+	return out, ds
+}
+
+// referencedModule returns the module id of a plain module.<id>.<output> reference, or "".
+func referencedModule(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "${"), "}")
+	if !strings.HasPrefix(s, "module.") {
+		return ""
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
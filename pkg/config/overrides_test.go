@@ -0,0 +1,75 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestModulesForReportsBadOverrides(t *testing.T) {
+	off := false
+	env := &EnvironmentConfig{
+		Metadata: EnvironmentMetadata{Name: "demo"},
+		Environments: map[string]EnvironmentEntry{
+			"dev": {Modules: map[string]ModuleOverride{
+				"dns":     {Enabled: &off},
+				"missing": {Inputs: map[string]interface{}{"x": 1}},
+			}},
+		},
+		Modules: []Module{
+			{ID: "base", Type: "aws_base", Links: AccessLinks{"read": {"dns"}}},
+			{ID: "dns", Type: "aws_dns"},
+			{ID: "app", Type: "helm_chart", Inputs: map[string]interface{}{"zone": "${module.dns.zone_id}"}},
+		},
+	}
+
+	_, err := env.ModulesFor("dev")
+	if err == nil {
+		t.Fatalf("expected override errors")
+	}
+	for _, want := range []string{
+		`overrides unknown module "missing"`,
+		`module "base" links.read refers to module "dns", which is disabled in environment "dev"`,
+		`module "app" input "zone" references module "dns", which is disabled`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got:\n%v", want, err)
+		}
+	}
+
+	env.Modules[0].Links = nil
+	delete(env.Environments["dev"].Modules, "missing")
+	env.Modules[2].Inputs["zone"] = "Z123"
+	mods, err := env.ModulesFor("dev")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mods) != 2 || mods[0].ID != "base" || mods[1].ID != "app" {
+		t.Fatalf("expected dns to be dropped, got %+v", mods)
+	}
+}
+
+func TestSpecPathForMapsResolvedModules(t *testing.T) {
+	off := false
+	env := &EnvironmentConfig{
+		Environments: map[string]EnvironmentEntry{
+			"prod": {Modules: map[string]ModuleOverride{
+				"dns": {Enabled: &off},
+				"app": {Inputs: map[string]interface{}{"replicas": 3}},
+			}},
+		},
+		Modules: []Module{
+			{ID: "dns", Type: "aws_dns"},
+			{ID: "app", Type: "helm_chart", Inputs: map[string]interface{}{"replicas": 1, "chart": "web"}},
+		},
+	}
+	for path, want := range map[string]string{
+		"modules[0].inputs.replicas": "environments.prod.modules.app.inputs.replicas",
+		"modules[0].inputs.chart":    "modules[1].inputs.chart",
+		"modules[0].type":            "modules[1].type",
+		"metadata.name":              "metadata.name",
+	} {
+		if got := env.SpecPathFor("prod", path); got != want {
+			t.Fatalf("SpecPathFor(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
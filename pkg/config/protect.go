@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Protection marks the resources of a module that plan and apply must not delete or replace unless
+// --allow-destroy names the module. In YAML it is true (every resource of the module), false (none,
+// dropping the defaults of module.yaml) or a list of resource types or <type>.<name> addresses.
+type Protection struct {
+	All       bool
+	Resources []string
+}
+
+// UnmarshalYAML accepts protect: true|false as well as protect: [aws_rds_cluster, ...].
+func (p *Protection) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		var all bool
+		if err := value.Decode(&all); err != nil {
+			return fmt.Errorf("protect must be true, false or a list of resource types")
+		}
+		*p = Protection{All: all}
+		return nil
+	case yaml.SequenceNode:
+		var resources []string
+		if err := value.Decode(&resources); err != nil {
+			return fmt.Errorf("protect must be true, false or a list of resource types")
+		}
+		*p = Protection{Resources: resources}
+		return nil
+	}
+	return fmt.Errorf("protect must be true, false or a list of resource types")
+}
+
+// MarshalYAML writes the form UnmarshalYAML reads.
+func (p Protection) MarshalYAML() (interface{}, error) {
+	if len(p.Resources) > 0 {
+		return p.Resources, nil
+	}
+	return p.All, nil
+}
+
+// Patterns returns the protected resources as module.yaml lists them: "*" for every resource.
+func (p Protection) Patterns() []string {
+	if p.All {
+		return []string{"*"}
+	}
+	return p.Resources
+}
+
+// protectPattern is a resource type, or a resource type and name.
+var protectPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_-]*)?$`)
+
+// ValidProtectPattern reports whether s names resources the way protect lists them.
+func ValidProtectPattern(s string) bool {
+	return s == "*" || protectPattern.MatchString(s)
+}
+
+// diagnoseProtection checks that protect lists resource types or <type>.<name> addresses.
+func diagnoseProtection(mods []Module, context string, ds *Diagnostics) {
+	for i, m := range mods {
+		if m.Protect == nil {
+			continue
+		}
+		for j, r := range m.Protect.Resources {
+			if !ValidProtectPattern(r) {
+				ds.Errorf(fmt.Sprintf("modules[%d].protect[%d]", i, j), "module %q protect entry %q is not a resource type or <type>.<name>%s", m.ID, r, contextSuffix(context))
+			}
+		}
+	}
+}
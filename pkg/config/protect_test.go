@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestProtectionYAMLAndDiagnostics(t *testing.T) {
+	var mods []Module
+	src := `
+- id: db
+  type: aws_postgres
+  protect: true
+- id: bucket
+  type: aws_s3
+  protect: [aws_s3_bucket, aws_s3_bucket.logs, "Bad Type"]
+- id: cache
+  type: aws_redis
+  protect: false
+`
+	if err := yaml.Unmarshal([]byte(src), &mods); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := mods[0].Protect.Patterns(); !reflect.DeepEqual(got, []string{"*"}) {
+		t.Fatalf("expected protect: true to cover every resource, got %v", got)
+	}
+	if got := mods[2].Protect.Patterns(); mods[2].Protect == nil || len(got) != 0 {
+		t.Fatalf("expected protect: false to protect nothing, got %v", got)
+	}
+	var bad []Module
+	if err := yaml.Unmarshal([]byte("- id: x\n  protect: {a: b}\n"), &bad); err == nil {
+		t.Fatalf("expected a map to be rejected")
+	}
+
+	var ds Diagnostics
+	diagnoseProtection(mods, "", &ds)
+	if len(ds) != 1 || ds[0].Path != "modules[1].protect[2]" || !strings.Contains(ds[0].Message, `"Bad Type"`) {
+		t.Fatalf("unexpected diagnostics: %v", ds)
+	}
+
+	out, err := yaml.Marshal(mods[1].Protect)
+	if err != nil || !strings.HasPrefix(string(out), "- aws_s3_bucket\n") {
+		t.Fatalf("unexpected marshal: %q %v", out, err)
+	}
+}
+
+func TestModulesForAppliesProtectOverride(t *testing.T) {
+	env := &EnvironmentConfig{
+		Metadata: EnvironmentMetadata{Name: "demo"},
+		Environments: map[string]EnvironmentEntry{
+			"dev": {Modules: map[string]ModuleOverride{
+				"db": {Protect: &Protection{}},
+			}},
+			"prod": {},
+		},
+		Modules: []Module{{ID: "db", Type: "aws_postgres", Protect: &Protection{All: true}}},
+	}
+	dev, err := env.ModulesFor("dev")
+	if err != nil {
+		t.Fatalf("ModulesFor: %v", err)
+	}
+	if len(dev[0].Protect.Patterns()) != 0 {
+		t.Fatalf("expected dev to drop protection, got %v", dev[0].Protect.Patterns())
+	}
+	prod, err := env.ModulesFor("prod")
+	if err != nil {
+		t.Fatalf("ModulesFor: %v", err)
+	}
+	if !prod[0].Protect.All {
+		t.Fatalf("expected prod to keep protect: true")
+	}
+}
@@ -0,0 +1,40 @@
+package config
+
+import "strings"
+
+// Variable render modes for Render.Variables.
+const (
+	// VariablesLocals bakes merged variable values into a locals block in versions.tf.
+	VariablesLocals = "locals"
+	// VariablesTfvars declares merged variables as typed variable blocks, writes their values to
+	// <env>.auto.tfvars.json and leaves the backend partial, completed by <env>.tfbackend.
+	VariablesTfvars = "tfvars"
+)
+
+// Render holds options for how a spec is rendered to Terraform.
+type Render struct {
+	// Variables is locals (the default) or tfvars. With tfvars one rendered stack can be applied
+	// to another environment with -backend-config and -var-file.
+	Variables string `yaml:"variables,omitempty"`
+}
+
+// VariableMode returns how a stack renders its variables: the Service's render.variables, else the
+// Environment's, else locals. Either argument may be nil.
+func VariableMode(env *EnvironmentConfig, svc *ServiceConfig) string {
+	if svc != nil && strings.TrimSpace(svc.Render.Variables) != "" {
+		return strings.TrimSpace(svc.Render.Variables)
+	}
+	if env != nil && strings.TrimSpace(env.Render.Variables) != "" {
+		return strings.TrimSpace(env.Render.Variables)
+	}
+	return VariablesLocals
+}
+
+// diagnoseRender checks render options.
+func diagnoseRender(r Render, ds *Diagnostics) {
+	switch strings.TrimSpace(r.Variables) {
+	case "", VariablesLocals, VariablesTfvars:
+	default:
+		ds.Errorf("render.variables", "render.variables must be %q or %q, got %q", VariablesLocals, VariablesTfvars, r.Variables)
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVariableModePrefersServiceSetting(t *testing.T) {
+	env := &EnvironmentConfig{Render: Render{Variables: VariablesTfvars}}
+	svc := &ServiceConfig{}
+	if got := VariableMode(nil, nil); got != VariablesLocals {
+		t.Fatalf("expected locals by default, got %q", got)
+	}
+	if got := VariableMode(env, svc); got != VariablesTfvars {
+		t.Fatalf("expected the Environment's setting, got %q", got)
+	}
+	svc.Render.Variables = VariablesLocals
+	if got := VariableMode(env, svc); got != VariablesLocals {
+		t.Fatalf("expected the Service's setting, got %q", got)
+	}
+}
+
+func TestDiagnoseRenderRejectsUnknownVariableMode(t *testing.T) {
+	var ds Diagnostics
+	diagnoseRender(Render{Variables: "vars"}, &ds)
+	if len(ds) != 1 || ds[0].Path != "render.variables" || !strings.Contains(ds[0].Message, `got "vars"`) {
+		t.Fatalf("unexpected diagnostics: %v", ds)
+	}
+}
@@ -8,15 +8,29 @@ type ServiceConfig struct {
 	Metadata   ServiceMetadata `yaml:"metadata"`
 	Modules    []Module        `yaml:"modules"`
 	GitProvider GitProvider          `yaml:"gitProvider,omitempty"`
+	// Variables declares types and constraints for service variables; it extends the Environment's.
+	Variables map[string]VariableSpec `yaml:"variables,omitempty"`
+	// DefaultProviders names the module that auto-wires an output when several modules provide it.
+	DefaultProviders map[string]string `yaml:"defaultProviders,omitempty"`
+	// Render selects rendering options, such as variables as tfvars instead of locals.
+	Render Render `yaml:"render,omitempty"`
+
+	// Extends names a base spec and Imports lists fragments merged before this file.
+	Extends string   `yaml:"extends,omitempty"`
+	Imports []string `yaml:"imports,omitempty"`
+	// Sources records which file supplied each value after extends/imports are resolved.
+	Sources *SourceIndex `yaml:"-"`
 }
 type ServiceMetadata struct {
 	Name    string                        `yaml:"name"`
 	Ref     string                        `yaml:"ref"`    // path to env.yaml
 	EnvRef  map[string]ServiceEnvRefEntry `yaml:"envRef"` // dev, prod, ...
 	Labels  map[string]string             `yaml:"labels,omitempty"`
+	// Approve adds approval gates to the ones the Environment declares.
+	Approve []ApprovalRequirement `yaml:"approve,omitempty"`
 }
 
 type ServiceEnvRefEntry struct {
-	Variables map[string]string    `yaml:"variables,omitempty"`
+	Variables VariableValues       `yaml:"variables,omitempty"`
 	Secrets   map[string]SecretRef `yaml:"secrets,omitempty"`
 }
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SpecEntry is one Environment or Service document of a spec set.
+type SpecEntry struct {
+	Kind string
+	File string
+	// Index is the document position within File, counting from 0.
+	Index int
+	// Documents is the number of documents in File, used to label entries of multi-document files.
+	Documents int
+
+	Environment *EnvironmentConfig
+	Service     *ServiceConfig
+	// ServiceEnv is the Environment a Service's metadata.ref resolved to.
+	ServiceEnv *EnvironmentConfig
+	// Diagnostics are the findings of this document; for a Service they include those of the
+	// Environment it references.
+	Diagnostics Diagnostics
+}
+
+// Name returns metadata.name of the entry.
+func (e SpecEntry) Name() string {
+	if e.Environment != nil {
+		return e.Environment.Metadata.Name
+	}
+	if e.Service != nil {
+		return e.Service.Metadata.Name
+	}
+	return ""
+}
+
+// Label identifies the entry in output: the file, plus "#n" (1-based) for multi-document files.
+func (e SpecEntry) Label() string {
+	if e.Documents > 1 {
+		return fmt.Sprintf("%s#%d", e.File, e.Index+1)
+	}
+	return e.File
+}
+
+// SpecSet is every spec loaded from a set of files and directories, Environments first.
+type SpecSet struct {
+	Entries []SpecEntry
+}
+
+// Environments returns the Environment entries of the set.
+func (s *SpecSet) Environments() []SpecEntry {
+	return s.filter("Environment")
+}
+
+// Services returns the Service entries of the set.
+func (s *SpecSet) Services() []SpecEntry {
+	return s.filter("Service")
+}
+
+func (s *SpecSet) filter(kind string) []SpecEntry {
+	var out []SpecEntry
+	for _, e := range s.Entries {
+		if e.Kind == kind {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// IsSpecSet reports whether path is loaded as a set rather than a single spec: a directory, or a
+// file holding more than one YAML document.
+func IsSpecSet(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		return true
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	docs, err := parseDocuments(data)
+	return err == nil && len(docs) > 1
+}
+
+type setDocument struct {
+	file  string
+	data  []byte
+	index int
+	total int
+	doc   *yaml.Node
+	kind  string
+}
+
+// LoadSpecSet loads every Environment and Service document found in paths. Directories are walked
+// recursively for *.yaml/*.yml (hidden directories such as .pltf and .git are skipped) and files
+// may hold several documents separated by "---". Documents of other kinds are ignored, as are files
+// that other specs pull in through extends/imports, since those are fragments rather than specs.
+//
+// A Service's metadata.ref is resolved against the set before falling back to disk: first as a path
+// to a file of the set holding one Environment, then as the metadata.name of an Environment in the
+// set. Each Environment is therefore loaded once, however many services reference it.
+//
+// Entries are returned even when they have errors; the diagnostics cover every document. The error
+// is reserved for paths that cannot be read.
+func LoadSpecSet(paths ...string) (*SpecSet, Diagnostics, error) {
+	files, err := collectSpecFiles(paths)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		docs []setDocument
+		ds   Diagnostics
+	)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read spec file %s: %w", file, err)
+		}
+		parsed, err := parseDocuments(data)
+		if err != nil {
+			ds = append(ds, yamlErrorDiagnostics(file, fmt.Errorf("failed to parse yaml %s: %w", file, err))...)
+			continue
+		}
+		for i, doc := range parsed {
+			kind := documentKind(doc)
+			if kind != "Environment" && kind != "Service" {
+				continue
+			}
+			docs = append(docs, setDocument{file: file, data: data, index: i, total: len(parsed), doc: doc, kind: kind})
+		}
+	}
+
+	// Files referenced through extends/imports are fragments of other specs, not specs of their own.
+	fragments := map[string]bool{}
+	for _, d := range docs {
+		for _, target := range composeTargets(d.file, d.doc) {
+			fragments[target] = true
+		}
+	}
+	specs := docs[:0]
+	for _, d := range docs {
+		if !fragments[absPath(d.file)] {
+			specs = append(specs, d)
+		}
+	}
+	docs = specs
+
+	set := &SpecSet{}
+	envByFile := map[string][]*EnvironmentConfig{}
+	envByName := map[string][]*EnvironmentConfig{}
+	for _, d := range docs {
+		if d.kind != "Environment" {
+			continue
+		}
+		cfg, envDiags := loadEnvironmentDocument(d.file, d.data, d.index, d.doc)
+		ds = append(ds, envDiags...)
+		if cfg == nil {
+			continue
+		}
+		abs := absPath(d.file)
+		envByFile[abs] = append(envByFile[abs], cfg)
+		envByName[cfg.Metadata.Name] = append(envByName[cfg.Metadata.Name], cfg)
+		set.Entries = append(set.Entries, SpecEntry{Kind: d.kind, File: d.file, Index: d.index, Documents: d.total, Environment: cfg, Diagnostics: envDiags})
+	}
+
+	resolveEnv := func(ref, envPath string) (*EnvironmentConfig, Diagnostics, error) {
+		if envs := envByFile[absPath(envPath)]; len(envs) == 1 {
+			return envs[0], nil, nil
+		}
+		if envs := envByName[ref]; len(envs) == 1 {
+			return envs[0], nil, nil
+		} else if len(envs) > 1 {
+			return nil, nil, fmt.Errorf("metadata.ref %q matches %d environments in the set", ref, len(envs))
+		}
+		return LoadEnvironmentConfigDiagnostics(envPath)
+	}
+	for _, d := range docs {
+		if d.kind != "Service" {
+			continue
+		}
+		svc, env, svcDiags := loadServiceDocument(d.file, d.data, d.index, d.doc, resolveEnv)
+		ds = append(ds, svcDiags...)
+		if svc == nil {
+			continue
+		}
+		set.Entries = append(set.Entries, SpecEntry{Kind: d.kind, File: d.file, Index: d.index, Documents: d.total, Service: svc, ServiceEnv: env, Diagnostics: svcDiags})
+	}
+
+	ds.Sort()
+	return set, ds, nil
+}
+
+func collectSpecFiles(paths []string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	add := func(path string) {
+		if abs := absPath(path); !seen[abs] {
+			seen[abs] = true
+			files = append(files, path)
+		}
+	}
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spec path %s: %w", root, err)
+		}
+		if !info.IsDir() {
+			add(root)
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".yaml", ".yml":
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk spec directory %s: %w", root, err)
+		}
+		sort.Strings(found)
+		for _, f := range found {
+			add(f)
+		}
+	}
+	return files, nil
+}
+
+// documentKind reads the top-level kind of a parsed document without decoding it.
+func documentKind(doc *yaml.Node) string {
+	if len(doc.Content) == 0 {
+		return ""
+	}
+	if k := mappingValue(doc.Content[0], "kind"); k != nil {
+		return k.Value
+	}
+	return ""
+}
+
+// composeTargets lists the absolute paths a document extends or imports. References that do not
+// resolve are left for the composer to report.
+func composeTargets(file string, doc *yaml.Node) []string {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := doc.Content[0]
+	var refs []string
+	if n := mappingValue(root, "extends"); n != nil && n.Kind == yaml.ScalarNode {
+		refs = append(refs, n.Value)
+	}
+	if n := mappingValue(root, "imports"); n != nil && n.Kind == yaml.SequenceNode {
+		for _, item := range n.Content {
+			if item.Kind == yaml.ScalarNode {
+				refs = append(refs, item.Value)
+			}
+		}
+	}
+	var out []string
+	for _, ref := range refs {
+		if target, err := resolveImport(filepath.Dir(file), ref); err == nil {
+			out = append(out, absPath(target))
+		}
+	}
+	return out
+}
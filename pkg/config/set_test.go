@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const setEnvSpec = `apiVersion: platform.io/v1
+kind: Environment
+metadata:
+  name: shared
+  org: acme
+  provider: aws
+environments:
+  dev:
+    account: "111111111111"
+    region: us-east-1
+modules:
+  - id: base
+    type: aws_base
+`
+
+func setServiceSpec(name, ref string) string {
+	return fmt.Sprintf(`apiVersion: platform.io/v1
+kind: Service
+metadata:
+  name: %s
+  ref: %s
+  envRef:
+    dev: {}
+modules:
+  - id: bucket
+    type: aws_s3
+`, name, ref)
+}
+
+func TestLoadSpecSetResolvesRefsWithinTheSet(t *testing.T) {
+	dir := t.TempDir()
+	writeSpec(t, filepath.Join(dir, "env.yaml"), setEnvSpec)
+	writeSpec(t, filepath.Join(dir, "services", "api.yaml"), setServiceSpec("api", "../env.yaml"))
+	// A multi-document file whose services reference the environment by name.
+	writeSpec(t, filepath.Join(dir, "services", "workers.yaml"),
+		setServiceSpec("worker-a", "shared")+"---\n"+setServiceSpec("worker-b", "shared"))
+	// Fragments pulled in through imports are not specs of their own.
+	writeSpec(t, filepath.Join(dir, "services", "common.yaml"), "kind: Service\nmodules:\n  - id: logs\n    type: aws_s3\n")
+	writeSpec(t, filepath.Join(dir, "services", "web.yaml"), "imports: [common.yaml]\n"+setServiceSpec("web", "../env.yaml"))
+	// Hidden directories and non-spec YAML are ignored.
+	writeSpec(t, filepath.Join(dir, ".pltf", "stale.yaml"), "kind: Service\n")
+	writeSpec(t, filepath.Join(dir, "ci.yml"), "steps: []\n")
+
+	set, ds, err := LoadSpecSet(dir)
+	if err != nil || ds.HasErrors() {
+		t.Fatalf("unexpected failure: err=%v diags=%+v", err, ds)
+	}
+	var names []string
+	for _, e := range set.Entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "shared,api,web,worker-a,worker-b" {
+		t.Fatalf("unexpected entries %s", got)
+	}
+
+	env := set.Environments()[0].Environment
+	for _, e := range set.Services() {
+		if e.ServiceEnv != env {
+			t.Fatalf("service %s should reuse the environment loaded from the set", e.Name())
+		}
+	}
+	if web := set.Services()[1].Service; len(web.Modules) != 2 {
+		t.Fatalf("imports should still compose within a set, got %+v", web.Modules)
+	}
+	if label := set.Services()[2].Label(); !strings.HasSuffix(label, "workers.yaml#1") {
+		t.Fatalf("multi-document entries should be labelled by position, got %s", label)
+	}
+}
+
+func TestLoadSpecSetReportsDiagnosticsPerDocument(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "all.yaml")
+	writeSpec(t, path, setEnvSpec+"---\n"+setServiceSpec("api", "shared")+"---\n"+
+		strings.Replace(setServiceSpec("bad", "shared"), "  name: bad\n", "  name: bad\n  nmae: typo\n", 1))
+
+	if !IsSpecSet(path) {
+		t.Fatalf("a multi-document file should load as a set")
+	}
+	set, ds, err := LoadSpecSet(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(set.Entries) != 2 {
+		t.Fatalf("expected the valid documents to load, got %d entries", len(set.Entries))
+	}
+	errs := ds.Errors()
+	if len(errs) != 1 || errs[0].Line != 30 || !strings.Contains(errs[0].Message, "nmae") {
+		t.Fatalf("expected unknown field reported at its line in the third document, got %+v", ds)
+	}
+
+	if _, err := LoadEnvironmentConfig(path); err == nil || !strings.Contains(err.Error(), "multiple YAML documents") {
+		t.Fatalf("single-spec loaders should still reject multi-document files, got %v", err)
+	}
+}
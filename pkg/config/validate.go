@@ -7,126 +7,163 @@ import (
 
 // Validate checks the EnvironmentConfig for structural issues.
 func (e *EnvironmentConfig) Validate() error {
+	return e.Diagnose().Err()
+}
+
+// Diagnose collects every structural error and lint warning in the EnvironmentConfig.
+func (e *EnvironmentConfig) Diagnose() Diagnostics {
+	var ds Diagnostics
 	if e.APIVersion == "" {
-		return fmt.Errorf("apiVersion is required")
+		ds.Errorf("apiVersion", "apiVersion is required")
 	}
 	if e.Kind != "Environment" {
-		return fmt.Errorf("kind must be 'Environment', got %q", e.Kind)
+		ds.Errorf("kind", "kind must be 'Environment', got %q", e.Kind)
 	}
 	if e.Metadata.Name == "" {
-		return fmt.Errorf("metadata.name is required")
+		ds.Errorf("metadata.name", "metadata.name is required")
 	}
 	if e.Metadata.Org == "" {
-		return fmt.Errorf("metadata.org is required")
+		ds.Errorf("metadata.org", "metadata.org is required")
 	}
 	if e.Metadata.Provider == "" {
-		return fmt.Errorf("metadata.provider is required")
+		ds.Errorf("metadata.provider", "metadata.provider is required")
 	}
 	if e.GitProvider == "" {
 		e.GitProvider = GitProviderGitHub
 	}
 	if err := validateGitProvider("metadata.gitProvider", string(e.GitProvider)); err != nil {
-		return err
+		ds.Errorf("gitProvider", "%s", err)
 	}
 
 	if len(e.Environments) == 0 {
-		return fmt.Errorf("at least one environment entry is required")
+		ds.Errorf("environments", "at least one environment entry is required")
 	}
 
-	for envName, envEntry := range e.Environments {
+	for _, envName := range sortedMapKeys(e.Environments) {
+		envEntry := e.Environments[envName]
 		if envEntry.Account == "" {
-			return fmt.Errorf("environments.%s.account is required", envName)
+			ds.Errorf("environments."+envName+".account", "environments.%s.account is required", envName)
 		}
 		if envEntry.Region == "" {
-			return fmt.Errorf("environments.%s.region is required", envName)
+			ds.Errorf("environments."+envName+".region", "environments.%s.region is required", envName)
 		}
+		_, overrideDiags := resolveModuleOverrides(e.Modules, envName, envEntry.Modules)
+		ds = append(ds, overrideDiags...)
+		diagnoseVariableValues("environments."+envName+".variables", envEntry.Variables, e.Variables, &ds)
 	}
+	diagnoseVariableSpecs("variables", e.Variables, &ds)
+	diagnoseApprovals("metadata.approve", e.Metadata.Approve, envKeySet(e.Environments), &ds)
 
-	if _, err := validateModules(e.Modules, "environment"); err != nil {
-		return err
-	}
-
-	return nil
+	diagnoseModules(e.Modules, "environment", &ds)
+	diagnoseWiring(e.Modules, e.DefaultProviders, "environment", false, &ds)
+	diagnoseRender(e.Render, &ds)
+	return ds
 }
 
 // Validate checks the ServiceConfig for structural issues.
 // If env is non-nil, it will also validate envRef entries against Environment.
 func (s *ServiceConfig) Validate(env *EnvironmentConfig) error {
+	return s.Diagnose(env).Err()
+}
+
+// Diagnose collects every structural error and lint warning in the ServiceConfig.
+// If env is non-nil, envRef entries are also checked against the Environment.
+func (s *ServiceConfig) Diagnose(env *EnvironmentConfig) Diagnostics {
+	var ds Diagnostics
 	if s.APIVersion == "" {
-		return fmt.Errorf("apiVersion is required")
+		ds.Errorf("apiVersion", "apiVersion is required")
 	}
 	if s.Kind != "Service" {
-		return fmt.Errorf("kind must be 'Service', got %q", s.Kind)
+		ds.Errorf("kind", "kind must be 'Service', got %q", s.Kind)
 	}
 	if s.Metadata.Name == "" {
-		return fmt.Errorf("metadata.name is required")
+		ds.Errorf("metadata.name", "metadata.name is required")
 	}
 	if s.Metadata.Ref == "" {
-		return fmt.Errorf("metadata.ref (path to environment) is required")
+		ds.Errorf("metadata.ref", "metadata.ref (path to environment) is required")
 	}
 	if s.GitProvider == "" {
 		s.GitProvider = GitProviderGitHub
 	}
 	if err := validateGitProvider("metadata.gitProvider", string(s.GitProvider)); err != nil {
-		return err
+		ds.Errorf("gitProvider", "%s", err)
 	}
 
 	if len(s.Metadata.EnvRef) == 0 {
-		return fmt.Errorf("metadata.envRef must define at least one environment (dev/prod, etc.)")
+		ds.Errorf("metadata.envRef", "metadata.envRef must define at least one environment (dev/prod, etc.)")
 	}
 
 	if env != nil {
-		for envName := range s.Metadata.EnvRef {
+		for _, envName := range sortedMapKeys(s.Metadata.EnvRef) {
 			if _, ok := env.Environments[envName]; !ok {
-				return fmt.Errorf("service envRef.%s has no matching environment in %s", envName, env.Metadata.Name)
+				ds.Errorf("metadata.envRef."+envName, "service envRef.%s has no matching environment in %s", envName, env.Metadata.Name)
 			}
 		}
 	}
-
-	if _, err := validateModules(s.Modules, "service"); err != nil {
-		return err
+	diagnoseVariableSpecs("variables", s.Variables, &ds)
+	svcEnvs := map[string]bool{}
+	for name := range s.Metadata.EnvRef {
+		svcEnvs[name] = true
+	}
+	diagnoseApprovals("metadata.approve", s.Metadata.Approve, svcEnvs, &ds)
+	specs := MergeVariableSpecs(env, s)
+	for _, envName := range sortedMapKeys(s.Metadata.EnvRef) {
+		diagnoseVariableValues("metadata.envRef."+envName+".variables", s.Metadata.EnvRef[envName].Variables, specs, &ds)
 	}
 
-	return nil
+	diagnoseModules(s.Modules, "service", &ds)
+	diagnoseWiring(s.Modules, s.DefaultProviders, "service", true, &ds)
+	diagnoseRender(s.Render, &ds)
+	return ds
 }
 
-// validateModules enforces module id/type presence, uniqueness, and link targets.
-func validateModules(mods []Module, context string) (map[string]struct{}, error) {
+// diagnoseModules records module id/type presence, uniqueness, and link target problems in ds.
+func diagnoseModules(mods []Module, context string, ds *Diagnostics) {
 	if len(mods) == 0 {
 		if context == "" {
-			return nil, fmt.Errorf("at least one module is required")
+			ds.Errorf("modules", "at least one module is required")
+		} else {
+			ds.Errorf("modules", "at least one module is required in %s", context)
 		}
-		return nil, fmt.Errorf("at least one module is required in %s", context)
+		return
 	}
 
 	ids := make(map[string]struct{})
-	for _, m := range mods {
+	for i, m := range mods {
+		path := fmt.Sprintf("modules[%d]", i)
 		if m.ID == "" {
-			return nil, fmt.Errorf("module id is required%s", contextSuffix(context))
+			ds.Errorf(path+".id", "module id is required%s", contextSuffix(context))
+			continue
 		}
 		if m.Type == "" {
-			return nil, fmt.Errorf("module %q type is required%s", m.ID, contextSuffix(context))
+			ds.Errorf(path+".type", "module %q type is required%s", m.ID, contextSuffix(context))
 		}
 		if _, exists := ids[m.ID]; exists {
-			return nil, fmt.Errorf("duplicate module id %q%s", m.ID, contextSuffix(context))
+			ds.Errorf(path+".id", "duplicate module id %q%s", m.ID, contextSuffix(context))
+			continue
 		}
 		ids[m.ID] = struct{}{}
 	}
+	diagnoseFanOut(mods, context, ds)
+	diagnosePreviousIDs(mods, context, ds)
+	diagnoseProtection(mods, context, ds)
+	diagnoseExpressions(mods, context, ds)
 
-	for _, m := range mods {
-		for access, targets := range m.Links {
+	for i, m := range mods {
+		for _, access := range sortedMapKeys(m.Links) {
+			targets := m.Links[access]
+			path := fmt.Sprintf("modules[%d].links.%s", i, access)
 			if len(targets) == 0 {
-				return nil, fmt.Errorf("module %q links.%s has no targets%s", m.ID, access, contextSuffix(context))
+				ds.Errorf(path, "module %q links.%s has no targets%s", m.ID, access, contextSuffix(context))
+				continue
 			}
 			for _, t := range targets {
 				if _, ok := ids[t]; !ok {
-					return nil, fmt.Errorf("module %q links.%s refers to unknown module %q%s", m.ID, access, t, contextSuffix(context))
+					ds.Errorf(path, "module %q links.%s refers to unknown module %q%s", m.ID, access, t, contextSuffix(context))
 				}
 			}
 		}
 	}
-
-	return ids, nil
 }
 
 func contextSuffix(context string) string {
@@ -147,3 +184,11 @@ func validateGitProvider(field, value string) error {
 		return fmt.Errorf("%s must be one of: github, gitlab, bitbucket", field)
 	}
 }
+
+func envKeySet(envs map[string]EnvironmentEntry) map[string]bool {
+	out := make(map[string]bool, len(envs))
+	for name := range envs {
+		out[name] = true
+	}
+	return out
+}
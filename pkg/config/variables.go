@@ -0,0 +1,279 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// VariableSpec declares a variable's type and constraints. Values set in environments.<env>.variables,
+// service envRef variables and --var are converted with Type instead of being guessed, so "007" stays
+// a string and a comma only splits a value declared as a list.
+type VariableSpec struct {
+	// Type is a Terraform type constraint (string, number, bool, list(string), map(string), ...);
+	// defaults to string.
+	Type        string `yaml:"type,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	// Allowed restricts the value (each element for lists) to these values.
+	Allowed []string `yaml:"allowed,omitempty"`
+	// Pattern is a regular expression every string value (each element for lists) must fully match.
+	Pattern string `yaml:"pattern,omitempty"`
+	// Default applies when no environment, service or --var sets the variable.
+	Default interface{} `yaml:"default,omitempty"`
+}
+
+// VariableValues holds variable values as written in the spec. Scalars are kept verbatim; YAML lists
+// and maps are stored as JSON so they can be converted to a declared list or map type.
+type VariableValues map[string]string
+
+// UnmarshalYAML accepts scalars as well as lists and maps.
+func (v *VariableValues) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: variables must be a mapping", node.Line)
+	}
+	out := make(VariableValues, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		if val.Kind == yaml.ScalarNode {
+			out[key.Value] = val.Value
+			continue
+		}
+		var decoded interface{}
+		if err := val.Decode(&decoded); err != nil {
+			return err
+		}
+		raw, err := RawVariableValue(decoded)
+		if err != nil {
+			return fmt.Errorf("line %d: variable %s: %w", val.Line, key.Value, err)
+		}
+		out[key.Value] = raw
+	}
+	*v = out
+	return nil
+}
+
+// RawVariableValue renders a decoded YAML value the way VariableValues stores it.
+func RawVariableValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case bool, int, int64, float64:
+		return fmt.Sprint(val), nil
+	}
+	data, err := json.Marshal(normalizeYAMLValue(v))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// normalizeYAMLValue converts map[interface{}]interface{} from older decoders into JSON-friendly maps.
+func normalizeYAMLValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[fmt.Sprint(k)] = normalizeYAMLValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = normalizeYAMLValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeYAMLValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+// CtyType parses Type, defaulting to string.
+func (s VariableSpec) CtyType() (cty.Type, error) {
+	if strings.TrimSpace(s.Type) == "" {
+		return cty.String, nil
+	}
+	return ParseInputType(s.Type)
+}
+
+// Convert turns a raw value into the declared type and checks allowed values and pattern. The result
+// uses the same Go types as decoded YAML (string, int64, float64, bool, []interface{}, map[string]interface{}).
+func (s VariableSpec) Convert(raw string) (interface{}, error) {
+	ty, err := s.CtyType()
+	if err != nil {
+		return nil, fmt.Errorf("invalid type %q: %w", s.Type, err)
+	}
+	value, err := convertVariable(raw, ty)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkValueType(value, ty, ""); err != nil {
+		return nil, err
+	}
+	if err := s.checkConstraints(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func convertVariable(raw string, ty cty.Type) (interface{}, error) {
+	trimmed := strings.TrimSpace(raw)
+	switch {
+	case ty == cty.String:
+		return raw, nil
+	case ty == cty.Number:
+		if i, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected number, got %q", raw)
+		}
+		return f, nil
+	case ty == cty.Bool:
+		b, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("expected bool, got %q", raw)
+		}
+		return b, nil
+	}
+
+	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(trimmed), &decoded); err != nil {
+			return nil, fmt.Errorf("invalid JSON for %s: %v", ty.FriendlyName(), err)
+		}
+		return decoded, nil
+	}
+	switch {
+	case ty.IsListType() || ty.IsSetType():
+		// "a, b" is shorthand for a list; an empty string is an empty list.
+		out := []interface{}{}
+		if trimmed == "" {
+			return out, nil
+		}
+		for _, part := range strings.Split(trimmed, ",") {
+			item, err := convertVariable(strings.TrimSpace(part), ty.ElementType())
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, item)
+		}
+		return out, nil
+	case ty == cty.DynamicPseudoType:
+		return raw, nil
+	}
+	return nil, fmt.Errorf("expected %s (JSON or YAML), got %q", ty.FriendlyName(), raw)
+}
+
+func (s VariableSpec) checkConstraints(value interface{}) error {
+	var pattern *regexp.Regexp
+	if s.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + s.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", s.Pattern, err)
+		}
+		pattern = re
+	}
+	check := func(v interface{}) error {
+		text := fmt.Sprint(v)
+		if len(s.Allowed) > 0 && !containsString(s.Allowed, text) {
+			return fmt.Errorf("value %q is not allowed (allowed: %s)", text, strings.Join(s.Allowed, ", "))
+		}
+		if pattern != nil {
+			if _, ok := v.(string); ok && !pattern.MatchString(text) {
+				return fmt.Errorf("value %q does not match pattern %s", text, s.Pattern)
+			}
+		}
+		return nil
+	}
+	if items, ok := value.([]interface{}); ok {
+		for _, item := range items {
+			if err := check(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, ok := value.(map[string]interface{}); ok {
+		return nil
+	}
+	return check(value)
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeVariableSpecs returns the declarations in scope for a stack: the Environment's, extended (and
+// overridden by name) by the Service's. Either argument may be nil.
+func MergeVariableSpecs(env *EnvironmentConfig, svc *ServiceConfig) map[string]VariableSpec {
+	out := map[string]VariableSpec{}
+	if env != nil {
+		for name, spec := range env.Variables {
+			out[name] = spec
+		}
+	}
+	if svc != nil {
+		for name, spec := range svc.Variables {
+			out[name] = spec
+		}
+	}
+	return out
+}
+
+// diagnoseVariableSpecs checks declarations (type, pattern, default) under path.
+func diagnoseVariableSpecs(path string, specs map[string]VariableSpec, ds *Diagnostics) {
+	for _, name := range sortedMapKeys(specs) {
+		spec := specs[name]
+		if _, err := spec.CtyType(); err != nil {
+			ds.Errorf(path+"."+name+".type", "variable %s: invalid type %q: %v", name, spec.Type, err)
+			continue
+		}
+		if spec.Pattern != "" {
+			if _, err := regexp.Compile(spec.Pattern); err != nil {
+				ds.Errorf(path+"."+name+".pattern", "variable %s: invalid pattern: %v", name, err)
+				continue
+			}
+		}
+		if spec.Default != nil {
+			raw, err := RawVariableValue(spec.Default)
+			if err == nil {
+				_, err = spec.Convert(raw)
+			}
+			if err != nil {
+				ds.Errorf(path+"."+name+".default", "variable %s default: %v", name, err)
+			}
+		}
+	}
+}
+
+// diagnoseVariableValues checks values under path against the declarations in specs. Undeclared
+// variables are not checked.
+func diagnoseVariableValues(path string, values map[string]string, specs map[string]VariableSpec, ds *Diagnostics) {
+	for _, name := range sortedMapKeys(values) {
+		spec, ok := specs[name]
+		if !ok {
+			continue
+		}
+		if _, err := spec.Convert(values[name]); err != nil {
+			ds.Errorf(path+"."+name, "variable %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariableSpecConvertUsesDeclaredType(t *testing.T) {
+	cases := []struct {
+		spec VariableSpec
+		raw  string
+		want interface{}
+	}{
+		{VariableSpec{}, "007", "007"},
+		{VariableSpec{Type: "string"}, "a,b", "a,b"},
+		{VariableSpec{Type: "number"}, "3", int64(3)},
+		{VariableSpec{Type: "bool"}, "true", true},
+		{VariableSpec{Type: "list(string)"}, "a, b", []interface{}{"a", "b"}},
+		{VariableSpec{Type: "list(string)"}, `["a","b"]`, []interface{}{"a", "b"}},
+		{VariableSpec{Type: "map(string)"}, `{"team":"core"}`, map[string]interface{}{"team": "core"}},
+	}
+	for _, c := range cases {
+		got, err := c.spec.Convert(c.raw)
+		if err != nil {
+			t.Fatalf("%s %q: unexpected error: %v", c.spec.Type, c.raw, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s %q: got %#v, want %#v", c.spec.Type, c.raw, got, c.want)
+		}
+	}
+
+	if _, err := (VariableSpec{Type: "number"}).Convert("abc"); err == nil {
+		t.Fatalf("expected a number conversion error")
+	}
+	if _, err := (VariableSpec{Allowed: []string{"small", "large"}}).Convert("medium"); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("expected allowed violation, got %v", err)
+	}
+	if _, err := (VariableSpec{Type: "list(string)", Pattern: "[a-z]+"}).Convert("ok, NOT"); err == nil || !strings.Contains(err.Error(), "pattern") {
+		t.Fatalf("expected pattern violation for a list element, got %v", err)
+	}
+}
+
+func TestEnvironmentDiagnosesTypedVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.yaml")
+	writeSpec(t, path, `apiVersion: platform.io/v1
+kind: Environment
+metadata:
+  name: shared
+  org: acme
+  provider: aws
+variables:
+  zones:
+    type: list(string)
+  size:
+    allowed: [small, large]
+    default: small
+  replicas:
+    type: number
+    default: many
+environments:
+  dev:
+    account: "111111111111"
+    region: us-east-1
+    variables:
+      zones: [us-east-1a, us-east-1b]
+      size: medium
+modules:
+  - id: base
+    type: aws_base
+`)
+	cfg, ds, err := LoadEnvironmentConfigDiagnostics(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Environments["dev"].Variables["zones"]; got != `["us-east-1a","us-east-1b"]` {
+		t.Fatalf("YAML lists should be kept as JSON, got %q", got)
+	}
+	var paths []string
+	for _, d := range ds.Errors() {
+		paths = append(paths, d.Path)
+	}
+	if got := strings.Join(paths, ","); got != "variables.replicas.default,environments.dev.variables.size" {
+		t.Fatalf("unexpected error paths %s (%+v)", got, ds)
+	}
+}
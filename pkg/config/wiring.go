@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// wireSourcePattern matches <id>.<output>, <id>["key"].<output> and <id>[0].<output>, optionally
+// written with a module. prefix.
+var wireSourcePattern = regexp.MustCompile(`^(module\.)?([A-Za-z0-9_-]+)(?:\[(?:"([^"]*)"|(\d+))\])?\.([A-Za-z0-9_]+)$`)
+
+// WireSource is where a wired input comes from: an output of a module in the same spec or, in a
+// Service, an output of the parent Environment.
+type WireSource struct {
+	// Module is the module id; empty for parent outputs.
+	Module string
+	// Key addresses one instance of a module with forEach (a quoted key) or count (an index).
+	Key     string
+	Keyed   bool
+	Numeric bool
+	Output  string
+}
+
+// Parent reports whether the source is a parent Environment output.
+func (w WireSource) Parent() bool {
+	return w.Module == ""
+}
+
+// String returns the source as a reference: module.<id>[key].<output> or parent.<output>.
+func (w WireSource) String() string {
+	if w.Parent() {
+		return "parent." + w.Output
+	}
+	ref := "module." + w.Module
+	switch {
+	case w.Keyed && w.Numeric:
+		ref += "[" + w.Key + "]"
+	case w.Keyed:
+		ref += "[" + strconv.Quote(w.Key) + "]"
+	}
+	return ref + "." + w.Output
+}
+
+// ParseWireSource parses a wire: value. "parent.<output>" names a parent output; write
+// "module.parent.<output>" for a module whose id is parent.
+func ParseWireSource(s string) (WireSource, error) {
+	sm := wireSourcePattern.FindStringSubmatch(s)
+	if sm == nil {
+		return WireSource{}, fmt.Errorf("%q is not a module output; use <module>.<output>, <module>[\"key\"].<output> or parent.<output>", s)
+	}
+	w := WireSource{Module: sm[2], Key: sm[3] + sm[4], Keyed: sm[3] != "" || sm[4] != "", Numeric: sm[4] != "", Output: sm[5]}
+	if sm[1] == "" && w.Module == "parent" {
+		if w.Keyed {
+			return WireSource{}, fmt.Errorf("%q: parent outputs have no instances", s)
+		}
+		w.Module = ""
+	}
+	return w, nil
+}
+
+// diagnoseWiring checks module wire: entries and the spec's defaultProviders. Modules may only
+// wire from modules of the same spec; Services may also wire from parent outputs.
+func diagnoseWiring(mods []Module, defaults map[string]string, context string, isService bool, ds *Diagnostics) {
+	byID := make(map[string]Module, len(mods))
+	for _, m := range mods {
+		byID[m.ID] = m
+	}
+	for i, m := range mods {
+		for _, input := range sortedMapKeys(m.Wire) {
+			path := fmt.Sprintf("modules[%d].wire.%s", i, input)
+			src, err := ParseWireSource(m.Wire[input])
+			if err != nil {
+				ds.Errorf(path, "module %q wire.%s: %v%s", m.ID, input, err, contextSuffix(context))
+				continue
+			}
+			if _, ok := m.Inputs[input]; ok {
+				ds.Errorf(path, "module %q sets input %q in both inputs and wire%s", m.ID, input, contextSuffix(context))
+			}
+			if src.Parent() {
+				if !isService {
+					ds.Errorf(path, "module %q wire.%s: parent outputs are only available in Service specs%s", m.ID, input, contextSuffix(context))
+				}
+				continue
+			}
+			target, ok := byID[src.Module]
+			switch {
+			case !ok:
+				ds.Errorf(path, "module %q wire.%s refers to unknown module %q%s", m.ID, input, src.Module, contextSuffix(context))
+			case src.Module == m.ID:
+				ds.Errorf(path, "module %q wire.%s refers to the module itself%s", m.ID, input, contextSuffix(context))
+			case src.Keyed && !target.FanOut():
+				ds.Errorf(path, "module %q wire.%s indexes %q, which has no forEach or count%s", m.ID, input, src.Module, contextSuffix(context))
+			case src.Keyed && src.Numeric != (target.Count != nil):
+				ds.Errorf(path, "module %q wire.%s: address %q instances with %s%s", m.ID, input, src.Module, instanceKeyHint(target), contextSuffix(context))
+			case !src.Keyed && target.FanOut() && target.Expand:
+				ds.Errorf(path, "module %q wire.%s: %q is expanded into one module per instance; pick one with %s%s", m.ID, input, src.Module, instanceKeyHint(target), contextSuffix(context))
+			}
+		}
+	}
+	for _, name := range sortedMapKeys(defaults) {
+		id := defaults[name]
+		target, ok := byID[id]
+		switch {
+		case !ok:
+			ds.Errorf("defaultProviders."+name, "defaultProviders.%s refers to unknown module %q%s", name, id, contextSuffix(context))
+		case target.FanOut():
+			ds.Errorf("defaultProviders."+name, "defaultProviders.%s: module %q fans out with forEach or count and cannot be a default provider%s", name, id, contextSuffix(context))
+		}
+	}
+}
+
+func instanceKeyHint(m Module) string {
+	if m.Count != nil {
+		return fmt.Sprintf("an index, e.g. %s[0]", m.ID)
+	}
+	return fmt.Sprintf("a key, e.g. %s[\"<key>\"]", m.ID)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWireSource(t *testing.T) {
+	cases := map[string]string{
+		"network.id":           "module.network.id",
+		"module.network.id":    "module.network.id",
+		`queues["orders"].arn`: `module.queues["orders"].arn`,
+		"workers[2].role_arn":  "module.workers[2].role_arn",
+		"parent.vpc_id":        "parent.vpc_id",
+		"module.parent.vpc_id": "module.parent.vpc_id",
+		"my-db.endpoint":       "module.my-db.endpoint",
+	}
+	for in, want := range cases {
+		src, err := ParseWireSource(in)
+		if err != nil {
+			t.Fatalf("ParseWireSource(%q) error: %v", in, err)
+		}
+		if got := src.String(); got != want {
+			t.Fatalf("ParseWireSource(%q) = %s, want %s", in, got, want)
+		}
+	}
+	for _, bad := range []string{"network", "network.outputs.id", "${module.network.id}", `parent["x"].id`} {
+		if _, err := ParseWireSource(bad); err == nil {
+			t.Fatalf("ParseWireSource(%q) should fail", bad)
+		}
+	}
+}
+
+func TestDiagnoseWiring(t *testing.T) {
+	two := 2
+	mods := []Module{
+		{ID: "net", Type: "aws_base"},
+		{ID: "workers", Type: "aws_iam_role", Count: &two},
+		{ID: "app", Type: "aws_eks", Inputs: map[string]interface{}{"vpc_id": "x"}, Wire: map[string]string{
+			"vpc_id":      "net.vpc_id",
+			"cluster":     "missing.name",
+			"role_arn":    `workers["a"].arn`,
+			"self":        "app.id",
+			"domain":      "parent.domain",
+			"subnet_ids":  "net.private_subnet_ids",
+			"worker_arns": "workers.arn",
+		}},
+	}
+	var ds Diagnostics
+	diagnoseWiring(mods, map[string]string{"vpc_id": "nope", "role_arn": "workers"}, "environment", false, &ds)
+	got := ds.Err().Error()
+	for _, want := range []string{
+		`sets input "vpc_id" in both inputs and wire`,
+		`wire.cluster refers to unknown module "missing"`,
+		`wire.role_arn: address "workers" instances with an index`,
+		`wire.self refers to the module itself`,
+		`parent outputs are only available in Service specs`,
+		`defaultProviders.vpc_id refers to unknown module "nope"`,
+		`defaultProviders.role_arn: module "workers" fans out`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in diagnostics:\n%s", want, got)
+		}
+	}
+	if n := len(ds.Errors()); n != 7 {
+		t.Fatalf("expected 7 errors, got %d:\n%s", n, got)
+	}
+
+	var svc Diagnostics
+	diagnoseWiring([]Module{{ID: "api", Type: "aws_lambda", Wire: map[string]string{"domain": "parent.domain"}}}, nil, "service", true, &svc)
+	if len(svc) != 0 {
+		t.Fatalf("services may wire from parent outputs, got %v", svc)
+	}
+}
+
+func TestValidateModuleInputsChecksWireOutputs(t *testing.T) {
+	metas := map[string]*ModuleMetadata{
+		"net": {Type: "net", Outputs: []OutputSpec{{Name: "vpc_id"}}},
+		"app": {Type: "app", Inputs: []InputSpec{{Name: "network_id"}}},
+	}
+	mods := []Module{
+		{ID: "net", Type: "net"},
+		{ID: "app", Type: "app", Wire: map[string]string{"network_id": "net.vpc_ix", "netwrk_id": "net.vpc_id"}},
+	}
+	err := ValidateModuleInputs(mods, metas, "")
+	verr, ok := err.(*InputValidationError)
+	if !ok || len(verr.Problems) != 2 {
+		t.Fatalf("expected two problems, got %v", err)
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, `has no output "vpc_ix" (did you mean "vpc_id"?)`) || !strings.Contains(msg, `"netwrk_id": is wired but not declared`) {
+		t.Fatalf("unexpected problems:\n%s", msg)
+	}
+	if got := verr.Diagnostics()[0].Path; !strings.HasPrefix(got, "modules[1].wire.") {
+		t.Fatalf("expected wire paths, got %q", got)
+	}
+}
@@ -48,10 +48,10 @@ func (a *AWS) Backend(body *hclwrite.Body, bucket, key, region, profile, _, _ st
 }
 
 // Provider adds the AWS provider to the HCL body.
-func (a *AWS) Provider(body *hclwrite.Body, region, _ string) {
+func (a *AWS) Provider(body *hclwrite.Body, region, _ hclwrite.Tokens) {
 	provBlock := body.AppendNewBlock("provider", []string{"aws"})
 	provBody := provBlock.Body()
-	provBody.SetAttributeValue("region", cty.StringVal(region))
+	provBody.SetAttributeRaw("region", region)
 	dt := provBody.AppendNewBlock("default_tags", nil)
 	tags := dt.Body()
 	tags.SetAttributeRaw("tags", provider.DefaultTagsTokens())
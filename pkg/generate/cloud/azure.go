@@ -53,9 +53,9 @@ func (a *Azure) Backend(body *hclwrite.Body, bucket, key, _, _, container, resou
 }
 
 // Provider adds the Azure provider to the HCL body.
-func (a *Azure) Provider(body *hclwrite.Body, _, subscriptionID string) {
+func (a *Azure) Provider(body *hclwrite.Body, _, subscriptionID hclwrite.Tokens) {
 	provBlock := body.AppendNewBlock("provider", []string{"azurerm"})
 	provBody := provBlock.Body()
-	provBody.SetAttributeValue("subscription_id", cty.StringVal(subscriptionID))
+	provBody.SetAttributeRaw("subscription_id", subscriptionID)
 	provBody.SetAttributeValue("features", cty.ObjectVal(map[string]cty.Value{}))
 }
@@ -43,9 +43,9 @@ func (g *GCP) Backend(body *hclwrite.Body, bucket, key, _, _, _, _ string) {
 }
 
 // Provider adds the GCP provider to the HCL body.
-func (g *GCP) Provider(body *hclwrite.Body, region, project string) {
+func (g *GCP) Provider(body *hclwrite.Body, region, project hclwrite.Tokens) {
 	provBlock := body.AppendNewBlock("provider", []string{"google"})
 	provBody := provBlock.Body()
-	provBody.SetAttributeValue("project", cty.StringVal(project))
-	provBody.SetAttributeValue("region", cty.StringVal(region))
+	provBody.SetAttributeRaw("project", project)
+	provBody.SetAttributeRaw("region", region)
 }
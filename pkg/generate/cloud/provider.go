@@ -11,8 +11,9 @@ type Provider interface {
 	RequiredProviders(body *hclwrite.Body, needsK8s bool, needsHelm bool)
 	// Backend returns the HCL block for the `backend` section in `versions.tf`.
 	Backend(body *hclwrite.Body, bucket, key, region, profile, container, resourceGroup string)
-	// Provider returns the HCL block for the `provider` section in `providers.tf`.
-	Provider(body *hclwrite.Body, region, account string)
+	// Provider returns the HCL block for the `provider` section in `providers.tf`. region and
+	// account are expressions: literals, or variable references for stacks rendered with tfvars.
+	Provider(body *hclwrite.Body, region, account hclwrite.Tokens)
 }
 
 // New returns a new cloud provider based on the given provider name.
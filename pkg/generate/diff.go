@@ -0,0 +1,216 @@
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	diffContext = 3
+	// diffMaxCells bounds the LCS table; larger changes are shown as one replaced block.
+	diffMaxCells = 4_000_000
+)
+
+// Drift is how an existing output directory differs from what its spec renders today.
+type Drift struct {
+	Changes
+	// Diff is a unified diff from the output directory (a/) to the fresh rendering (b/).
+	Diff string
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns a unified diff turning old into new, or "" when they are equal. oldName and
+// newName label the ---/+++ lines; a nil side is shown as /dev/null.
+func unifiedDiff(oldName, newName string, old, new []byte) string {
+	if bytes.Equal(old, new) {
+		return ""
+	}
+	if old == nil {
+		oldName = "/dev/null"
+	}
+	if new == nil {
+		newName = "/dev/null"
+	}
+	if bytes.IndexByte(old, 0) >= 0 || bytes.IndexByte(new, 0) >= 0 {
+		return fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+	}
+
+	ops := diffLines(splitLines(old), splitLines(new))
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change and grow the hunk while changes are within 2*context lines.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		from := max(first-diffContext, start)
+		to := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				to = i + 1
+			} else if i-to >= 2*diffContext {
+				break
+			}
+		}
+		to = min(to+diffContext, len(ops))
+		writeHunk(&b, ops, from, to)
+		start = to
+	}
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, ops []diffOp, from, to int) {
+	oldStart, newStart := 1, 1
+	for _, op := range ops[:from] {
+		if op.kind != '+' {
+			oldStart++
+		}
+		if op.kind != '-' {
+			newStart++
+		}
+	}
+	oldLen, newLen := 0, 0
+	for _, op := range ops[from:to] {
+		if op.kind != '+' {
+			oldLen++
+		}
+		if op.kind != '-' {
+			newLen++
+		}
+	}
+	// An empty side starts at the line before it, as in diff -u.
+	if oldLen == 0 {
+		oldStart--
+	}
+	if newLen == 0 {
+		newStart--
+	}
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+	for _, op := range ops[from:to] {
+		b.WriteByte(op.kind)
+		b.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// splitLines splits data after each newline, keeping the newline so a missing final one shows.
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the edit script from a to b. Common leading and trailing lines are matched
+// directly and the middle by longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	var head, tail []diffOp
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		head = append(head, diffOp{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		tail = append([]diffOp{{' ', a[len(a)-1]}}, tail...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	ops := head
+	if len(a)*len(b) > diffMaxCells {
+		for _, l := range a {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range b {
+			ops = append(ops, diffOp{'+', l})
+		}
+		return append(ops, tail...)
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return append(ops, tail...)
+}
+
+// treeDiff renders a unified diff for every file in c, from the existing output directory (a/) to
+// the fresh rendering (b/).
+func treeDiff(rendered, existing string, c Changes) (string, error) {
+	read := func(root, rel string) ([]byte, error) {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+		if err == nil && data == nil {
+			data = []byte{}
+		}
+		return data, err
+	}
+	var b strings.Builder
+	for _, rel := range c.Added {
+		data, err := read(rendered, rel)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(unifiedDiff("a/"+rel, "b/"+rel, nil, data))
+	}
+	for _, rel := range c.Updated {
+		old, err := read(existing, rel)
+		if err != nil {
+			return "", err
+		}
+		data, err := read(rendered, rel)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(unifiedDiff("a/"+rel, "b/"+rel, old, data))
+	}
+	for _, rel := range c.Removed {
+		old, err := read(existing, rel)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(unifiedDiff("a/"+rel, "b/"+rel, old, nil))
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,45 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiffHunks(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	new := "a\nb\nC\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	got := unifiedDiff("a/x.tf", "b/x.tf", []byte(old), []byte(new))
+	want := `--- a/x.tf
++++ b/x.tf
+@@ -1,6 +1,6 @@
+ a
+ b
+-c
++C
+ d
+ e
+ f
+@@ -10,3 +10,4 @@
+ j
+ k
+ l
++m
+`
+	if got != want {
+		t.Fatalf("unexpected diff:\n%s", got)
+	}
+	if d := unifiedDiff("a/x.tf", "b/x.tf", []byte(old), []byte(old)); d != "" {
+		t.Fatalf("expected no diff for equal content, got %q", d)
+	}
+}
+
+func TestUnifiedDiffAddedAndRemovedFiles(t *testing.T) {
+	added := unifiedDiff("a/new.tf", "b/new.tf", nil, []byte("x\n"))
+	if !strings.HasPrefix(added, "--- /dev/null\n+++ b/new.tf\n@@ -0,0 +1,1 @@\n+x\n") {
+		t.Fatalf("unexpected diff for an added file:\n%s", added)
+	}
+	removed := unifiedDiff("a/old.tf", "b/old.tf", []byte("x"), nil)
+	if !strings.Contains(removed, "+++ /dev/null\n@@ -1,1 +0,0 @@\n-x\n\\ No newline at end of file\n") {
+		t.Fatalf("unexpected diff for a removed file:\n%s", removed)
+	}
+}
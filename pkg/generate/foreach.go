@@ -0,0 +1,158 @@
+package generate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"pltf/pkg/config"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var (
+	eachExprPattern = regexp.MustCompile(`^(each\.(key|value)(\.[a-zA-Z0-9_]+)*|count\.index)$`)
+	eachInCount     = regexp.MustCompile(`\beach\.(key|value)\b`)
+)
+
+// indexFanOut records the modules of mods that fan out: expanded groups so references to
+// module.<id>["key"] can be pointed at the instance's own block, and the rest as groups rendered
+// with Terraform for_each/count.
+func (g *Generator) indexFanOut(mods []config.Module) {
+	for _, m := range mods {
+		if !m.FanOut() {
+			continue
+		}
+		if m.Expand {
+			g.expandedGroups[m.ID] = m
+		} else {
+			g.forEachGroups[m.ID] = m
+		}
+	}
+}
+
+// instanceView returns mods with each for_each group replaced by one module per instance, addressed
+// as <id>["key"] (or <id>[index]) with each.* substituted. Input validation and IAM augmentation
+// use it so they see every instance.
+func (g *Generator) instanceView(mods []config.Module) ([]config.Module, error) {
+	out := make([]config.Module, 0, len(mods))
+	for _, m := range mods {
+		if _, ok := g.forEachGroups[m.ID]; !ok {
+			out = append(out, m)
+			continue
+		}
+		insts, err := m.Instances()
+		if err != nil {
+			return nil, err
+		}
+		for _, inst := range insts {
+			im, err := m.ExpandInstance(inst)
+			if err != nil {
+				return nil, err
+			}
+			im.ID = m.InstanceRef(inst.Key)
+			out = append(out, im)
+		}
+	}
+	return out, nil
+}
+
+// writeFanOut adds for_each (a map of instance key to each.value) or count to a group's block.
+func (g *Generator) writeFanOut(body *hclwrite.Body, m config.Module) error {
+	if m.Count != nil {
+		body.SetAttributeValue("count", cty.NumberIntVal(int64(*m.Count)))
+		return nil
+	}
+	insts, err := m.Instances()
+	if err != nil {
+		return err
+	}
+	values := make(map[string]interface{}, len(insts))
+	for _, inst := range insts {
+		values[inst.Key] = inst.Value
+	}
+	tokens, err := g.valueToTokens(values)
+	if err != nil {
+		return fmt.Errorf("module %q forEach: %w", m.ID, err)
+	}
+	body.SetAttributeRaw("for_each", tokens)
+	return nil
+}
+
+// countIndexInputs rewrites each.key/each.value to count.index in a count group's inputs, since
+// Terraform only provides each for for_each.
+func countIndexInputs(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return eachInCount.ReplaceAllString(val, "count.index")
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = countIndexInputs(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = countIndexInputs(item)
+		}
+		return out
+	}
+	return v
+}
+
+// moduleRef resolves a module reference with an optional instance key (quoted for forEach,
+// numeric for count) to the traversal that reads output. Keys of expanded groups address the
+// instance's own block.
+func (g *Generator) moduleRef(id, key string, numeric, keyed bool, output string) hcl.Traversal {
+	if keyed {
+		if group, ok := g.expandedGroups[id]; ok {
+			id = group.InstanceID(key)
+			keyed = false
+		}
+	}
+	trav := hcl.Traversal{hcl.TraverseRoot{Name: "module"}, hcl.TraverseAttr{Name: id}}
+	if keyed {
+		idx := cty.StringVal(key)
+		if numeric {
+			n, _ := strconv.ParseInt(key, 10, 64)
+			idx = cty.NumberIntVal(n)
+		}
+		trav = append(trav, hcl.TraverseIndex{Key: idx})
+	}
+	return append(trav, hcl.TraverseAttr{Name: output})
+}
+
+// depID is the module block a reference depends on: the instance's own block for expanded groups.
+func (g *Generator) depID(id, key string, keyed bool) string {
+	if group, ok := g.expandedGroups[id]; ok && keyed {
+		return group.InstanceID(key)
+	}
+	return id
+}
+
+// fanOutOutputTokens collects an output across a group's instances: a map keyed by instance key
+// for for_each, a list for count.
+func fanOutOutputTokens(m config.Module, output string) hclwrite.Tokens {
+	src := fmt.Sprintf("{ for k, m in module.%s : k => m.%s }", m.ID, output)
+	if m.Count != nil {
+		src = fmt.Sprintf("[for m in module.%s : m.%s]", m.ID, output)
+	}
+	return exprTokens(src)
+}
+
+// exprTokens lexes an HCL expression into hclwrite tokens.
+func exprTokens(src string) hclwrite.Tokens {
+	lexed, _ := hclsyntax.LexExpression([]byte(src), "", hcl.InitialPos)
+	out := make(hclwrite.Tokens, 0, len(lexed))
+	for _, t := range lexed {
+		if t.Type == hclsyntax.TokenEOF {
+			continue
+		}
+		out = append(out, &hclwrite.Token{Type: t.Type, Bytes: t.Bytes})
+	}
+	return out
+}
@@ -2,24 +2,72 @@ package generate
 
 import "pltf/pkg/config"
 
-// GenerateEnvironmentTF renders Terraform for a single environment entry in an Environment config.
-func GenerateEnvironmentTF(envCfg *config.EnvironmentConfig, embeddedRoot, customRoot, envName, outDir, specDir string, cliVars map[string]string) error {
+// GenerateEnvironmentTF renders Terraform for a single environment entry in an Environment config
+// and reports which files in outDir changed.
+func GenerateEnvironmentTF(envCfg *config.EnvironmentConfig, embeddedRoot, customRoot, envName, outDir, specDir string, cliVars map[string]string) (Changes, error) {
 	g, err := NewGenerator(envCfg, nil, embeddedRoot, customRoot, envName, outDir, specDir, cliVars)
 	if err != nil {
-		return err
+		return Changes{}, err
 	}
-	return g.Generate()
+	if err := g.Generate(); err != nil {
+		return Changes{}, err
+	}
+	return g.Changes(), nil
+}
+
+// CheckEnvironmentTF renders an environment into a temporary directory and reports how outDir
+// differs from it, without changing outDir.
+func CheckEnvironmentTF(envCfg *config.EnvironmentConfig, embeddedRoot, customRoot, envName, outDir, specDir string, cliVars map[string]string) (Drift, error) {
+	g, err := NewGenerator(envCfg, nil, embeddedRoot, customRoot, envName, outDir, specDir, cliVars)
+	if err != nil {
+		return Drift{}, err
+	}
+	return g.Check()
+}
+
+// DiagnoseEnvironmentGraph reports cycles and unreachable references in the module dependency
+// graph of an environment entry; see Generator.DiagnoseGraph.
+func DiagnoseEnvironmentGraph(envCfg *config.EnvironmentConfig, embeddedRoot, customRoot, envName, specDir string, cliVars map[string]string) (config.Diagnostics, error) {
+	g, err := NewGenerator(envCfg, nil, embeddedRoot, customRoot, envName, "", specDir, cliVars)
+	if err != nil {
+		return nil, err
+	}
+	return g.DiagnoseGraph()
 }
 
 // =====================
 // Service
 // =====================
 
-// GenerateServiceTF renders Terraform for a service envRef entry using its referenced Environment.
-func GenerateServiceTF(svcCfg *config.ServiceConfig, envCfg *config.EnvironmentConfig, embeddedRoot, customRoot, envName, outDir, specDir string, cliVars map[string]string) error {
+// GenerateServiceTF renders Terraform for a service envRef entry using its referenced Environment
+// and reports which files in outDir changed.
+func GenerateServiceTF(svcCfg *config.ServiceConfig, envCfg *config.EnvironmentConfig, embeddedRoot, customRoot, envName, outDir, specDir string, cliVars map[string]string) (Changes, error) {
 	g, err := NewGenerator(envCfg, svcCfg, embeddedRoot, customRoot, envName, outDir, specDir, cliVars)
 	if err != nil {
-		return err
+		return Changes{}, err
+	}
+	if err := g.Generate(); err != nil {
+		return Changes{}, err
+	}
+	return g.Changes(), nil
+}
+
+// CheckServiceTF renders a service envRef entry into a temporary directory and reports how outDir
+// differs from it, without changing outDir.
+func CheckServiceTF(svcCfg *config.ServiceConfig, envCfg *config.EnvironmentConfig, embeddedRoot, customRoot, envName, outDir, specDir string, cliVars map[string]string) (Drift, error) {
+	g, err := NewGenerator(envCfg, svcCfg, embeddedRoot, customRoot, envName, outDir, specDir, cliVars)
+	if err != nil {
+		return Drift{}, err
+	}
+	return g.Check()
+}
+
+// DiagnoseServiceGraph reports cycles and unreachable references in the module dependency graph
+// of a service envRef entry; see Generator.DiagnoseGraph.
+func DiagnoseServiceGraph(svcCfg *config.ServiceConfig, envCfg *config.EnvironmentConfig, embeddedRoot, customRoot, envName, specDir string, cliVars map[string]string) (config.Diagnostics, error) {
+	g, err := NewGenerator(envCfg, svcCfg, embeddedRoot, customRoot, envName, "", specDir, cliVars)
+	if err != nil {
+		return nil, err
 	}
-	return g.Generate()
+	return g.DiagnoseGraph()
 }
@@ -10,6 +10,7 @@ import (
 
 	"pltf/pkg/augment"
 	"pltf/pkg/config"
+	"pltf/pkg/secrets"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
@@ -18,13 +19,12 @@ import (
 )
 
 var (
-	moduleRefPattern     = regexp.MustCompile(`^module\.([a-zA-Z0-9_.-]+)\.([a-zA-Z0-9_]+)$`)
+	moduleRefPattern     = regexp.MustCompile(`^module\.([a-zA-Z0-9_.-]+?)(?:\[(?:"([^"]*)"|(\d+))\])?\.([a-zA-Z0-9_]+)$`)
 	varRefPattern        = regexp.MustCompile(`^var\.([a-zA-Z0-9_]+)$`)
 	parentRefPattern     = regexp.MustCompile(`^parent\.([a-zA-Z0-9_]+)$`)
 	interpolationPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
 	templatePattern      = regexp.MustCompile(`\$\{\{([^}]+)\}\}`)
-	fullExprPattern      = regexp.MustCompile(`^\s*\$\{\{?\s*(.+?)\s*\}?\}\s*$`)
-	moduleRefAnywhere    = regexp.MustCompile(`module\.([a-zA-Z0-9_.-]+)\.[a-zA-Z0-9_]+`)
+	moduleRefAnywhere    = regexp.MustCompile(`module\.([a-zA-Z0-9_.-]+?)(?:\[(?:"([^"]*)"|(\d+))\])?\.([a-zA-Z0-9_]+)`)
 	curlyContentPattern  = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
 	identifierPattern    = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
 	outputNameCleaner    = regexp.MustCompile(`[^A-Za-z0-9_]`)
@@ -54,6 +54,17 @@ type Generator struct {
 	// service only
 	svcEnvEntry config.ServiceEnvRefEntry
 
+	// environment modules after per-environment overrides (enabled/inputs) for envKey, with
+	// expand: true groups replaced by their instances
+	envModules []config.Module
+	// service modules with expand: true groups replaced by their instances
+	svcModules []config.Module
+
+	// modules that fan out, by id: rendered with Terraform for_each/count, or expanded into
+	// one block per instance
+	forEachGroups  map[string]config.Module
+	expandedGroups map[string]config.Module
+
 	// all modules in scope: env + service for service stacks
 	allModules []config.Module
 
@@ -65,6 +76,9 @@ type Generator struct {
 	// map output name -> list of module IDs that provide it
 	outputProviders map[string][]string
 
+	// map capability -> module outputs tagged with it
+	capabilityProviders map[string][]capabilityProvider
+
 	// map module id -> metadata
 	moduleMetas map[string]*config.ModuleMetadata
 
@@ -74,12 +88,27 @@ type Generator struct {
 	// merged locals/vars for this run (env + service + CLI)
 	mergedVars map[string]interface{}
 
+	// how merged vars are rendered: config.VariablesLocals or config.VariablesTfvars
+	variableMode string
+
+	// secrets in scope for this run (env + service envRef)
+	secretRefs map[string]config.SecretRef
+
 	iamAugmentations map[string]augment.Augmentation
 
 	globalLabels map[string]string
 
-	// module dependencies (module id -> set of module ids it depends on)
-	moduleDeps map[string]map[string]struct{}
+	// module dependencies (module id -> module ids it depends on -> why)
+	moduleDeps map[string]map[string]depReason
+	// references that cannot become edges: to the module itself, to modules outside the stack
+	badRefs []moduleReference
+	// where each rendered module input comes from (module id -> sources), written to SourceMapFile
+	sources map[string]ModuleSources
+	// spec entry of every module block in scope, built on first use
+	specModules map[string]specModule
+
+	// what the last Generate changed in outDir
+	changes Changes
 }
 
 // NewGenerator builds a Generator with loaded module metadata, output wiring, and env/service context.
@@ -116,7 +145,10 @@ func NewGenerator(
 		cliVars:             cliVars,
 		isService:           svcCfg != nil,
 		moduleScopes:        map[string]moduleScope{},
-		moduleDeps:          map[string]map[string]struct{}{},
+		moduleDeps:          map[string]map[string]depReason{},
+		sources:             map[string]ModuleSources{},
+		forEachGroups:       map[string]config.Module{},
+		expandedGroups:      map[string]config.Module{},
 	}
 
 	if strings.TrimSpace(customRoot) != "" {
@@ -133,6 +165,15 @@ func NewGenerator(
 	if strings.TrimSpace(g.envEntry.Region) == "" {
 		return nil, fmt.Errorf("environment %q region is required", envName)
 	}
+	// Apply per-environment module overrides before any wiring sees the module list.
+	envModules, err := envCfg.ModulesFor(envName)
+	if err != nil {
+		return nil, err
+	}
+	g.indexFanOut(envModules)
+	if g.envModules, err = config.ExpandModules(envModules); err != nil {
+		return nil, err
+	}
 
 	// Get service env if applicable
 	if g.isService {
@@ -141,18 +182,22 @@ func NewGenerator(
 			return nil, fmt.Errorf("service envRef.%q not found in service %q", envName, svcCfg.Metadata.Name)
 		}
 		g.svcEnvEntry = svcEnvEntry
-		g.allModules = append(g.allModules, envCfg.Modules...)
-		g.allModules = append(g.allModules, svcCfg.Modules...)
+		g.indexFanOut(svcCfg.Modules)
+		if g.svcModules, err = config.ExpandModules(svcCfg.Modules); err != nil {
+			return nil, err
+		}
+		g.allModules = append(g.allModules, g.envModules...)
+		g.allModules = append(g.allModules, g.svcModules...)
 
-		for _, mod := range envCfg.Modules {
+		for _, mod := range g.envModules {
 			g.moduleScopes[mod.ID] = scopeEnv
 		}
-		for _, mod := range svcCfg.Modules {
+		for _, mod := range g.svcModules {
 			g.moduleScopes[mod.ID] = scopeService
 		}
 	} else {
-		g.allModules = envCfg.Modules
-		for _, mod := range envCfg.Modules {
+		g.allModules = g.envModules
+		for _, mod := range g.envModules {
 			g.moduleScopes[mod.ID] = scopeEnv
 		}
 	}
@@ -194,6 +239,7 @@ func NewGenerator(
 
 	// Pre-process to find all output providers
 	g.outputProviders = make(map[string][]string)
+	g.capabilityProviders = make(map[string][]capabilityProvider)
 	g.moduleMetas = make(map[string]*config.ModuleMetadata)
 
 	for _, m := range g.allModules {
@@ -202,14 +248,63 @@ func NewGenerator(
 			return nil, fmt.Errorf("module type %q (id=%s) not found in module roots", m.Type, m.ID)
 		}
 		g.moduleMetas[m.ID] = meta
+		if _, ok := g.forEachGroups[m.ID]; ok {
+			// Instances share one block; their outputs are maps, so they never auto-wire.
+			continue
+		}
 
 		for _, out := range meta.Outputs {
 			g.outputProviders[out.Name] = append(g.outputProviders[out.Name], m.ID)
+			if out.Capability != "" {
+				g.capabilityProviders[out.Capability] = append(g.capabilityProviders[out.Capability], capabilityProvider{module: m.ID, output: out.Name})
+			}
+		}
+	}
+
+	// Validation and IAM augmentation see each instance of a for_each group as its own module.
+	instances, err := g.instanceView(g.allModules)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range g.allModules {
+		for access, targets := range m.Links {
+			for _, t := range targets {
+				if _, ok := g.forEachGroups[t]; ok {
+					return nil, fmt.Errorf("module %q links.%s targets %q, which fans out with Terraform for_each; set expand: true on %q", m.ID, access, t, t)
+				}
+			}
+		}
+		if group, ok := g.forEachGroups[m.ID]; ok {
+			insts, _ := group.Instances()
+			for _, inst := range insts {
+				g.moduleScopes[group.InstanceRef(inst.Key)] = g.moduleScopes[m.ID]
+			}
 		}
 	}
 
+	// Catch input typos and type mismatches here instead of as Terraform "Unsupported argument" errors.
+	if err := config.ValidateModuleInputs(instances, g.modMap, ""); err != nil {
+		return nil, err
+	}
+
 	g.addGlobalTags()
-	g.mergedVars = g.getMergedVars()
+	mergedVars, err := g.getMergedVars()
+	if err != nil {
+		return nil, err
+	}
+	g.mergedVars = mergedVars
+	g.secretRefs = secrets.Refs(envCfg, svcCfg, envName)
+	if err := secrets.Validate(g.secretRefs, envCfg.Metadata.Provider); err != nil {
+		return nil, err
+	}
+	g.variableMode = config.VariableMode(envCfg, svcCfg)
+	for _, name := range sortedSecretNames(g.secretRefs) {
+		// Data-source secrets become locals, so they cannot share a name with a variable. With
+		// tfvars every variable is a Terraform variable, like the other secrets.
+		if _, clash := g.mergedVars[name]; clash && (secrets.IsDataSource(g.secretRefs[name]) || g.variableMode == config.VariablesTfvars) {
+			return nil, fmt.Errorf("secret %q (source=%s) has the same name as a variable", name, secrets.SourceOf(g.secretRefs[name]))
+		}
+	}
 	serviceName := envName
 	if svcCfg != nil {
 		serviceName = svcCfg.Metadata.Name
@@ -219,7 +314,7 @@ func NewGenerator(
 		EnvName:     envName,
 		ServiceName: serviceName,
 		IsService:   g.isService,
-		Modules:     g.allModules,
+		Modules:     instances,
 		Vars:        g.mergedVars,
 		ModuleScopes: func() map[string]string {
 			out := make(map[string]string, len(g.moduleScopes))
@@ -233,29 +328,89 @@ func NewGenerator(
 	return g, nil
 }
 
-// Generate writes Terraform for the configured stack into g.outDir.
+// Generate writes Terraform for the configured stack into g.outDir. The stack is rendered into a
+// staging directory next to it first; only files whose content changed are replaced, files no
+// longer generated are removed, and Terraform's own files (.terraform, the lock file, local state
+// and plans) are kept, so terraform init is not needed again. Changes reports what was touched.
 func (g *Generator) Generate() error {
 	if err := g.assertSafeOutDir(); err != nil {
 		return err
 	}
-	// 1. Create output directory
-	if err := os.RemoveAll(g.outDir); err != nil {
-		return fmt.Errorf("failed to clear output dir %s: %w", g.outDir, err)
+	// 1. Render into a staging directory on the same filesystem
+	final := g.outDir
+	if err := os.MkdirAll(filepath.Dir(final), 0o755); err != nil {
+		return fmt.Errorf("failed to create output dir %s: %w", final, err)
+	}
+	stage, err := os.MkdirTemp(filepath.Dir(final), "."+filepath.Base(final)+".stage-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging dir for %s: %w", final, err)
+	}
+	defer os.RemoveAll(stage)
+	// The previous source map tells which modules this run no longer generates.
+	prev, _ := ReadSourceMap(final)
+
+	g.outDir = stage
+	err = g.render()
+	g.outDir = final
+	if err != nil {
+		return err
+	}
+
+	// 2. Apply only what changed
+	changes, err := syncTree(stage, final)
+	if err != nil {
+		return fmt.Errorf("failed to update output dir %s: %w", final, err)
+	}
+	changes.Warnings = g.renameHints(prev, g.stackModules())
+	g.changes = changes
+	return nil
+}
+
+// Changes reports what the last Generate changed in the output directory.
+func (g *Generator) Changes() Changes {
+	return g.changes
+}
+
+// Check renders into a temporary directory and compares the result with the output directory,
+// which is left untouched. The drift is empty when the output directory is up to date.
+func (g *Generator) Check() (Drift, error) {
+	if err := g.assertSafeOutDir(); err != nil {
+		return Drift{}, err
+	}
+	stage, err := os.MkdirTemp("", "pltf-check-")
+	if err != nil {
+		return Drift{}, fmt.Errorf("failed to create staging dir: %w", err)
+	}
+	defer os.RemoveAll(stage)
+
+	final := g.outDir
+	g.outDir = stage
+	err = g.render()
+	g.outDir = final
+	if err != nil {
+		return Drift{}, err
+	}
+
+	changes, err := diffTrees(stage, final)
+	if err != nil {
+		return Drift{}, fmt.Errorf("failed to compare with %s: %w", final, err)
 	}
-	if err := os.MkdirAll(g.outDir, 0o755); err != nil {
-		return fmt.Errorf("failed to create output dir %s: %w", g.outDir, err)
+	diff, err := treeDiff(stage, final, changes)
+	if err != nil {
+		return Drift{}, fmt.Errorf("failed to diff %s: %w", final, err)
 	}
+	return Drift{Changes: changes, Diff: diff}, nil
+}
 
+// render writes every generated file into g.outDir, which is expected to be empty.
+func (g *Generator) render() error {
 	// 2. Write shared files (versions.tf, providers.tf, secrets.tf)
 	if err := g.writeBaseFiles(); err != nil {
 		return err
 	}
 
 	// 3. Write a file for each module
-	modulesToGen := g.envCfg.Modules
-	if g.isService {
-		modulesToGen = g.svcCfg.Modules
-	}
+	modulesToGen := g.stackModules()
 
 	usedModuleTypes := make(map[string]bool)
 	for _, m := range modulesToGen {
@@ -270,6 +425,18 @@ func (g *Generator) Generate() error {
 			return err
 		}
 	}
+	if err := g.checkCycles(modulesToGen); err != nil {
+		return err
+	}
+	if err := g.writeMovedFile(modulesToGen); err != nil {
+		return fmt.Errorf("failed to write %s: %w", movedFileName, err)
+	}
+	if err := g.writeProtectedFile(modulesToGen); err != nil {
+		return fmt.Errorf("failed to write %s: %w", protectedFileName, err)
+	}
+	if err := g.writeSourceMap(modulesToGen); err != nil {
+		return fmt.Errorf("failed to write %s: %w", SourceMapFile, err)
+	}
 
 	// 4. Copy module sources
 	if err := copyUsedModules(g.outDir, usedModuleTypes, g.moduleRootByType); err != nil {
@@ -297,30 +464,61 @@ func (g *Generator) assertSafeOutDir() error {
 }
 
 func (g *Generator) writeModuleFile(m config.Module, meta *config.ModuleMetadata) error {
+	modFile, err := g.moduleFile(m, meta)
+	if err != nil {
+		return err
+	}
+	modPath := filepath.Join(g.outDir, fmt.Sprintf("%s.tf", m.ID))
+	return os.WriteFile(modPath, modFile.Bytes(), 0o644)
+}
+
+// moduleFile renders the module block for m, recording its dependencies.
+func (g *Generator) moduleFile(m config.Module, meta *config.ModuleMetadata) (*hclwrite.File, error) {
 	modFile := hclwrite.NewEmptyFile()
 	body := modFile.Body()
+	body.AppendUnstructuredTokens(g.provenanceHeader(m))
 
 	modBlock := body.AppendNewBlock("module", []string{m.ID})
 	modBody := modBlock.Body()
 
 	modBody.SetAttributeValue("source", cty.StringVal(fmt.Sprintf("./modules/%s", meta.Type)))
+	if _, ok := g.forEachGroups[m.ID]; ok {
+		if err := g.writeFanOut(modBody, m); err != nil {
+			return nil, err
+		}
+		if m.Count != nil {
+			m.Inputs, _ = countIndexInputs(m.Inputs).(map[string]interface{})
+		}
+	}
+
+	g.startSources(m, meta)
 
 	// Process declared inputs
 	for _, inSpec := range meta.Inputs {
 		if err := g.processInput(modBody, m, inSpec); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	// Process any extra inputs not in metadata
-	for key, val := range m.Inputs {
-		if !inputDeclared(meta, key) {
-			g.collectDepsFromValue(m.ID, val)
+	for _, key := range sortedKeys(m.Inputs) {
+		if val := m.Inputs[key]; !inputDeclared(meta, key) {
+			g.trace(m.ID, key, g.inputOrigin(m, key))
+			g.collectDepsFromValue(m.ID, g.inputReason(m.ID, key), val)
 			if err := g.setAttribute(modBody, key, val); err != nil {
-				return fmt.Errorf("module %q extra input %q: %w", m.ID, key, err)
+				return nil, fmt.Errorf("module %q extra input %q: %w", m.ID, key, err)
+			}
+		}
+	}
+	for _, key := range sortedKeys(m.Wire) {
+		if !inputDeclared(meta, key) {
+			g.trace(m.ID, key, g.wireOrigin(m, key))
+			if err := g.setWiredInput(modBody, m, key); err != nil {
+				return nil, err
 			}
 		}
 	}
+	g.finishSources(m.ID, modBody)
 
 	if deps := g.sortedDeps(m.ID); len(deps) > 0 {
 		if g.isService {
@@ -331,9 +529,7 @@ func (g *Generator) writeModuleFile(m config.Module, meta *config.ModuleMetadata
 			modBody.SetAttributeRaw("depends_on", g.dependsTokens(deps))
 		}
 	}
-
-	modPath := filepath.Join(g.outDir, fmt.Sprintf("%s.tf", m.ID))
-	return os.WriteFile(modPath, modFile.Bytes(), 0o644)
+	return modFile, nil
 }
 
 func (g *Generator) materializeFileInputs(m config.Module) (config.Module, error) {
@@ -484,12 +680,16 @@ func (g *Generator) writeOutputsFile(mods []config.Module) error {
 
 			block := body.AppendNewBlock("output", []string{name})
 			b := block.Body()
-			trav := hcl.Traversal{
-				hcl.TraverseRoot{Name: "module"},
-				hcl.TraverseAttr{Name: m.ID},
-				hcl.TraverseAttr{Name: out.Name},
+			if group, ok := g.forEachGroups[m.ID]; ok {
+				b.SetAttributeRaw("value", fanOutOutputTokens(group, out.Name))
+			} else {
+				trav := hcl.Traversal{
+					hcl.TraverseRoot{Name: "module"},
+					hcl.TraverseAttr{Name: m.ID},
+					hcl.TraverseAttr{Name: out.Name},
+				}
+				b.SetAttributeRaw("value", hclwrite.TokensForTraversal(trav))
 			}
-			b.SetAttributeRaw("value", hclwrite.TokensForTraversal(trav))
 			if desc := strings.TrimSpace(out.Description); desc != "" {
 				b.SetAttributeValue("description", cty.StringVal(desc))
 			}
@@ -504,36 +704,56 @@ func (g *Generator) writeOutputsFile(mods []config.Module) error {
 }
 
 func (g *Generator) processInput(modBody *hclwrite.Body, m config.Module, inSpec config.InputSpec) error {
-	val, err := g.resolveInput(modBody, m, inSpec)
+	val, from, err := g.resolveInput(modBody, m, inSpec)
 	if err != nil {
 		return err
 	}
+	g.trace(m.ID, inSpec.Name, from)
 
-	g.collectDepsFromValue(m.ID, val)
+	g.collectDepsFromValue(m.ID, g.inputReason(m.ID, inSpec.Name), val)
 	return g.setAttribute(modBody, inSpec.Name, val)
 }
 
-func (g *Generator) resolveInput(modBody *hclwrite.Body, m config.Module, inSpec config.InputSpec) (interface{}, error) {
+func (g *Generator) resolveInput(modBody *hclwrite.Body, m config.Module, inSpec config.InputSpec) (interface{}, origin, error) {
 	// 1. Direct input from YAML
 	if raw, ok := m.Inputs[inSpec.Name]; ok {
-		return raw, nil
+		return raw, g.inputOrigin(m, inSpec.Name), nil
+	}
+
+	// 2. Explicit wiring from another module's output
+	if _, ok := m.Wire[inSpec.Name]; ok {
+		return nil, g.wireOrigin(m, inSpec.Name), g.setWiredInput(modBody, m, inSpec.Name)
 	}
 
-	// 2. Auto-fill platform fields
+	// 3. Auto-fill platform fields
 	switch inSpec.Name {
 	case "env_name":
-		return g.envName, nil
+		return g.intrinsic("env_name"), origin{source: SourcePlatform, detail: "name of the Environment", at: specKey{path: "metadata.name"}}, nil
 	case "layer_name":
-		layer := g.envName
 		if g.isService {
-			layer = g.svcCfg.Metadata.Name
+			return g.svcCfg.Metadata.Name, origin{source: SourcePlatform, detail: "name of the Service", at: specKey{path: "metadata.name", service: true}}, nil
 		}
-		return layer, nil
+		return g.envName, origin{source: SourcePlatform, detail: "name of the Environment", at: specKey{path: "metadata.name"}}, nil
 	case "module_name":
-		return m.ID, nil
+		from := origin{source: SourcePlatform, detail: "id of the module", at: g.moduleKey(m.ID, ".id")}
+		// Instances of a for_each group need distinct names, matching the expanded ids.
+		if group, ok := g.forEachGroups[m.ID]; ok {
+			if group.Count != nil {
+				return m.ID + "_${count.index}", from, nil
+			}
+			return m.ID + "_${each.key}", from, nil
+		}
+		return m.ID, from, nil
+	}
+
+	// 4. Auto-wire from the module output providing the input's capability
+	if inSpec.Capability != "" {
+		if from, ok, err := g.wireCapability(modBody, m, inSpec); ok || err != nil {
+			return nil, from, err
+		}
 	}
 
-	// 3. Auto-wire from another module's output
+	// 5. Auto-wire from another module's output; defaultProviders settles ties
 	if providers, ok := g.outputProviders[inSpec.Name]; ok {
 		// Filter out the current module from the list of providers
 		var candidates []string
@@ -545,56 +765,203 @@ func (g *Generator) resolveInput(modBody *hclwrite.Body, m config.Module, inSpec
 
 		if g.isService {
 			serviceProviders, envProviders := g.splitByScope(candidates)
+			serviceProviders = preferProvider(serviceProviders, g.svcCfg.DefaultProviders[inSpec.Name])
+			envProviders = preferProvider(envProviders, g.envCfg.DefaultProviders[inSpec.Name])
 			if len(serviceProviders) > 1 {
-				return nil, fmt.Errorf(
-					"module %q input %q can be satisfied by multiple service modules: %v. Please specify which to use in your YAML.",
-					m.ID, inSpec.Name, serviceProviders,
+				return nil, origin{}, fmt.Errorf(
+					"module %q input %q can be satisfied by multiple service modules: %v. Set wire.%s on the module or defaultProviders.%s in the service spec.",
+					m.ID, inSpec.Name, serviceProviders, inSpec.Name, inSpec.Name,
 				)
 			}
 			if len(envProviders) > 1 && len(serviceProviders) == 0 {
-				return nil, fmt.Errorf(
-					"module %q input %q can be satisfied by multiple environment modules: %v. Please specify which to use in your YAML.",
-					m.ID, inSpec.Name, envProviders,
+				return nil, origin{}, fmt.Errorf(
+					"module %q input %q can be satisfied by multiple environment modules: %v. Set wire.%s: parent.<output> on the module or defaultProviders.%s in the environment spec.",
+					m.ID, inSpec.Name, envProviders, inSpec.Name, inSpec.Name,
 				)
 			}
 
 			switch {
 			case len(serviceProviders) == 1:
-				g.addDep(m.ID, serviceProviders[0])
+				g.addDep(m.ID, serviceProviders[0], inputDep(inSpec.Name))
 				setAttrModuleOutputRef(modBody, inSpec.Name, serviceProviders[0], inSpec.Name)
-				return nil, nil // Attribute is set directly, so we return nil
+				return nil, g.providerOrigin(SourceAutoWire, "", serviceProviders[0], inSpec.Name), nil // Attribute is set directly, so we return nil
 			case len(envProviders) == 1:
-				setAttrParentOutputRef(modBody, inSpec.Name, inSpec.Name)
-				return nil, nil // Attribute is set directly, so we return nil
+				setAttrParentOutputRef(modBody, inSpec.Name, g.envOutputName(envProviders[0], inSpec.Name))
+				return nil, g.providerOrigin(SourceAutoWire, "", envProviders[0], inSpec.Name), nil // Attribute is set directly, so we return nil
 			}
 		} else {
+			candidates = preferProvider(candidates, g.envCfg.DefaultProviders[inSpec.Name])
 			if len(candidates) > 1 {
-				return nil, fmt.Errorf(
-					"module %q input %q can be satisfied by multiple modules: %v. Please specify which to use in your YAML.",
-					m.ID, inSpec.Name, candidates,
+				return nil, origin{}, fmt.Errorf(
+					"module %q input %q can be satisfied by multiple modules: %v. Set wire.%s on the module or defaultProviders.%s in the spec.",
+					m.ID, inSpec.Name, candidates, inSpec.Name, inSpec.Name,
 				)
 			}
 			if len(candidates) == 1 {
-				g.addDep(m.ID, candidates[0])
+				g.addDep(m.ID, candidates[0], inputDep(inSpec.Name))
 				setAttrModuleOutputRef(modBody, inSpec.Name, candidates[0], inSpec.Name)
-				return nil, nil // Attribute is set directly, so we return nil
+				return nil, g.providerOrigin(SourceAutoWire, "", candidates[0], inSpec.Name), nil // Attribute is set directly, so we return nil
 			}
 		}
 	}
 
-	// 4. Wire from merged locals/vars if available
+	// 6. Wire from merged locals/vars if available
 	if _, ok := g.mergedVars[inSpec.Name]; ok {
-		return nil, g.setVarReference(modBody, inSpec.Name, inSpec.Name)
+		return nil, g.varOrigin(inSpec.Name), g.setVarReference(modBody, inSpec.Name, inSpec.Name)
 	}
 
-	// 5. Handle required/default logic
+	// 7. Handle required/default logic
 	if inSpec.Required && inSpec.Default == nil {
-		return nil, fmt.Errorf("module %q (type=%s) missing required input %q", m.ID, m.Type, inSpec.Name)
+		return nil, origin{}, fmt.Errorf("module %q (type=%s) missing required input %q", m.ID, m.Type, inSpec.Name)
 	}
 	if inSpec.Default == nil {
-		return nil, nil // Skip if no value and no default
+		return nil, origin{}, nil // Skip if no value and no default
+	}
+	return inSpec.Default, origin{source: SourceDefault, detail: "module.yaml of " + m.Type}, nil
+}
+
+// setWiredInput sets input from the module output named in m.Wire. Wiring from a for_each group
+// without an instance key passes the output of every instance (a map, or a list for count).
+func (g *Generator) setWiredInput(body *hclwrite.Body, m config.Module, input string) error {
+	src, err := config.ParseWireSource(m.Wire[input])
+	if err != nil {
+		return fmt.Errorf("module %q wire.%s: %w", m.ID, input, err)
+	}
+	if src.Parent() {
+		if !g.isService {
+			return fmt.Errorf("module %q wire.%s: parent outputs are only available in services", m.ID, input)
+		}
+		setAttrParentOutputRef(body, input, src.Output)
+		return nil
+	}
+
+	local := scopeEnv
+	if g.isService {
+		local = scopeService
+	}
+	dep := g.depID(src.Module, src.Key, src.Keyed)
+	if scope, ok := g.moduleScopes[dep]; !ok || scope != local {
+		return fmt.Errorf("module %q wire.%s refers to unknown module %q", m.ID, input, src.Module)
+	}
+	if dep == m.ID {
+		return fmt.Errorf("module %q wire.%s refers to the module itself", m.ID, input)
+	}
+	g.addDep(m.ID, dep, depReason{what: "wire." + input, path: "wire." + input})
+	if group, ok := g.forEachGroups[src.Module]; ok && !src.Keyed {
+		body.SetAttributeRaw(input, fanOutOutputTokens(group, src.Output))
+		return nil
+	}
+	body.SetAttributeRaw(input, hclwrite.TokensForTraversal(g.moduleRef(src.Module, src.Key, src.Numeric, src.Keyed, src.Output)))
+	return nil
+}
+
+// capabilityProvider is a module output tagged with a capability.
+type capabilityProvider struct {
+	module string
+	output string
+}
+
+// wireCapability sets input from the module output that provides its capability, preferring
+// modules in the same spec over the parent Environment. defaultProviders, keyed by capability,
+// settles ties. It reports false when nothing in the stack provides the capability.
+func (g *Generator) wireCapability(body *hclwrite.Body, m config.Module, inSpec config.InputSpec) (origin, bool, error) {
+	local := scopeEnv
+	if g.isService {
+		local = scopeService
+	}
+	var localProviders, parentProviders []capabilityProvider
+	for _, p := range g.capabilityProviders[inSpec.Capability] {
+		switch {
+		case p.module == m.ID:
+		case g.moduleScopes[p.module] == local:
+			localProviders = append(localProviders, p)
+		case g.isService:
+			parentProviders = append(parentProviders, p)
+		}
+	}
+	localDefault := g.envCfg.DefaultProviders[inSpec.Capability]
+	if g.isService {
+		localDefault = g.svcCfg.DefaultProviders[inSpec.Capability]
+	}
+	localProviders = preferCapabilityProvider(localProviders, localDefault)
+	parentProviders = preferCapabilityProvider(parentProviders, g.envCfg.DefaultProviders[inSpec.Capability])
+
+	switch {
+	case len(localProviders) > 1:
+		return origin{}, false, fmt.Errorf(
+			"module %q input %q accepts capability %q, which multiple modules provide: %s. Set wire.%s on the module or defaultProviders.%s in the spec.",
+			m.ID, inSpec.Name, inSpec.Capability, formatCapabilityProviders(localProviders), inSpec.Name, inSpec.Capability,
+		)
+	case len(localProviders) == 1:
+		p := localProviders[0]
+		g.addDep(m.ID, p.module, depReason{what: fmt.Sprintf("input %s (capability %s)", inSpec.Name, inSpec.Capability), path: "inputs." + inSpec.Name})
+		setAttrModuleOutputRef(body, inSpec.Name, p.module, p.output)
+		return g.providerOrigin(SourceCapability, inSpec.Capability, p.module, p.output), true, nil
+	case len(parentProviders) > 1:
+		return origin{}, false, fmt.Errorf(
+			"module %q input %q accepts capability %q, which multiple environment modules provide: %s. Set wire.%s: parent.<output> on the module or defaultProviders.%s in the environment spec.",
+			m.ID, inSpec.Name, inSpec.Capability, formatCapabilityProviders(parentProviders), inSpec.Name, inSpec.Capability,
+		)
+	case len(parentProviders) == 1:
+		p := parentProviders[0]
+		setAttrParentOutputRef(body, inSpec.Name, g.envOutputName(p.module, p.output))
+		return g.providerOrigin(SourceCapability, inSpec.Capability, p.module, p.output), true, nil
+	}
+	return origin{}, false, nil
+}
+
+// preferCapabilityProvider narrows several providers of a capability to the outputs of the
+// default provider, when it is one of them.
+func preferCapabilityProvider(ps []capabilityProvider, preferred string) []capabilityProvider {
+	if len(ps) < 2 || preferred == "" {
+		return ps
+	}
+	var out []capabilityProvider
+	for _, p := range ps {
+		if p.module == preferred {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 {
+		return ps
+	}
+	return out
+}
+
+func formatCapabilityProviders(ps []capabilityProvider) string {
+	refs := make([]string, len(ps))
+	for i, p := range ps {
+		refs[i] = p.module + "." + p.output
+	}
+	return strings.Join(refs, ", ")
+}
+
+// preferProvider narrows several providers of an output to the spec's default provider, when it is
+// one of them.
+func preferProvider(ids []string, preferred string) []string {
+	if len(ids) < 2 || preferred == "" {
+		return ids
+	}
+	for _, id := range ids {
+		if id == preferred {
+			return []string{id}
+		}
+	}
+	return ids
+}
+
+// envOutputName is the name the Environment stack gives output outName of module modID in
+// outputs.tf, where outputs that several modules share are prefixed with the module id.
+func (g *Generator) envOutputName(modID, outName string) string {
+	baseCounts := map[string]int{}
+	for _, m := range g.envModules {
+		if meta := g.moduleMetas[m.ID]; meta != nil {
+			for _, out := range meta.Outputs {
+				baseCounts[sanitizeOutputName(out.Name)]++
+			}
+		}
 	}
-	return inSpec.Default, nil
+	return g.uniqueOutputName(modID, outName, baseCounts, map[string]struct{}{})
 }
 
 func (g *Generator) setAttribute(body *hclwrite.Body, name string, value interface{}) error {
@@ -786,11 +1153,6 @@ func (g *Generator) stringToTokens(s string) hclwrite.Tokens {
 	s = g.replaceIntrinsicPlaceholders(s)
 	normalized := templatePattern.ReplaceAllString(s, `${$1}`)
 
-	// If the whole string is a single interpolation like ${...} or ${{...}}, render as pure expression (no quotes).
-	if sm := fullExprPattern.FindStringSubmatch(normalized); sm != nil {
-		return g.expressionToTokens(strings.TrimSpace(sm[1]))
-	}
-
 	// Case 1: The entire string is a single reference, e.g., "module.foo.bar"
 	if sm := moduleRefPattern.FindStringSubmatch(normalized); sm != nil {
 		return g.expressionToTokens(normalized)
@@ -802,64 +1164,143 @@ func (g *Generator) stringToTokens(s string) hclwrite.Tokens {
 		return g.expressionToTokens(normalized)
 	}
 
-	// Case 2: String with interpolations, e.g., "http://${module.dns.domain}"
-	matches := interpolationPattern.FindAllStringSubmatchIndex(normalized, -1)
-	if len(matches) == 0 {
+	if !strings.Contains(normalized, "${") {
 		// Just a plain string literal
 		return hclwrite.TokensForValue(cty.StringVal(normalized))
 	}
 
-	// It's an interpolated string
-	var tokens hclwrite.Tokens
-	tokens = append(tokens, &hclwrite.Token{
-		Type:  hclsyntax.TokenOQuote,
-		Bytes: []byte(`"`),
-	})
+	// Case 2: HCL template, e.g., "${var.x == \"prod\" ? 3 : 1}" or "http://${module.dns.domain}"
+	tokens, err := g.templateToTokens(normalized)
+	if err != nil {
+		// Specs are validated on load, so this is text that only looks like a template.
+		return hclwrite.TokensForValue(cty.StringVal(normalized))
+	}
+	return tokens
+}
 
-	lastIndex := 0
-	for _, match := range matches {
-		start, end := match[0], match[1]
-		refStart, refEnd := match[2], match[3]
+// templateToTokens parses s as an HCL template and renders it with references rewritten for the
+// generated stack. A string that is a single interpolation renders as a bare expression.
+func (g *Generator) templateToTokens(s string) (hclwrite.Tokens, error) {
+	expr, src, err := config.ParseInputTemplate(s)
+	if err != nil {
+		return nil, err
+	}
+	if wrap, ok := expr.(*hclsyntax.TemplateWrapExpr); ok {
+		rng := wrap.Wrapped.Range()
+		if text := string(src[rng.Start.Byte:rng.End.Byte]); simpleRef(text) {
+			return g.expressionToTokens(text), nil
+		}
+		return exprTokens(g.rewriteExpr(src, wrap.Wrapped)), nil
+	}
+	tmpl, ok := expr.(*hclsyntax.TemplateExpr)
+	if !ok {
+		return nil, fmt.Errorf("unsupported template %q", s)
+	}
 
-		// Add literal part
-		if start > lastIndex {
-			tokens = append(tokens, &hclwrite.Token{
-				Type:  hclsyntax.TokenStringLit,
-				Bytes: []byte(normalized[lastIndex:start]),
-			})
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, part := range tmpl.Parts {
+		if lit, ok := part.(*hclsyntax.LiteralValueExpr); ok && lit.Val.Type() == cty.String {
+			b.WriteString(templateLiteralEscaper.Replace(lit.Val.AsString()))
+			continue
 		}
+		b.WriteString("${")
+		b.WriteString(g.rewriteExpr(src, part))
+		b.WriteString("}")
+	}
+	b.WriteByte('"')
+	return exprTokens(b.String()), nil
+}
 
-		// Add interpolated part
-		tokens = append(tokens, &hclwrite.Token{
-			Type:  hclsyntax.TokenTemplateInterp,
-			Bytes: []byte(`${`),
-		})
-		// The reference inside ${...}
-		refTokens := g.expressionToTokens(strings.TrimSpace(normalized[refStart:refEnd]))
-		tokens = append(tokens, refTokens...)
+var templateLiteralEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+	"${", "$${",
+	"%{", "%%{",
+)
 
-		tokens = append(tokens, &hclwrite.Token{
-			Type:  hclsyntax.TokenTemplateSeqEnd,
-			Bytes: []byte("}"),
-		})
+// simpleRef reports whether expr is a single module, var, parent or each/count reference, which
+// expressionToTokens renders directly.
+func simpleRef(expr string) bool {
+	return moduleRefPattern.MatchString(expr) || varRefPattern.MatchString(expr) ||
+		parentRefPattern.MatchString(expr) || eachExprPattern.MatchString(expr)
+}
 
-		lastIndex = end
+// rewriteExpr returns the source of expr with every var., parent. and module. reference rewritten.
+func (g *Generator) rewriteExpr(src []byte, expr hclsyntax.Expression) string {
+	rng := expr.Range()
+	out := string(src[rng.Start.Byte:rng.End.Byte])
+	travs := config.InputTraversals(expr)
+	for i := len(travs) - 1; i >= 0; i-- {
+		t := travs[i]
+		repl, ok := g.rewriteTraversal(t.Traversal)
+		if !ok {
+			continue
+		}
+		start, end := t.SrcRange.Start.Byte-rng.Start.Byte, t.SrcRange.End.Byte-rng.Start.Byte
+		out = out[:start] + repl + out[end:]
 	}
+	return out
+}
 
-	// Add trailing literal part
-	if lastIndex < len(normalized) {
-		tokens = append(tokens, &hclwrite.Token{
-			Type:  hclsyntax.TokenStringLit,
-			Bytes: []byte(normalized[lastIndex:]),
-		})
+// rewriteTraversal maps a spec reference to the generated stack: var.X to local.X (var.X for
+// secrets), parent.X to the parent's remote state outputs and module.X.Y through moduleRef.
+// Anything else, such as each/count, is left as written.
+func (g *Generator) rewriteTraversal(trav hcl.Traversal) (string, bool) {
+	if len(trav) < 2 {
+		return "", false
 	}
-
-	tokens = append(tokens, &hclwrite.Token{
-		Type:  hclsyntax.TokenCQuote,
-		Bytes: []byte(`"`),
-	})
-
-	return tokens
+	first, ok := trav[1].(hcl.TraverseAttr)
+	if !ok {
+		return "", false
+	}
+	var out hcl.Traversal
+	switch trav.RootName() {
+	case "var":
+		root := "local"
+		if g.getSecretNames()[first.Name] {
+			root = "var"
+		}
+		out = append(hcl.Traversal{hcl.TraverseRoot{Name: root}}, trav[1:]...)
+	case "parent":
+		out = append(hcl.Traversal{
+			hcl.TraverseRoot{Name: "data"},
+			hcl.TraverseAttr{Name: "terraform_remote_state"},
+			hcl.TraverseAttr{Name: "env"},
+			hcl.TraverseAttr{Name: "outputs"},
+		}, trav[1:]...)
+	case "module":
+		rest := trav[2:]
+		key, numeric, keyed := "", false, false
+		if len(rest) > 0 {
+			if idx, ok := rest[0].(hcl.TraverseIndex); ok {
+				keyed = true
+				switch idx.Key.Type() {
+				case cty.String:
+					key = idx.Key.AsString()
+				case cty.Number:
+					key, numeric = idx.Key.AsBigFloat().Text('f', -1), true
+				default:
+					return "", false
+				}
+				rest = rest[1:]
+			}
+		}
+		if len(rest) == 0 {
+			return "", false
+		}
+		output, ok := rest[0].(hcl.TraverseAttr)
+		if !ok {
+			return "", false
+		}
+		out = append(g.moduleRef(first.Name, key, numeric, keyed, output.Name), rest[1:]...)
+	default:
+		return "", false
+	}
+	return string(hclwrite.TokensForTraversal(out).Bytes()), true
 }
 
 func (g *Generator) mapToTokens(m map[string]interface{}) (hclwrite.Tokens, error) {
@@ -1014,33 +1455,59 @@ func (g *Generator) replaceIntrinsicPlaceholders(val string) string {
 	if g.isService && g.svcCfg != nil {
 		layerName = g.svcCfg.Metadata.Name
 	}
-	accountOrProject := g.envEntry.Account
 
 	repl := strings.NewReplacer(buildPlaceholderPairs(map[string]string{
-		"env_name":    g.envName,
+		"env_name":    g.intrinsic("env_name"),
 		"layer_name":  layerName,
 		"parent_name": layerName,
-		"account_id":  accountOrProject,
-		"project_id":  accountOrProject,
-		"region":      g.envEntry.Region,
+		"account_id":  g.intrinsic("account_id"),
+		"project_id":  g.intrinsic("account_id"),
+		"region":      g.intrinsic("region"),
 	})...)
 	return repl.Replace(val)
 }
 
+// intrinsic returns the value of the env_name, account_id or region placeholder. Stacks rendered
+// with tfvars read them from their variables so the stack can be applied to other environments.
+func (g *Generator) intrinsic(name string) string {
+	if g.variableMode == config.VariablesTfvars {
+		if name == "env_name" {
+			return "${var.environment}"
+		}
+		return "${var." + name + "}"
+	}
+	switch name {
+	case "env_name":
+		return g.envName
+	case "account_id":
+		return g.envEntry.Account
+	}
+	return g.envEntry.Region
+}
+
 // expressionToTokens renders bare expressions (no surrounding quotes), supporting module, var, and parent references.
 func (g *Generator) expressionToTokens(expr string) hclwrite.Tokens {
 	normalized := templatePattern.ReplaceAllString(expr, `${$1}`)
 
 	if sm := moduleRefPattern.FindStringSubmatch(normalized); sm != nil {
-		return hclwrite.TokensForTraversal(hcl.Traversal{
-			hcl.TraverseRoot{Name: "module"},
-			hcl.TraverseAttr{Name: sm[1]},
-			hcl.TraverseAttr{Name: sm[2]},
-		})
+		trav := g.moduleRef(sm[1], sm[2]+sm[3], sm[3] != "", strings.Contains(normalized, "["), sm[4])
+		return hclwrite.TokensForTraversal(trav)
+	}
+	if eachExprPattern.MatchString(normalized) {
+		parts := strings.Split(normalized, ".")
+		trav := hcl.Traversal{hcl.TraverseRoot{Name: parts[0]}}
+		for _, p := range parts[1:] {
+			trav = append(trav, hcl.TraverseAttr{Name: p})
+		}
+		return hclwrite.TokensForTraversal(trav)
 	}
 	if sm := varRefPattern.FindStringSubmatch(normalized); sm != nil {
+		root := "local"
+		if g.getSecretNames()[sm[1]] {
+			root = "var"
+		}
 		return hclwrite.TokensForTraversal(hcl.Traversal{
-			hcl.TraverseRoot{Name: "local"},
+			hcl.TraverseRoot{Name: root},
 			hcl.TraverseAttr{Name: sm[1]},
 		})
 	}
@@ -1096,41 +1563,48 @@ func (g *Generator) writeBaseFiles() error {
 	needsHelm := g.hasModuleType("aws_k8s_base") || g.hasModuleType("gcp_k8s_base") || g.hasModuleType("helm_chart")
 	cluster := g.clusterRefs()
 
-	// Collect locals and secrets
+	// Collect locals
 	locals := g.mergedVars
-	secretNames := g.getSecretNames()
 
-	var backendKey string
 	backendCfg, err := ResolveBackendConfig(provider, g.envCfg, g.envEntry)
 	if err != nil {
 		return err
 	}
 	bucket := backendCfg.Bucket
-	if g.isService {
-		backendKey = fmt.Sprintf("service/%s/%s/terraform.tfstate", g.svcCfg.Metadata.Name, g.envKey)
-	} else {
-		backendKey = fmt.Sprintf("env/%s/%s/terraform.tfstate", g.envCfg.Metadata.Name, g.envKey)
-	}
+	backendKey := g.backendKey(g.envKey)
 
 	if bucket == "" {
 		return fmt.Errorf("backend bucket is not specified in the configuration")
 	}
 
-	if err := writeVersionsTF(g.outDir, bucket, backendKey, backendCfg.Region, provider, backendCfg.BackendType, locals, needsK8s, needsHelm, backendCfg.Container, backendCfg.ResourceGroup, backendCfg.Profile); err != nil {
+	asVariables := g.variableMode == config.VariablesTfvars
+	if err := writeVersionsTF(g.outDir, bucket, backendKey, backendCfg.Region, provider, backendCfg.BackendType, locals, asVariables, needsK8s, needsHelm, backendCfg.Container, backendCfg.ResourceGroup, backendCfg.Profile); err != nil {
 		return fmt.Errorf("failed to write versions.tf: %w", err)
 	}
+	if asVariables {
+		if err := writeVariablesTF(g.outDir, locals, config.MergeVariableSpecs(g.envCfg, g.svcCfg), g.isService); err != nil {
+			return fmt.Errorf("failed to write variables.tf: %w", err)
+		}
+		if err := g.writeStackValues(); err != nil {
+			return err
+		}
+	}
 
-	if err := writeProvidersTF(g.outDir, provider, providerRegion, account, needsK8s, needsHelm, cluster); err != nil {
+	if err := writeProvidersTF(g.outDir, provider, providerRegion, account, asVariables, needsK8s, needsHelm, cluster); err != nil {
 		return fmt.Errorf("failed to write providers.tf: %w", err)
 	}
 
-	if err := writeSecretsTF(g.outDir, secretNames); err != nil {
+	if err := writeSecretsTF(g.outDir, g.secretRefs); err != nil {
 		return fmt.Errorf("failed to write secrets.tf: %w", err)
 	}
 
 	// For services, write remote state to access env outputs
-	if g.isService {
-		envStateKey := fmt.Sprintf("env/%s/%s/terraform.tfstate", g.envCfg.Metadata.Name, g.envKey)
+	if g.isService && asVariables {
+		if err := writeRemoteStateVarTF(g.outDir, backendCfg.BackendType); err != nil {
+			return fmt.Errorf("failed to write service state.tf: %w", err)
+		}
+	} else if g.isService {
+		envStateKey := g.envStateKey(g.envKey)
 		if err := writeRemoteStateTF(g.outDir, backendCfg.BackendType, backendCfg.Bucket, envStateKey, backendCfg.Region, backendCfg.Container, backendCfg.ResourceGroup, backendCfg.Profile); err != nil {
 			return fmt.Errorf("failed to write service state.tf: %w", err)
 		}
@@ -1139,11 +1613,17 @@ func (g *Generator) writeBaseFiles() error {
 	return nil
 }
 
-func (g *Generator) getMergedVars() map[string]interface{} {
-	// Precedence: environment vars -> service envRef vars -> CLI --var overrides.
+func (g *Generator) getMergedVars() (map[string]interface{}, error) {
+	return g.mergedVarsFor(g.envKey)
+}
+
+// mergedVarsFor merges the variables of environment key envKey, which the stack must support.
+func (g *Generator) mergedVarsFor(envKey string) (map[string]interface{}, error) {
+	envEntry := g.envCfg.Environments[envKey]
+	// Precedence: declared defaults -> environment vars -> service envRef vars -> CLI --var overrides.
 	merged := map[string]interface{}{}
-	merged["account_id"] = g.envEntry.Account
-	merged["region"] = g.envEntry.Region
+	merged["account_id"] = envEntry.Account
+	merged["region"] = envEntry.Region
 	merged["environment"] = g.envName
 	if g.globalLabels == nil {
 		merged["global_tags"] = map[string]string{}
@@ -1151,39 +1631,88 @@ func (g *Generator) getMergedVars() map[string]interface{} {
 		merged["global_tags"] = g.globalLabels
 	}
 
+	// Declared variables are converted to their type; undeclared ones keep the guessing heuristic.
+	specs := config.MergeVariableSpecs(g.envCfg, g.svcCfg)
+	set := func(source, k, v string) error {
+		spec, ok := specs[k]
+		if !ok {
+			merged[k] = parseVarValue(v)
+			return nil
+		}
+		value, err := spec.Convert(v)
+		if err != nil {
+			return fmt.Errorf("variable %s (%s): %w", k, source, err)
+		}
+		merged[k] = value
+		return nil
+	}
+
+	for _, k := range sortedKeys(specs) {
+		if specs[k].Default == nil {
+			continue
+		}
+		raw, err := config.RawVariableValue(specs[k].Default)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s default: %w", k, err)
+		}
+		if err := set("default", k, raw); err != nil {
+			return nil, err
+		}
+	}
+
 	// Env vars
-	for k, v := range g.envEntry.Variables {
-		merged[k] = parseVarValue(v)
+	for k, v := range envEntry.Variables {
+		if err := set("environments."+envKey, k, v); err != nil {
+			return nil, err
+		}
 	}
 
 	// Service vars
 	if g.isService {
-		for k, v := range g.svcEnvEntry.Variables {
-			merged[k] = parseVarValue(v)
+		for k, v := range g.svcCfg.Metadata.EnvRef[envKey].Variables {
+			if err := set("envRef."+envKey, k, v); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	// CLI vars (highest precedence)
 	for k, v := range g.cliVars {
-		merged[k] = parseVarValue(v)
+		if err := set("--var", k, v); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	return merged
+	sort.Strings(keys)
+	return keys
 }
 
+// getSecretNames returns the secrets declared as Terraform variables. Secrets read through a data
+// source are locals and are referenced like any other variable.
 func (g *Generator) getSecretNames() map[string]bool {
-	// Secrets come from env entry and optionally service envRef.
-	secrets := map[string]bool{}
-	if g.envEntry.Secrets != nil {
-		for name := range g.envEntry.Secrets {
-			secrets[name] = true
+	names := map[string]bool{}
+	for name, ref := range g.secretRefs {
+		if !secrets.IsDataSource(ref) {
+			names[name] = true
 		}
 	}
-	if g.isService && g.svcEnvEntry.Secrets != nil {
-		for name := range g.svcEnvEntry.Secrets {
-			secrets[name] = true
-		}
+	return names
+}
+
+func sortedSecretNames(refs map[string]config.SecretRef) []string {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
 	}
-	return secrets
+	sort.Strings(names)
+	return names
 }
 
 func (g *Generator) addGlobalTags() {
@@ -1198,27 +1727,50 @@ func (g *Generator) addGlobalTags() {
 	}
 }
 
-func (g *Generator) addDep(modID, depID string) {
+func (g *Generator) addDep(modID, depID string, why depReason) {
 	if modID == "" || depID == "" || modID == depID {
 		return
 	}
 	if _, ok := g.moduleDeps[modID]; !ok {
-		g.moduleDeps[modID] = map[string]struct{}{}
+		g.moduleDeps[modID] = map[string]depReason{}
 	}
-	g.moduleDeps[modID][depID] = struct{}{}
+	if _, ok := g.moduleDeps[modID][depID]; !ok {
+		g.moduleDeps[modID][depID] = why
+	}
+}
+
+// addRefDep records a module.<id>[key].<output> reference found in an input value. References to
+// the module itself, to unknown modules and, in a Service, to Environment modules are kept for
+// DiagnoseGraph; only the latter still becomes an edge, which depends_on leaves out.
+func (g *Generator) addRefDep(modID string, why depReason, id, key string, keyed bool, output string) {
+	dep := g.depID(id, key, keyed)
+	ref := moduleReference{module: modID, why: why, target: dep, output: output}
+	switch scope, known := g.moduleScopes[dep]; {
+	case dep == modID:
+		g.badRefs = append(g.badRefs, ref)
+		return
+	case !known:
+		if _, group := g.forEachGroups[id]; !group {
+			g.badRefs = append(g.badRefs, ref)
+			return
+		}
+	case g.isService && scope == scopeEnv:
+		g.badRefs = append(g.badRefs, ref)
+	}
+	g.addDep(modID, dep, why)
 }
 
-func (g *Generator) collectDepsFromValue(modID string, v interface{}) {
+func (g *Generator) collectDepsFromValue(modID string, why depReason, v interface{}) {
 	switch val := v.(type) {
 	case string:
+		seen := map[string]struct{}{}
 		addModuleDep := func(s string) {
-			if sm := moduleRefPattern.FindStringSubmatch(s); len(sm) > 1 {
-				g.addDep(modID, sm[1])
-			}
 			for _, match := range moduleRefAnywhere.FindAllStringSubmatch(s, -1) {
-				if len(match) > 1 {
-					g.addDep(modID, match[1])
+				if _, dup := seen[match[0]]; dup {
+					continue
 				}
+				seen[match[0]] = struct{}{}
+				g.addRefDep(modID, why, match[1], match[2]+match[3], strings.Contains(match[0], "["), match[4])
 			}
 		}
 		// look inside ${...}
@@ -1230,19 +1782,19 @@ func (g *Generator) collectDepsFromValue(modID string, v interface{}) {
 		addModuleDep(val)
 	case []interface{}:
 		for _, item := range val {
-			g.collectDepsFromValue(modID, item)
+			g.collectDepsFromValue(modID, why, item)
 		}
 	case []string:
 		for _, item := range val {
-			g.collectDepsFromValue(modID, item)
+			g.collectDepsFromValue(modID, why, item)
 		}
 	case map[string]interface{}:
 		for _, item := range val {
-			g.collectDepsFromValue(modID, item)
+			g.collectDepsFromValue(modID, why, item)
 		}
 	case map[string]string:
 		for _, item := range val {
-			g.collectDepsFromValue(modID, item)
+			g.collectDepsFromValue(modID, why, item)
 		}
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"pltf/modules"
 	"pltf/pkg/config"
@@ -326,6 +327,33 @@ func TestStringToTokensHandlesCurlyRefs(t *testing.T) {
 	}
 }
 
+func TestStringToTokensRendersExpressions(t *testing.T) {
+	g := &Generator{
+		envName:  "example-aws",
+		envEntry: config.EnvironmentEntry{Account: "111111111111", Region: "us-east-1"},
+	}
+	cases := map[string]string{
+		`${join(",", var.subnets)}`:              `join(",", local.subnets)`,
+		`${var.x == "prod" ? 3 : 1}`:             `local.x == "prod" ? 3 : 1`,
+		`${var.a}-${var.b}`:                      `"${local.a}-${local.b}"`,
+		`arn:${parent.domain}:x`:                 `"arn:${data.terraform_remote_state.env.outputs.domain}:x"`,
+		`${[for s in var.subnets : upper(s)]}`:   `[for s in local.subnets : upper(s)]`,
+		`${{ lookup(var.sizes, "dev", 1) }}`:     `lookup(local.sizes, "dev", 1)`,
+		`https://${module.dns.domain}/path`:      `"https://${module.dns.domain}/path"`,
+		`${module.dns.outputs.zone_id}`:          `module.dns.outputs.zone_id`,
+		`say "hi" to ${var.name} in ${region}`:   `"say \"hi\" to ${local.name} in us-east-1"`,
+		`${coalesce(var.override, "${region}")}`: `coalesce(local.override, "us-east-1")`,
+		`^\d+-${var.suffix}$$`:                   `"^\\d+-${local.suffix}$$"`,
+	}
+	for in, want := range cases {
+		file := hclwrite.NewEmptyFile()
+		file.Body().SetAttributeRaw("v", g.stringToTokens(in))
+		if got := strings.TrimPrefix(strings.TrimSpace(string(file.Bytes())), "v = "); got != want {
+			t.Fatalf("stringToTokens(%q):\n got  %s\n want %s", in, got, want)
+		}
+	}
+}
+
 func TestMapKeysWithDotsAreQuoted(t *testing.T) {
 	g := &Generator{}
 	file := hclwrite.NewEmptyFile()
@@ -564,6 +592,249 @@ func TestCollectDepsFromNestedValues(t *testing.T) {
 	}
 }
 
+func TestGeneratorAppliesPerEnvironmentModuleOverrides(t *testing.T) {
+	disabled := false
+	vars := map[string]string{"cluster_name": "demo", "enable_metrics": "true"}
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {
+				Account: "111111111111",
+				Region:  "us-east-1",
+				Modules: map[string]config.ModuleOverride{"docdb": {Enabled: &disabled}},
+			},
+			"prod": {
+				Account: "222222222222",
+				Region:  "us-west-2",
+				Modules: map[string]config.ModuleOverride{"eks": {Inputs: map[string]interface{}{"max_nodes": 20}}},
+			},
+		},
+		Modules: []config.Module{
+			{ID: "base", Type: "aws_base"},
+			{ID: "eks", Type: "aws_eks", Inputs: map[string]interface{}{"max_nodes": 5}},
+			{ID: "docdb", Type: "aws_documentdb"},
+		},
+	}
+
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+
+	devOut := t.TempDir()
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", devOut, "", vars)
+	if err != nil {
+		t.Fatalf("NewGenerator(dev) error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate(dev) error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(devOut, "docdb.tf")); !os.IsNotExist(err) {
+		t.Fatalf("expected docdb.tf to be skipped in dev, stat err=%v", err)
+	}
+	eksDev, _ := os.ReadFile(filepath.Join(devOut, "eks.tf"))
+	if !strings.Contains(string(eksDev), "max_nodes") || !strings.Contains(string(eksDev), "= 5") {
+		t.Fatalf("expected shared max_nodes in dev, got:\n%s", eksDev)
+	}
+
+	prodOut := t.TempDir()
+	g, err = NewGenerator(envCfg, nil, modRoot, "", "prod", prodOut, "", vars)
+	if err != nil {
+		t.Fatalf("NewGenerator(prod) error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate(prod) error: %v", err)
+	}
+	assertFiles(t, prodOut, "docdb.tf")
+	eksProd, _ := os.ReadFile(filepath.Join(prodOut, "eks.tf"))
+	if !strings.Contains(string(eksProd), "= 20") {
+		t.Fatalf("expected prod max_nodes override, got:\n%s", eksProd)
+	}
+	if envCfg.Modules[1].Inputs["max_nodes"] != 5 {
+		t.Fatalf("override must not mutate the shared module list")
+	}
+}
+
+func TestGeneratorWiresSecretResolvers(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {
+				Account: "111111111111",
+				Region:  "us-east-1",
+				Secrets: map[string]config.SecretRef{
+					"api_key":     {},
+					"db_password": {Source: "ssm", Path: "/app/db/password"},
+					"stripe":      {Source: "secretsmanager", Path: "app/keys#stripe"},
+					"vault_token": {Source: "vault", Path: "secret/app#token"},
+				},
+			},
+		},
+		Modules: []config.Module{
+			{ID: "base", Type: "aws_base"},
+			{ID: "logs", Type: "aws_s3", Inputs: map[string]interface{}{"bucket_name": "logs-${var.api_key}-${var.db_password}"}},
+		},
+	}
+
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+	outDir := t.TempDir()
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", outDir, "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	secretsTF, _ := os.ReadFile(filepath.Join(outDir, "secrets.tf"))
+	for _, want := range []string{
+		`variable "api_key"`,
+		`data "aws_ssm_parameter" "db_password"`,
+		`with_decryption = true`,
+		`db_password = sensitive(data.aws_ssm_parameter.db_password.value)`,
+		`stripe      = sensitive(jsondecode(data.aws_secretsmanager_secret_version.stripe.secret_string)["stripe"])`,
+		`vault_token = sensitive(data.vault_kv_secret_v2.vault_token.data["token"])`,
+		`source  = "hashicorp/vault"`,
+	} {
+		if !strings.Contains(string(secretsTF), want) {
+			t.Fatalf("secrets.tf missing %q:\n%s", want, secretsTF)
+		}
+	}
+	if strings.Contains(string(secretsTF), `variable "db_password"`) {
+		t.Fatalf("data-source secrets must not also be variables:\n%s", secretsTF)
+	}
+	logsTF, _ := os.ReadFile(filepath.Join(outDir, "logs.tf"))
+	if !strings.Contains(string(logsTF), "logs-${var.api_key}-${local.db_password}") {
+		t.Fatalf("expected variable and data-source secrets to be referenced, got:\n%s", logsTF)
+	}
+
+	envCfg.Environments["dev"].Secrets["region"] = config.SecretRef{Source: "ssm", Path: "/app/region"}
+	if _, err := NewGenerator(envCfg, nil, modRoot, "", "dev", t.TempDir(), "", nil); err == nil ||
+		!strings.Contains(err.Error(), `secret "region" (source=ssm) has the same name as a variable`) {
+		t.Fatalf("expected name clash error, got %v", err)
+	}
+}
+
+func TestGeneratorConvertsDeclaredVariables(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Variables: map[string]config.VariableSpec{
+			"build_id": {Type: "string"},
+			"zones":    {Type: "list(string)"},
+			"replicas": {Type: "number", Default: 2},
+			"tier":     {Allowed: []string{"small", "large"}},
+		},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {
+				Account:   "111111111111",
+				Region:    "us-east-1",
+				Variables: map[string]string{"build_id": "007", "zones": "a,b", "legacy": "x,y"},
+			},
+		},
+		Modules: []config.Module{{ID: "base", Type: "aws_base"}},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if got := g.mergedVars["build_id"]; got != "007" {
+		t.Fatalf("declared strings must not be guessed, got %#v", got)
+	}
+	if got, ok := g.mergedVars["zones"].([]interface{}); !ok || len(got) != 2 {
+		t.Fatalf("expected zones as a list, got %#v", g.mergedVars["zones"])
+	}
+	if got := g.mergedVars["replicas"]; got != int64(2) {
+		t.Fatalf("expected the declared default, got %#v", got)
+	}
+	if _, ok := g.mergedVars["legacy"].([]interface{}); !ok {
+		t.Fatalf("undeclared variables keep the old heuristics, got %#v", g.mergedVars["legacy"])
+	}
+
+	if _, err := NewGenerator(envCfg, nil, modRoot, "", "dev", t.TempDir(), "", map[string]string{"tier": "huge"}); err == nil ||
+		!strings.Contains(err.Error(), "variable tier (--var)") {
+		t.Fatalf("expected constraint violation from --var, got %v", err)
+	}
+}
+
+func TestGeneratorFansOutModules(t *testing.T) {
+	three := 3
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{
+			{ID: "base", Type: "aws_base"},
+			{ID: "role", Type: "aws_iam_role"},
+			{
+				ID:      "queues",
+				Type:    "aws_sqs",
+				ForEach: map[string]interface{}{"orders": map[string]interface{}{"fifo": true}, "emails": map[string]interface{}{"fifo": false}},
+				Inputs:  map[string]interface{}{"fifo": "${each.value.fifo}"},
+				Links:   config.AccessLinks{"write": {"role"}},
+			},
+			{ID: "jobs", Type: "aws_sqs", ForEach: []interface{}{"a", "b"}, Expand: true, Links: config.AccessLinks{"read": {"role"}}},
+			{ID: "shards", Type: "aws_sqs", Count: &three, Inputs: map[string]interface{}{"delay_seconds": "${each.value}"}},
+			{ID: "dlq", Type: "aws_sqs", Inputs: map[string]interface{}{"fifo": "${module.jobs[\"b\"].queue_arn}"}},
+		},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+	outDir := t.TempDir()
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", outDir, "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	assertFiles(t, outDir, "queues.tf", "jobs_a.tf", "jobs_b.tf", "shards.tf")
+	if _, err := os.Stat(filepath.Join(outDir, "jobs.tf")); err == nil {
+		t.Fatalf("expanded groups should not also render a group block")
+	}
+
+	// Compare with whitespace collapsed; alignment depends on neighbouring attributes.
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(outDir, name))
+		return strings.Join(strings.Fields(string(data)), " ")
+	}
+	for file, wants := range map[string][]string{
+		"queues.tf": {"for_each", "emails = {", "fifo = each.value.fifo", `module_name = "queues_${each.key}"`},
+		"shards.tf": {"count", "delay_seconds = count.index", `module_name = "shards_${count.index}"`},
+		"jobs_b.tf": {`module_name = "jobs_b"`},
+		"dlq.tf":    {"fifo = module.jobs_b.queue_arn", "module.jobs_b"},
+		"role.tf": {
+			`module.queues["emails"].queue_arn`,
+			`module.queues["orders"].queue_arn`,
+			"module.jobs_a.queue_arn",
+			"module.jobs_b.queue_arn",
+		},
+		"outputs.tf": {"for k, m in module.queues : k => m.queue_arn", "for m in module.shards : m.queue_arn", "module.jobs_a.queue_arn"},
+	} {
+		got := read(file)
+		for _, want := range wants {
+			if !strings.Contains(got, want) {
+				t.Fatalf("%s missing %q:\n%s", file, want, got)
+			}
+		}
+	}
+
+	envCfg.Modules[1].Links = config.AccessLinks{"read": {"queues"}}
+	if _, err := NewGenerator(envCfg, nil, modRoot, "", "dev", t.TempDir(), "", nil); err == nil ||
+		!strings.Contains(err.Error(), "set expand: true") {
+		t.Fatalf("expected links to a for_each group to be rejected, got %v", err)
+	}
+}
+
 func assertFiles(t *testing.T, root string, files ...string) {
 	t.Helper()
 	for _, f := range files {
@@ -572,3 +843,283 @@ func assertFiles(t *testing.T, root string, files ...string) {
 		}
 	}
 }
+
+func TestGeneratorRegeneratesIncrementally(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1", Variables: map[string]string{"cluster_name": "dev-cluster", "enable_metrics": "true"}},
+		},
+		Modules: []config.Module{
+			{ID: "base", Type: "aws_base"},
+			{ID: "eks", Type: "aws_eks"},
+		},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+	outDir := t.TempDir()
+	generateOnce := func() Changes {
+		t.Helper()
+		g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", outDir, "", nil)
+		if err != nil {
+			t.Fatalf("NewGenerator error: %v", err)
+		}
+		if err := g.Generate(); err != nil {
+			t.Fatalf("Generate error: %v", err)
+		}
+		return g.Changes()
+	}
+
+	first := generateOnce()
+	if len(first.Added) == 0 || len(first.Updated) != 0 || len(first.Removed) != 0 {
+		t.Fatalf("expected only additions on the first run, got %s", first)
+	}
+
+	// Terraform's own files must survive regeneration.
+	if err := os.MkdirAll(filepath.Join(outDir, ".terraform", "providers"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{filepath.Join(".terraform", "providers", "marker"), ".terraform.lock.hcl", "terraform.tfstate"} {
+		if err := os.WriteFile(filepath.Join(outDir, f), []byte("keep"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	basePath := filepath.Join(outDir, "base.tf")
+	if err := os.Chtimes(basePath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	second := generateOnce()
+	if !second.Empty() || second.Unchanged != len(first.Added) {
+		t.Fatalf("expected an unchanged second run, got %s", second)
+	}
+	if info, err := os.Stat(basePath); err != nil || !info.ModTime().Equal(old) {
+		t.Fatalf("unchanged files must not be rewritten (err=%v)", err)
+	}
+
+	envCfg.Modules = envCfg.Modules[:1]
+	third := generateOnce()
+	if !containsPath(third.Removed, "eks.tf") || containsPath(third.Removed, "base.tf") {
+		t.Fatalf("expected eks.tf to be removed, got %+v", third)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "eks.tf")); !os.IsNotExist(err) {
+		t.Fatalf("expected eks.tf to be deleted, stat err=%v", err)
+	}
+	for _, f := range []string{filepath.Join(".terraform", "providers", "marker"), ".terraform.lock.hcl", "terraform.tfstate"} {
+		if _, err := os.Stat(filepath.Join(outDir, f)); err != nil {
+			t.Fatalf("expected %s to be preserved: %v", f, err)
+		}
+	}
+	if entries, _ := filepath.Glob(filepath.Join(filepath.Dir(outDir), ".*.stage-*")); len(entries) != 0 {
+		t.Fatalf("staging directories left behind: %v", entries)
+	}
+}
+
+func containsPath(paths []string, want string) bool {
+	for _, p := range paths {
+		if p == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGeneratorCheckReportsDrift(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{{ID: "base", Type: "aws_base"}},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+	outDir := t.TempDir()
+	newGen := func() *Generator {
+		t.Helper()
+		g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", outDir, "", nil)
+		if err != nil {
+			t.Fatalf("NewGenerator error: %v", err)
+		}
+		return g
+	}
+	if err := newGen().Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	drift, err := newGen().Check()
+	if err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+	if !drift.Empty() || drift.Diff != "" {
+		t.Fatalf("expected freshly generated output to be up to date, got %s\n%s", drift.Changes, drift.Diff)
+	}
+
+	basePath := filepath.Join(outDir, "base.tf")
+	data, err := os.ReadFile(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := append([]byte("# hand edit\n"), data...)
+	if err := os.WriteFile(basePath, edited, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "extra.tf"), []byte("locals {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err = newGen().Check()
+	if err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+	if len(drift.Updated) != 1 || drift.Updated[0] != "base.tf" || len(drift.Removed) != 1 || drift.Removed[0] != "extra.tf" {
+		t.Fatalf("unexpected drift: %+v", drift.Changes)
+	}
+	for _, want := range []string{"--- a/base.tf\n+++ b/base.tf\n", "-# hand edit\n", "--- a/extra.tf\n+++ /dev/null\n"} {
+		if !strings.Contains(drift.Diff, want) {
+			t.Fatalf("expected diff to contain %q, got:\n%s", want, drift.Diff)
+		}
+	}
+	if got, _ := os.ReadFile(basePath); string(got) != string(edited) {
+		t.Fatalf("Check must not modify the output directory")
+	}
+}
+
+func TestGeneratorWiresExplicitSourcesAndDefaultProviders(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1", Variables: map[string]string{"cluster_name": "dev", "enable_metrics": "true"}},
+		},
+		Modules: []config.Module{
+			{ID: "net", Type: "aws_base"},
+			// Explicit values keep edge from auto-wiring net's outputs while net wires edge's.
+			{ID: "edge", Type: "aws_base", Inputs: map[string]interface{}{"vpc_id": "vpc-123", "private_subnet_ids": []interface{}{"subnet-1"}}},
+			{ID: "eks", Type: "aws_eks", Wire: map[string]string{
+				"kms_account_key_arn": "net.kms_account_key_arn",
+				"private_subnet_ids":  "edge.public_subnets_ids",
+			}},
+		},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err == nil || !strings.Contains(err.Error(), `input "vpc_id" can be satisfied by multiple modules`) ||
+		!strings.Contains(err.Error(), "defaultProviders.vpc_id") {
+		t.Fatalf("expected an ambiguity error pointing at defaultProviders, got %v", err)
+	}
+
+	envCfg.DefaultProviders = map[string]string{"vpc_id": "net"}
+	outDir := t.TempDir()
+	g, err = NewGenerator(envCfg, nil, modRoot, "", "dev", outDir, "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "eks.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(strings.Fields(string(data)), " ")
+	for _, want := range []string{
+		"vpc_id = module.net.vpc_id",
+		"kms_account_key_arn = module.net.kms_account_key_arn",
+		"private_subnet_ids = module.edge.public_subnets_ids",
+		"depends_on = [ module.edge, module.net ]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in eks.tf, got:\n%s", want, data)
+		}
+	}
+}
+
+func writeCapabilityModule(t *testing.T, root, name, body string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	meta := "name: " + name + "\ntype: " + name + "\nprovider: aws\nversion: 1.0.0\n" + body
+	if err := os.WriteFile(filepath.Join(dir, "module.yaml"), []byte(meta), 0o644); err != nil {
+		t.Fatalf("write module.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte("# "+name+"\n"), 0o644); err != nil {
+		t.Fatalf("write main.tf: %v", err)
+	}
+}
+
+func TestGeneratorWiresInputsByCapability(t *testing.T) {
+	custom := t.TempDir()
+	writeCapabilityModule(t, custom, "kv_store", `capabilities:
+  provides: [store.arn]
+outputs:
+  - name: store_arn
+    type: string
+    capability: store.arn
+`)
+	writeCapabilityModule(t, custom, "kv_client", `capabilities:
+  accepts: [store.arn]
+inputs:
+  - name: target_arn
+    type: string
+    required: true
+    capability: store.arn
+`)
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{
+			{ID: "a", Type: "kv_store", Source: "custom"},
+			{ID: "b", Type: "kv_store", Source: "custom"},
+			{ID: "client", Type: "kv_client", Source: "custom"},
+		},
+	}
+
+	g, err := NewGenerator(envCfg, nil, modRoot, custom, "dev", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err == nil || !strings.Contains(err.Error(), `accepts capability "store.arn", which multiple modules provide: a.store_arn, b.store_arn`) ||
+		!strings.Contains(err.Error(), "defaultProviders.store.arn") {
+		t.Fatalf("expected a capability ambiguity error pointing at defaultProviders, got %v", err)
+	}
+
+	envCfg.DefaultProviders = map[string]string{"store.arn": "b"}
+	outDir := t.TempDir()
+	g, err = NewGenerator(envCfg, nil, modRoot, custom, "dev", outDir, "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "client.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(strings.Fields(string(data)), " ")
+	for _, want := range []string{"target_arn = module.b.store_arn", "depends_on = [ module.b ]"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in client.tf, got:\n%s", want, data)
+		}
+	}
+}
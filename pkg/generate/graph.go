@@ -0,0 +1,254 @@
+package generate
+
+import (
+	"fmt"
+	"strings"
+
+	"pltf/pkg/config"
+)
+
+// depReason is why a module depends on another: what describes the edge to a reader and path is
+// the spec key under the module that creates it, such as inputs.vpc_id or wire.vpc_id.
+type depReason struct {
+	what string
+	path string
+}
+
+func inputDep(name string) depReason {
+	return depReason{what: "input " + name, path: "inputs." + name}
+}
+
+// moduleReference is a module.<target>.<output> reference in module's inputs.
+type moduleReference struct {
+	module string
+	why    depReason
+	target string
+	output string
+}
+
+// inputReason describes an edge created by input name, attributing the inputs that links generate
+// (iam_policy, kubernetes_trusts) to links.
+func (g *Generator) inputReason(modID, name string) depReason {
+	if aug, ok := g.iamAugmentations[modID]; ok {
+		if (name == "iam_policy" && len(aug.IamPolicy) > 0) || (name == "kubernetes_trusts" && len(aug.KubernetesTrusts) > 0) {
+			return depReason{what: "links (" + name + ")", path: "links"}
+		}
+	}
+	return inputDep(name)
+}
+
+// dependencyCycles returns the cycles among the modules of the stack being generated, each as a
+// path that starts and ends with the same module. Each cycle is reported once, starting from its
+// smallest module id.
+func (g *Generator) dependencyCycles(mods []config.Module) [][]string {
+	inStack := make(map[string]struct{}, len(mods))
+	for _, m := range mods {
+		inStack[m.ID] = struct{}{}
+	}
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	seen := map[string]struct{}{}
+	var (
+		stack  []string
+		cycles [][]string
+		visit  func(id string)
+	)
+	visit = func(id string) {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, dep := range g.sortedDeps(id) {
+			if _, ok := inStack[dep]; !ok {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				start := len(stack) - 1
+				for stack[start] != dep {
+					start--
+				}
+				cycle := rotateCycle(stack[start:])
+				key := strings.Join(cycle, " ")
+				if _, dup := seen[key]; !dup {
+					seen[key] = struct{}{}
+					cycles = append(cycles, append(cycle, cycle[0]))
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+	}
+	for _, id := range sortedKeys(inStack) {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+	return cycles
+}
+
+// rotateCycle returns a copy of cycle starting at its smallest id.
+func rotateCycle(cycle []string) []string {
+	first := 0
+	for i, id := range cycle {
+		if id < cycle[first] {
+			first = i
+		}
+	}
+	return append(append([]string{}, cycle[first:]...), cycle[:first]...)
+}
+
+// formatCycle renders a cycle as "a" -> "b" (input x) -> "a" (wire.y).
+func (g *Generator) formatCycle(cycle []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%q", cycle[0])
+	for i := 1; i < len(cycle); i++ {
+		fmt.Fprintf(&b, " -> %q (%s)", cycle[i], g.moduleDeps[cycle[i-1]][cycle[i]].what)
+	}
+	return b.String()
+}
+
+// checkCycles fails when the modules of the stack depend on each other in a cycle, which
+// Terraform would reject.
+func (g *Generator) checkCycles(mods []config.Module) error {
+	cycles := g.dependencyCycles(mods)
+	if len(cycles) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(cycles))
+	for i, c := range cycles {
+		msgs[i] = g.formatCycle(c)
+	}
+	return fmt.Errorf("module dependency cycle: %s", strings.Join(msgs, "; "))
+}
+
+// resolveGraph resolves every module's inputs as render does, without writing files, so
+// moduleDeps holds the stack's full dependency graph.
+func (g *Generator) resolveGraph() error {
+	for _, m := range g.stackModules() {
+		if _, err := g.moduleFile(g.applyAugmentations(m), g.moduleMetas[m.ID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stackModules returns the modules rendered into this stack: the Service's modules for a service,
+// the Environment's otherwise.
+func (g *Generator) stackModules() []config.Module {
+	if g.isService {
+		return g.svcModules
+	}
+	return g.envModules
+}
+
+// DiagnoseGraph builds the stack's module dependency graph from explicit references, wiring,
+// auto-wired inputs and links, and reports cycles, modules that refer to themselves or to modules
+// outside the stack, and Service references to Environment modules, which the service stack
+// cannot reach. Paths point into the spec that declares the module.
+func (g *Generator) DiagnoseGraph() (config.Diagnostics, error) {
+	if err := g.resolveGraph(); err != nil {
+		return nil, err
+	}
+	paths := g.specModulePaths()
+	at := func(modID string, why depReason) string {
+		return paths[modID] + "." + why.path
+	}
+	context := "environment"
+	if g.isService {
+		context = "service"
+	}
+
+	var ds config.Diagnostics
+	for _, c := range g.dependencyCycles(g.stackModules()) {
+		ds.Errorf(at(c[0], g.moduleDeps[c[0]][c[1]]), "module dependency cycle: %s", g.formatCycle(c))
+	}
+	for _, r := range g.badRefs {
+		ref := fmt.Sprintf("module.%s.%s", r.target, r.output)
+		switch scope, known := g.moduleScopes[r.target]; {
+		case r.target == r.module:
+			ds.Errorf(at(r.module, r.why), "module %q refers to itself in %s (%s)", r.module, r.why.what, ref)
+		case !known:
+			ds.Errorf(at(r.module, r.why), "module %q %s refers to %s, but %q is not a module in this %s",
+				r.module, r.why.what, ref, r.target, context)
+		case scope == scopeEnv:
+			if !declaresOutput(g.moduleMetas[r.target], r.output) {
+				ds.Warnf(at(r.module, r.why), "module %q %s refers to %s, but %q is an Environment module and does not export output %q",
+					r.module, r.why.what, ref, r.target, r.output)
+				continue
+			}
+			ds.Warnf(at(r.module, r.why), "module %q %s refers to %s, which belongs to the Environment and is not in the service stack; use ${parent.%s}",
+				r.module, r.why.what, ref, g.envOutputName(r.target, r.output))
+		}
+	}
+	return ds, nil
+}
+
+// specModulePaths maps each module block of the stack to modules[i] of the spec that declares it;
+// instances of an expanded group map to the group.
+func (g *Generator) specModulePaths() map[string]string {
+	out := map[string]string{}
+	for id, sm := range g.specModuleIndex() {
+		if sm.service == g.isService {
+			out[id] = sm.path
+		}
+	}
+	return out
+}
+
+// specModule is the spec entry a module block comes from.
+type specModule struct {
+	id      string // id in the spec: the group for instances of an expanded group
+	path    string // modules[i]
+	service bool   // declared by the Service spec rather than the Environment
+}
+
+// specModuleIndex maps every module block in scope, Environment and Service, to its spec entry.
+// Environment modules the active environment disables are not in scope.
+func (g *Generator) specModuleIndex() map[string]specModule {
+	if g.specModules != nil {
+		return g.specModules
+	}
+	g.specModules = map[string]specModule{}
+	g.indexSpecModules(g.envCfg.Modules, g.envEntry.Modules, false)
+	if g.isService {
+		g.indexSpecModules(g.svcCfg.Modules, nil, true)
+	}
+	return g.specModules
+}
+
+func (g *Generator) indexSpecModules(mods []config.Module, overrides map[string]config.ModuleOverride, service bool) {
+	for i, m := range mods {
+		if overrides[m.ID].Disabled() {
+			continue
+		}
+		sm := specModule{id: m.ID, path: fmt.Sprintf("modules[%d]", i), service: service}
+		g.specModules[m.ID] = sm
+		if !m.FanOut() || !m.Expand {
+			continue
+		}
+		insts, err := m.Instances()
+		if err != nil {
+			continue
+		}
+		for _, inst := range insts {
+			g.specModules[m.InstanceID(inst.Key)] = sm
+		}
+	}
+}
+
+func declaresOutput(meta *config.ModuleMetadata, name string) bool {
+	if meta == nil {
+		return false
+	}
+	for _, out := range meta.Outputs {
+		if out.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"pltf/modules"
+	"pltf/pkg/config"
+)
+
+func TestGeneratorReportsDependencyCycles(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{
+			{ID: "net", Type: "aws_base", Inputs: map[string]interface{}{
+				"vpc_id":             "${module.edge.vpc_id}",
+				"private_subnet_ids": []interface{}{"subnet-1"},
+			}},
+			{ID: "edge", Type: "aws_base", Wire: map[string]string{"vpc_id": "net.vpc_id"}, Inputs: map[string]interface{}{
+				"private_subnet_ids": []interface{}{"subnet-2"},
+			}},
+			{ID: "dns", Type: "aws_dns", Inputs: map[string]interface{}{
+				"domain":            "${module.dns.domain}",
+				"external_cert_arn": "${module.gone.cert_arn}",
+			}},
+		},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	ds, err := g.DiagnoseGraph()
+	if err != nil {
+		t.Fatalf("DiagnoseGraph error: %v", err)
+	}
+	if n := len(ds.Errors()); n != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", n, ds)
+	}
+	wants := map[string]string{
+		"modules[1].wire.vpc_id":              `module dependency cycle: "edge" -> "net" (wire.vpc_id) -> "edge" (input vpc_id)`,
+		"modules[2].inputs.domain":            `module "dns" refers to itself in input domain (module.dns.domain)`,
+		"modules[2].inputs.external_cert_arn": `refers to module.gone.cert_arn, but "gone" is not a module in this environment`,
+	}
+	for _, d := range ds {
+		want, ok := wants[d.Path]
+		if !ok || !strings.Contains(d.Message, want) {
+			t.Fatalf("unexpected diagnostic at %s: %s", d.Path, d.Message)
+		}
+	}
+
+	g, err = NewGenerator(envCfg, nil, modRoot, "", "dev", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err == nil || !strings.Contains(err.Error(), "module dependency cycle") {
+		t.Fatalf("expected Generate to reject the cycle, got %v", err)
+	}
+}
+
+func TestGeneratorWarnsAboutServiceReferencesToEnvModules(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{{ID: "base", Type: "aws_base"}},
+	}
+	svcCfg := &config.ServiceConfig{
+		Metadata: config.ServiceMetadata{Name: "payments", EnvRef: map[string]config.ServiceEnvRefEntry{"dev": {}}},
+		Modules: []config.Module{
+			{ID: "eks", Type: "aws_eks", Inputs: map[string]interface{}{
+				"vpc_id":             "${module.base.vpc_id}",
+				"private_subnet_ids": "${module.base.app_subnet_ids}",
+				"cluster_name":       "svc-dev",
+				"enable_metrics":     true,
+			}},
+		},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+
+	g, err := NewGenerator(envCfg, svcCfg, modRoot, "", "dev", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	ds, err := g.DiagnoseGraph()
+	if err != nil {
+		t.Fatalf("DiagnoseGraph error: %v", err)
+	}
+	if ds.HasErrors() || len(ds) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", ds)
+	}
+	ds.Sort()
+	if ds[0].Path != "modules[0].inputs.private_subnet_ids" || !strings.Contains(ds[0].Message, `does not export output "app_subnet_ids"`) {
+		t.Fatalf("unexpected warning: %v", ds[0])
+	}
+	if ds[1].Path != "modules[0].inputs.vpc_id" || !strings.Contains(ds[1].Message, "use ${parent.vpc_id}") {
+		t.Fatalf("unexpected warning: %v", ds[1])
+	}
+}
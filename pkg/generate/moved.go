@@ -0,0 +1,95 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"pltf/pkg/config"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// movedFileName holds a moved block for every previous id of the stack's modules.
+const movedFileName = "moved.tf"
+
+// writeMovedFile moves the state of each module from the addresses it had under its previousIds.
+// Instances of an expanded group move from <previous id>_<key>. Nothing is written when no module
+// was renamed.
+func (g *Generator) writeMovedFile(mods []config.Module) error {
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+	n := 0
+	for _, m := range mods {
+		suffix := ""
+		if sm, ok := g.specModuleIndex()[m.ID]; ok && sm.id != m.ID {
+			suffix = strings.TrimPrefix(m.ID, sm.id)
+		}
+		for _, prev := range m.PreviousIDs {
+			if n > 0 {
+				body.AppendNewline()
+			}
+			b := body.AppendNewBlock("moved", nil).Body()
+			b.SetAttributeTraversal("from", moduleTraversal(prev+suffix))
+			b.SetAttributeTraversal("to", moduleTraversal(m.ID))
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(g.outDir, movedFileName), file.Bytes(), 0o644)
+}
+
+func moduleTraversal(id string) hcl.Traversal {
+	return hcl.Traversal{hcl.TraverseRoot{Name: "module"}, hcl.TraverseAttr{Name: id}}
+}
+
+// renameHints compares the stack with prev, the source map of the previous generate into the same
+// directory, and describes modules that look renamed: one no longer generated and a new one of
+// the same type, with no previousIds linking them.
+func (g *Generator) renameHints(prev *SourceMap, mods []config.Module) []string {
+	if prev == nil || prev.Env != g.envKey || prev.Modules == nil {
+		return nil
+	}
+	current := map[string]string{}
+	claimed := map[string]struct{}{}
+	for _, m := range mods {
+		current[m.ID] = g.moduleMetas[m.ID].Type
+		suffix := ""
+		if sm, ok := g.specModuleIndex()[m.ID]; ok && sm.id != m.ID {
+			suffix = strings.TrimPrefix(m.ID, sm.id)
+		}
+		for _, p := range m.PreviousIDs {
+			claimed[p+suffix] = struct{}{}
+		}
+	}
+
+	var hints []string
+	for _, gone := range sortedKeys(prev.Modules) {
+		if _, ok := current[gone]; ok {
+			continue
+		}
+		if _, ok := claimed[gone]; ok {
+			continue
+		}
+		typ := prev.Modules[gone].Type
+		var candidates []string
+		for id, t := range current {
+			if _, existed := prev.Modules[id]; !existed && t == typ {
+				candidates = append(candidates, fmt.Sprintf("%q", id))
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		sort.Strings(candidates)
+		hints = append(hints, fmt.Sprintf(
+			"module %q (%s) is no longer generated and %s of the same type is new; if it was renamed, add previousIds: [%s] to it so Terraform moves its state instead of destroying it",
+			gone, typ, strings.Join(candidates, " or "), gone))
+	}
+	return hints
+}
@@ -0,0 +1,88 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pltf/modules"
+	"pltf/pkg/config"
+)
+
+func TestGeneratorWritesMovedBlocksForPreviousIDs(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{
+			{ID: "network", Type: "aws_base", PreviousIDs: []string{"base"}},
+			{ID: "zones", Type: "aws_dns", ForEach: []interface{}{"a.example.com"}, Expand: true, PreviousIDs: []string{"dns"}, Inputs: map[string]interface{}{
+				"domain":            "${each.key}",
+				"external_cert_arn": "arn:aws:acm:us-east-1:111111111111:certificate/x",
+			}},
+		},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+
+	outDir := t.TempDir()
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", outDir, "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, movedFileName))
+	if err != nil {
+		t.Fatalf("read %s: %v", movedFileName, err)
+	}
+	want := "moved {\n  from = module.base\n  to   = module.network\n}\n\n" +
+		"moved {\n  from = module.dns_a_example_com\n  to   = module.zones_a_example_com\n}\n"
+	if string(data) != want {
+		t.Fatalf("unexpected %s:\n%s", movedFileName, data)
+	}
+}
+
+func TestGeneratorWarnsAboutModulesThatLookRenamed(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{{ID: "base", Type: "aws_base"}},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+	outDir := t.TempDir()
+	generate := func() Changes {
+		t.Helper()
+		g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", outDir, "", nil)
+		if err != nil {
+			t.Fatalf("NewGenerator error: %v", err)
+		}
+		if err := g.Generate(); err != nil {
+			t.Fatalf("Generate error: %v", err)
+		}
+		return g.Changes()
+	}
+	if c := generate(); len(c.Warnings) != 0 {
+		t.Fatalf("expected no warnings on the first run, got %v", c.Warnings)
+	}
+
+	envCfg.Modules[0].ID = "network"
+	c := generate()
+	if len(c.Warnings) != 1 || !strings.Contains(c.Warnings[0], `module "base" (aws_base) is no longer generated and "network" of the same type is new`) ||
+		!strings.Contains(c.Warnings[0], "add previousIds: [base]") {
+		t.Fatalf("expected a rename warning, got %v", c.Warnings)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, movedFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no %s without previousIds, got %v", movedFileName, err)
+	}
+}
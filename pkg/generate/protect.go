@@ -0,0 +1,63 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+
+	"pltf/pkg/config"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// ProtectedOutput is the root output listing, per module block, the resources that plan and
+	// apply must not delete or replace. Living in the state, it keeps guarding a module's resources
+	// after the module leaves the spec, until an apply records that they are no longer protected.
+	ProtectedOutput = "pltf_protected"
+
+	protectedFileName = "protected.tf"
+)
+
+// protection returns the resources of module block m that are protected: the spec's protect
+// (after environment overrides) or else the module.yaml defaults.
+func (g *Generator) protection(m config.Module) []string {
+	if m.Protect != nil {
+		return m.Protect.Patterns()
+	}
+	if meta := g.moduleMetas[m.ID]; meta != nil {
+		return meta.Protect
+	}
+	return nil
+}
+
+// writeProtectedFile writes the ProtectedOutput output. Nothing is written when no module protects
+// any resource.
+func (g *Generator) writeProtectedFile(mods []config.Module) error {
+	protected := map[string]cty.Value{}
+	for _, m := range mods {
+		patterns := g.protection(m)
+		if len(patterns) == 0 {
+			continue
+		}
+		vals := make([]cty.Value, len(patterns))
+		for i, p := range patterns {
+			vals[i] = cty.StringVal(p)
+		}
+		protected[m.ID] = cty.ListVal(vals)
+	}
+	if len(protected) == 0 {
+		return nil
+	}
+
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+	body.AppendUnstructuredTokens(hclwrite.Tokens{
+		{Type: hclsyntax.TokenComment, Bytes: []byte("# Resources pltf terraform plan/apply refuse to delete or replace without --allow-destroy=<module>.\n")},
+	})
+	b := body.AppendNewBlock("output", []string{ProtectedOutput}).Body()
+	b.SetAttributeValue("description", cty.StringVal("Protected resources by pltf module id"))
+	b.SetAttributeValue("value", cty.ObjectVal(protected))
+	return os.WriteFile(filepath.Join(g.outDir, protectedFileName), file.Bytes(), 0o644)
+}
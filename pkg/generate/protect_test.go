@@ -0,0 +1,55 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pltf/modules"
+	"pltf/pkg/config"
+)
+
+func TestGeneratorWritesProtectedOutput(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev": {Account: "111111111111", Region: "us-east-1"},
+		},
+		Modules: []config.Module{
+			{ID: "base", Type: "aws_base"},
+			{ID: "logs", Type: "aws_s3", Inputs: map[string]interface{}{"bucket_name": "logs"}},
+			{ID: "assets", Type: "aws_s3", Inputs: map[string]interface{}{"bucket_name": "assets"}, Protect: &config.Protection{}},
+			{ID: "dns", Type: "aws_dns", Protect: &config.Protection{All: true}, Inputs: map[string]interface{}{
+				"domain":            "example.com",
+				"external_cert_arn": "arn:aws:acm:us-east-1:111111111111:certificate/x",
+			}},
+		},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+
+	outDir := t.TempDir()
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", outDir, "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, protectedFileName))
+	if err != nil {
+		t.Fatalf("read %s: %v", protectedFileName, err)
+	}
+	got := string(data)
+	for _, want := range []string{`output "pltf_protected"`, `dns  = ["*"]`, `logs = ["aws_s3_bucket"]`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in %s:\n%s", want, protectedFileName, got)
+		}
+	}
+	if strings.Contains(got, "assets") || strings.Contains(got, "base") {
+		t.Fatalf("expected only protected modules in %s:\n%s", protectedFileName, got)
+	}
+}
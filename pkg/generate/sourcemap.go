@@ -0,0 +1,292 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pltf/pkg/config"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// SourceMapFile is written next to the generated Terraform and records where the value of every
+// module input comes from. pltf explain reads it.
+const SourceMapFile = "pltf-sourcemap.json"
+
+// Sources of a module input, following the order in which resolveInput tries them.
+const (
+	SourceInput      = "input"      // inputs of the module in the spec
+	SourceOverride   = "override"   // environments.<env>.modules.<id>.inputs
+	SourceLinks      = "links"      // generated from the module's links
+	SourceWire       = "wire"       // wire of the module
+	SourcePlatform   = "platform"   // env_name, layer_name and module_name
+	SourceCapability = "capability" // the module output providing the input's capability
+	SourceAutoWire   = "auto-wire"  // the module output named like the input
+	SourceVar        = "var"        // a merged variable
+	SourceDefault    = "default"    // the default in module.yaml
+)
+
+// SourceMap records, for one generated stack, where each module input comes from.
+type SourceMap struct {
+	Kind    string                   `json:"kind"` // Environment or Service
+	Name    string                   `json:"name"`
+	Env     string                   `json:"env"`
+	Modules map[string]ModuleSources `json:"modules"` // by module block id
+}
+
+// ModuleSources is the spec entry of a module block and the origin of each input it sets.
+type ModuleSources struct {
+	Type   string                 `json:"type"`
+	File   string                 `json:"file"` // generated file, relative to the output directory
+	Spec   *SpecLocation          `json:"spec,omitempty"`
+	Inputs map[string]InputSource `json:"inputs"`
+}
+
+// InputSource is where a module input comes from. Value is the expression rendered for it.
+type InputSource struct {
+	Source string        `json:"source"`
+	Detail string        `json:"detail,omitempty"`
+	Value  string        `json:"value"`
+	Spec   *SpecLocation `json:"spec,omitempty"`
+}
+
+// SpecLocation is a key in a spec. File is relative to the directory of the spec being generated.
+type SpecLocation struct {
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Path string `json:"path"`
+}
+
+func (l SpecLocation) String() string {
+	switch {
+	case l.File != "" && l.Line > 0:
+		return fmt.Sprintf("%s:%d (%s)", l.File, l.Line, l.Path)
+	case l.File != "":
+		return fmt.Sprintf("%s (%s)", l.File, l.Path)
+	}
+	return l.Path
+}
+
+// ReadSourceMap reads the source map of the stack generated into outDir.
+func ReadSourceMap(outDir string) (*SourceMap, error) {
+	path := filepath.Join(outDir, SourceMapFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sm SourceMap
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &sm, nil
+}
+
+// origin is how resolveInput set an input: the source, a description for readers, and the spec
+// key that supplied the value, if any.
+type origin struct {
+	source string
+	detail string
+	at     specKey
+}
+
+// specKey is a path in the Environment spec, or in the Service spec when service is set.
+type specKey struct {
+	path    string
+	service bool
+}
+
+// moduleKey is the key rest (such as .inputs.vpc_id) under the spec entry of module block modID.
+func (g *Generator) moduleKey(modID, rest string) specKey {
+	sm, ok := g.specModuleIndex()[modID]
+	if !ok {
+		return specKey{}
+	}
+	return specKey{path: sm.path + rest, service: sm.service}
+}
+
+// inputOrigin attributes an input set in the module's inputs to links, an environment override
+// or the module entry itself.
+func (g *Generator) inputOrigin(m config.Module, name string) origin {
+	if g.inputReason(m.ID, name).path == "links" {
+		return origin{source: SourceLinks, detail: "generated from the module's links", at: g.moduleKey(m.ID, ".links")}
+	}
+	if sm := g.specModuleIndex()[m.ID]; !sm.service {
+		if _, ok := g.envEntry.Modules[sm.id].Inputs[name]; ok {
+			return origin{
+				source: SourceOverride,
+				detail: "override for environment " + g.envKey,
+				at:     specKey{path: fmt.Sprintf("environments.%s.modules.%s.inputs.%s", g.envKey, sm.id, name)},
+			}
+		}
+	}
+	return origin{source: SourceInput, at: g.moduleKey(m.ID, ".inputs."+name)}
+}
+
+func (g *Generator) wireOrigin(m config.Module, name string) origin {
+	return origin{source: SourceWire, detail: "wired from " + m.Wire[name], at: g.moduleKey(m.ID, ".wire."+name)}
+}
+
+// providerOrigin attributes an auto-wired input to output of module modID, pointing at the spec
+// entry of that module.
+func (g *Generator) providerOrigin(source, capability, modID, output string) origin {
+	detail := fmt.Sprintf("output %s of module %q", output, modID)
+	if g.isService && g.moduleScopes[modID] == scopeEnv {
+		detail = fmt.Sprintf("output %s of Environment module %q, read as parent.%s", output, modID, g.envOutputName(modID, output))
+	}
+	if capability != "" {
+		detail = "capability " + capability + ": " + detail
+	}
+	return origin{source: source, detail: detail, at: g.moduleKey(modID, "")}
+}
+
+// varOrigin points at the value of variable name that wins in getMergedVars.
+func (g *Generator) varOrigin(name string) origin {
+	from := origin{source: SourceVar, detail: "variable " + name}
+	if _, ok := g.cliVars[name]; ok {
+		from.detail += " (set with --var)"
+		return from
+	}
+	if _, ok := g.svcEnvEntry.Variables[name]; ok && g.isService {
+		from.at = specKey{path: fmt.Sprintf("metadata.envRef.%s.variables.%s", g.envKey, name), service: true}
+		return from
+	}
+	if _, ok := g.envEntry.Variables[name]; ok {
+		from.at = specKey{path: fmt.Sprintf("environments.%s.variables.%s", g.envKey, name)}
+		return from
+	}
+	if g.isService {
+		if spec, ok := g.svcCfg.Variables[name]; ok && spec.Default != nil {
+			from.at = specKey{path: "variables." + name + ".default", service: true}
+			return from
+		}
+	}
+	if spec, ok := g.envCfg.Variables[name]; ok && spec.Default != nil {
+		from.at = specKey{path: "variables." + name + ".default"}
+		return from
+	}
+	switch name {
+	case "account_id":
+		from.at = specKey{path: fmt.Sprintf("environments.%s.account", g.envKey)}
+	case "region":
+		from.at = specKey{path: fmt.Sprintf("environments.%s.region", g.envKey)}
+	case "environment":
+		from.at = specKey{path: "metadata.name"}
+	case "global_tags":
+		from.at = specKey{path: "metadata.labels", service: g.isService}
+	}
+	return from
+}
+
+// locate resolves k to a file and line through the spec's source index.
+func (g *Generator) locate(k specKey) *SpecLocation {
+	if k.path == "" {
+		return nil
+	}
+	idx := g.envCfg.Sources
+	if k.service {
+		idx = g.svcCfg.Sources
+	}
+	loc := &SpecLocation{Path: k.path}
+	if pos, ok := idx.Lookup(k.path); ok {
+		loc.File, loc.Line = g.specFile(pos.File), pos.Line
+	} else if idx != nil {
+		loc.File = g.specFile(idx.File)
+	}
+	return loc
+}
+
+// specFile makes file relative to the spec directory so the source map does not depend on where
+// pltf runs.
+func (g *Generator) specFile(file string) string {
+	if file == "" || g.specDir == "" {
+		return filepath.ToSlash(file)
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	dir, err := filepath.Abs(g.specDir)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// provenanceHeader is the comment on top of a module file naming the spec entry it comes from.
+// It leaves out line numbers, which live in the source map, so unrelated spec edits do not touch
+// every module file.
+func (g *Generator) provenanceHeader(m config.Module) hclwrite.Tokens {
+	in := ""
+	if loc := g.locate(g.moduleKey(m.ID, "")); loc != nil && loc.File != "" {
+		in = " in " + loc.File
+	}
+	text := fmt.Sprintf("# Generated by pltf from module %q (%s)%s; do not edit.\n"+
+		"# Run `pltf explain %s.<input>` to see where an input's value comes from.\n", m.ID, m.Type, in, m.ID)
+	return hclwrite.Tokens{
+		{Type: hclsyntax.TokenComment, Bytes: []byte(text)},
+		{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+	}
+}
+
+// startSources starts recording the inputs of module block m.
+func (g *Generator) startSources(m config.Module, meta *config.ModuleMetadata) {
+	g.sources[m.ID] = ModuleSources{
+		Type:   meta.Type,
+		File:   m.ID + ".tf",
+		Spec:   g.locate(g.moduleKey(m.ID, "")),
+		Inputs: map[string]InputSource{},
+	}
+}
+
+// trace records where input of module modID comes from.
+func (g *Generator) trace(modID, input string, from origin) {
+	ms, ok := g.sources[modID]
+	if !ok || from.source == "" {
+		return
+	}
+	ms.Inputs[input] = InputSource{Source: from.source, Detail: from.detail, Spec: g.locate(from.at)}
+}
+
+// finishSources fills in the rendered expression of each traced input and drops the inputs
+// that ended up unset.
+func (g *Generator) finishSources(modID string, body *hclwrite.Body) {
+	ms, ok := g.sources[modID]
+	if !ok {
+		return
+	}
+	for name, in := range ms.Inputs {
+		attr := body.GetAttribute(name)
+		if attr == nil {
+			delete(ms.Inputs, name)
+			continue
+		}
+		in.Value = strings.TrimSpace(string(hclwrite.Format(attr.Expr().BuildTokens(nil).Bytes())))
+		ms.Inputs[name] = in
+	}
+}
+
+// writeSourceMap writes SourceMapFile for the module blocks of the stack.
+func (g *Generator) writeSourceMap(mods []config.Module) error {
+	sm := SourceMap{Kind: "Environment", Name: g.envName, Env: g.envKey, Modules: map[string]ModuleSources{}}
+	if g.isService {
+		sm.Kind, sm.Name = "Service", g.svcCfg.Metadata.Name
+	}
+	for _, m := range mods {
+		if ms, ok := g.sources[m.ID]; ok {
+			sm.Modules[m.ID] = ms
+		}
+	}
+	data, err := json.MarshalIndent(sm, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(g.outDir, SourceMapFile), append(data, '\n'), 0o644)
+}
@@ -0,0 +1,106 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pltf/modules"
+	"pltf/pkg/config"
+)
+
+const sourceMapEnvSpec = `apiVersion: platform.io/v1
+kind: Environment
+metadata:
+  name: example
+  org: testorg
+  provider: aws
+environments:
+  dev:
+    account: "111111111111"
+    region: us-east-1
+    variables:
+      k8s_version: "1.29"
+    modules:
+      eks:
+        inputs:
+          cluster_name: dev-eks
+modules:
+  - id: base
+    type: aws_base
+  - id: eks
+    type: aws_eks
+    wire:
+      vpc_id: base.vpc_id
+    inputs:
+      cluster_name: eks
+      enable_metrics: true
+`
+
+func TestGeneratorWritesSourceMap(t *testing.T) {
+	specDir := t.TempDir()
+	specPath := filepath.Join(specDir, "env.yaml")
+	if err := os.WriteFile(specPath, []byte(sourceMapEnvSpec), 0o644); err != nil {
+		t.Fatalf("write spec: %v", err)
+	}
+	envCfg, err := config.LoadEnvironmentConfig(specPath)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "dev", outDir, specDir, nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	sm, err := ReadSourceMap(outDir)
+	if err != nil {
+		t.Fatalf("read source map: %v", err)
+	}
+	if sm.Kind != "Environment" || sm.Env != "dev" {
+		t.Fatalf("unexpected source map header: %+v", sm)
+	}
+	eks, ok := sm.Modules["eks"]
+	if !ok || eks.File != "eks.tf" || eks.Spec == nil || eks.Spec.String() != "env.yaml:20 (modules[1])" {
+		t.Fatalf("unexpected eks entry: %+v", eks)
+	}
+	wants := map[string]struct{ source, value, spec string }{
+		"cluster_name":        {SourceOverride, `"dev-eks"`, "env.yaml:16 (environments.dev.modules.eks.inputs.cluster_name)"},
+		"enable_metrics":      {SourceInput, "true", "env.yaml:26 (modules[1].inputs.enable_metrics)"},
+		"vpc_id":              {SourceWire, "module.base.vpc_id", "env.yaml:23 (modules[1].wire.vpc_id)"},
+		"kms_account_key_arn": {SourceAutoWire, "module.base.kms_account_key_arn", "env.yaml:18 (modules[0])"},
+		"k8s_version":         {SourceVar, "local.k8s_version", "env.yaml:12 (environments.dev.variables.k8s_version)"},
+		"module_name":         {SourcePlatform, `"eks"`, "env.yaml:20 (modules[1].id)"},
+		"max_nodes":           {SourceDefault, "5", ""},
+	}
+	for name, want := range wants {
+		got, ok := eks.Inputs[name]
+		if !ok {
+			t.Fatalf("source map has no entry for eks.%s", name)
+		}
+		spec := ""
+		if got.Spec != nil {
+			spec = got.Spec.String()
+		}
+		if got.Source != want.source || got.Value != want.value || spec != want.spec {
+			t.Fatalf("eks.%s: got %+v (spec %q), want %+v", name, got, spec, want)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "eks.tf"))
+	if err != nil {
+		t.Fatalf("read eks.tf: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "# Generated by pltf from module \"eks\" (aws_eks) in env.yaml; do not edit.\n") {
+		t.Fatalf("expected a provenance header, got:\n%s", data)
+	}
+}
@@ -0,0 +1,192 @@
+package generate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Changes reports how a Generate run changed the output directory. Paths are relative to it and
+// slash-separated.
+type Changes struct {
+	Added     []string
+	Updated   []string
+	Removed   []string
+	Unchanged int
+	// Warnings are findings about the new output that need attention, such as modules that look
+	// renamed without previousIds.
+	Warnings []string
+}
+
+// Empty reports whether the output directory was left as it was.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0
+}
+
+func (c Changes) String() string {
+	return fmt.Sprintf("%d added, %d updated, %d removed, %d unchanged", len(c.Added), len(c.Updated), len(c.Removed), c.Unchanged)
+}
+
+// preserved reports whether rel belongs to Terraform rather than to the generated configuration:
+// the provider/module cache, the dependency lock file, local state and saved plans. These are
+// never compared, replaced or removed.
+func preserved(rel string) bool {
+	first := strings.SplitN(rel, "/", 2)[0]
+	base := filepath.Base(rel)
+	return first == ".terraform" ||
+		base == ".terraform.lock.hcl" ||
+		strings.HasPrefix(base, "terraform.tfstate") ||
+		strings.HasSuffix(base, ".tfplan") ||
+		strings.HasPrefix(base, ".pltf-plan")
+}
+
+// hashTree returns the sha256 of every regular file under root, keyed by slash-separated relative
+// path, skipping preserved paths. A missing root is an empty tree.
+func hashTree(root string) (map[string][32]byte, error) {
+	out := map[string][32]byte{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && os.IsNotExist(err) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if preserved(rel) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		out[rel] = sum
+		return nil
+	})
+	return out, err
+}
+
+// TreeHash returns a hex sha256 over the generated files in dir and their relative paths, skipping
+// the paths Terraform owns. Two output directories hash the same when generate would leave one
+// exactly like the other.
+func TreeHash(dir string) (string, error) {
+	sums, err := hashTree(dir)
+	if err != nil {
+		return "", err
+	}
+	rels := make([]string, 0, len(sums))
+	for rel := range sums {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	h := sha256.New()
+	for _, rel := range rels {
+		sum := sums[rel]
+		fmt.Fprintf(h, "%s\x00%x\n", rel, sum[:])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func hashFile(path string) ([32]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return [32]byte{}, err
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// diffTrees compares a freshly rendered tree (src) with an existing output directory (dst).
+func diffTrees(src, dst string) (Changes, error) {
+	var c Changes
+	want, err := hashTree(src)
+	if err != nil {
+		return c, err
+	}
+	have, err := hashTree(dst)
+	if err != nil {
+		return c, err
+	}
+	for rel, sum := range want {
+		old, ok := have[rel]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, rel)
+		case !bytes.Equal(old[:], sum[:]):
+			c.Updated = append(c.Updated, rel)
+		default:
+			c.Unchanged++
+		}
+	}
+	for rel := range have {
+		if _, ok := want[rel]; !ok {
+			c.Removed = append(c.Removed, rel)
+		}
+	}
+	sort.Strings(c.Added)
+	sort.Strings(c.Updated)
+	sort.Strings(c.Removed)
+	return c, nil
+}
+
+// syncTree makes dst match the staged tree src, touching only files whose content changed, and
+// leaves preserved paths alone. Files are moved out of src, so src is consumed.
+func syncTree(src, dst string) (Changes, error) {
+	c, err := diffTrees(src, dst)
+	if err != nil {
+		return c, err
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return c, err
+	}
+	for _, rel := range append(append([]string{}, c.Added...), c.Updated...) {
+		from := filepath.Join(src, filepath.FromSlash(rel))
+		to := filepath.Join(dst, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+			return c, err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return c, fmt.Errorf("update %s: %w", rel, err)
+		}
+	}
+	dirs := map[string]struct{}{}
+	for _, rel := range c.Removed {
+		if err := os.Remove(filepath.Join(dst, filepath.FromSlash(rel))); err != nil && !os.IsNotExist(err) {
+			return c, fmt.Errorf("remove %s: %w", rel, err)
+		}
+		for d := filepath.Dir(filepath.FromSlash(rel)); d != "."; d = filepath.Dir(d) {
+			dirs[d] = struct{}{}
+		}
+	}
+	// Drop directories left empty by removals, deepest first.
+	ordered := make([]string, 0, len(dirs))
+	for d := range dirs {
+		ordered = append(ordered, d)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return len(ordered[i]) > len(ordered[j]) })
+	for _, d := range ordered {
+		_ = os.Remove(filepath.Join(dst, d)) // fails harmlessly when not empty
+	}
+	return c, nil
+}
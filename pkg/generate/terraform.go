@@ -7,8 +7,11 @@ import (
 	"sort"
 	"strings"
 
+	"pltf/pkg/config"
 	"pltf/pkg/generate/cloud"
 	"pltf/pkg/provider"
+	"pltf/pkg/secrets"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -25,6 +28,7 @@ func writeVersionsTF(
 	providerType string,
 	backendType string,
 	locals map[string]interface{},
+	asVariables bool,
 	needsK8s bool,
 	needsHelm bool,
 	container string,
@@ -47,15 +51,26 @@ func writeVersionsTF(
 	rpBlock := tfBody.AppendNewBlock("required_providers", nil)
 	p.RequiredProviders(rpBlock.Body(), needsK8s, needsHelm)
 
-	// backend block
-	p.Backend(tfBody, backendBucket, backendKey, backendRegion, backendProfile, container, resourceGroup)
+	// backend block; with asVariables it is partial and each environment's settings are written to
+	// <env>.tfbackend, so the stack can be initialised against any of them
+	if asVariables {
+		scratch := hclwrite.NewEmptyFile()
+		p.Backend(scratch.Body(), backendBucket, backendKey, backendRegion, backendProfile, container, resourceGroup)
+		tfBody.AppendNewBlock("backend", scratch.Body().Blocks()[0].Labels())
+	} else {
+		p.Backend(tfBody, backendBucket, backendKey, backendRegion, backendProfile, container, resourceGroup)
+	}
 
 	body.AppendNewline()
 
-	// locals
+	// locals; with asVariables each one reads the variable of the same name
 	localsBlock := body.AppendNewBlock("locals", nil)
 	localsBody := localsBlock.Body()
 	for _, k := range sortedKeysInterfaceMap(locals) {
+		if asVariables {
+			localsBody.SetAttributeTraversal(k, hcl.Traversal{hcl.TraverseRoot{Name: "var"}, hcl.TraverseAttr{Name: k}})
+			continue
+		}
 		ctyVal, err := toCtyValue(locals[k])
 		if err != nil {
 			return fmt.Errorf("cannot convert local %s to cty: %w", k, err)
@@ -71,6 +86,7 @@ func writeProvidersTF(
 	providerType string,
 	region string,
 	account string,
+	asVariables bool,
 	needsK8s bool,
 	needsHelm bool,
 	cluster *clusterRef,
@@ -87,8 +103,14 @@ func writeProvidersTF(
 		return err
 	}
 
-	// Core provider
-	p.Provider(body, region, account)
+	// Core provider; with asVariables region and account come from var.region and var.account_id
+	regionTokens := hclwrite.TokensForValue(cty.StringVal(region))
+	accountTokens := hclwrite.TokensForValue(cty.StringVal(account))
+	if asVariables {
+		regionTokens = hclwrite.TokensForTraversal(hcl.Traversal{hcl.TraverseRoot{Name: "var"}, hcl.TraverseAttr{Name: "region"}})
+		accountTokens = hclwrite.TokensForTraversal(hcl.Traversal{hcl.TraverseRoot{Name: "var"}, hcl.TraverseAttr{Name: "account_id"}})
+	}
+	p.Provider(body, regionTokens, accountTokens)
 
 	if (needsK8s || needsHelm) && cluster != nil && cluster.auth != nil {
 		body.AppendNewline()
@@ -114,57 +136,91 @@ func writeProvidersTF(
 	return os.WriteFile(filepath.Join(outDir, "providers.tf"), file.Bytes(), 0o644)
 }
 
-func writeRemoteStateTF(outDir string, backendType string, bucket string, key string, region string, container string, resourceGroup string, backendProfile string) error {
+// writeRemoteStateVarTF writes the remote state of the Environment with its settings read from
+// var.pltf_env_state, which the values file of each environment sets.
+func writeRemoteStateVarTF(outDir string, backendType string) error {
+	backend, err := remoteStateBackend(backendType)
+	if err != nil {
+		return err
+	}
 	file := hclwrite.NewEmptyFile()
 	body := file.Body()
+	rsBody := body.AppendNewBlock("data", []string{"terraform_remote_state", "env"}).Body()
+	rsBody.SetAttributeValue("backend", cty.StringVal(backend))
+	rsBody.SetAttributeTraversal("config", hcl.Traversal{hcl.TraverseRoot{Name: "var"}, hcl.TraverseAttr{Name: envStateVariable}})
+	return os.WriteFile(filepath.Join(outDir, "state.tf"), file.Bytes(), 0o644)
+}
 
-	rsBlock := body.AppendNewBlock("data", []string{"terraform_remote_state", "env"})
-	rsBody := rsBlock.Body()
+// remoteStateBackend is the terraform_remote_state backend for a backend type.
+func remoteStateBackend(backendType string) (string, error) {
+	switch backendType {
+	case "aws", "s3", "":
+		return "s3", nil
+	case "gcp", "google", "gcs":
+		return "gcs", nil
+	case "azure", "azurerm":
+		return "azurerm", nil
+	}
+	return "", fmt.Errorf("unsupported backend %q in writeRemoteStateTF", backendType)
+}
 
+// remoteStateConfig is the config of the Environment's remote state.
+func remoteStateConfig(backendType string, bucket string, key string, region string, container string, resourceGroup string, backendProfile string) (map[string]string, error) {
 	switch backendType {
 	case "aws", "s3", "":
-		cfg := map[string]cty.Value{
-			"bucket": cty.StringVal(bucket),
-			"key":    cty.StringVal(key),
-			"region": cty.StringVal(region),
-		}
+		cfg := map[string]string{"bucket": bucket, "key": key, "region": region}
 		if strings.TrimSpace(backendProfile) != "" {
-			cfg["profile"] = cty.StringVal(backendProfile)
+			cfg["profile"] = backendProfile
 		}
-		rsBody.SetAttributeValue("backend", cty.StringVal("s3"))
-		rsBody.SetAttributeValue("config", cty.ObjectVal(cfg))
+		return cfg, nil
 	case "gcp", "google", "gcs":
-		rsBody.SetAttributeValue("backend", cty.StringVal("gcs"))
-		rsBody.SetAttributeValue("config", cty.ObjectVal(map[string]cty.Value{
-			"bucket": cty.StringVal(bucket),
-			"prefix": cty.StringVal(key),
-		}))
+		return map[string]string{"bucket": bucket, "prefix": key}, nil
 	case "azure", "azurerm":
 		if bucket == "" {
-			return fmt.Errorf("backend.bucket (storage account name) is required for azure")
+			return nil, fmt.Errorf("backend.bucket (storage account name) is required for azure")
 		}
 		if container == "" {
 			container = "tfstate"
 		}
-		cfg := map[string]cty.Value{
-			"storage_account_name": cty.StringVal(bucket),
-			"container_name":       cty.StringVal(container),
-			"key":                  cty.StringVal(key),
-		}
+		cfg := map[string]string{"storage_account_name": bucket, "container_name": container, "key": key}
 		if resourceGroup != "" {
-			cfg["resource_group_name"] = cty.StringVal(resourceGroup)
+			cfg["resource_group_name"] = resourceGroup
 		}
-		rsBody.SetAttributeValue("backend", cty.StringVal("azurerm"))
-		rsBody.SetAttributeValue("config", cty.ObjectVal(cfg))
-	default:
-		return fmt.Errorf("unsupported backend %q in writeRemoteStateTF", backendType)
+		return cfg, nil
+	}
+	return nil, fmt.Errorf("unsupported backend %q in writeRemoteStateTF", backendType)
+}
+
+func writeRemoteStateTF(outDir string, backendType string, bucket string, key string, region string, container string, resourceGroup string, backendProfile string) error {
+	backend, err := remoteStateBackend(backendType)
+	if err != nil {
+		return err
 	}
+	cfg, err := remoteStateConfig(backendType, bucket, key, region, container, resourceGroup, backendProfile)
+	if err != nil {
+		return err
+	}
+	vals := map[string]cty.Value{}
+	for k, v := range cfg {
+		vals[k] = cty.StringVal(v)
+	}
+
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+
+	rsBlock := body.AppendNewBlock("data", []string{"terraform_remote_state", "env"})
+	rsBody := rsBlock.Body()
+	rsBody.SetAttributeValue("backend", cty.StringVal(backend))
+	rsBody.SetAttributeValue("config", cty.ObjectVal(vals))
 
 	return os.WriteFile(filepath.Join(outDir, "state.tf"), file.Bytes(), 0o644)
 }
 
-func writeSecretsTF(outDir string, secretNames map[string]bool) error {
-	if len(secretNames) == 0 {
+// writeSecretsTF declares the stack's secrets. Secrets resolved outside Terraform stay sensitive
+// variables (filled from TF_VAR_<name>); data-source secrets are read in place and exposed as
+// sensitive locals.
+func writeSecretsTF(outDir string, refs map[string]config.SecretRef) error {
+	if len(refs) == 0 {
 		return nil
 	}
 
@@ -172,20 +228,67 @@ func writeSecretsTF(outDir string, secretNames map[string]bool) error {
 	body := file.Body()
 
 	// deterministic order
-	names := make([]string, 0, len(secretNames))
-	for name := range secretNames {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 
+	required := map[string]cty.Value{}
+	dataLocals := map[string]hclwrite.Tokens{}
 	for _, name := range names {
-		block := body.AppendNewBlock("variable", []string{name})
-		b := block.Body()
-		// we don't set type: defaults to any, that's OK.
-		b.SetAttributeValue("sensitive", cty.BoolVal(true))
+		r, err := secrets.Lookup(refs[name].Source)
+		if err != nil {
+			return fmt.Errorf("secret %q: %w", name, err)
+		}
+		ds, ok := r.(secrets.DataSourceResolver)
+		if !ok {
+			block := body.AppendNewBlock("variable", []string{name})
+			b := block.Body()
+			// we don't set type: defaults to any, that's OK.
+			b.SetAttributeValue("sensitive", cty.BoolVal(true))
+			body.AppendNewline()
+			continue
+		}
+		value, err := ds.DataSource(body, name, refs[name])
+		if err != nil {
+			return fmt.Errorf("secret %q: %w", name, err)
+		}
+		body.AppendNewline()
+		dataLocals[name] = hclwrite.TokensForFunctionCall("sensitive", value)
+		if pname, source, version := ds.RequiredProvider(); pname != "" {
+			required[pname] = cty.ObjectVal(map[string]cty.Value{
+				"source":  cty.StringVal(source),
+				"version": cty.StringVal(version),
+			})
+		}
+	}
+
+	if len(dataLocals) > 0 {
+		localsBody := body.AppendNewBlock("locals", nil).Body()
+		for _, name := range names {
+			if tokens, ok := dataLocals[name]; ok {
+				localsBody.SetAttributeRaw(name, tokens)
+			}
+		}
+	}
+	if len(required) > 0 {
 		body.AppendNewline()
+		rp := body.AppendNewBlock("terraform", nil).Body().AppendNewBlock("required_providers", nil).Body()
+		for _, pname := range sortedKeysCtyMap(required) {
+			rp.SetAttributeValue(pname, required[pname])
+		}
 	}
 
 	return os.WriteFile(filepath.Join(outDir, "secrets.tf"), file.Bytes(), 0o644)
 }
 
+func sortedKeysCtyMap(m map[string]cty.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
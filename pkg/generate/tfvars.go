@@ -0,0 +1,218 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"pltf/pkg/config"
+	"pltf/pkg/generate/cloud"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// envStateVariable holds the remote state config of the Environment in Service stacks rendered
+// with tfvars.
+const envStateVariable = "pltf_env_state"
+
+// AutoTfvarsFile is the values file of the environment key the stack was rendered for. Terraform
+// loads it on its own; -var and -var-file still override it.
+func AutoTfvarsFile(envKey string) string {
+	return envKey + ".auto.tfvars.json"
+}
+
+// TfvarsFile is the values file of another environment key the stack can be promoted to. Terraform
+// only loads it when passed with -var-file, which then overrides the auto-loaded values.
+func TfvarsFile(envKey string) string {
+	return envKey + ".tfvars.json"
+}
+
+// BackendConfigFile holds the backend settings of environment key envKey for the partial backend
+// of stacks rendered with tfvars; pass it to terraform init with -backend-config.
+func BackendConfigFile(envKey string) string {
+	return envKey + ".tfbackend"
+}
+
+// writeVariablesTF declares every merged variable as a typed variable block in variables.tf.
+// Declared variables keep their declared type and description; the others get a type derived from
+// their value. Service stacks also declare envStateVariable.
+func writeVariablesTF(outDir string, vars map[string]interface{}, specs map[string]config.VariableSpec, isService bool) error {
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+	for i, name := range sortedKeysInterfaceMap(vars) {
+		if i > 0 {
+			body.AppendNewline()
+		}
+		typ := inferVariableType(vars[name])
+		spec, declared := specs[name]
+		if declared {
+			typ = declaredVariableType(spec.Type)
+		}
+		b := body.AppendNewBlock("variable", []string{name}).Body()
+		b.SetAttributeRaw("type", exprTokens(typ))
+		if declared && spec.Description != "" {
+			b.SetAttributeValue("description", cty.StringVal(spec.Description))
+		}
+	}
+	if isService {
+		body.AppendNewline()
+		b := body.AppendNewBlock("variable", []string{envStateVariable}).Body()
+		b.SetAttributeRaw("type", exprTokens("map(string)"))
+		b.SetAttributeValue("description", cty.StringVal("Backend config of the Environment's remote state"))
+	}
+	return os.WriteFile(filepath.Join(outDir, "variables.tf"), file.Bytes(), 0o644)
+}
+
+// backendKey is the state key of the stack in environment key envKey.
+func (g *Generator) backendKey(envKey string) string {
+	if g.isService {
+		return fmt.Sprintf("service/%s/%s/terraform.tfstate", g.svcCfg.Metadata.Name, envKey)
+	}
+	return g.envStateKey(envKey)
+}
+
+// envStateKey is the state key of the Environment in environment key envKey.
+func (g *Generator) envStateKey(envKey string) string {
+	return fmt.Sprintf("env/%s/%s/terraform.tfstate", g.envCfg.Metadata.Name, envKey)
+}
+
+// stackEnvKeys returns the environment keys the rendered stack can be applied to: those the spec
+// defines (and, for a Service, its envRef) whose module overrides match the rendered environment's,
+// since overrides change the generated modules.
+func (g *Generator) stackEnvKeys() []string {
+	rendered := g.envCfg.Environments[g.envKey].Modules
+	var keys []string
+	for _, key := range sortedKeys(g.envCfg.Environments) {
+		entry := g.envCfg.Environments[key]
+		if strings.TrimSpace(entry.Region) == "" {
+			continue
+		}
+		if g.isService {
+			if _, ok := g.svcCfg.Metadata.EnvRef[key]; !ok {
+				continue
+			}
+		}
+		if key != g.envKey && !reflect.DeepEqual(entry.Modules, rendered) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// writeStackValues writes the values and BackendConfigFile of every environment key the stack can be
+// applied to, so one rendered stack can be promoted between them. The rendered environment's values
+// go to AutoTfvarsFile so plain terraform picks them up; the others go to TfvarsFile:
+//
+//	terraform init -reconfigure -backend-config=prod.tfbackend
+//	terraform apply -var-file=prod.tfvars.json
+func (g *Generator) writeStackValues() error {
+	provider := g.envCfg.Metadata.Provider
+	for _, key := range g.stackEnvKeys() {
+		name := TfvarsFile(key)
+		if key == g.envKey {
+			name = AutoTfvarsFile(key)
+		}
+		vars, err := g.mergedVarsFor(key)
+		if err != nil {
+			return fmt.Errorf("variables of environment %q: %w", key, err)
+		}
+		backendCfg, err := ResolveBackendConfig(provider, g.envCfg, g.envCfg.Environments[key])
+		if err != nil {
+			return fmt.Errorf("backend of environment %q: %w", key, err)
+		}
+		if g.isService {
+			state, err := remoteStateConfig(backendCfg.BackendType, backendCfg.Bucket, g.envStateKey(key), backendCfg.Region, backendCfg.Container, backendCfg.ResourceGroup, backendCfg.Profile)
+			if err != nil {
+				return err
+			}
+			vars[envStateVariable] = state
+		}
+		data, err := json.MarshalIndent(vars, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(g.outDir, name), append(data, '\n'), 0o644); err != nil {
+			return err
+		}
+		if err := writeBackendConfig(g.outDir, key, provider, backendCfg, g.backendKey(key)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", BackendConfigFile(key), err)
+		}
+	}
+	return nil
+}
+
+// writeBackendConfig writes the settings the backend block of versions.tf would hold for one
+// environment as a -backend-config file.
+func writeBackendConfig(outDir, envKey, providerType string, cfg BackendConfig, key string) error {
+	p, err := cloud.New(providerType)
+	if err != nil {
+		return err
+	}
+	scratch := hclwrite.NewEmptyFile()
+	p.Backend(scratch.Body(), cfg.Bucket, key, cfg.Region, cfg.Profile, cfg.Container, cfg.ResourceGroup)
+	attrs := scratch.Body().Blocks()[0].Body().Attributes()
+
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+	for _, name := range sortedKeys(attrs) {
+		body.SetAttributeRaw(name, attrs[name].Expr().BuildTokens(nil))
+	}
+	return os.WriteFile(filepath.Join(outDir, BackendConfigFile(envKey)), file.Bytes(), 0o644)
+}
+
+// declaredVariableType turns a variables.<name>.type declaration into a Terraform type constraint,
+// spelling out the shorthands module.yaml types allow.
+func declaredVariableType(typ string) string {
+	switch typ = strings.TrimSpace(typ); typ {
+	case "":
+		return "string"
+	case "object", "tuple":
+		return "any"
+	case "list", "set", "map":
+		return typ + "(any)"
+	}
+	return typ
+}
+
+// inferVariableType derives a type constraint from a merged value: collections whose elements
+// share a type become list(T) or map(T), anything mixed is any.
+func inferVariableType(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int, int32, int64, float32, float64:
+		return "number"
+	case map[string]string:
+		return "map(string)"
+	case []interface{}:
+		return "list(" + commonVariableType(val) + ")"
+	case map[string]interface{}:
+		items := make([]interface{}, 0, len(val))
+		for _, k := range sortedKeysInterfaceMap(val) {
+			items = append(items, val[k])
+		}
+		return "map(" + commonVariableType(items) + ")"
+	}
+	return "any"
+}
+
+// commonVariableType is the type all items share, or any.
+func commonVariableType(items []interface{}) string {
+	if len(items) == 0 {
+		return "any"
+	}
+	typ := inferVariableType(items[0])
+	for _, item := range items[1:] {
+		if inferVariableType(item) != typ {
+			return "any"
+		}
+	}
+	return typ
+}
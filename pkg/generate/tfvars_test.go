@@ -0,0 +1,183 @@
+package generate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pltf/modules"
+	"pltf/pkg/config"
+)
+
+func TestInferVariableType(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		want  string
+	}{
+		{"x", "string"},
+		{int64(3), "number"},
+		{true, "bool"},
+		{[]interface{}{"a", "b"}, "list(string)"},
+		{[]interface{}{"a", int64(1)}, "list(any)"},
+		{[]interface{}{}, "list(any)"},
+		{map[string]string{"team": "x"}, "map(string)"},
+		{map[string]interface{}{"a": []interface{}{true}}, "map(list(bool))"},
+	}
+	for _, c := range cases {
+		if got := inferVariableType(c.value); got != c.want {
+			t.Fatalf("inferVariableType(%#v) = %q, want %q", c.value, got, c.want)
+		}
+	}
+}
+
+func TestGeneratorRendersVariablesAsTfvars(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Render:   config.Render{Variables: config.VariablesTfvars},
+		Variables: map[string]config.VariableSpec{
+			"replicas": {Type: "number", Description: "Node count"},
+		},
+		Environments: map[string]config.EnvironmentEntry{
+			"stage": {Account: "111111111111", Region: "us-east-1", Variables: config.VariableValues{
+				"replicas":       "3",
+				"cluster_name":   "stage",
+				"enable_metrics": "true",
+			}},
+			"prod": {Account: "222222222222", Region: "us-west-2", Variables: config.VariableValues{
+				"replicas": "5",
+			}},
+			"dev": {Account: "333333333333", Region: "us-east-2", Modules: map[string]config.ModuleOverride{
+				"eks": {Inputs: map[string]interface{}{"max_nodes": 2}},
+			}},
+		},
+		Modules: []config.Module{{ID: "base", Type: "aws_base"}, {ID: "eks", Type: "aws_eks"}},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+	outDir := t.TempDir()
+	g, err := NewGenerator(envCfg, nil, modRoot, "", "stage", outDir, "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(strings.Fields(string(data)), " ")
+	}
+	versions := read("versions.tf")
+	if !strings.Contains(versions, "cluster_name = var.cluster_name") || !strings.Contains(versions, `backend "s3" { }`) || strings.Contains(versions, `"stage"`) {
+		t.Fatalf("expected locals to read variables and a partial backend, got: %s", versions)
+	}
+	if providers := read("providers.tf"); !strings.Contains(providers, "region = var.region") {
+		t.Fatalf("expected the provider region from var.region, got: %s", providers)
+	}
+	variables := read("variables.tf")
+	for _, want := range []string{
+		`variable "replicas" { type = number description = "Node count" }`,
+		`variable "enable_metrics" { type = bool }`,
+		`variable "global_tags" { type = map(string) }`,
+	} {
+		if !strings.Contains(variables, want) {
+			t.Fatalf("expected %q in variables.tf, got: %s", want, variables)
+		}
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(read(AutoTfvarsFile("stage"))), &values); err != nil {
+		t.Fatalf("decode tfvars: %v", err)
+	}
+	if values["replicas"] != float64(3) || values["cluster_name"] != "stage" || values["enable_metrics"] != true {
+		t.Fatalf("unexpected tfvars values: %v", values)
+	}
+	if backend := read(BackendConfigFile("stage")); !strings.Contains(backend, `key = "env/example/stage/terraform.tfstate"`) {
+		t.Fatalf("unexpected backend config: %s", backend)
+	}
+
+	// prod renders the same modules, so the stack can be promoted to it.
+	values = nil
+	if err := json.Unmarshal([]byte(read(TfvarsFile("prod"))), &values); err != nil {
+		t.Fatalf("decode prod tfvars: %v", err)
+	}
+	if values["region"] != "us-west-2" || values["account_id"] != "222222222222" || values["replicas"] != float64(5) {
+		t.Fatalf("unexpected prod tfvars values: %v", values)
+	}
+	if backend := read(BackendConfigFile("prod")); !strings.Contains(backend, `key = "env/example/prod/terraform.tfstate"`) || !strings.Contains(backend, `region = "us-west-2"`) {
+		t.Fatalf("unexpected prod backend config: %s", backend)
+	}
+	// dev overrides a module, so this stack is not dev's.
+	if _, err := os.Stat(filepath.Join(outDir, TfvarsFile("dev"))); !os.IsNotExist(err) {
+		t.Fatalf("expected no values file for dev, got %v", err)
+	}
+	// Only the rendered environment's values are loaded by plain terraform.
+	for _, name := range []string{TfvarsFile("stage"), AutoTfvarsFile("prod")} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected no %s, got %v", name, err)
+		}
+	}
+}
+
+func TestGeneratorTfvarsServiceReadsEnvStateFromVariables(t *testing.T) {
+	envCfg := &config.EnvironmentConfig{
+		Metadata: config.EnvironmentMetadata{Name: "example", Org: "testorg", Provider: "aws"},
+		Render:   config.Render{Variables: config.VariablesTfvars},
+		Environments: map[string]config.EnvironmentEntry{
+			"dev":  {Account: "111111111111", Region: "us-east-1"},
+			"prod": {Account: "222222222222", Region: "us-west-2"},
+		},
+		Modules: []config.Module{{ID: "base", Type: "aws_base"}},
+	}
+	svcCfg := &config.ServiceConfig{
+		Metadata: config.ServiceMetadata{
+			Name:   "payments",
+			EnvRef: map[string]config.ServiceEnvRefEntry{"dev": {}, "prod": {}},
+		},
+		Modules: []config.Module{{ID: "assets", Type: "aws_s3", Inputs: map[string]interface{}{"bucket_name": "payments-${region}"}}},
+	}
+	modRoot, err := modules.Materialize()
+	if err != nil {
+		t.Fatalf("materialize embedded modules: %v", err)
+	}
+	outDir := t.TempDir()
+	g, err := NewGenerator(envCfg, svcCfg, modRoot, "", "dev", outDir, "", nil)
+	if err != nil {
+		t.Fatalf("NewGenerator error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(strings.Fields(string(data)), " ")
+	}
+	if state := read("state.tf"); !strings.Contains(state, "config = var.pltf_env_state") {
+		t.Fatalf("expected the remote state config from a variable, got: %s", state)
+	}
+	if assets := read("assets.tf"); !strings.Contains(assets, `bucket_name = "payments-${local.region}"`) {
+		t.Fatalf("expected ${region} to read the region variable, got: %s", assets)
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(read(TfvarsFile("prod"))), &values); err != nil {
+		t.Fatalf("decode prod tfvars: %v", err)
+	}
+	state, _ := values[envStateVariable].(map[string]interface{})
+	if state["key"] != "env/example/prod/terraform.tfstate" || state["region"] != "us-west-2" {
+		t.Fatalf("unexpected env state config: %v", values[envStateVariable])
+	}
+	if backend := read(BackendConfigFile("prod")); !strings.Contains(backend, `key = "service/payments/prod/terraform.tfstate"`) {
+		t.Fatalf("unexpected prod backend config: %s", backend)
+	}
+}
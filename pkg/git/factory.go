@@ -29,11 +29,13 @@ type PRComment struct {
 	Marker string
 }
 
-// ReviewStatus describes a single reviewer's latest status.
+// ReviewStatus describes a single reviewer's latest status. Outdated marks an approval given on
+// an earlier commit than the pull request head.
 type ReviewStatus struct {
-	Name   string
-	Team   string
-	Status string
+	Name     string
+	Team     string
+	Status   string
+	Outdated bool
 }
 
 // ReviewSummary aggregates review status and approvals.
@@ -48,6 +50,12 @@ type Commenter interface {
 	GetReviewSummary() (ReviewSummary, error)
 }
 
+// TeamResolver is implemented by commenters that can list team members, so approval gates can
+// name a team ("org/team") instead of individual reviewers.
+type TeamResolver interface {
+	TeamMembers(team string) ([]string, error)
+}
+
 // NewCommenter creates a commenter for the specified provider or auto-detected provider if empty.
 func NewCommenter(provider string) (Commenter, error) {
 	selected := strings.TrimSpace(provider)
@@ -27,6 +27,9 @@ type ghEvent struct {
 	Action      string `json:"action"`
 	PullRequest *struct {
 		Number int `json:"number"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
 	} `json:"pull_request"`
 }
 
@@ -39,6 +42,7 @@ type ghReview struct {
 	State             string `json:"state"`
 	AuthorAssociation string `json:"author_association"`
 	SubmittedAt       string `json:"submitted_at"`
+	CommitID          string `json:"commit_id"`
 	User              struct {
 		Login string `json:"login"`
 	} `json:"user"`
@@ -103,11 +107,18 @@ func (c *githubCommenter) GetReviewSummary() (ReviewSummary, error) {
 
 	client := &http.Client{Timeout: 15 * time.Second}
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/reviews?per_page=100", c.owner, c.repo, prNumber)
-	var reviews []ghReview
-	if err := doGitHubRequest(ctx, client, c.token, http.MethodGet, url, nil, &reviews); err != nil {
+	reviews, err := listGitHub[ghReview](ctx, client, c.token, url)
+	if err != nil {
 		return ReviewSummary{}, err
 	}
 
+	return summarizeReviews(reviews, detectHeadSHA()), nil
+}
+
+// summarizeReviews keeps each reviewer's latest APPROVED, CHANGES_REQUESTED or DISMISSED review,
+// as GitHub does: a later comment does not withdraw an approval. Approvals given on a commit other
+// than head are marked outdated and not counted; when head is unknown, every approval is.
+func summarizeReviews(reviews []ghReview, head string) ReviewSummary {
 	type reviewEntry struct {
 		review ghReview
 		at     time.Time
@@ -118,6 +129,11 @@ func (c *githubCommenter) GetReviewSummary() (ReviewSummary, error) {
 		if login == "" {
 			continue
 		}
+		switch strings.ToUpper(strings.TrimSpace(r.State)) {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+		default:
+			continue
+		}
 		t := parseReviewTime(r.SubmittedAt)
 		if prev, ok := latest[login]; ok {
 			if prev.at.After(t) {
@@ -137,16 +153,43 @@ func (c *githubCommenter) GetReviewSummary() (ReviewSummary, error) {
 	for _, name := range names {
 		r := latest[name].review
 		state := strings.ToUpper(strings.TrimSpace(r.State))
-		if state == "APPROVED" {
+		outdated := state == "APPROVED" && (head == "" || r.CommitID != head)
+		if state == "APPROVED" && !outdated {
 			summary.Approvals++
 		}
 		summary.Reviews = append(summary.Reviews, ReviewStatus{
-			Name:   name,
-			Team:   strings.TrimSpace(r.AuthorAssociation),
-			Status: state,
+			Name:     name,
+			Team:     strings.TrimSpace(r.AuthorAssociation),
+			Status:   state,
+			Outdated: outdated,
 		})
 	}
-	return summary, nil
+	return summary
+}
+
+// TeamMembers lists the logins of team "org/slug". The token needs read:org.
+func (c *githubCommenter) TeamMembers(team string) ([]string, error) {
+	org, slug, err := splitRepo(team)
+	if err != nil {
+		return nil, fmt.Errorf("invalid team %q: want org/team", team)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	url := fmt.Sprintf("https://api.github.com/orgs/%s/teams/%s/members?per_page=100", org, slug)
+	members, err := listGitHub[struct {
+		Login string `json:"login"`
+	}](ctx, client, c.token, url)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(members))
+	for _, m := range members {
+		out = append(out, m.Login)
+	}
+	return out, nil
 }
 
 func detectPRNumber() (int, error) {
@@ -169,10 +212,24 @@ func detectPRNumber() (int, error) {
 	return 0, ErrNoPRNumber
 }
 
+// detectHeadSHA returns the commit at the head of the pull request. The event payload comes first:
+// on pull_request events GITHUB_SHA is the test merge commit, which reviews never point at.
+func detectHeadSHA() string {
+	if eventPath := os.Getenv("GITHUB_EVENT_PATH"); eventPath != "" {
+		if b, err := os.ReadFile(eventPath); err == nil {
+			var ev ghEvent
+			if json.Unmarshal(b, &ev) == nil && ev.PullRequest != nil && ev.PullRequest.Head.SHA != "" {
+				return ev.PullRequest.Head.SHA
+			}
+		}
+	}
+	return strings.TrimSpace(os.Getenv("GITHUB_SHA"))
+}
+
 func findExistingPRComment(ctx context.Context, client *http.Client, token, owner, repo string, prNumber int, marker string) (int64, bool, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/comments?per_page=100", owner, repo, prNumber)
-	var comments []ghComment
-	if err := doGitHubRequest(ctx, client, token, http.MethodGet, url, nil, &comments); err != nil {
+	comments, err := listGitHub[ghComment](ctx, client, token, url)
+	if err != nil {
 		return 0, false, err
 	}
 	if marker == "" {
@@ -186,19 +243,57 @@ func findExistingPRComment(ctx context.Context, client *http.Client, token, owne
 	return 0, false, nil
 }
 
+// listGitHub fetches every page of a GitHub list endpoint, following the Link rel="next" header.
+func listGitHub[T any](ctx context.Context, client *http.Client, token, url string) ([]T, error) {
+	var all []T
+	for url != "" {
+		var page []T
+		header, err := sendGitHubRequest(ctx, client, token, http.MethodGet, url, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		url = nextPageURL(header.Get("Link"))
+	}
+	return all, nil
+}
+
+// nextPageURL returns the rel="next" URL of a Link header, or "" on the last page.
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok {
+			continue
+		}
+		for _, p := range strings.Split(params, ";") {
+			if strings.TrimSpace(p) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+	return ""
+}
+
 func doGitHubRequest(ctx context.Context, client *http.Client, token, method, url string, payload any, out any) error {
+	_, err := sendGitHubRequest(ctx, client, token, method, url, payload, out)
+	return err
+}
+
+// sendGitHubRequest performs a GitHub API request, decodes the response into out and returns the
+// response headers.
+func sendGitHubRequest(ctx context.Context, client *http.Client, token, method, url string, payload any, out any) (http.Header, error) {
 	var body io.Reader
 	if payload != nil {
 		b, err := json.Marshal(payload)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		body = bytes.NewReader(b)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Accept", "application/vnd.github+json")
@@ -207,20 +302,22 @@ func doGitHubRequest(ctx context.Context, client *http.Client, token, method, ur
 	resp, err := client.Do(req)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "warn: github request failed: %v\n", err)
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
 		fmt.Fprintf(os.Stderr, "warn: github API %s %s failed: %s - %s\n", method, url, resp.Status, string(b))
-		return fmt.Errorf("github API %s %s: %s - %s", method, url, resp.Status, string(b))
+		return nil, fmt.Errorf("github API %s %s: %s - %s", method, url, resp.Status, string(b))
 	}
 
 	if out != nil {
-		return json.NewDecoder(resp.Body).Decode(out)
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	return resp.Header, nil
 }
 
 func splitRepo(full string) (owner, repo string, err error) {
@@ -0,0 +1,39 @@
+package git
+
+import "testing"
+
+func review(login, state, commit, at string) ghReview {
+	r := ghReview{State: state, CommitID: commit, SubmittedAt: at}
+	r.User.Login = login
+	return r
+}
+
+func TestSummarizeReviewsIgnoresCommentsAfterApproval(t *testing.T) {
+	got := summarizeReviews([]ghReview{
+		review("alice", "APPROVED", "head", "2024-01-01T10:00:00Z"),
+		review("alice", "COMMENTED", "head", "2024-01-01T11:00:00Z"),
+		review("bob", "APPROVED", "head", "2024-01-01T10:00:00Z"),
+		review("bob", "CHANGES_REQUESTED", "head", "2024-01-01T11:00:00Z"),
+	}, "head")
+	if got.Approvals != 1 || len(got.Reviews) != 2 {
+		t.Fatalf("unexpected summary: %+v", got)
+	}
+	if got.Reviews[0].Status != "APPROVED" || got.Reviews[1].Status != "CHANGES_REQUESTED" {
+		t.Fatalf("unexpected review states: %+v", got.Reviews)
+	}
+}
+
+func TestSummarizeReviewsMarksApprovalsOfEarlierCommitsOutdated(t *testing.T) {
+	reviews := []ghReview{
+		review("alice", "APPROVED", "old", "2024-01-01T10:00:00Z"),
+		review("bob", "APPROVED", "head", "2024-01-01T10:00:00Z"),
+	}
+	got := summarizeReviews(reviews, "head")
+	if got.Approvals != 1 || !got.Reviews[0].Outdated || got.Reviews[1].Outdated {
+		t.Fatalf("unexpected summary: %+v", got)
+	}
+
+	if got := summarizeReviews(reviews, ""); got.Approvals != 0 {
+		t.Fatalf("approvals must not count when the head commit is unknown: %+v", got)
+	}
+}
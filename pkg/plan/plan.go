@@ -0,0 +1,426 @@
+// Package plan models the JSON form of a Terraform plan (terraform show -json) for pltf: resource
+// changes with their before and after values, why resources are replaced, drift detected while
+// refreshing, output changes and sensitive markers, with every change attributed to the pltf module
+// id that owns it.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Action is what a plan does to a resource or output, with create-then-delete and
+// delete-then-create folded into replace.
+type Action string
+
+const (
+	ActionNoOp    Action = "no-op"
+	ActionCreate  Action = "create"
+	ActionRead    Action = "read"
+	ActionUpdate  Action = "update"
+	ActionReplace Action = "replace"
+	ActionDelete  Action = "delete"
+	ActionForget  Action = "forget"
+)
+
+// Symbol is the marker terraform uses for the action in plan output.
+func (a Action) Symbol() string {
+	switch a {
+	case ActionCreate:
+		return "+"
+	case ActionUpdate:
+		return "~"
+	case ActionReplace:
+		return "-/+"
+	case ActionDelete:
+		return "-"
+	case ActionRead:
+		return "<="
+	case ActionForget:
+		return "."
+	}
+	return " "
+}
+
+// Changes reports whether the action changes infrastructure.
+func (a Action) Changes() bool {
+	switch a {
+	case ActionCreate, ActionUpdate, ActionReplace, ActionDelete, ActionForget:
+		return true
+	}
+	return false
+}
+
+func actionOf(actions []string) Action {
+	if len(actions) == 2 {
+		return ActionReplace
+	}
+	if len(actions) == 1 {
+		return Action(actions[0])
+	}
+	return ActionNoOp
+}
+
+// Plan is a parsed Terraform plan.
+type Plan struct {
+	FormatVersion    string
+	TerraformVersion string
+	// Resources are the planned resource changes, no-ops included, in plan order.
+	Resources []ResourceChange
+	// Drift are the changes made outside Terraform that refreshing found.
+	Drift   []ResourceChange
+	Outputs []OutputChange
+	// Errored is set when planning failed part way; the plan is then incomplete.
+	Errored bool
+}
+
+// ResourceChange is the planned change of one resource instance.
+type ResourceChange struct {
+	Address         string
+	PreviousAddress string // set when the resource moved
+	ModuleAddress   string
+	Mode            string // managed or data
+	Type            string
+	Name            string
+	Provider        string
+	Deposed         string
+	// Module is the pltf module id the resource belongs to; empty for root resources.
+	Module string
+	Action Action
+	// Reason is terraform's action_reason, such as replace_because_cannot_update.
+	Reason          string
+	Before          interface{}
+	After           interface{}
+	AfterUnknown    interface{}
+	BeforeSensitive interface{}
+	AfterSensitive  interface{}
+	// ReplacePaths are the attribute paths that force the replacement.
+	ReplacePaths [][]interface{}
+}
+
+// OutputChange is the planned change of a root output.
+type OutputChange struct {
+	Name      string
+	Action    Action
+	Sensitive bool
+	Before    interface{}
+	After     interface{}
+}
+
+type jsonPlan struct {
+	FormatVersion    string                `json:"format_version"`
+	TerraformVersion string                `json:"terraform_version"`
+	ResourceChanges  []jsonResourceChange  `json:"resource_changes"`
+	ResourceDrift    []jsonResourceChange  `json:"resource_drift"`
+	OutputChanges    map[string]jsonChange `json:"output_changes"`
+	Errored          bool                  `json:"errored"`
+}
+
+type jsonResourceChange struct {
+	Address         string     `json:"address"`
+	PreviousAddress string     `json:"previous_address"`
+	ModuleAddress   string     `json:"module_address"`
+	Mode            string     `json:"mode"`
+	Type            string     `json:"type"`
+	Name            string     `json:"name"`
+	ProviderName    string     `json:"provider_name"`
+	Deposed         string     `json:"deposed"`
+	Change          jsonChange `json:"change"`
+	ActionReason    string     `json:"action_reason"`
+}
+
+type jsonChange struct {
+	Actions         []string        `json:"actions"`
+	Before          interface{}     `json:"before"`
+	After           interface{}     `json:"after"`
+	AfterUnknown    interface{}     `json:"after_unknown"`
+	BeforeSensitive interface{}     `json:"before_sensitive"`
+	AfterSensitive  interface{}     `json:"after_sensitive"`
+	ReplacePaths    [][]interface{} `json:"replace_paths"`
+}
+
+// Parse reads the output of terraform show -json for a saved plan.
+func Parse(data []byte) (*Plan, error) {
+	var raw jsonPlan
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse plan JSON: %w", err)
+	}
+	p := &Plan{FormatVersion: raw.FormatVersion, TerraformVersion: raw.TerraformVersion, Errored: raw.Errored}
+	for _, rc := range raw.ResourceChanges {
+		p.Resources = append(p.Resources, resourceChange(rc))
+	}
+	for _, rc := range raw.ResourceDrift {
+		p.Drift = append(p.Drift, resourceChange(rc))
+	}
+	names := make([]string, 0, len(raw.OutputChanges))
+	for name := range raw.OutputChanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := raw.OutputChanges[name]
+		p.Outputs = append(p.Outputs, OutputChange{
+			Name:      name,
+			Action:    actionOf(c.Actions),
+			Sensitive: containsTrue(c.BeforeSensitive) || containsTrue(c.AfterSensitive),
+			Before:    c.Before,
+			After:     c.After,
+		})
+	}
+	return p, nil
+}
+
+// Load reads a plan JSON file written from terraform show -json.
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+func resourceChange(rc jsonResourceChange) ResourceChange {
+	return ResourceChange{
+		Address:         rc.Address,
+		PreviousAddress: rc.PreviousAddress,
+		ModuleAddress:   rc.ModuleAddress,
+		Mode:            rc.Mode,
+		Type:            rc.Type,
+		Name:            rc.Name,
+		Provider:        rc.ProviderName,
+		Deposed:         rc.Deposed,
+		Module:          ModuleID(rc.ModuleAddress),
+		Action:          actionOf(rc.Change.Actions),
+		Reason:          rc.ActionReason,
+		Before:          rc.Change.Before,
+		After:           rc.Change.After,
+		AfterUnknown:    rc.Change.AfterUnknown,
+		BeforeSensitive: rc.Change.BeforeSensitive,
+		AfterSensitive:  rc.Change.AfterSensitive,
+		ReplacePaths:    rc.Change.ReplacePaths,
+	}
+}
+
+// ModuleID is the pltf module id of a module address: module.<id>, module.<id>["key"] or
+// module.<id>[0], possibly followed by nested modules. Root resources have no module id.
+func ModuleID(moduleAddress string) string {
+	rest, ok := strings.CutPrefix(moduleAddress, "module.")
+	if !ok {
+		return ""
+	}
+	if i := strings.IndexAny(rest, ".["); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
+
+// ChangedAttributes returns the top-level attributes whose value differs between before and after,
+// or that are only known after apply.
+func (rc ResourceChange) ChangedAttributes() []string {
+	before, _ := rc.Before.(map[string]interface{})
+	after, _ := rc.After.(map[string]interface{})
+	unknown, _ := rc.AfterUnknown.(map[string]interface{})
+	seen := map[string]bool{}
+	for name, v := range after {
+		if !reflect.DeepEqual(before[name], v) {
+			seen[name] = true
+		}
+	}
+	for name, v := range before {
+		if _, ok := after[name]; !ok && v != nil {
+			seen[name] = true
+		}
+	}
+	for name, v := range unknown {
+		if containsTrue(v) {
+			seen[name] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for name := range seen {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Sensitive reports whether the top-level attribute, or anything nested in it, is sensitive before
+// or after the change.
+func (rc ResourceChange) Sensitive(attr string) bool {
+	for _, marks := range []interface{}{rc.BeforeSensitive, rc.AfterSensitive} {
+		if m, ok := marks.(map[string]interface{}); ok && containsTrue(m[attr]) {
+			return true
+		}
+	}
+	return false
+}
+
+var reasonText = map[string]string{
+	"replace_because_cannot_update":     "cannot update in place",
+	"replace_because_tainted":           "tainted",
+	"replace_by_request":                "requested with -replace",
+	"replace_by_triggers":               "replace_triggered_by",
+	"delete_because_no_resource_config": "removed from configuration",
+	"delete_because_no_module":          "module removed from configuration",
+	"delete_because_wrong_repetition":   "count/for_each changed",
+	"delete_because_count_index":        "count reduced",
+	"delete_because_each_key":           "for_each key removed",
+	"read_because_config_unknown":       "configuration known only after apply",
+	"read_because_dependency_pending":   "dependency has pending changes",
+}
+
+// Why describes why a resource is replaced or deleted, from its action reason and replace paths.
+func (rc ResourceChange) Why() string {
+	text := reasonText[rc.Reason]
+	if text == "" {
+		text = strings.ReplaceAll(rc.Reason, "_", " ")
+	}
+	var paths []string
+	for _, p := range rc.ReplacePaths {
+		paths = append(paths, formatPath(p))
+	}
+	switch {
+	case text != "" && len(paths) > 0:
+		return text + ": " + strings.Join(paths, ", ")
+	case len(paths) > 0:
+		return "forced by " + strings.Join(paths, ", ")
+	}
+	return text
+}
+
+func formatPath(path []interface{}) string {
+	var b strings.Builder
+	for i, step := range path {
+		switch s := step.(type) {
+		case string:
+			if i > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(s)
+		case float64:
+			fmt.Fprintf(&b, "[%d]", int(s))
+		default:
+			fmt.Fprintf(&b, "[%v]", s)
+		}
+	}
+	return b.String()
+}
+
+// containsTrue reports whether a sensitive or unknown marker value is, or holds, true.
+func containsTrue(v interface{}) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case map[string]interface{}:
+		for _, e := range t {
+			if containsTrue(e) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, e := range t {
+			if containsTrue(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Counts tallies planned resource changes. Replacements are counted on their own rather than as an
+// add plus a destroy.
+type Counts struct {
+	Add     int
+	Change  int
+	Replace int
+	Destroy int
+}
+
+func (c *Counts) add(a Action) {
+	switch a {
+	case ActionCreate:
+		c.Add++
+	case ActionUpdate:
+		c.Change++
+	case ActionReplace:
+		c.Replace++
+	case ActionDelete:
+		c.Destroy++
+	}
+}
+
+// Empty reports whether nothing changes.
+func (c Counts) Empty() bool {
+	return c == Counts{}
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%d to add, %d to change, %d to replace, %d to destroy", c.Add, c.Change, c.Replace, c.Destroy)
+}
+
+// Counts tallies the changes of the whole plan.
+func (p *Plan) Counts() Counts {
+	var c Counts
+	for _, rc := range p.Resources {
+		c.add(rc.Action)
+	}
+	return c
+}
+
+// ModuleChanges are the changes to the resources of one pltf module.
+type ModuleChanges struct {
+	// ID is the pltf module id; empty for resources outside any module.
+	ID     string
+	Counts Counts
+	// Resources are the resources that change, in plan order.
+	Resources []ResourceChange
+	// Drift are the resources changed outside Terraform.
+	Drift []ResourceChange
+}
+
+// ByModule groups the changes and drift of the plan by pltf module id, sorted by id. Modules with
+// neither are left out.
+func (p *Plan) ByModule() []ModuleChanges {
+	byID := map[string]*ModuleChanges{}
+	get := func(id string) *ModuleChanges {
+		if m, ok := byID[id]; ok {
+			return m
+		}
+		m := &ModuleChanges{ID: id}
+		byID[id] = m
+		return m
+	}
+	for _, rc := range p.Resources {
+		if !rc.Action.Changes() {
+			continue
+		}
+		m := get(rc.Module)
+		m.Counts.add(rc.Action)
+		m.Resources = append(m.Resources, rc)
+	}
+	for _, rc := range p.Drift {
+		if !rc.Action.Changes() {
+			continue
+		}
+		m := get(rc.Module)
+		m.Drift = append(m.Drift, rc)
+	}
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out := make([]ModuleChanges, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, *byID[id])
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package plan
+
+import (
+	"reflect"
+	"testing"
+)
+
+const samplePlan = `{
+  "format_version": "1.2",
+  "terraform_version": "1.7.5",
+  "resource_drift": [
+    {"address": "module.db.aws_rds_cluster.this", "module_address": "module.db", "type": "aws_rds_cluster",
+     "change": {"actions": ["update"], "before": {"deletion_protection": true}, "after": {"deletion_protection": false}}}
+  ],
+  "resource_changes": [
+    {"address": "module.db.aws_rds_cluster.this", "module_address": "module.db", "type": "aws_rds_cluster",
+     "change": {"actions": ["create", "delete"], "replace_paths": [["engine"], ["tags", "env"]]},
+     "action_reason": "replace_because_cannot_update"},
+    {"address": "module.app[\"api\"].aws_iam_role.this", "module_address": "module.app[\"api\"]", "type": "aws_iam_role",
+     "change": {"actions": ["update"],
+                "before": {"name": "api", "policy": "a", "secret": "s"},
+                "after": {"name": "api", "secret": "t"},
+                "after_unknown": {"arn": true},
+                "before_sensitive": {"secret": true}}},
+    {"address": "module.app[\"api\"].data.aws_caller_identity.this", "module_address": "module.app[\"api\"]", "mode": "data",
+     "type": "aws_caller_identity", "change": {"actions": ["read"]}},
+    {"address": "aws_s3_bucket.old", "type": "aws_s3_bucket", "change": {"actions": ["delete"]},
+     "action_reason": "delete_because_no_resource_config"},
+    {"address": "module.base.aws_vpc.this", "module_address": "module.base", "type": "aws_vpc", "change": {"actions": ["no-op"]}}
+  ],
+  "output_changes": {
+    "token": {"actions": ["update"], "after_sensitive": true},
+    "vpc_id": {"actions": ["no-op"]}
+  }
+}`
+
+func TestParseGroupsChangesByModule(t *testing.T) {
+	p, err := Parse([]byte(samplePlan))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := p.Counts(); got != (Counts{Change: 1, Replace: 1, Destroy: 1}) {
+		t.Fatalf("unexpected counts: %+v", got)
+	}
+
+	mods := p.ByModule()
+	var ids []string
+	for _, m := range mods {
+		ids = append(ids, m.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"", "app", "db"}) {
+		t.Fatalf("unexpected module groups: %v", ids)
+	}
+	db := mods[2]
+	if len(db.Resources) != 1 || len(db.Drift) != 1 || db.Counts.Replace != 1 {
+		t.Fatalf("unexpected db group: %+v", db)
+	}
+	if why := db.Resources[0].Why(); why != "cannot update in place: engine, tags.env" {
+		t.Fatalf("unexpected replace reason: %q", why)
+	}
+	if why := mods[0].Resources[0].Why(); why != "removed from configuration" {
+		t.Fatalf("unexpected delete reason: %q", why)
+	}
+
+	role := mods[1].Resources[0]
+	if got := role.ChangedAttributes(); !reflect.DeepEqual(got, []string{"arn", "policy", "secret"}) {
+		t.Fatalf("unexpected changed attributes: %v", got)
+	}
+	if !role.Sensitive("secret") || role.Sensitive("policy") {
+		t.Fatalf("expected only secret to be sensitive")
+	}
+
+	if len(p.Outputs) != 2 || p.Outputs[0].Name != "token" || !p.Outputs[0].Sensitive || p.Outputs[0].Action != ActionUpdate {
+		t.Fatalf("unexpected outputs: %+v", p.Outputs)
+	}
+}
+
+func TestModuleID(t *testing.T) {
+	for addr, want := range map[string]string{
+		"":                        "",
+		"module.eks":              "eks",
+		`module.app["api"]`:       "app",
+		"module.nodes[0]":         "nodes",
+		"module.eks.module.nodes": "eks",
+	} {
+		if got := ModuleID(addr); got != want {
+			t.Fatalf("ModuleID(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"pltf/pkg/generate"
+	"pltf/pkg/plan"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"gopkg.in/yaml.v3"
+)
+
+// Input is what policies see as input:
+//
+//	input.kind, input.name, input.env  the stack and its environment key
+//	input.environment                  the Environment spec, as written (after extends/imports)
+//	input.service                      the Service spec, for Service stacks
+//	input.modules.<id>.type            module blocks generate rendered, with
+//	input.modules.<id>.inputs.<name>   the value of every input they set
+//	input.resources[_]                 resource changes of the plan, with the pltf module id
+//	input.plan                         the plan as terraform show -json prints it
+//
+// Literal input values are decoded; inputs set from references, such as module outputs, hold the
+// Terraform expression as a string. resources and plan are only set once a plan exists.
+type Input struct {
+	Kind        string
+	Name        string
+	Env         string
+	Environment interface{}
+	Service     interface{}
+	Modules     map[string]ModuleInput
+	Resources   []Resource
+	Plan        json.RawMessage
+}
+
+// ModuleInput is a generated module block.
+type ModuleInput struct {
+	Type   string                 `json:"type"`
+	Inputs map[string]interface{} `json:"inputs"`
+}
+
+// Resource is a planned resource change.
+type Resource struct {
+	Address string      `json:"address"`
+	Module  string      `json:"module"`
+	Type    string      `json:"type"`
+	Name    string      `json:"name"`
+	Mode    string      `json:"mode"`
+	Action  plan.Action `json:"action"`
+	Before  interface{} `json:"before"`
+	After   interface{} `json:"after"`
+}
+
+// SpecValue converts a spec struct into the generic form policies match on, keyed as in YAML.
+func SpecValue(spec interface{}) (interface{}, error) {
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ModulesFromSourceMap returns the module blocks and input values recorded by generate.
+func ModulesFromSourceMap(sm *generate.SourceMap) map[string]ModuleInput {
+	out := map[string]ModuleInput{}
+	if sm == nil {
+		return out
+	}
+	for id, ms := range sm.Modules {
+		inputs := map[string]interface{}{}
+		for name, src := range ms.Inputs {
+			inputs[name] = inputValue(src.Value)
+		}
+		out[id] = ModuleInput{Type: ms.Type, Inputs: inputs}
+	}
+	return out
+}
+
+// inputValue decodes the rendered expression of an input when it is a literal and returns the
+// expression otherwise.
+func inputValue(expr string) interface{} {
+	e, diags := hclsyntax.ParseExpression([]byte(expr), "input", hcl.InitialPos)
+	if diags.HasErrors() || len(e.Variables()) > 0 {
+		return expr
+	}
+	v, diags := e.Value(nil)
+	if diags.HasErrors() || !v.IsWhollyKnown() {
+		return expr
+	}
+	data, err := ctyjson.Marshal(v, v.Type())
+	if err != nil {
+		return expr
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return expr
+	}
+	return out
+}
+
+// ResourcesFromPlan lists the resource changes of p.
+func ResourcesFromPlan(p *plan.Plan) []Resource {
+	if p == nil {
+		return nil
+	}
+	out := make([]Resource, 0, len(p.Resources))
+	for _, rc := range p.Resources {
+		out = append(out, Resource{
+			Address: rc.Address,
+			Module:  rc.Module,
+			Type:    rc.Type,
+			Name:    rc.Name,
+			Mode:    rc.Mode,
+			Action:  rc.Action,
+			Before:  rc.Before,
+			After:   rc.After,
+		})
+	}
+	return out
+}
+
+// document is the input value handed to Rego.
+func (in Input) document() (map[string]interface{}, error) {
+	doc := map[string]interface{}{
+		"kind":    in.Kind,
+		"name":    in.Name,
+		"env":     in.Env,
+		"modules": in.Modules,
+	}
+	if in.Environment != nil {
+		doc["environment"] = in.Environment
+	}
+	if in.Service != nil {
+		doc["service"] = in.Service
+	}
+	if in.Modules == nil {
+		doc["modules"] = map[string]ModuleInput{}
+	}
+	if len(in.Plan) > 0 {
+		var p interface{}
+		if err := json.Unmarshal(in.Plan, &p); err != nil {
+			return nil, fmt.Errorf("decode plan JSON: %w", err)
+		}
+		doc["plan"] = p
+		doc["resources"] = in.Resources
+		if in.Resources == nil {
+			doc["resources"] = []Resource{}
+		}
+	}
+	// Round trip through JSON so Rego sees plain maps, lists, strings, numbers and booleans.
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
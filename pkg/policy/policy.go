@@ -0,0 +1,325 @@
+// Package policy evaluates organisation rules written in Rego against a stack: the parsed spec, the
+// module inputs generate rendered and, once there is one, the plan. Policies are loaded from a
+// directory; each is a package under pltf. with a violation rule and a METADATA annotation giving its
+// severity and how it is enforced in each environment.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+// Severity ranks how serious a violation is.
+type Severity string
+
+const (
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 0
+	case SeverityHigh:
+		return 1
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 3
+	}
+	return 4
+}
+
+func validSeverity(s Severity) bool {
+	return s.rank() < 4
+}
+
+// Enforcement is what a violation does to plan and apply.
+type Enforcement string
+
+const (
+	// Warn reports the violation.
+	Warn Enforcement = "warn"
+	// Deny fails plan and blocks apply.
+	Deny Enforcement = "deny"
+	// Off skips the policy.
+	Off Enforcement = "off"
+)
+
+// Namespace is the package prefix of policies.
+const Namespace = "pltf"
+
+// Policy is a loaded Rego package with a violation rule.
+type Policy struct {
+	// Name is the package path below pltf., such as postgres_multi_az.
+	Name        string
+	Title       string
+	Description string
+	File        string
+	Severity    Severity
+	// Enforcement is keyed by environment key; "*" applies to the others.
+	Enforcement map[string]Enforcement
+
+	query rego.PreparedEvalQuery
+}
+
+// EnforcementFor returns how the policy is enforced in environment env. Policies warn unless their
+// metadata says otherwise.
+func (p *Policy) EnforcementFor(env string) Enforcement {
+	if e, ok := p.Enforcement[env]; ok {
+		return e
+	}
+	if e, ok := p.Enforcement["*"]; ok {
+		return e
+	}
+	return Warn
+}
+
+// Engine evaluates the policies of a directory.
+type Engine struct {
+	Dir      string
+	Policies []*Policy
+}
+
+// Result is one violation reported by a policy.
+type Result struct {
+	Policy      string      `json:"policy"`
+	Title       string      `json:"title,omitempty"`
+	Severity    Severity    `json:"severity"`
+	Enforcement Enforcement `json:"enforcement"`
+	Message     string      `json:"message"`
+	// Module and Resource locate the violation when the policy reports them.
+	Module   string `json:"module,omitempty"`
+	Resource string `json:"resource,omitempty"`
+}
+
+// Load parses every .rego file under dir (test files, *_test.rego, are skipped) and prepares the
+// violation rule of each pltf. package. Packages without a violation rule are helpers other
+// policies can import.
+func Load(ctx context.Context, dir string) (*Engine, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".rego") && !strings.HasSuffix(path, "_test.rego") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read policies %s: %w", dir, err)
+	}
+	sort.Strings(files)
+
+	modules := map[string]*ast.Module{}
+	policies := map[string]*Policy{}
+	var order []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		mod, err := ast.ParseModuleWithOpts(file, string(data), ast.ParserOptions{ProcessAnnotation: true, RegoVersion: ast.RegoV1})
+		if err != nil {
+			return nil, err
+		}
+		modules[file] = mod
+		name, ok := policyName(mod)
+		if !ok {
+			continue
+		}
+		p := policies[name]
+		if p == nil {
+			p = &Policy{Name: name, File: file, Severity: SeverityMedium, Enforcement: map[string]Enforcement{}}
+			policies[name] = p
+			order = append(order, name)
+		}
+		if err := applyMetadata(p, mod, file); err != nil {
+			return nil, err
+		}
+	}
+
+	compiler := ast.NewCompiler()
+	if compiler.Compile(modules); compiler.Failed() {
+		return nil, fmt.Errorf("compile policies: %v", compiler.Errors)
+	}
+	e := &Engine{Dir: dir}
+	for _, name := range order {
+		p := policies[name]
+		query := fmt.Sprintf("data.%s.%s.violation", Namespace, name)
+		p.query, err = rego.New(rego.Compiler(compiler), rego.Query(query)).PrepareForEval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", name, err)
+		}
+		e.Policies = append(e.Policies, p)
+	}
+	return e, nil
+}
+
+// policyName returns the package path of mod below pltf. when the module defines violation.
+func policyName(mod *ast.Module) (string, bool) {
+	path := mod.Package.Path.String()
+	prefix := "data." + Namespace + "."
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	for _, r := range mod.Rules {
+		if r.Head.Ref().String() == "violation" {
+			return strings.TrimPrefix(path, prefix), true
+		}
+	}
+	return "", false
+}
+
+// applyMetadata reads the package METADATA annotation of mod:
+//
+//	# METADATA
+//	# title: Production Postgres is multi-AZ
+//	# custom:
+//	#   severity: high
+//	#   enforcement:
+//	#     prod: deny
+//	#     "*": warn
+func applyMetadata(p *Policy, mod *ast.Module, file string) error {
+	for _, a := range mod.Annotations {
+		if a.Scope != "package" {
+			continue
+		}
+		if a.Title != "" {
+			p.Title = a.Title
+		}
+		if a.Description != "" {
+			p.Description = a.Description
+		}
+		if s, ok := a.Custom["severity"]; ok {
+			sev := Severity(strings.ToLower(fmt.Sprint(s)))
+			if !validSeverity(sev) {
+				return fmt.Errorf("%s: severity %q is not low, medium, high or critical", file, s)
+			}
+			p.Severity = sev
+		}
+		if raw, ok := a.Custom["enforcement"]; ok {
+			levels, err := parseEnforcement(raw)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			for env, e := range levels {
+				p.Enforcement[env] = e
+			}
+		}
+	}
+	return nil
+}
+
+// parseEnforcement accepts a single level for every environment or a map of environment keys to
+// levels.
+func parseEnforcement(raw interface{}) (map[string]Enforcement, error) {
+	out := map[string]Enforcement{}
+	set := func(env string, v interface{}) error {
+		e := Enforcement(strings.ToLower(fmt.Sprint(v)))
+		if e != Warn && e != Deny && e != Off {
+			return fmt.Errorf("enforcement %q is not warn, deny or off", v)
+		}
+		out[env] = e
+		return nil
+	}
+	switch v := raw.(type) {
+	case string:
+		return out, set("*", v)
+	case map[string]interface{}:
+		for env, level := range v {
+			if err := set(env, level); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("enforcement must be a level or a map of environment keys to levels")
+}
+
+// Evaluate runs every policy against in and returns the violations, denials first, then by severity.
+func (e *Engine) Evaluate(ctx context.Context, in Input) ([]Result, error) {
+	doc, err := in.document()
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, p := range e.Policies {
+		enforcement := p.EnforcementFor(in.Env)
+		if enforcement == Off {
+			continue
+		}
+		rs, err := p.query.Eval(ctx, rego.EvalInput(doc))
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", p.Name, err)
+		}
+		for _, r := range rs {
+			for _, expr := range r.Expressions {
+				items, _ := expr.Value.([]interface{})
+				for _, item := range items {
+					results = append(results, p.result(item, enforcement))
+				}
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Enforcement != b.Enforcement {
+			return a.Enforcement == Deny
+		}
+		if a.Severity != b.Severity {
+			return a.Severity.rank() < b.Severity.rank()
+		}
+		if a.Policy != b.Policy {
+			return a.Policy < b.Policy
+		}
+		return a.Message < b.Message
+	})
+	return results, nil
+}
+
+// result builds the Result of one violation: a message, or an object with msg and optionally
+// module, resource and severity.
+func (p *Policy) result(item interface{}, enforcement Enforcement) Result {
+	r := Result{Policy: p.Name, Title: p.Title, Severity: p.Severity, Enforcement: enforcement}
+	switch v := item.(type) {
+	case string:
+		r.Message = v
+	case map[string]interface{}:
+		r.Message, _ = v["msg"].(string)
+		r.Module, _ = v["module"].(string)
+		r.Resource, _ = v["resource"].(string)
+		if s, ok := v["severity"].(string); ok && validSeverity(Severity(s)) {
+			r.Severity = Severity(s)
+		}
+	default:
+		r.Message = fmt.Sprint(v)
+	}
+	if r.Message == "" {
+		r.Message = p.Title
+	}
+	return r
+}
+
+// Denied returns the results that fail plan and block apply.
+func Denied(results []Result) []Result {
+	var out []Result
+	for _, r := range results {
+		if r.Enforcement == Deny {
+			out = append(out, r)
+		}
+	}
+	return out
+}
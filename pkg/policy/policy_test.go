@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pltf/pkg/generate"
+	"pltf/pkg/plan"
+)
+
+func TestEvaluateAppliesSeverityAndEnforcementPerEnv(t *testing.T) {
+	ctx := context.Background()
+	e, err := Load(ctx, filepath.Join("testdata", "policies"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(e.Policies) != 2 {
+		t.Fatalf("expected the two policies and no helper, got %d", len(e.Policies))
+	}
+
+	sm := &generate.SourceMap{Modules: map[string]generate.ModuleSources{
+		"db":     {Type: "aws_postgres", Inputs: map[string]generate.InputSource{"multi_az": {Value: "false"}, "vpc_id": {Value: "module.base.vpc_id"}}},
+		"orders": {Type: "aws_postgres", Inputs: map[string]generate.InputSource{"multi_az": {Value: "true"}}},
+	}}
+	in := Input{Kind: "Environment", Name: "demo", Env: "dev", Modules: ModulesFromSourceMap(sm)}
+	if got := in.Modules["db"].Inputs["vpc_id"]; got != "module.base.vpc_id" {
+		t.Fatalf("expected references to stay expressions, got %v", got)
+	}
+
+	results, err := e.Evaluate(ctx, in)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(results) != 1 || results[0].Module != "db" || results[0].Enforcement != Warn || results[0].Severity != SeverityHigh {
+		t.Fatalf("expected a warning for db in dev, got %+v", results)
+	}
+
+	in.Env = "prod"
+	results, err = e.Evaluate(ctx, in)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(Denied(results)) != 1 || results[0].Title != "Production Postgres is multi-AZ" {
+		t.Fatalf("expected a denial in prod, got %+v", results)
+	}
+}
+
+func TestEvaluateSeesSpecAndPlan(t *testing.T) {
+	ctx := context.Background()
+	e, err := Load(ctx, filepath.Join("testdata", "policies"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	svc, err := SpecValue(map[string]interface{}{
+		"modules": []map[string]interface{}{{"id": "assets", "type": "aws_s3", "inputs": map[string]interface{}{"block_public": false}}},
+	})
+	if err != nil {
+		t.Fatalf("SpecValue: %v", err)
+	}
+	planJSON := []byte(`{"resource_changes": [{"address": "module.assets.aws_s3_bucket_acl.this", "module_address": "module.assets",
+		"type": "aws_s3_bucket_acl", "name": "this", "change": {"actions": ["create"], "after": {"acl": "public-read"}}}]}`)
+	p, err := plan.Parse(planJSON)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	in := Input{Kind: "Service", Name: "payments", Env: "dev", Service: svc, Resources: ResourcesFromPlan(p), Plan: planJSON}
+	results, err := e.Evaluate(ctx, in)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(results) != 2 || len(Denied(results)) != 2 || results[0].Severity != SeverityCritical {
+		t.Fatalf("expected two critical denials, got %+v", results)
+	}
+	if results[0].Resource != "module.assets.aws_s3_bucket_acl.this" || !strings.Contains(results[1].Message, "service payments makes bucket assets public") {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestLoadRejectsBadMetadata(t *testing.T) {
+	dir := t.TempDir()
+	src := "# METADATA\n# custom:\n#   enforcement: block\npackage pltf.bad\n\nviolation contains \"x\" if false\n"
+	if err := os.WriteFile(filepath.Join(dir, "bad.rego"), []byte(src), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Load(context.Background(), dir); err == nil || !strings.Contains(err.Error(), `enforcement "block"`) {
+		t.Fatalf("expected an enforcement error, got %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+package pltf.lib
+
+services if input.kind == "Service"
@@ -0,0 +1,3 @@
+package pltf.lib
+
+test_services if services with input as {"kind": "Service"}
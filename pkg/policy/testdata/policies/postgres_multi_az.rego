@@ -0,0 +1,15 @@
+# METADATA
+# title: Production Postgres is multi-AZ
+# custom:
+#   severity: high
+#   enforcement:
+#     prod: deny
+#     "*": warn
+package pltf.postgres_multi_az
+
+violation contains {"msg": msg, "module": id} if {
+	some id, m in input.modules
+	m.type == "aws_postgres"
+	m.inputs.multi_az != true
+	msg := sprintf("module %s must set multi_az", [id])
+}
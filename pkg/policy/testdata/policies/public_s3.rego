@@ -0,0 +1,22 @@
+# METADATA
+# title: No public S3 buckets
+# custom:
+#   severity: critical
+#   enforcement: deny
+package pltf.public_s3
+
+import data.pltf.lib.services
+
+violation contains {"msg": msg, "module": m.id} if {
+	services
+	some m in input.service.modules
+	m.type == "aws_s3"
+	m.inputs.block_public == false
+	msg := sprintf("service %s makes bucket %s public", [input.name, m.id])
+}
+
+violation contains {"msg": "bucket ACL is public-read", "resource": r.address} if {
+	some r in input.resources
+	r.type == "aws_s3_bucket_acl"
+	r.after.acl == "public-read"
+}
@@ -13,6 +13,7 @@ const (
 	K8sProviderVersion   = ">= 2.30.0"
 	HelmProviderVersion  = ">= 2.13.2"
 	AzureProviderVersion = ">= 4.0.0"
+	VaultProviderVersion = ">= 4.0.0"
 )
 
 func DefaultTagsTokens() hclwrite.Tokens {
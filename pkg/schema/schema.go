@@ -0,0 +1,281 @@
+// Package schema builds JSON Schema (draft-07) documents for the platform.io/v1 spec kinds and
+// module.yaml, for editor validation and autocompletion (e.g. yaml-language-server).
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"pltf/pkg/config"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Schema is a JSON Schema document or subschema.
+type Schema map[string]interface{}
+
+const draft07 = "http://json-schema.org/draft-07/schema#"
+
+// requiredFields mirrors the structural checks in config.Validate so editors flag the same omissions.
+var requiredFields = map[reflect.Type][]string{
+	reflect.TypeOf(config.EnvironmentConfig{}):   {"apiVersion", "kind", "metadata", "environments", "modules"},
+	reflect.TypeOf(config.ServiceConfig{}):       {"apiVersion", "kind", "metadata", "modules"},
+	reflect.TypeOf(config.EnvironmentMetadata{}): {"name", "org", "provider"},
+	reflect.TypeOf(config.ServiceMetadata{}):     {"name", "ref", "envRef"},
+	reflect.TypeOf(config.EnvironmentEntry{}):    {"account", "region"},
+	reflect.TypeOf(config.Module{}):              {"id", "type"},
+	reflect.TypeOf(config.ModuleMetadata{}):      {"name", "type", "provider", "version"},
+	reflect.TypeOf(config.InputSpec{}):           {"name", "type"},
+	reflect.TypeOf(config.OutputSpec{}):          {"name", "type"},
+}
+
+var (
+	accessLinksType = reflect.TypeOf(config.AccessLinks{})
+	gitProviderType = reflect.TypeOf(config.GitProvider(""))
+	moduleType      = reflect.TypeOf(config.Module{})
+	protectionType  = reflect.TypeOf(config.Protection{})
+	variablesType   = reflect.TypeOf(config.VariableValues{})
+)
+
+// Environment returns the schema for kind: Environment. When catalog is non-empty, modules[].type
+// is restricted to the catalog and modules[].inputs is checked against the matching module.yaml.
+func Environment(catalog map[string]*config.ModuleMetadata) Schema {
+	return document(reflect.TypeOf(config.EnvironmentConfig{}), "Environment", catalog)
+}
+
+// Service returns the schema for kind: Service. catalog works as in Environment.
+func Service(catalog map[string]*config.ModuleMetadata) Schema {
+	return document(reflect.TypeOf(config.ServiceConfig{}), "Service", catalog)
+}
+
+// ModuleMetadata returns the schema for module.yaml.
+func ModuleMetadata() Schema {
+	return document(reflect.TypeOf(config.ModuleMetadata{}), "", nil)
+}
+
+func document(t reflect.Type, kind string, catalog map[string]*config.ModuleMetadata) Schema {
+	b := &builder{defs: Schema{}}
+	root := b.structSchema(t)
+	props := root["properties"].(Schema)
+	if kind != "" {
+		props["kind"] = Schema{"const": kind}
+		props["apiVersion"] = Schema{"type": "string", "enum": config.KnownAPIVersions()}
+	}
+	if len(catalog) > 0 {
+		b.defs[moduleType.Name()] = catalogModule(b.defs[moduleType.Name()].(Schema), catalog)
+	}
+	root["$schema"] = draft07
+	if kind != "" {
+		root["title"] = "pltf " + kind
+	} else {
+		root["title"] = "pltf module.yaml"
+	}
+	if len(b.defs) > 0 {
+		root["definitions"] = b.defs
+	}
+	return root
+}
+
+type builder struct {
+	defs Schema
+}
+
+func (b *builder) typeSchema(t reflect.Type) Schema {
+	switch t {
+	case accessLinksType:
+		// links accept "read: bucket" as well as "read: [bucket, logs]".
+		return Schema{
+			"type": "object",
+			"additionalProperties": Schema{"oneOf": []Schema{
+				{"type": "string"},
+				{"type": "array", "items": Schema{"type": "string"}},
+			}},
+		}
+	case variablesType:
+		// values are converted to their declared type, so lists and maps are allowed too.
+		return Schema{"type": "object", "additionalProperties": Schema{}}
+	case protectionType:
+		// protect is true, false or a list of resource types.
+		return Schema{"oneOf": []Schema{
+			{"type": "boolean"},
+			{"type": "array", "items": Schema{"type": "string"}},
+		}}
+	case gitProviderType:
+		return Schema{"type": "string", "enum": []string{
+			string(config.GitProviderGitHub), string(config.GitProviderGitLab), string(config.GitProviderBitbucket),
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.typeSchema(t.Elem())
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": b.typeSchema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": b.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.defs[t.Name()]; !ok {
+			b.defs[t.Name()] = Schema{} // placeholder guards against recursive types
+			b.defs[t.Name()] = b.structSchema(t)
+		}
+		return Schema{"$ref": "#/definitions/" + t.Name()}
+	}
+	// interface{} and anything else accept any value.
+	return Schema{}
+}
+
+// structSchema mirrors strict YAML decoding: only tagged fields are allowed.
+func (b *builder) structSchema(t reflect.Type) Schema {
+	props := Schema{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		props[name] = b.typeSchema(f.Type)
+	}
+	s := Schema{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if req := requiredFields[t]; len(req) > 0 {
+		s["required"] = req
+	}
+	return s
+}
+
+// catalogModule narrows the Module definition to the catalog: type becomes an enum and inputs are
+// selected by type with if/then, which yaml-language-server uses for completion and validation.
+func catalogModule(base Schema, catalog map[string]*config.ModuleMetadata) Schema {
+	types := make([]string, 0, len(catalog))
+	for t := range catalog {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	props := Schema{}
+	for k, v := range base["properties"].(Schema) {
+		props[k] = v
+	}
+	props["type"] = Schema{"type": "string", "enum": types}
+
+	branches := make([]Schema, 0, len(types))
+	for _, t := range types {
+		meta := catalog[t]
+		branches = append(branches, Schema{
+			"if": Schema{
+				"properties": Schema{"type": Schema{"const": t}},
+				"required":   []string{"type"},
+			},
+			"then": Schema{
+				"properties": Schema{"inputs": moduleInputs(meta)},
+			},
+		})
+	}
+
+	out := Schema{}
+	for k, v := range base {
+		out[k] = v
+	}
+	out["properties"] = props
+	out["allOf"] = branches
+	return out
+}
+
+// moduleInputs builds the inputs object for one module type. Modules that declare no inputs stay
+// open, matching config.ValidateModuleInputs.
+func moduleInputs(meta *config.ModuleMetadata) Schema {
+	s := Schema{"type": "object"}
+	if meta.Description != "" {
+		s["description"] = meta.Description
+	}
+	if len(meta.Inputs) == 0 {
+		return s
+	}
+	props := Schema{}
+	for _, in := range meta.Inputs {
+		ty, err := config.ParseInputType(in.Type)
+		if err != nil {
+			ty = cty.DynamicPseudoType
+		}
+		p := ctySchema(ty)
+		desc := in.Description
+		if in.Type != "" {
+			desc = strings.TrimSpace(fmt.Sprintf("%s (%s)", desc, strings.Join(strings.Fields(in.Type), " ")))
+		}
+		if desc != "" {
+			p["description"] = desc
+		}
+		if in.Default != nil {
+			p["default"] = in.Default
+		}
+		props[in.Name] = p
+	}
+	s["properties"] = props
+	s["additionalProperties"] = false
+	return s
+}
+
+// ctySchema converts a Terraform type constraint into a schema. Strings are accepted everywhere
+// because any input may hold a reference (module.x.y, var.x, ${...}), and scalars convert the way
+// Terraform converts them.
+func ctySchema(ty cty.Type) Schema {
+	switch {
+	case ty == cty.DynamicPseudoType:
+		return Schema{}
+	case ty == cty.String:
+		return Schema{"type": []string{"string", "number", "boolean"}}
+	case ty == cty.Number:
+		return Schema{"type": []string{"number", "string"}}
+	case ty == cty.Bool:
+		return Schema{"type": []string{"boolean", "string"}}
+	case ty.IsListType() || ty.IsSetType():
+		return Schema{"type": []string{"array", "string"}, "items": ctySchema(ty.ElementType())}
+	case ty.IsTupleType():
+		items := make([]Schema, 0, len(ty.TupleElementTypes()))
+		for _, et := range ty.TupleElementTypes() {
+			items = append(items, ctySchema(et))
+		}
+		return Schema{"type": []string{"array", "string"}, "items": items}
+	case ty.IsMapType():
+		return Schema{"type": []string{"object", "string"}, "additionalProperties": ctySchema(ty.ElementType())}
+	case ty.IsObjectType():
+		props := Schema{}
+		var required []string
+		for name, at := range ty.AttributeTypes() {
+			props[name] = ctySchema(at)
+			if !ty.AttributeOptional(name) {
+				required = append(required, name)
+			}
+		}
+		s := Schema{"type": []string{"object", "string"}, "properties": props}
+		if len(required) > 0 {
+			sort.Strings(required)
+			s["required"] = required
+		}
+		return s
+	}
+	return Schema{}
+}
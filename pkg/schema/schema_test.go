@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"pltf/pkg/config"
+)
+
+func TestEnvironmentSchemaMirrorsConfig(t *testing.T) {
+	s := roundTrip(t, Environment(nil))
+
+	props := s["properties"].(map[string]interface{})
+	if got := props["kind"].(map[string]interface{})["const"]; got != "Environment" {
+		t.Fatalf("kind const = %v", got)
+	}
+	for _, key := range []string{"apiVersion", "metadata", "backend", "environments", "modules", "extends", "imports", "gitProvider"} {
+		if _, ok := props[key]; !ok {
+			t.Fatalf("missing property %q", key)
+		}
+	}
+	if _, ok := props["Sources"]; ok {
+		t.Fatalf("yaml:\"-\" fields must not appear in the schema")
+	}
+	if s["additionalProperties"] != false {
+		t.Fatalf("root must reject unknown fields like strict decoding does")
+	}
+
+	defs := s["definitions"].(map[string]interface{})
+	module := defs["Module"].(map[string]interface{})
+	if !reflect.DeepEqual(module["required"], []interface{}{"id", "type"}) {
+		t.Fatalf("module required = %v", module["required"])
+	}
+	links := module["properties"].(map[string]interface{})["links"].(map[string]interface{})
+	if _, ok := links["additionalProperties"].(map[string]interface{})["oneOf"]; !ok {
+		t.Fatalf("links should accept a string or a list, got %v", links)
+	}
+	if _, ok := module["allOf"]; ok {
+		t.Fatalf("allOf is only expected with a catalog")
+	}
+}
+
+func TestCatalogSchemaDiscriminatesInputsByType(t *testing.T) {
+	catalog := map[string]*config.ModuleMetadata{
+		"aws_eks": {Name: "aws_eks", Type: "aws_eks", Inputs: []config.InputSpec{
+			{Name: "max_nodes", Type: "number", Description: "Max nodes", Default: 5},
+			{Name: "trusts", Type: "list(object({ namespace = string, name = optional(string) }))"},
+		}},
+		"custom": {Name: "custom", Type: "custom"},
+	}
+	s := roundTrip(t, Service(catalog))
+	module := s["definitions"].(map[string]interface{})["Module"].(map[string]interface{})
+
+	typ := module["properties"].(map[string]interface{})["type"].(map[string]interface{})
+	if !reflect.DeepEqual(typ["enum"], []interface{}{"aws_eks", "custom"}) {
+		t.Fatalf("type enum = %v", typ["enum"])
+	}
+
+	branches := module["allOf"].([]interface{})
+	if len(branches) != 2 {
+		t.Fatalf("expected one branch per module type, got %d", len(branches))
+	}
+	eks := branches[0].(map[string]interface{})
+	cond := eks["if"].(map[string]interface{})["properties"].(map[string]interface{})["type"].(map[string]interface{})
+	if cond["const"] != "aws_eks" {
+		t.Fatalf("first branch should select aws_eks, got %v", cond)
+	}
+	inputs := eks["then"].(map[string]interface{})["properties"].(map[string]interface{})["inputs"].(map[string]interface{})
+	if inputs["additionalProperties"] != false {
+		t.Fatalf("declared inputs should be closed, got %v", inputs)
+	}
+	inProps := inputs["properties"].(map[string]interface{})
+	maxNodes := inProps["max_nodes"].(map[string]interface{})
+	if maxNodes["description"] != "Max nodes (number)" || maxNodes["default"] != float64(5) {
+		t.Fatalf("unexpected max_nodes schema %v", maxNodes)
+	}
+	item := inProps["trusts"].(map[string]interface{})["items"].(map[string]interface{})
+	if !reflect.DeepEqual(item["required"], []interface{}{"namespace"}) {
+		t.Fatalf("object required attributes = %v", item["required"])
+	}
+
+	custom := branches[1].(map[string]interface{})["then"].(map[string]interface{})["properties"].(map[string]interface{})["inputs"].(map[string]interface{})
+	if _, closed := custom["additionalProperties"]; closed {
+		t.Fatalf("modules without declared inputs should stay open, got %v", custom)
+	}
+}
+
+func roundTrip(t *testing.T, s Schema) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	return out
+}
@@ -0,0 +1,212 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pltf/pkg/config"
+	"pltf/pkg/provider"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func init() {
+	Register("env", envResolver{})
+	Register("file", fileResolver{})
+	Register("sops", sopsResolver{})
+	Register("ssm", ssmResolver{})
+	Register("secretsmanager", secretsManagerResolver{})
+	Register("vault", vaultResolver{})
+}
+
+// execCommand is swapped in tests to avoid depending on the sops binary.
+var execCommand = exec.CommandContext
+
+// envResolver reads the environment variable named by path. Without a path, or without an explicit
+// source (path used to be ignored), nothing is supplied and Terraform falls back to TF_VAR_<name>
+// from the caller's environment.
+type envResolver struct{}
+
+func (envResolver) Validate(config.SecretRef, string) error { return nil }
+
+func (envResolver) Resolve(_ context.Context, ref config.SecretRef, _ string) (string, bool, error) {
+	name := strings.TrimSpace(ref.Path)
+	if name == "" || strings.TrimSpace(ref.Source) == "" {
+		return "", false, nil
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", false, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, true, nil
+}
+
+// fileResolver reads the file at path, relative to the spec directory. A single trailing newline is
+// dropped so files written with echo work as expected.
+type fileResolver struct{}
+
+func (fileResolver) Validate(ref config.SecretRef, _ string) error {
+	return requirePath(ref, "path to the secret file")
+}
+
+func (fileResolver) Resolve(_ context.Context, ref config.SecretRef, baseDir string) (string, bool, error) {
+	data, err := os.ReadFile(resolvePath(ref.Path, baseDir))
+	if err != nil {
+		return "", false, err
+	}
+	value := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), true, nil
+}
+
+// sopsResolver decrypts a sops file with the sops CLI. "file.enc.yaml#db.password" extracts a
+// single key; without a field the whole decrypted document is used.
+type sopsResolver struct{}
+
+func (sopsResolver) Validate(ref config.SecretRef, _ string) error {
+	return requirePath(ref, "sops file, optionally with #key.path")
+}
+
+func (sopsResolver) Resolve(ctx context.Context, ref config.SecretRef, baseDir string) (string, bool, error) {
+	file, field := splitPath(ref.Path)
+	args := []string{"--decrypt"}
+	if field != "" {
+		var extract strings.Builder
+		for _, part := range strings.Split(field, ".") {
+			extract.WriteString("[" + strconv.Quote(part) + "]")
+		}
+		args = append(args, "--extract", extract.String())
+	}
+	args = append(args, resolvePath(file, baseDir))
+
+	cmd := execCommand(ctx, "sops", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", false, fmt.Errorf("sops: %s", msg)
+		}
+		return "", false, fmt.Errorf("sops: %w", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
+
+// ssmResolver reads an SSM parameter (decrypted) through aws_ssm_parameter.
+type ssmResolver struct{}
+
+func (ssmResolver) Validate(ref config.SecretRef, cloud string) error {
+	if err := requireAWS(cloud); err != nil {
+		return err
+	}
+	return requirePath(ref, "SSM parameter name")
+}
+
+func (ssmResolver) DataSource(body *hclwrite.Body, name string, ref config.SecretRef) (hclwrite.Tokens, error) {
+	b := body.AppendNewBlock("data", []string{"aws_ssm_parameter", name}).Body()
+	b.SetAttributeValue("name", cty.StringVal(strings.TrimSpace(ref.Path)))
+	b.SetAttributeValue("with_decryption", cty.True)
+	return traversal("data", "aws_ssm_parameter", name, "value"), nil
+}
+
+func (ssmResolver) RequiredProvider() (string, string, string) { return "", "", "" }
+
+// secretsManagerResolver reads a Secrets Manager secret. "secret-id#field" selects one key of a JSON
+// secret.
+type secretsManagerResolver struct{}
+
+func (secretsManagerResolver) Validate(ref config.SecretRef, cloud string) error {
+	if err := requireAWS(cloud); err != nil {
+		return err
+	}
+	return requirePath(ref, "secret id, optionally with #json_field")
+}
+
+func (secretsManagerResolver) DataSource(body *hclwrite.Body, name string, ref config.SecretRef) (hclwrite.Tokens, error) {
+	id, field := splitPath(ref.Path)
+	b := body.AppendNewBlock("data", []string{"aws_secretsmanager_secret_version", name}).Body()
+	b.SetAttributeValue("secret_id", cty.StringVal(id))
+	value := traversal("data", "aws_secretsmanager_secret_version", name, "secret_string")
+	if field == "" {
+		return value, nil
+	}
+	return fieldOf(hclwrite.TokensForFunctionCall("jsondecode", value), field), nil
+}
+
+func (secretsManagerResolver) RequiredProvider() (string, string, string) { return "", "", "" }
+
+// vaultResolver reads a KV v2 secret through the Vault provider, configured from VAULT_ADDR and
+// VAULT_TOKEN. The path is "<mount>/<name>#field"; without a field the whole secret is used as JSON.
+type vaultResolver struct{}
+
+func (vaultResolver) Validate(ref config.SecretRef, _ string) error {
+	if err := requirePath(ref, "<mount>/<name>, optionally with #field"); err != nil {
+		return err
+	}
+	if path, _ := splitPath(ref.Path); !strings.Contains(strings.Trim(path, "/"), "/") {
+		return fmt.Errorf("vault path %q must be <mount>/<name>", ref.Path)
+	}
+	return nil
+}
+
+func (vaultResolver) DataSource(body *hclwrite.Body, name string, ref config.SecretRef) (hclwrite.Tokens, error) {
+	path, field := splitPath(ref.Path)
+	mount, secret, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	b := body.AppendNewBlock("data", []string{"vault_kv_secret_v2", name}).Body()
+	b.SetAttributeValue("mount", cty.StringVal(mount))
+	b.SetAttributeValue("name", cty.StringVal(secret))
+	if field == "" {
+		return traversal("data", "vault_kv_secret_v2", name, "data_json"), nil
+	}
+	return fieldOf(traversal("data", "vault_kv_secret_v2", name, "data"), field), nil
+}
+
+func (vaultResolver) RequiredProvider() (string, string, string) {
+	return "vault", "hashicorp/vault", provider.VaultProviderVersion
+}
+
+func requirePath(ref config.SecretRef, what string) error {
+	if strings.TrimSpace(ref.Path) == "" {
+		return fmt.Errorf("source %s requires path (%s)", SourceOf(ref), what)
+	}
+	return nil
+}
+
+func requireAWS(cloud string) error {
+	if cloud != "" && cloud != "aws" {
+		return fmt.Errorf("only available for aws stacks, not %s", cloud)
+	}
+	return nil
+}
+
+func resolvePath(path, baseDir string) string {
+	path = strings.TrimSpace(path)
+	if filepath.IsAbs(path) || baseDir == "" {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+func traversal(root string, attrs ...string) hclwrite.Tokens {
+	t := hcl.Traversal{hcl.TraverseRoot{Name: root}}
+	for _, a := range attrs {
+		t = append(t, hcl.TraverseAttr{Name: a})
+	}
+	return hclwrite.TokensForTraversal(t)
+}
+
+// fieldOf indexes expr with ["field"].
+func fieldOf(expr hclwrite.Tokens, field string) hclwrite.Tokens {
+	out := append(hclwrite.Tokens{}, expr...)
+	out = append(out, &hclwrite.Token{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")})
+	out = append(out, hclwrite.TokensForValue(cty.StringVal(field))...)
+	return append(out, &hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")})
+}
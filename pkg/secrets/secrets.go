@@ -0,0 +1,180 @@
+// Package secrets resolves SecretRef entries (environments.<env>.secrets and service envRef secrets)
+// from their source. Resolvers either read the secret inside Terraform through a data source wired
+// into the generated stack, or resolve the value when terraform runs and pass it as TF_VAR_<name>.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"pltf/pkg/config"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// DefaultSource is used when a SecretRef has no source. It keeps the historical behaviour: the
+// value comes from the environment (TF_VAR_<name>, or the variable named by path).
+const DefaultSource = "env"
+
+// Resolver handles one SecretRef source.
+type Resolver interface {
+	// Validate checks a reference for a stack on the given cloud provider (aws, gcp, azure).
+	Validate(ref config.SecretRef, provider string) error
+}
+
+// DataSourceResolver reads the secret inside Terraform.
+type DataSourceResolver interface {
+	Resolver
+	// DataSource appends the data source for secret name to body and returns the expression that
+	// yields the secret value.
+	DataSource(body *hclwrite.Body, name string, ref config.SecretRef) (hclwrite.Tokens, error)
+	// RequiredProvider returns the provider the data source needs beyond the stack's cloud
+	// provider as local name, source and version constraint; name is empty when none is needed.
+	RequiredProvider() (name, source, version string)
+}
+
+// ValueResolver resolves the secret before terraform runs; the value is passed as TF_VAR_<name>.
+type ValueResolver interface {
+	Resolver
+	// Resolve returns the secret value. Relative paths resolve against baseDir (the spec directory).
+	// ok is false when the resolver has nothing to supply and Terraform's own lookup should apply.
+	Resolve(ctx context.Context, ref config.SecretRef, baseDir string) (value string, ok bool, err error)
+}
+
+var (
+	mu        sync.RWMutex
+	resolvers = map[string]Resolver{}
+)
+
+// Register makes a resolver available under source. Registering a source again replaces it, which
+// lets callers swap in their own implementation of a built-in source.
+func Register(source string, r Resolver) {
+	mu.Lock()
+	defer mu.Unlock()
+	resolvers[strings.ToLower(strings.TrimSpace(source))] = r
+}
+
+// Lookup returns the resolver for a SecretRef source ("" means DefaultSource).
+func Lookup(source string) (Resolver, error) {
+	source = SourceOf(config.SecretRef{Source: source})
+	mu.RLock()
+	defer mu.RUnlock()
+	if r, ok := resolvers[source]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("unknown secret source %q (known: %s)", source, strings.Join(sourcesLocked(), ", "))
+}
+
+// Sources lists the registered sources.
+func Sources() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sourcesLocked()
+}
+
+func sourcesLocked() []string {
+	out := make([]string, 0, len(resolvers))
+	for s := range resolvers {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SourceOf returns the normalised source of ref.
+func SourceOf(ref config.SecretRef) string {
+	if s := strings.ToLower(strings.TrimSpace(ref.Source)); s != "" {
+		return s
+	}
+	return DefaultSource
+}
+
+// Refs returns the secrets in scope for envKey: environment secrets, overlaid by the service's
+// envRef secrets when svcCfg is set.
+func Refs(envCfg *config.EnvironmentConfig, svcCfg *config.ServiceConfig, envKey string) map[string]config.SecretRef {
+	out := map[string]config.SecretRef{}
+	if envCfg != nil {
+		for name, ref := range envCfg.Environments[envKey].Secrets {
+			out[name] = ref
+		}
+	}
+	if svcCfg != nil {
+		for name, ref := range svcCfg.Metadata.EnvRef[envKey].Secrets {
+			out[name] = ref
+		}
+	}
+	return out
+}
+
+// Validate checks every reference against its resolver.
+func Validate(refs map[string]config.SecretRef, provider string) error {
+	var problems []string
+	for _, name := range sortedNames(refs) {
+		r, err := Lookup(refs[name].Source)
+		if err == nil {
+			err = r.Validate(refs[name], provider)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("secret %q: %v", name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// IsDataSource reports whether ref is read inside Terraform rather than passed as a variable.
+func IsDataSource(ref config.SecretRef) bool {
+	r, err := Lookup(ref.Source)
+	if err != nil {
+		return false
+	}
+	_, ok := r.(DataSourceResolver)
+	return ok
+}
+
+// Environment resolves every value-backed secret in refs and returns TF_VAR_<name>=<value> entries
+// for the terraform process. Data-source secrets are skipped; Terraform reads them itself.
+func Environment(ctx context.Context, refs map[string]config.SecretRef, baseDir string) ([]string, error) {
+	var env []string
+	for _, name := range sortedNames(refs) {
+		r, err := Lookup(refs[name].Source)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q: %w", name, err)
+		}
+		vr, ok := r.(ValueResolver)
+		if !ok {
+			continue
+		}
+		value, found, err := vr.Resolve(ctx, refs[name], baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q (source=%s): %w", name, SourceOf(refs[name]), err)
+		}
+		if found {
+			env = append(env, "TF_VAR_"+name+"="+value)
+		}
+	}
+	return env, nil
+}
+
+func sortedNames(refs map[string]config.SecretRef) []string {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitPath separates "path#field" into the path and an optional field selector.
+func splitPath(path string) (string, string) {
+	path = strings.TrimSpace(path)
+	if i := strings.LastIndex(path, "#"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pltf/pkg/config"
+)
+
+func TestEnvironmentResolvesValueSources(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token.txt"), []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+	t.Setenv("PLTF_TEST_API_KEY", "from-env")
+
+	// Echo the sops arguments back so the test does not need the sops binary.
+	saved := execCommand
+	execCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", append([]string{"-c", `printf '%s' "$*"`, name}, args...)...)
+	}
+	t.Cleanup(func() { execCommand = saved })
+
+	refs := map[string]config.SecretRef{
+		"api_key":     {Source: "env", Path: "PLTF_TEST_API_KEY"},
+		"legacy":      {Path: "/ignored/before/resolvers"},
+		"token":       {Source: "file", Path: "token.txt"},
+		"db_password": {Source: "sops", Path: "secrets.enc.yaml#db.password"},
+		"from_ssm":    {Source: "ssm", Path: "/app/db"},
+	}
+	env, err := Environment(context.Background(), refs, dir)
+	if err != nil {
+		t.Fatalf("Environment returned error: %v", err)
+	}
+	want := []string{
+		"TF_VAR_api_key=from-env",
+		`TF_VAR_db_password=--decrypt --extract ["db"]["password"] ` + filepath.Join(dir, "secrets.enc.yaml"),
+		"TF_VAR_token=from-file",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("unexpected environment:\n got %q\nwant %q", env, want)
+	}
+
+	refs = map[string]config.SecretRef{"missing": {Source: "env", Path: "PLTF_TEST_UNSET"}}
+	if _, err := Environment(context.Background(), refs, dir); err == nil || !strings.Contains(err.Error(), "PLTF_TEST_UNSET is not set") {
+		t.Fatalf("expected unset variable error, got %v", err)
+	}
+}
+
+func TestValidateChecksSourceAndPath(t *testing.T) {
+	refs := map[string]config.SecretRef{
+		"a": {Source: "keychain"},
+		"b": {Source: "ssm"},
+		"c": {Source: "vault", Path: "secret"},
+		"d": {Source: "secretsmanager", Path: "app/db#password"},
+	}
+	err := Validate(refs, "gcp")
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{
+		`secret "a": unknown secret source "keychain"`,
+		`secret "b": only available for aws stacks, not gcp`,
+		`secret "c": vault path "secret" must be <mount>/<name>`,
+		`secret "d": only available for aws stacks`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("missing %q in %v", want, err)
+		}
+	}
+	if err := Validate(map[string]config.SecretRef{"d": refs["d"]}, "aws"); err != nil {
+		t.Fatalf("unexpected error for aws stack: %v", err)
+	}
+}